@@ -20,6 +20,7 @@ func (e ExecutionPayloadEnvelope) MarshalJSON() ([]byte, error) {
 		BlockValue            *hexutil.Big    `json:"blockValue"  gencodec:"required"`
 		BlobsBundle           *BlobsBundleV1  `json:"blobsBundle"`
 		Override              bool            `json:"shouldOverrideBuilder"`
+		Requests              []hexutil.Bytes `json:"executionRequests"`
 		ParentBeaconBlockRoot *common.Hash    `json:"parentBeaconBlockRoot,omitempty"`
 	}
 	var enc ExecutionPayloadEnvelope
@@ -27,6 +28,12 @@ func (e ExecutionPayloadEnvelope) MarshalJSON() ([]byte, error) {
 	enc.BlockValue = (*hexutil.Big)(e.BlockValue)
 	enc.BlobsBundle = e.BlobsBundle
 	enc.Override = e.Override
+	if e.Requests != nil {
+		enc.Requests = make([]hexutil.Bytes, len(e.Requests))
+		for k, v := range e.Requests {
+			enc.Requests[k] = v
+		}
+	}
 	enc.ParentBeaconBlockRoot = e.ParentBeaconBlockRoot
 	return json.Marshal(&enc)
 }
@@ -38,6 +45,7 @@ func (e *ExecutionPayloadEnvelope) UnmarshalJSON(input []byte) error {
 		BlockValue            *hexutil.Big    `json:"blockValue"  gencodec:"required"`
 		BlobsBundle           *BlobsBundleV1  `json:"blobsBundle"`
 		Override              *bool           `json:"shouldOverrideBuilder"`
+		Requests              []hexutil.Bytes `json:"executionRequests"`
 		ParentBeaconBlockRoot *common.Hash    `json:"parentBeaconBlockRoot,omitempty"`
 	}
 	var dec ExecutionPayloadEnvelope
@@ -58,6 +66,12 @@ func (e *ExecutionPayloadEnvelope) UnmarshalJSON(input []byte) error {
 	if dec.Override != nil {
 		e.Override = *dec.Override
 	}
+	if dec.Requests != nil {
+		e.Requests = make([][]byte, len(dec.Requests))
+		for k, v := range dec.Requests {
+			e.Requests[k] = v
+		}
+	}
 	if dec.ParentBeaconBlockRoot != nil {
 		e.ParentBeaconBlockRoot = dec.ParentBeaconBlockRoot
 	}
@@ -0,0 +1,61 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/vm/analysis"
+	"github.com/urfave/cli/v2"
+)
+
+var cfgCommand = &cli.Command{
+	Action:    cfgCmd,
+	Name:      "cfg",
+	Usage:     "Writes the control-flow graph of evm bytecode in the Graphviz DOT format",
+	ArgsUsage: "<file>",
+	Description: `
+The cfg command analyses deployed bytecode, given as hex in a file or with --input,
+and writes its control-flow graph to stdout. The functions of the selector dispatcher
+and the storage slots predicted for their SLOAD and SSTORE instructions are part of
+the graph.`,
+}
+
+func cfgCmd(ctx *cli.Context) error {
+	var in string
+	switch {
+	case len(ctx.Args().First()) > 0:
+		input, err := os.ReadFile(ctx.Args().First())
+		if err != nil {
+			return err
+		}
+		in = string(input)
+	case ctx.IsSet(InputFlag.Name):
+		in = ctx.String(InputFlag.Name)
+	default:
+		return errors.New("missing filename or --input value")
+	}
+	code, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(in), "0x"))
+	if err != nil {
+		return fmt.Errorf("invalid bytecode: %v", err)
+	}
+	return analysis.Analyze(code).WriteDOT(os.Stdout)
+}
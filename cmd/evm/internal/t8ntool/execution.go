@@ -37,6 +37,8 @@ import (
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
 	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
+	"github.com/gballet/go-verkle"
 	"github.com/holiman/uint256"
 	"golang.org/x/crypto/sha3"
 )
@@ -62,6 +64,8 @@ type ExecutionResult struct {
 	WithdrawalsRoot      *common.Hash          `json:"withdrawalsRoot,omitempty"`
 	CurrentExcessBlobGas *math.HexOrDecimal64  `json:"currentExcessBlobGas,omitempty"`
 	CurrentBlobGasUsed   *math.HexOrDecimal64  `json:"blobGasUsed,omitempty"`
+	VerkleProof          *verkle.VerkleProof   `json:"verkleProof,omitempty"`
+	StateDiff            verkle.StateDiff      `json:"stateDiff,omitempty"`
 }
 
 type ommer struct {
@@ -135,7 +139,9 @@ func (pre *Prestate) Apply(vmConfig vm.Config, chainConfig *params.ChainConfig,
 		return h
 	}
 	var (
-		statedb     = MakePreState(rawdb.NewMemoryDatabase(), pre.Pre)
+		isVerkle    = chainConfig.IsVerkle(new(big.Int).SetUint64(pre.Env.Number), pre.Env.Timestamp)
+		statedb     = MakePreState(rawdb.NewMemoryDatabase(), pre.Pre, isVerkle)
+		preRoot     = statedb.IntermediateRoot(false)
 		signer      = types.MakeSigner(chainConfig, new(big.Int).SetUint64(pre.Env.Number), pre.Env.Timestamp)
 		gaspool     = new(core.GasPool)
 		blockHash   = common.Hash{0x13, 0x37}
@@ -192,6 +198,14 @@ func (pre *Prestate) Apply(vmConfig vm.Config, chainConfig *params.ChainConfig,
 		evm := vm.NewEVM(vmContext, vm.TxContext{}, statedb, chainConfig, vmConfig)
 		core.ProcessBeaconBlockRoot(*beaconRoot, evm, statedb)
 	}
+	if chainConfig.IsPrague(new(big.Int).SetUint64(pre.Env.Number), pre.Env.Timestamp) {
+		var (
+			prevNumber = pre.Env.Number - 1
+			prevHash   = pre.Env.BlockHashes[math.HexOrDecimal64(prevNumber)]
+			evm        = vm.NewEVM(vmContext, vm.TxContext{}, statedb, chainConfig, vmConfig)
+		)
+		core.ProcessParentBlockHash(prevHash, evm, statedb)
+	}
 
 	for i := 0; txIt.Next(); i++ {
 		tx, err := txIt.Tx()
@@ -245,6 +259,9 @@ func (pre *Prestate) Apply(vmConfig vm.Config, chainConfig *params.ChainConfig,
 			gaspool.SetGas(prevGas)
 			continue
 		}
+		if isVerkle {
+			statedb.AccessEvents().Merge(evm.AccessEvents)
+		}
 		includedTxs = append(includedTxs, tx)
 		if hashError != nil {
 			return nil, nil, nil, NewError(ErrorMissingBlockhash, hashError)
@@ -345,6 +362,11 @@ func (pre *Prestate) Apply(vmConfig vm.Config, chainConfig *params.ChainConfig,
 		execRs.CurrentExcessBlobGas = (*math.HexOrDecimal64)(&excessBlobGas)
 		execRs.CurrentBlobGasUsed = (*math.HexOrDecimal64)(&blobGasUsed)
 	}
+	if isVerkle {
+		if execRs.VerkleProof, execRs.StateDiff, err = makeVerkleProof(statedb, preRoot, root); err != nil {
+			return nil, nil, nil, NewError(ErrorEVM, fmt.Errorf("could not prove state: %v", err))
+		}
+	}
 	// Re-create statedb instance with new root upon the updated database
 	// for accessing latest states.
 	statedb, err = state.New(root, statedb.Database(), nil)
@@ -355,8 +377,12 @@ func (pre *Prestate) Apply(vmConfig vm.Config, chainConfig *params.ChainConfig,
 	return statedb, execRs, body, nil
 }
 
-func MakePreState(db ethdb.Database, accounts types.GenesisAlloc) *state.StateDB {
-	sdb := state.NewDatabaseWithConfig(db, &triedb.Config{Preimages: true})
+func MakePreState(db ethdb.Database, accounts types.GenesisAlloc, isVerkle bool) *state.StateDB {
+	config := &triedb.Config{Preimages: true}
+	if isVerkle {
+		config.IsVerkle, config.PathDB = true, pathdb.Defaults
+	}
+	sdb := state.NewDatabaseWithConfig(db, config)
 	statedb, _ := state.New(types.EmptyRootHash, sdb, nil)
 	for addr, a := range accounts {
 		statedb.SetCode(addr, a.Code)
@@ -372,6 +398,28 @@ func MakePreState(db ethdb.Database, accounts types.GenesisAlloc) *state.StateDB
 	return statedb
 }
 
+// makeVerkleProof proves the values of the verkle tree leaves accessed during
+// the execution in the pre-state, along with their values in the post-state.
+func makeVerkleProof(statedb *state.StateDB, preRoot, postRoot common.Hash) (*verkle.VerkleProof, verkle.StateDiff, error) {
+	keys := statedb.AccessEvents().Keys()
+	if len(keys) == 0 {
+		return nil, nil, nil
+	}
+	var (
+		triedb = statedb.Database().TrieDB()
+		cache  = statedb.PointCache()
+	)
+	pretrie, err := trie.NewVerkleTrie(preRoot, triedb, cache)
+	if err != nil {
+		return nil, nil, err
+	}
+	posttrie, err := trie.NewVerkleTrie(postRoot, triedb, cache)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pretrie.Proof(posttrie, keys)
+}
+
 func rlpHash(x interface{}) (h common.Hash) {
 	hw := sha3.NewLegacyKeccak256()
 	rlp.Encode(hw, x)
@@ -189,7 +189,11 @@ func Transition(ctx *cli.Context) error {
 	}
 	// Dump the execution result
 	collector := make(Alloc)
-	s.DumpToCollector(collector, nil)
+	if s.Database().TrieDB().IsVerkle() {
+		log.Warn("Post-state alloc is not available for verkle trees")
+	} else {
+		s.DumpToCollector(collector, nil)
+	}
 	return dispatchOutput(ctx, baseDir, result, collector, body)
 }
 
@@ -0,0 +1,93 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/cmd/evm/internal/t8ntool"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/stateless"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/tests"
+	"github.com/urfave/cli/v2"
+)
+
+var statelessCommand = &cli.Command{
+	Action:    statelessCmd,
+	Name:      "stateless",
+	Usage:     "Executes a block statelessly from its execution witness and verifies the post-state root",
+	ArgsUsage: "<block file> <witness file>",
+	Description: `
+The block and the witness are read as RLP, either raw or hex encoded, as returned
+by debug_getRawBlock and debug_executionWitness. The chain configuration is taken
+from the --prestate genesis file if given, otherwise from --state.fork.`,
+	Flags: []cli.Flag{
+		GenesisFlag,
+		t8ntool.ForknameFlag,
+	},
+}
+
+func statelessCmd(ctx *cli.Context) error {
+	if ctx.Args().Len() != 2 {
+		return errors.New("block and witness file arguments required")
+	}
+	var config *params.ChainConfig
+	if ctx.IsSet(GenesisFlag.Name) {
+		config = readGenesis(ctx.String(GenesisFlag.Name)).Config
+	} else {
+		var err error
+		if config, _, err = tests.GetChainConfig(ctx.String(t8ntool.ForknameFlag.Name)); err != nil {
+			return err
+		}
+	}
+	block := new(types.Block)
+	if err := readRLPFile(ctx.Args().Get(0), block); err != nil {
+		return fmt.Errorf("invalid block: %v", err)
+	}
+	witness := new(stateless.Witness)
+	if err := readRLPFile(ctx.Args().Get(1), witness); err != nil {
+		return fmt.Errorf("invalid witness: %v", err)
+	}
+	root, err := core.ExecuteStateless(config, beacon.New(ethash.NewFaker()), block, witness)
+	if err != nil {
+		return fmt.Errorf("block %d (%x) invalid: %v", block.NumberU64(), block.Hash(), err)
+	}
+	fmt.Printf("block %d (%x) valid, post-state root %x\n", block.NumberU64(), block.Hash(), root)
+	return nil
+}
+
+// readRLPFile decodes the RLP content of the given file, which may be hex
+// encoded, into val.
+func readRLPFile(path string, val interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if text := bytes.TrimSpace(data); len(text) > 1 && text[0] == '0' && (text[1] == 'x' || text[1] == 'X') {
+		data = common.FromHex(string(text))
+	}
+	return rlp.DecodeBytes(data, val)
+}
@@ -17,21 +17,26 @@
 package main
 
 import (
+	"bufio"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/storagelayout"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -39,8 +44,8 @@ import (
 	"github.com/ethereum/go-ethereum/internal/flags"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
-	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
 	"github.com/urfave/cli/v2"
 )
 
@@ -53,6 +58,7 @@ var (
 		Flags: flags.Merge([]cli.Flag{
 			utils.CachePreimagesFlag,
 			utils.OverrideCancun,
+			utils.OverridePrague,
 			utils.OverrideVerkle,
 		}, utils.DatabaseFlags),
 		Description: `
@@ -60,7 +66,9 @@ The init command initializes a new genesis block and definition for the network.
 This is a destructive action and changes the network in which you will be
 participating.
 
-It expects the genesis file as argument.`,
+It expects the genesis file as argument. Large genesis states can be streamed from
+the file referenced by the "allocFile" field of the genesis, resolved relative to
+the genesis file. It holds the accounts in the output format of 'geth dump --iterative'.`,
 	}
 	dumpGenesisCommand = &cli.Command{
 		Action:    dumpGenesis,
@@ -101,6 +109,7 @@ if one is set.  Otherwise it prints the genesis from the datadir.`,
 			utils.TxLookupLimitFlag,
 			utils.TransactionHistoryFlag,
 			utils.StateHistoryFlag,
+			utils.StateHistoryIndexFlag,
 		}, utils.DatabaseFlags),
 		Description: `
 The import command imports blocks from an RLP-encoded form. The form can be one file
@@ -150,6 +159,22 @@ from Era archives.
 		Description: `
 The export-history command will export blocks and their corresponding receipts
 into Era archives. Eras are typically packaged in steps of 8192 blocks.
+`,
+	}
+	pruneHistoryCommand = &cli.Command{
+		Action:    pruneHistory,
+		Name:      "prune-history",
+		Usage:     "Prune the ancient block bodies and receipts below a boundary",
+		ArgsUsage: "[<blockNum> | merge]",
+		Flags:     flags.Merge(utils.DatabaseFlags),
+		Description: `
+The prune-history command removes the block bodies and receipts below the given
+block number, or below the first proof-of-stake block if "merge" or nothing is
+specified, from the ancient store. The headers and canonical hashes are retained,
+along with any block not moved into the ancient store yet.
+
+The pruned history can't be served anymore, export it first with export-history
+if needed. Pruning can also be done online with --history.prune.
 `,
 	}
 	importPreimagesCommand = &cli.Command{
@@ -180,9 +205,40 @@ It's deprecated, please use "geth db import" instead.
 			utils.IncludeIncompletesFlag,
 			utils.StartKeyFlag,
 			utils.DumpLimitFlag,
+			utils.DumpThreadsFlag,
+			utils.DumpFormatFlag,
+			utils.DumpAddressesFlag,
+			utils.DumpContractsFlag,
+			utils.DumpMinBalanceFlag,
+			utils.DumpStorageLimitFlag,
 		}, utils.DatabaseFlags),
 		Description: `
 This command dumps out the state for a given block (or latest, if none provided).
+
+The accounts can be filtered by address, code presence or minimum balance, and
+the dumped storage of each account can be limited. With multiple threads, the key
+ranges are dumped concurrently, in no particular order. If the dump is interrupted
+by the limit, it can be continued with the logged continuation token as --start.
+`,
+	}
+	decodeStorageCommand = &cli.Command{
+		Action:    decodeStorage,
+		Name:      "decode-storage",
+		Usage:     "Decode the storage of a contract using its storage layout",
+		ArgsUsage: "<address> [? <blockHash> | <blockNum>]",
+		Flags: flags.Merge([]cli.Flag{
+			utils.CacheFlag,
+			utils.StorageLayoutFlag,
+			utils.StorageDecodeLimitFlag,
+		}, utils.DatabaseFlags),
+		Description: `
+This command decodes the storage of a contract at a given block (or latest, if
+none provided) into typed values, according to the storage layout emitted by solc
+(the "storageLayout" output selection).
+
+The entries of mappings are recovered from the preimages of the storage slots,
+which are only recorded if the node runs with --cache.preimages for the storage
+keys and with --vmdebug for the hashes computed by the contract.
 `,
 	}
 )
@@ -207,6 +263,10 @@ func initGenesis(ctx *cli.Context) error {
 	if err := json.NewDecoder(file).Decode(genesis); err != nil {
 		utils.Fatalf("invalid genesis file: %v", err)
 	}
+	// Resolve the allocation file relative to the genesis file
+	if genesis.AllocFile != "" && !filepath.IsAbs(genesis.AllocFile) {
+		genesis.AllocFile = filepath.Join(filepath.Dir(genesisPath), genesis.AllocFile)
+	}
 	// Open and initialise both full and light databases
 	stack, _ := makeConfigNode(ctx)
 	defer stack.Close()
@@ -216,6 +276,10 @@ func initGenesis(ctx *cli.Context) error {
 		v := ctx.Uint64(utils.OverrideCancun.Name)
 		overrides.OverrideCancun = &v
 	}
+	if ctx.IsSet(utils.OverridePrague.Name) {
+		v := ctx.Uint64(utils.OverridePrague.Name)
+		overrides.OverridePrague = &v
+	}
 	if ctx.IsSet(utils.OverrideVerkle.Name) {
 		v := ctx.Uint64(utils.OverrideVerkle.Name)
 		overrides.OverrideVerkle = &v
@@ -491,6 +555,44 @@ func exportHistory(ctx *cli.Context) error {
 	return nil
 }
 
+func pruneHistory(ctx *cli.Context) error {
+	if ctx.Args().Len() > 1 {
+		utils.Fatalf("usage: %s", ctx.Command.ArgsUsage)
+	}
+	boundary := core.HistoryPruneMerge
+	if ctx.Args().Len() == 1 {
+		boundary = ctx.Args().First()
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	cutoff, err := core.ResolveHistoryPrune(db, boundary)
+	if err != nil {
+		return err
+	}
+	frozen, err := db.Ancients()
+	if err != nil {
+		return err
+	}
+	if cutoff > frozen {
+		log.Warn("History cutoff beyond the ancient store, pruning up to its head", "cutoff", cutoff, "ancients", frozen)
+		cutoff = frozen
+	}
+	if tail := core.HistoryCutoff(db); cutoff <= tail {
+		log.Info("Chain history already pruned", "cutoff", tail)
+		return nil
+	}
+	start := time.Now()
+	if err := core.PruneHistory(db, cutoff, nil); err != nil {
+		return err
+	}
+	fmt.Printf("Pruning done in %v\n", time.Since(start))
+	return nil
+}
+
 // importPreimages imports preimage data from the specified file.
 // it is deprecated, and the export function has been removed, but
 // the import function is kept around for the time being so that
@@ -514,32 +616,127 @@ func importPreimages(ctx *cli.Context) error {
 	return nil
 }
 
-func parseDumpConfig(ctx *cli.Context, stack *node.Node) (*state.DumpConfig, ethdb.Database, common.Hash, error) {
+func parseDumpConfig(ctx *cli.Context, db ethdb.Database) (*state.DumpConfig, common.Hash, error) {
+	if ctx.NArg() > 1 {
+		return nil, common.Hash{}, fmt.Errorf("expected 1 argument (number or hash), got %d", ctx.NArg())
+	}
+	header, err := readHeaderArg(db, ctx.Args().First())
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+	startArg := common.FromHex(ctx.String(utils.StartKeyFlag.Name))
+	var start []byte
+	switch {
+	case len(startArg) == 0: // common.Hash
+		start = common.Hash{}.Bytes()
+	case len(startArg) == 32:
+		start = startArg
+	case len(startArg) == 20:
+		start = crypto.Keccak256(startArg)
+		log.Info("Converting start-address to hash", "address", common.BytesToAddress(startArg), "hash", common.BytesToHash(start).Hex())
+	case len(startArg) > 32:
+		start = startArg // Continuation token of a previous dump
+	default:
+		return nil, common.Hash{}, fmt.Errorf("invalid start argument: %x. 20 or 32 hex-encoded bytes required", startArg)
+	}
+	var conf = &state.DumpConfig{
+		SkipCode:          ctx.Bool(utils.ExcludeCodeFlag.Name),
+		SkipStorage:       ctx.Bool(utils.ExcludeStorageFlag.Name),
+		OnlyWithAddresses: !ctx.Bool(utils.IncludeIncompletesFlag.Name),
+		Start:             start,
+		Max:               ctx.Uint64(utils.DumpLimitFlag.Name),
+		Threads:           ctx.Int(utils.DumpThreadsFlag.Name),
+		OnlyContracts:     ctx.Bool(utils.DumpContractsFlag.Name),
+		MaxStorage:        ctx.Uint64(utils.DumpStorageLimitFlag.Name),
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, common.Hash{}, err
+	}
+	if list := ctx.String(utils.DumpAddressesFlag.Name); list != "" {
+		for _, addr := range strings.Split(list, ",") {
+			if !common.IsHexAddress(strings.TrimSpace(addr)) {
+				return nil, common.Hash{}, fmt.Errorf("invalid address: %q", addr)
+			}
+			conf.Addresses = append(conf.Addresses, common.HexToAddress(strings.TrimSpace(addr)))
+		}
+	}
+	if ctx.IsSet(utils.DumpMinBalanceFlag.Name) {
+		balance, ok := math.ParseBig256(ctx.String(utils.DumpMinBalanceFlag.Name))
+		if !ok {
+			return nil, common.Hash{}, fmt.Errorf("invalid minimum balance: %q", ctx.String(utils.DumpMinBalanceFlag.Name))
+		}
+		conf.MinBalance = uint256.MustFromBig(balance)
+	}
+	log.Info("State dump configured", "block", header.Number, "hash", header.Hash().Hex(),
+		"skipcode", conf.SkipCode, "skipstorage", conf.SkipStorage,
+		"start", hexutil.Encode(conf.Start), "limit", conf.Max, "threads", conf.Threads)
+	return conf, header.Root, nil
+}
+
+func dump(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
 	db := utils.MakeChainDatabase(ctx, stack, true)
 	defer db.Close()
 
-	var header *types.Header
-	if ctx.NArg() > 1 {
-		return nil, nil, common.Hash{}, fmt.Errorf("expected 1 argument (number or hash), got %d", ctx.NArg())
+	conf, root, err := parseDumpConfig(ctx, db)
+	if err != nil {
+		return err
 	}
-	if ctx.NArg() == 1 {
-		arg := ctx.Args().First()
+	triedb := utils.MakeTrieDatabase(ctx, db, true, true, false) // always enable preimage lookup
+	defer triedb.Close()
+
+	state, err := state.New(root, state.NewDatabaseWithNodeDB(db, triedb), nil)
+	if err != nil {
+		return err
+	}
+	if !ctx.Bool(utils.IterativeOutputFlag.Name) {
+		fmt.Println(string(state.Dump(conf)))
+		return nil
+	}
+	var next []byte
+	switch format := ctx.String(utils.DumpFormatFlag.Name); format {
+	case "json":
+		next = state.IterativeDump(conf, json.NewEncoder(os.Stdout))
+	case "rlp":
+		out := bufio.NewWriter(os.Stdout)
+		if next, err = state.RLPDump(conf, out); err != nil {
+			return err
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown dump format %q", format)
+	}
+	if next != nil {
+		log.Info("State dump incomplete, continue with --start", "token", hexutil.Encode(next))
+	}
+	return nil
+}
+
+// readHeaderArg resolves a block number or hash argument to its header, the head
+// header if the argument is empty.
+func readHeaderArg(db ethdb.Database, arg string) (*types.Header, error) {
+	var header *types.Header
+	if arg != "" {
 		if hashish(arg) {
 			hash := common.HexToHash(arg)
 			if number := rawdb.ReadHeaderNumber(db, hash); number != nil {
 				header = rawdb.ReadHeader(db, hash, *number)
 			} else {
-				return nil, nil, common.Hash{}, fmt.Errorf("block %x not found", hash)
+				return nil, fmt.Errorf("block %x not found", hash)
 			}
 		} else {
 			number, err := strconv.ParseUint(arg, 10, 64)
 			if err != nil {
-				return nil, nil, common.Hash{}, err
+				return nil, err
 			}
 			if hash := rawdb.ReadCanonicalHash(db, number); hash != (common.Hash{}) {
 				header = rawdb.ReadHeader(db, hash, number)
 			} else {
-				return nil, nil, common.Hash{}, fmt.Errorf("header for block %d not found", number)
+				return nil, fmt.Errorf("header for block %d not found", number)
 			}
 		}
 	} else {
@@ -547,54 +744,66 @@ func parseDumpConfig(ctx *cli.Context, stack *node.Node) (*state.DumpConfig, eth
 		header = rawdb.ReadHeadHeader(db)
 	}
 	if header == nil {
-		return nil, nil, common.Hash{}, errors.New("no head block found")
+		return nil, errors.New("no head block found")
 	}
-	startArg := common.FromHex(ctx.String(utils.StartKeyFlag.Name))
-	var start common.Hash
-	switch len(startArg) {
-	case 0: // common.Hash
-	case 32:
-		start = common.BytesToHash(startArg)
-	case 20:
-		start = crypto.Keccak256Hash(startArg)
-		log.Info("Converting start-address to hash", "address", common.BytesToAddress(startArg), "hash", start.Hex())
-	default:
-		return nil, nil, common.Hash{}, fmt.Errorf("invalid start argument: %x. 20 or 32 hex-encoded bytes required", startArg)
+	return header, nil
+}
+
+func decodeStorage(ctx *cli.Context) error {
+	if ctx.NArg() < 1 || ctx.NArg() > 2 {
+		return errors.New("expected contract address and optional block number or hash")
 	}
-	var conf = &state.DumpConfig{
-		SkipCode:          ctx.Bool(utils.ExcludeCodeFlag.Name),
-		SkipStorage:       ctx.Bool(utils.ExcludeStorageFlag.Name),
-		OnlyWithAddresses: !ctx.Bool(utils.IncludeIncompletesFlag.Name),
-		Start:             start.Bytes(),
-		Max:               ctx.Uint64(utils.DumpLimitFlag.Name),
+	if !common.IsHexAddress(ctx.Args().First()) {
+		return fmt.Errorf("invalid contract address: %q", ctx.Args().First())
 	}
-	log.Info("State dump configured", "block", header.Number, "hash", header.Hash().Hex(),
-		"skipcode", conf.SkipCode, "skipstorage", conf.SkipStorage,
-		"start", hexutil.Encode(conf.Start), "limit", conf.Max)
-	return conf, db, header.Root, nil
-}
+	address := common.HexToAddress(ctx.Args().First())
 
-func dump(ctx *cli.Context) error {
+	if !ctx.IsSet(utils.StorageLayoutFlag.Name) {
+		return fmt.Errorf("missing storage layout, use --%s", utils.StorageLayoutFlag.Name)
+	}
+	blob, err := os.ReadFile(ctx.String(utils.StorageLayoutFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to read storage layout: %v", err)
+	}
+	layout, err := storagelayout.Parse(blob)
+	if err != nil {
+		return fmt.Errorf("invalid storage layout: %v", err)
+	}
 	stack, _ := makeConfigNode(ctx)
 	defer stack.Close()
 
-	conf, db, root, err := parseDumpConfig(ctx, stack)
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+
+	header, err := readHeaderArg(db, ctx.Args().Get(1))
 	if err != nil {
 		return err
 	}
 	triedb := utils.MakeTrieDatabase(ctx, db, true, true, false) // always enable preimage lookup
 	defer triedb.Close()
 
-	state, err := state.New(root, state.NewDatabaseWithNodeDB(db, triedb), nil)
+	statedb, err := state.New(header.Root, state.NewDatabaseWithNodeDB(db, triedb), nil)
 	if err != nil {
 		return err
 	}
-	if ctx.Bool(utils.IterativeOutputFlag.Name) {
-		state.IterativeDump(conf, json.NewEncoder(os.Stdout))
-	} else {
-		fmt.Println(string(state.Dump(conf)))
+	storage, err := storagelayout.NewTrieStorage(db, triedb, header.Root, address, statedb.GetStorageRoot(address))
+	if err != nil {
+		return err
 	}
-	return nil
+	result, err := storagelayout.Decode(ctx.Context, layout, storage, ctx.Uint64(utils.StorageDecodeLimitFlag.Name))
+	if err != nil {
+		return err
+	}
+	if result.MissingPreimages > 0 {
+		log.Warn("Storage slots without preimage, mapping entries might be missing", "slots", result.MissingPreimages)
+	}
+	if result.Incomplete {
+		log.Warn("Storage too large to be scanned, mapping entries might be missing")
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
 }
 
 // hashish returns true for strings that look like hashes.
@@ -17,6 +17,7 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
@@ -159,6 +160,36 @@ block is used.
 				Description: `
 The export-preimages command exports hash preimages to a flat file, in exactly
 the expected order for the overlay tree migration.
+`,
+			},
+			{
+				Action:    snapshotExportState,
+				Name:      "export-state",
+				Usage:     "Export the flat state of a specific root from the snapshot",
+				ArgsUsage: "<dumpfile> [<root>]",
+				Flags:     flags.Merge(utils.NetworkFlags, utils.DatabaseFlags),
+				Description: `
+geth snapshot export-state <dumpfile> [<state-root>]
+will stream all accounts, storage slots and contract codes of the specified
+state from the snapshot into a compact, chunked and checksummed flat file.
+The default export target is the HEAD state.
+
+The file can be imported into an empty database with 'geth snapshot import-state'.
+`,
+			},
+			{
+				Action:    snapshotImportState,
+				Name:      "import-state",
+				Usage:     "Import a flat state file, rebuilding the snapshot and the tries",
+				ArgsUsage: "<dumpfile>",
+				Flags:     flags.Merge(utils.NetworkFlags, utils.DatabaseFlags),
+				Description: `
+geth snapshot import-state <dumpfile>
+will import a flat state file created by 'geth snapshot export-state' into the
+database, rebuilding the state snapshot and the tries in the configured state
+scheme. The resulting state root is verified against the one of the export.
+
+The database must not contain a state snapshot yet.
 `,
 			},
 		},
@@ -541,10 +572,16 @@ func dumpState(ctx *cli.Context) error {
 	stack, _ := makeConfigNode(ctx)
 	defer stack.Close()
 
-	conf, db, root, err := parseDumpConfig(ctx, stack)
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+
+	conf, root, err := parseDumpConfig(ctx, db)
 	if err != nil {
 		return err
 	}
+	if len(conf.Start) > common.HashLength {
+		return errors.New("continuation tokens are not supported by snapshot dumps")
+	}
 	triedb := utils.MakeTrieDatabase(ctx, db, false, true, false)
 	defer triedb.Close()
 
@@ -658,6 +695,89 @@ func snapshotExportPreimages(ctx *cli.Context) error {
 	return utils.ExportSnapshotPreimages(chaindb, snaptree, ctx.Args().First(), root)
 }
 
+// snapshotExportState dumps the flat state of a root to a file.
+func snapshotExportState(ctx *cli.Context) error {
+	if ctx.NArg() < 1 || ctx.NArg() > 2 {
+		utils.Fatalf("This command requires one or two arguments.")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(ctx, stack, true)
+	defer chaindb.Close()
+
+	triedb := utils.MakeTrieDatabase(ctx, chaindb, false, true, false)
+	defer triedb.Close()
+
+	var root common.Hash
+	if ctx.NArg() > 1 {
+		var err error
+		if root, err = parseRoot(ctx.Args().Get(1)); err != nil {
+			log.Error("Failed to resolve state root", "err", err)
+			return err
+		}
+	} else {
+		headBlock := rawdb.ReadHeadBlock(chaindb)
+		if headBlock == nil {
+			log.Error("Failed to load head block")
+			return errors.New("no head block")
+		}
+		root = headBlock.Root()
+	}
+	snapConfig := snapshot.Config{
+		CacheSize:  256,
+		Recovery:   false,
+		NoBuild:    true,
+		AsyncBuild: false,
+	}
+	snaptree, err := snapshot.New(snapConfig, chaindb, triedb, root)
+	if err != nil {
+		return err
+	}
+	fn := ctx.Args().First()
+	log.Info("Exporting state", "root", root, "file", fn)
+
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	writer := bufio.NewWriter(fh)
+	if err := snapshot.ExportState(snaptree, root, chaindb, writer); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// snapshotImportState imports the flat state from a file.
+func snapshotImportState(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(ctx, stack, false)
+	defer chaindb.Close()
+
+	scheme, err := rawdb.ParseStateScheme(ctx.String(utils.StateSchemeFlag.Name), chaindb)
+	if err != nil {
+		return err
+	}
+	fn := ctx.Args().First()
+	log.Info("Importing state", "file", fn, "scheme", scheme)
+
+	fh, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	_, err = snapshot.ImportState(bufio.NewReader(fh), chaindb, scheme)
+	return err
+}
+
 // checkAccount iterates the snap data layers, and looks up the given account
 // across all layers.
 func checkAccount(ctx *cli.Context) error {
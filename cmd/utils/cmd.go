@@ -409,6 +409,9 @@ func ExportHistory(bc *core.BlockChain, dir string, first, last, step uint64) er
 		log.Warn("Last block beyond head, setting last = head", "head", head, "last", last)
 		last = head
 	}
+	if cutoff := bc.HistoryCutoff(); first < cutoff {
+		return fmt.Errorf("%w before #%d", core.ErrHistoryPruned, cutoff)
+	}
 	network := "unknown"
 	if name, ok := params.NetworkNames[bc.Config().ChainID.String()]; ok {
 		network = name
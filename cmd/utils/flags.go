@@ -219,7 +219,7 @@ var (
 	}
 	StartKeyFlag = &cli.StringFlag{
 		Name:  "start",
-		Usage: "Start position. Either a hash or address",
+		Usage: "Start position. Either a hash, an address or the continuation token of a dump",
 		Value: "0x0000000000000000000000000000000000000000000000000000000000000000",
 	}
 	DumpLimitFlag = &cli.Uint64Flag{
@@ -227,6 +227,41 @@ var (
 		Usage: "Max number of elements (0 = no limit)",
 		Value: 0,
 	}
+	DumpThreadsFlag = &cli.IntFlag{
+		Name:  "threads",
+		Usage: "Number of key ranges dumped concurrently (the output is unordered if more than one)",
+		Value: 1,
+	}
+	DumpFormatFlag = &cli.StringFlag{
+		Name:  "format",
+		Usage: `Format of the iterative output ("json" or "rlp")`,
+		Value: "json",
+	}
+	DumpAddressesFlag = &cli.StringFlag{
+		Name:  "addresses",
+		Usage: "Comma separated list of the accounts to dump (default = all)",
+	}
+	DumpContractsFlag = &cli.BoolFlag{
+		Name:  "contracts",
+		Usage: "Only dump the accounts with code",
+	}
+	DumpMinBalanceFlag = &cli.StringFlag{
+		Name:  "minbalance",
+		Usage: "Only dump the accounts with at least the given balance (in wei)",
+	}
+	DumpStorageLimitFlag = &cli.Uint64Flag{
+		Name:  "storagelimit",
+		Usage: "Max number of storage slots dumped per account (0 = no limit)",
+	}
+	StorageLayoutFlag = &cli.StringFlag{
+		Name:  "layout",
+		Usage: "Path to the storage layout of the contract, as emitted by solc",
+	}
+	StorageDecodeLimitFlag = &cli.Uint64Flag{
+		Name:  "limit",
+		Usage: "Max number of array elements and mapping entries decoded per collection (0 = maximum of 65536)",
+		Value: 1024,
+	}
 
 	defaultSyncMode = ethconfig.Defaults.SyncMode
 	SnapshotFlag    = &cli.BoolFlag{
@@ -256,6 +291,11 @@ var (
 		Usage:    "Manually specify the Cancun fork timestamp, overriding the bundled setting",
 		Category: flags.EthCategory,
 	}
+	OverridePrague = &cli.Uint64Flag{
+		Name:     "override.prague",
+		Usage:    "Manually specify the Prague fork timestamp, overriding the bundled setting",
+		Category: flags.EthCategory,
+	}
 	OverrideVerkle = &cli.Uint64Flag{
 		Name:     "override.verkle",
 		Usage:    "Manually specify the Verkle fork timestamp, overriding the bundled setting",
@@ -304,12 +344,22 @@ var (
 		Value:    ethconfig.Defaults.StateHistory,
 		Category: flags.StateCategory,
 	}
+	StateHistoryIndexFlag = &cli.BoolFlag{
+		Name:     "history.state.index",
+		Usage:    "Index the state histories to serve historical state in path scheme",
+		Category: flags.StateCategory,
+	}
 	TransactionHistoryFlag = &cli.Uint64Flag{
 		Name:     "history.transactions",
 		Usage:    "Number of recent blocks to maintain transactions index for (default = about one year, 0 = entire chain)",
 		Value:    ethconfig.Defaults.TransactionHistory,
 		Category: flags.StateCategory,
 	}
+	HistoryPruneFlag = &cli.StringFlag{
+		Name:     "history.prune",
+		Usage:    `Prune the block bodies and receipts below a block number or "merge" from the ancient store (default = retain all)`,
+		Category: flags.StateCategory,
+	}
 	// Transaction pool settings
 	TxPoolLocalsFlag = &cli.StringFlag{
 		Name:     "txpool.locals",
@@ -435,6 +485,11 @@ var (
 		Usage:    "Disable heuristic state prefetch during block import (less CPU and disk IO, more time waiting for data)",
 		Category: flags.PerfCategory,
 	}
+	PipelinedImportFlag = &cli.BoolFlag{
+		Name:     "import.pipeline",
+		Usage:    "Execute each imported block on the uncommitted state of its parent while the parent is validated and written",
+		Category: flags.PerfCategory,
+	}
 	CachePreimagesFlag = &cli.BoolFlag{
 		Name:     "cache.preimages",
 		Usage:    "Enable recording the SHA3/keccak preimages of trie keys",
@@ -536,6 +591,11 @@ var (
 		Usage:    "Record information useful for VM and contract debugging",
 		Category: flags.VMCategory,
 	}
+	VMFuseInstructionsFlag = &cli.BoolFlag{
+		Name:     "vm.fusion",
+		Usage:    "Execute common instruction sequences as superinstructions when not tracing",
+		Category: flags.VMCategory,
+	}
 
 	// API options.
 	RPCGlobalGasCapFlag = &cli.Uint64Flag{
@@ -1003,6 +1063,12 @@ Please note that --` + MetricsHTTPFlag.Name + ` must be set to start the server.
 		Value:    metrics.DefaultConfig.InfluxDBOrganization,
 		Category: flags.MetricsCategory,
 	}
+	MetricsContentionWindowFlag = &cli.Uint64Flag{
+		Name:     "metrics.contention.window",
+		Usage:    "Number of recent blocks to track state write contention for (0 = disabled)",
+		Value:    ethconfig.Defaults.ContentionWindow,
+		Category: flags.MetricsCategory,
+	}
 )
 
 var (
@@ -1765,6 +1831,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(CacheNoPrefetchFlag.Name) {
 		cfg.NoPrefetch = ctx.Bool(CacheNoPrefetchFlag.Name)
 	}
+	if ctx.IsSet(PipelinedImportFlag.Name) {
+		cfg.PipelinedImport = ctx.Bool(PipelinedImportFlag.Name)
+	}
 	// Read the value from the flag no matter if it's set or not.
 	cfg.Preimages = ctx.Bool(CachePreimagesFlag.Name)
 	if cfg.NoPruning && !cfg.Preimages {
@@ -1774,6 +1843,12 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(StateHistoryFlag.Name) {
 		cfg.StateHistory = ctx.Uint64(StateHistoryFlag.Name)
 	}
+	if ctx.IsSet(StateHistoryIndexFlag.Name) {
+		cfg.StateHistoryIndex = ctx.Bool(StateHistoryIndexFlag.Name)
+	}
+	if ctx.IsSet(MetricsContentionWindowFlag.Name) {
+		cfg.ContentionWindow = ctx.Uint64(MetricsContentionWindowFlag.Name)
+	}
 	if ctx.IsSet(StateSchemeFlag.Name) {
 		cfg.StateScheme = ctx.String(StateSchemeFlag.Name)
 	}
@@ -1789,6 +1864,12 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 		log.Warn("The flag --txlookuplimit is deprecated and will be removed, please use --history.transactions")
 		cfg.TransactionHistory = ctx.Uint64(TxLookupLimitFlag.Name)
 	}
+	if ctx.IsSet(HistoryPruneFlag.Name) {
+		cfg.HistoryPrune = ctx.String(HistoryPruneFlag.Name)
+		if err := core.ValidateHistoryPrune(cfg.HistoryPrune); err != nil {
+			Fatalf("%v", err)
+		}
+	}
 	if ctx.String(GCModeFlag.Name) == "archive" && cfg.TransactionHistory != 0 {
 		cfg.TransactionHistory = 0
 		log.Warn("Disabled transaction unindexing for archive node")
@@ -1827,6 +1908,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.Bool(VMEnableDebugFlag.Name)
 	}
+	if ctx.IsSet(VMFuseInstructionsFlag.Name) {
+		cfg.FuseInstructions = ctx.Bool(VMFuseInstructionsFlag.Name)
+	}
 
 	if ctx.IsSet(RPCGlobalGasCapFlag.Name) {
 		cfg.RPCGasCap = ctx.Uint64(RPCGlobalGasCapFlag.Name)
@@ -2247,6 +2331,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node, readonly bool) (*core.BlockCh
 	cache := &core.CacheConfig{
 		TrieCleanLimit:      ethconfig.Defaults.TrieCleanCache,
 		TrieCleanNoPrefetch: ctx.Bool(CacheNoPrefetchFlag.Name),
+		PipelinedImport:     ctx.Bool(PipelinedImportFlag.Name),
 		TrieDirtyLimit:      ethconfig.Defaults.TrieDirtyCache,
 		TrieDirtyDisabled:   ctx.String(GCModeFlag.Name) == "archive",
 		TrieTimeLimit:       ethconfig.Defaults.TrieTimeout,
@@ -2254,6 +2339,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node, readonly bool) (*core.BlockCh
 		Preimages:           ctx.Bool(CachePreimagesFlag.Name),
 		StateScheme:         scheme,
 		StateHistory:        ctx.Uint64(StateHistoryFlag.Name),
+		StateHistoryIndex:   ctx.Bool(StateHistoryIndexFlag.Name),
 	}
 	if cache.TrieDirtyDisabled && !cache.Preimages {
 		cache.Preimages = true
@@ -2273,7 +2359,10 @@ func MakeChain(ctx *cli.Context, stack *node.Node, readonly bool) (*core.BlockCh
 	if ctx.IsSet(CacheFlag.Name) || ctx.IsSet(CacheGCFlag.Name) {
 		cache.TrieDirtyLimit = ctx.Int(CacheFlag.Name) * ctx.Int(CacheGCFlag.Name) / 100
 	}
-	vmcfg := vm.Config{EnablePreimageRecording: ctx.Bool(VMEnableDebugFlag.Name)}
+	vmcfg := vm.Config{
+		EnablePreimageRecording: ctx.Bool(VMEnableDebugFlag.Name),
+		FuseInstructions:        ctx.Bool(VMFuseInstructionsFlag.Name),
+	}
 
 	// Disable transaction indexing/unindexing by default.
 	chain, err := core.NewBlockChain(chainDb, cache, gspec, nil, engine, vmcfg, nil, nil)
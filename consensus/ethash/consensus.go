@@ -282,6 +282,8 @@ func (ethash *Ethash) verifyHeader(chain consensus.ChainHeaderReader, header, pa
 		return fmt.Errorf("invalid blobGasUsed: have %d, expected nil", header.BlobGasUsed)
 	case header.ParentBeaconRoot != nil:
 		return fmt.Errorf("invalid parentBeaconRoot, have %#x, expected nil", header.ParentBeaconRoot)
+	case header.RequestsHash != nil:
+		return fmt.Errorf("invalid requestsHash, have %#x, expected nil", header.RequestsHash)
 	}
 	// Add some fake checks for tests
 	if ethash.fakeDelay != nil {
@@ -556,6 +558,9 @@ func (ethash *Ethash) SealHash(header *types.Header) (hash common.Hash) {
 	if header.ParentBeaconRoot != nil {
 		panic("parent beacon root set on ethash")
 	}
+	if header.RequestsHash != nil {
+		panic("requests hash set on ethash")
+	}
 	rlp.Encode(hasher, enc)
 	hasher.Sum(hash[:0])
 	return hash
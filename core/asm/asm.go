@@ -32,6 +32,7 @@ type instructionIterator struct {
 	op      vm.OpCode
 	error   error
 	started bool
+	eof     bool
 }
 
 // NewInstructionIterator creates a new instruction iterator.
@@ -41,6 +42,14 @@ func NewInstructionIterator(code []byte) *instructionIterator {
 	return it
 }
 
+// NewEOFInstructionIterator creates a new instruction iterator for a code
+// section of an EOF container, in which more instructions carry immediates.
+func NewEOFInstructionIterator(code []byte) *instructionIterator {
+	it := NewInstructionIterator(code)
+	it.eof = true
+	return it
+}
+
 // Next returns true if there is a next instruction and moves on.
 func (it *instructionIterator) Next() bool {
 	if it.error != nil || uint64(len(it.code)) <= it.pc {
@@ -65,7 +74,20 @@ func (it *instructionIterator) Next() bool {
 	}
 
 	it.op = vm.OpCode(it.code[it.pc])
-	if it.op.IsPush() {
+	if it.eof {
+		size := uint64(vm.Immediates(it.op))
+		if it.op == vm.RJUMPV && it.pc+1 < uint64(len(it.code)) {
+			size = 1 + 2*(uint64(it.code[it.pc+1])+1)
+		}
+		if uint64(len(it.code)) < it.pc+1+size {
+			it.error = fmt.Errorf("incomplete %v instruction at %v", it.op, it.pc)
+			return false
+		}
+		it.arg = it.code[it.pc+1 : it.pc+1+size]
+		if size == 0 {
+			it.arg = nil
+		}
+	} else if it.op.IsPush() {
 		a := uint64(it.op) - uint64(vm.PUSH1) + 1
 		u := it.pc + 1 + a
 		if uint64(len(it.code)) <= it.pc || uint64(len(it.code)) < u {
@@ -105,32 +127,78 @@ func PrintDisassembled(code string) error {
 	if err != nil {
 		return err
 	}
-
-	it := NewInstructionIterator(script)
-	for it.Next() {
-		if it.Arg() != nil && 0 < len(it.Arg()) {
-			fmt.Printf("%05x: %v %#x\n", it.PC(), it.Op(), it.Arg())
-		} else {
-			fmt.Printf("%05x: %v\n", it.PC(), it.Op())
-		}
+	// Print everything up to the first malformed instruction
+	instrs, err := disassemble(script)
+	for _, instr := range instrs {
+		fmt.Print(instr)
 	}
-	return it.Error()
+	return err
 }
 
 // Disassemble returns all disassembled EVM instructions in human-readable format.
+// EOF containers are disassembled section by section, including the data
+// section and the subcontainers.
 func Disassemble(script []byte) ([]string, error) {
-	instrs := make([]string, 0)
+	instrs, err := disassemble(script)
+	if err != nil {
+		return nil, err
+	}
+	return instrs, nil
+}
+
+// disassemble returns the instructions disassembled up to the first error.
+func disassemble(script []byte) ([]string, error) {
+	if vm.HasEOFMagic(script) {
+		return disassembleEOF(script, "")
+	}
+	return disassembleCode(NewInstructionIterator(script), "")
+}
 
-	it := NewInstructionIterator(script)
+// disassembleCode returns the instructions of the iterator, each prefixed by
+// the given indentation.
+func disassembleCode(it *instructionIterator, indent string) ([]string, error) {
+	instrs := make([]string, 0)
 	for it.Next() {
 		if it.Arg() != nil && 0 < len(it.Arg()) {
-			instrs = append(instrs, fmt.Sprintf("%05x: %v %#x\n", it.PC(), it.Op(), it.Arg()))
+			instrs = append(instrs, fmt.Sprintf("%s%05x: %v %#x\n", indent, it.PC(), it.Op(), it.Arg()))
 		} else {
-			instrs = append(instrs, fmt.Sprintf("%05x: %v\n", it.PC(), it.Op()))
+			instrs = append(instrs, fmt.Sprintf("%s%05x: %v\n", indent, it.PC(), it.Op()))
 		}
 	}
-	if err := it.Error(); err != nil {
+	return instrs, it.Error()
+}
+
+// disassembleEOF returns the disassembled code sections, the data section and
+// the recursively disassembled subcontainers of an EOF container.
+func disassembleEOF(script []byte, indent string) ([]string, error) {
+	var c vm.Container
+	if err := c.UnmarshalBinary(script); err != nil {
 		return nil, err
 	}
+	var instrs []string
+	for i, code := range c.CodeSections() {
+		inputs, outputs, maxStackIncrease, returning := c.SectionType(i)
+		if returning {
+			instrs = append(instrs, fmt.Sprintf("%scode section %d: inputs %d, outputs %d, max stack increase %d\n", indent, i, inputs, outputs, maxStackIncrease))
+		} else {
+			instrs = append(instrs, fmt.Sprintf("%scode section %d: inputs %d, non-returning, max stack increase %d\n", indent, i, inputs, maxStackIncrease))
+		}
+		section, err := disassembleCode(NewEOFInstructionIterator(code), indent+"  ")
+		instrs = append(instrs, section...)
+		if err != nil {
+			return instrs, err
+		}
+	}
+	for i, sub := range c.SubContainers() {
+		instrs = append(instrs, fmt.Sprintf("%ssubcontainer %d:\n", indent, i))
+		container, err := disassembleEOF(sub, indent+"  ")
+		instrs = append(instrs, container...)
+		if err != nil {
+			return instrs, err
+		}
+	}
+	if data := c.Data(); len(data) > 0 {
+		instrs = append(instrs, fmt.Sprintf("%sdata: %#x\n", indent, data))
+	}
 	return instrs, nil
 }
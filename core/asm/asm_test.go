@@ -17,9 +17,12 @@
 package asm
 
 import (
+	"reflect"
 	"testing"
 
 	"encoding/hex"
+
+	"github.com/ethereum/go-ethereum/core/vm"
 )
 
 // Tests disassembling instructions
@@ -56,3 +59,34 @@ func TestInstructionIterator(t *testing.T) {
 		}
 	}
 }
+
+// Tests disassembling EOF containers section by section.
+func TestDisassembleEOF(t *testing.T) {
+	// Section 0 calls section 1, which loads the first data word and returns it.
+	code, _ := hex.DecodeString("ef000101000802000200050007ff0001000080000100010001e300015000d10000e00000e442")
+	have, err := Disassemble(code)
+	if err != nil {
+		t.Fatalf("failed to disassemble: %v", err)
+	}
+	want := []string{
+		"code section 0: inputs 0, non-returning, max stack increase 1\n",
+		"  00000: CALLF 0x0001\n",
+		"  00003: POP\n",
+		"  00004: STOP\n",
+		"code section 1: inputs 0, outputs 1, max stack increase 1\n",
+		"  00000: DATALOADN 0x0000\n",
+		"  00003: RJUMP 0x0000\n",
+		"  00006: RETF\n",
+		"data: 0x42\n",
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("disassembly mismatch:\nhave %q\nwant %q", have, want)
+	}
+	// Truncated immediates are reported
+	it := NewEOFInstructionIterator([]byte{byte(vm.RJUMPV), 1, 0, 0})
+	for it.Next() {
+	}
+	if it.Error() == nil {
+		t.Fatal("truncated RJUMPV table not reported")
+	}
+}
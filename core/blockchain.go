@@ -101,6 +101,7 @@ const (
 	blockCacheLimit     = 256
 	receiptsCacheLimit  = 32
 	txLookupCacheLimit  = 1024
+	executionsLimit     = 1024
 	maxFutureBlocks     = 256
 	maxTimeFutureBlocks = 30
 	TriesInMemory       = 128
@@ -142,15 +143,22 @@ type CacheConfig struct {
 	SnapshotLimit       int           // Memory allowance (MB) to use for caching snapshot entries in memory
 	Preimages           bool          // Whether to store preimage of trie key to the disk
 	StateHistory        uint64        // Number of blocks from head whose state histories are reserved.
+	StateHistoryIndex   bool          // Whether the state histories are indexed to serve historical state
 	StateScheme         string        // Scheme used to store ethereum states and merkle tree nodes on top
+	ContentionWindow    uint64        // Number of recent blocks whose state write contention is tracked (0 = disabled)
+	PipelinedImport     bool          // Whether to execute blocks on the uncommitted state of their parent during import
+	HistoryPrune        string        // Boundary below which the block bodies and receipts are pruned ("" = keep, "merge" or block number)
 
 	SnapshotNoBuild bool // Whether the background generation is allowed
 	SnapshotWait    bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
 }
 
 // triedbConfig derives the configures for trie database.
-func (c *CacheConfig) triedbConfig() *triedb.Config {
-	config := &triedb.Config{Preimages: c.Preimages}
+func (c *CacheConfig) triedbConfig(isVerkle bool) *triedb.Config {
+	config := &triedb.Config{
+		Preimages: c.Preimages,
+		IsVerkle:  isVerkle,
+	}
 	if c.StateScheme == rawdb.HashScheme {
 		config.HashDB = &hashdb.Config{
 			CleanCacheSize: c.TrieCleanLimit * 1024 * 1024,
@@ -158,9 +166,10 @@ func (c *CacheConfig) triedbConfig() *triedb.Config {
 	}
 	if c.StateScheme == rawdb.PathScheme {
 		config.PathDB = &pathdb.Config{
-			StateHistory:   c.StateHistory,
-			CleanCacheSize: c.TrieCleanLimit * 1024 * 1024,
-			DirtyCacheSize: c.TrieDirtyLimit * 1024 * 1024,
+			StateHistory:      c.StateHistory,
+			StateHistoryIndex: c.StateHistoryIndex,
+			CleanCacheSize:    c.TrieCleanLimit * 1024 * 1024,
+			DirtyCacheSize:    c.TrieDirtyLimit * 1024 * 1024,
 		}
 	}
 	return config
@@ -228,7 +237,13 @@ type BlockChain struct {
 	logsFeed      event.Feed
 	blockProcFeed event.Feed
 	scope         event.SubscriptionScope
-	genesisBlock  *types.Block
+
+	stateDiffFeed     event.Feed
+	stateDiffScope    event.SubscriptionScope // Tracks the state diff subscribers, diffs are only gathered if there's any
+	stateDiffs        []StateDiffEvent        // State diffs gathered under the chain mutex, awaiting delivery
+	stateDiffLock     sync.Mutex              // Protects the gathered state diffs
+	stateDiffSendLock sync.Mutex              // Keeps the state diffs delivered in order
+	genesisBlock      *types.Block
 
 	// This mutex synchronizes chain write operations.
 	// Readers don't need to take it, they can just read the database.
@@ -245,6 +260,10 @@ type BlockChain struct {
 	blockCache    *lru.Cache[common.Hash, *types.Block]
 	txLookupCache *lru.Cache[common.Hash, txLookup]
 
+	// executions counts the executions of recently imported blocks, including
+	// the speculative ones which got discarded
+	executions *lru.Cache[common.Hash, uint64]
+
 	// future blocks are blocks added for later processing
 	futureBlocks *lru.Cache[common.Hash, *types.Block]
 
@@ -259,6 +278,8 @@ type BlockChain struct {
 	processor  Processor // Block transaction processor interface
 	forker     *ForkChoice
 	vmConfig   vm.Config
+
+	contention *contentionTracker // Write contention tracker, nil if disabled
 }
 
 // NewBlockChain returns a fully initialised block chain using information
@@ -269,7 +290,20 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, genesis *Genesis
 		cacheConfig = defaultCacheConfig
 	}
 	// Open trie database with provided config
-	triedb := triedb.NewDatabase(db, cacheConfig.triedbConfig())
+	isVerkle, err := isVerkleAtGenesis(db, genesis)
+	if err != nil {
+		return nil, err
+	}
+	if isVerkle {
+		if cacheConfig.StateScheme != rawdb.PathScheme {
+			return nil, errors.New("verkle state requires the path state scheme")
+		}
+		// Snapshots are not supported for verkle trees
+		copied := *cacheConfig
+		copied.SnapshotLimit = 0
+		cacheConfig = &copied
+	}
+	triedb := triedb.NewDatabase(db, cacheConfig.triedbConfig(isVerkle))
 
 	// Setup the genesis block, commit the provided genesis specification
 	// to database if the genesis block is not present yet, or load the
@@ -303,6 +337,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, genesis *Genesis
 		receiptsCache: lru.NewCache[common.Hash, []*types.Receipt](receiptsCacheLimit),
 		blockCache:    lru.NewCache[common.Hash, *types.Block](blockCacheLimit),
 		txLookupCache: lru.NewCache[common.Hash, txLookup](txLookupCacheLimit),
+		executions:    lru.NewCache[common.Hash, uint64](executionsLimit),
 		futureBlocks:  lru.NewCache[common.Hash, *types.Block](maxFutureBlocks),
 		engine:        engine,
 		vmConfig:      vmConfig,
@@ -313,8 +348,10 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, genesis *Genesis
 	bc.validator = NewBlockValidator(chainConfig, bc, engine)
 	bc.prefetcher = newStatePrefetcher(chainConfig, bc, engine)
 	bc.processor = NewStateProcessor(chainConfig, bc, engine)
+	if cacheConfig.ContentionWindow > 0 {
+		bc.contention = newContentionTracker(chainConfig, cacheConfig.ContentionWindow)
+	}
 
-	var err error
 	bc.hc, err = NewHeaderChain(db, chainConfig, engine, bc.insertStopped)
 	if err != nil {
 		return nil, err
@@ -472,6 +509,14 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, genesis *Genesis
 	if txLookupLimit != nil {
 		bc.txIndexer = newTxIndexer(*txLookupLimit, bc)
 	}
+	// Start the history pruner if the ancient chain history is to be dropped.
+	if cacheConfig.HistoryPrune != "" {
+		if err := ValidateHistoryPrune(cacheConfig.HistoryPrune); err != nil {
+			return nil, err
+		}
+		bc.wg.Add(1)
+		go bc.historyPruneLoop(cacheConfig.HistoryPrune)
+	}
 	return bc, nil
 }
 
@@ -894,6 +939,9 @@ func (bc *BlockChain) ExportN(w io.Writer, first uint64, last uint64) error {
 	if first > last {
 		return fmt.Errorf("export failed: first (%d) is greater than last (%d)", first, last)
 	}
+	if cutoff := bc.HistoryCutoff(); first < cutoff {
+		return fmt.Errorf("export failed: %w before #%d", ErrHistoryPruned, cutoff)
+	}
 	log.Info("Exporting batch of blocks", "count", last-first+1)
 
 	var (
@@ -966,6 +1014,7 @@ func (bc *BlockChain) stopWithoutSaving() {
 	}
 	// Unsubscribe all subscriptions registered from blockchain.
 	bc.scope.Close()
+	bc.stateDiffScope.Close()
 
 	// Signal shutdown to all goroutines.
 	close(bc.quit)
@@ -1368,11 +1417,21 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	if err := blockBatch.Write(); err != nil {
 		log.Crit("Failed to write block into disk", "err", err)
 	}
+	// Assemble the state changes of the block on commit if anyone is subscribed,
+	// they're delivered once the chain mutex is released.
+	if bc.stateDiffScope.Count() > 0 {
+		state.TrackStateDiff()
+	}
 	// Commit all cached state changes into underlying memory database.
 	root, err := state.Commit(block.NumberU64(), bc.chainConfig.IsEIP158(block.Number()))
 	if err != nil {
 		return err
 	}
+	if diff := state.CommittedDiff(); diff != nil {
+		bc.stateDiffLock.Lock()
+		bc.stateDiffs = append(bc.stateDiffs, StateDiffEvent{Header: block.Header(), Diff: diff})
+		bc.stateDiffLock.Unlock()
+	}
 	// If node is running in path mode, skip explicit gc operation
 	// which is unnecessary in this mode.
 	if bc.triedb.Scheme() == rawdb.PathScheme {
@@ -1433,12 +1492,30 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	return nil
 }
 
+// sendStateDiffs delivers the state diffs gathered while writing blocks to the
+// subscribers. It must be called without holding the chain mutex, so that slow
+// subscribers don't hold up the chain.
+func (bc *BlockChain) sendStateDiffs() {
+	bc.stateDiffSendLock.Lock()
+	defer bc.stateDiffSendLock.Unlock()
+
+	bc.stateDiffLock.Lock()
+	diffs := bc.stateDiffs
+	bc.stateDiffs = nil
+	bc.stateDiffLock.Unlock()
+
+	for _, diff := range diffs {
+		bc.stateDiffFeed.Send(diff)
+	}
+}
+
 // WriteBlockAndSetHead writes the given block and all associated state to the database,
 // and applies the block as the new chain head.
 func (bc *BlockChain) WriteBlockAndSetHead(block *types.Block, receipts []*types.Receipt, logs []*types.Log, state *state.StateDB, emitHeadEvent bool) (status WriteStatus, err error) {
 	if !bc.chainmu.TryLock() {
 		return NonStatTy, errChainStopped
 	}
+	defer bc.sendStateDiffs() // Runs after the unlock
 	defer bc.chainmu.Unlock()
 
 	return bc.writeBlockAndSetHead(block, receipts, logs, state, emitHeadEvent)
@@ -1541,6 +1618,7 @@ func (bc *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 	if !bc.chainmu.TryLock() {
 		return 0, errChainStopped
 	}
+	defer bc.sendStateDiffs() // Runs after the unlock
 	defer bc.chainmu.Unlock()
 	return bc.insertChain(chain, true)
 }
@@ -1672,7 +1750,10 @@ func (bc *BlockChain) insertChain(chain types.Blocks, setHead bool) (int, error)
 		return it.index, err
 	}
 	// No validation errors for the first block (or chain prefix skipped)
-	var activeState *state.StateDB
+	var (
+		activeState *state.StateDB
+		pending     *speculation // Current block if executed in the pipeline
+	)
 	defer func() {
 		// The chain importer is starting and stopping trie prefetchers. If a bad
 		// block or other error is hit however, an early return may not properly
@@ -1740,52 +1821,91 @@ func (bc *BlockChain) insertChain(chain types.Blocks, setHead bool) (int, error)
 		if parent == nil {
 			parent = bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
 		}
-		statedb, err := state.New(parent.Root, bc.stateCache, bc.snaps)
-		if err != nil {
-			return it.index, err
-		}
+		var (
+			statedb *state.StateDB
+			res     *ProcessResult
+			writes  []*state.TxWrites
+			ptime   time.Duration
+			err     error
 
-		// Enable prefetching to pull in trie node paths while processing transactions
-		statedb.StartPrefetcher("chain")
-		activeState = statedb
+			followupInterrupt atomic.Bool
+		)
+		if pending != nil && pending.block.Hash() == block.Hash() {
+			// The block was already executed while its parent was being validated
+			// and committed, pick up the results
+			statedb, res, writes, ptime = pending.statedb, pending.res, pending.writes, pending.ptime
+		} else {
+			statedb, err = state.New(parent.Root, bc.stateCache, bc.snaps)
+			if err != nil {
+				return it.index, err
+			}
 
-		// If we have a followup block, run that against the current state to pre-cache
-		// transactions and probabilistically some of the account/storage trie nodes.
-		var followupInterrupt atomic.Bool
-		if !bc.cacheConfig.TrieCleanNoPrefetch {
-			if followup, err := it.peek(); followup != nil && err == nil {
-				throwaway, _ := state.New(parent.Root, bc.stateCache, bc.snaps)
+			// Enable prefetching to pull in trie node paths while processing transactions
+			statedb.StartPrefetcher("chain")
+			activeState = statedb
 
-				go func(start time.Time, followup *types.Block, throwaway *state.StateDB) {
-					bc.prefetcher.Prefetch(followup, throwaway, bc.vmConfig, &followupInterrupt)
+			// If we have a followup block, run that against the current state to pre-cache
+			// transactions and probabilistically some of the account/storage trie nodes.
+			if !bc.cacheConfig.TrieCleanNoPrefetch && !bc.cacheConfig.PipelinedImport {
+				if followup, err := it.peek(); followup != nil && err == nil {
+					throwaway, _ := state.New(parent.Root, bc.stateCache, bc.snaps)
 
-					blockPrefetchExecuteTimer.Update(time.Since(start))
-					if followupInterrupt.Load() {
-						blockPrefetchInterruptMeter.Mark(1)
-					}
-				}(time.Now(), followup, throwaway)
+					go func(start time.Time, followup *types.Block, throwaway *state.StateDB) {
+						bc.prefetcher.Prefetch(followup, throwaway, bc.vmConfig, &followupInterrupt)
+
+						blockPrefetchExecuteTimer.Update(time.Since(start))
+						if followupInterrupt.Load() {
+							blockPrefetchInterruptMeter.Mark(1)
+						}
+					}(time.Now(), followup, throwaway)
+				}
 			}
-		}
 
-		// Process block using the parent state as reference point
-		pstart := time.Now()
-		receipts, logs, usedGas, err := bc.processor.Process(block, statedb, bc.vmConfig)
-		if err != nil {
-			bc.reportBlock(block, receipts, err)
-			followupInterrupt.Store(true)
-			return it.index, err
+			// Process block using the parent state as reference point
+			pstart := time.Now()
+			if bc.contention != nil {
+				statedb.StartWriteLog()
+			}
+			bc.countExecution(block.Hash())
+			res, err = bc.processor.Process(block, statedb, bc.vmConfig)
+			writes = statedb.StopWriteLog()
+			if err != nil {
+				bc.reportBlock(block, nil, err)
+				followupInterrupt.Store(true)
+				return it.index, err
+			}
+			ptime = time.Since(pstart)
+		}
+		pending = nil
+
+		// If pipelining is enabled, start executing the followup block on the not
+		// yet committed post-state, while this block is validated and written. It
+		// is discarded if this block turns out to be invalid. Peeking waits for
+		// the header verification of the followup, but its body is only checked
+		// against the header once the parent is written, so do that upfront.
+		var followup chan *speculation
+		if bc.cacheConfig.PipelinedImport && setHead {
+			if next, err := it.peek(); next != nil && err == nil && validateBodyContents(next) == nil {
+				statedb.Finalise(bc.chainConfig.IsEIP158(block.Number()))
+				if followup = bc.speculate(next, block.Header(), statedb); followup != nil {
+					bc.countExecution(next.Hash())
+				}
+			}
 		}
-		ptime := time.Since(pstart)
 
 		vstart := time.Now()
-		if err := bc.validator.ValidateState(block, statedb, receipts, usedGas); err != nil {
-			bc.reportBlock(block, receipts, err)
+		if err := bc.validator.ValidateState(block, statedb, res); err != nil {
+			bc.reportBlock(block, res.Receipts, err)
 			followupInterrupt.Store(true)
 			return it.index, err
 		}
 		vtime := time.Since(vstart)
 		proctime := time.Since(start) // processing + validation
 
+		if bc.contention != nil {
+			bc.contention.add(block, writes)
+		}
+
 		// Update the metrics touched during block processing and validation
 		accountReadTimer.Update(statedb.AccountReads)                   // Account reads are complete(in processing)
 		storageReadTimer.Update(statedb.StorageReads)                   // Storage reads are complete(in processing)
@@ -1809,9 +1929,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks, setHead bool) (int, error)
 		)
 		if !setHead {
 			// Don't set the head, only insert the block
-			err = bc.writeBlockWithState(block, receipts, statedb)
+			err = bc.writeBlockWithState(block, res.Receipts, statedb)
 		} else {
-			status, err = bc.writeBlockAndSetHead(block, receipts, logs, statedb, false)
+			status, err = bc.writeBlockAndSetHead(block, res.Receipts, res.Logs, statedb, false)
 		}
 		followupInterrupt.Store(true)
 		if err != nil {
@@ -1828,7 +1948,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, setHead bool) (int, error)
 
 		// Report the import stats before returning the various results
 		stats.processed++
-		stats.usedGas += usedGas
+		stats.usedGas += res.GasUsed
 
 		var snapDiffItems, snapBufItems common.StorageSize
 		if bc.snaps != nil {
@@ -1837,6 +1957,11 @@ func (bc *BlockChain) insertChain(chain types.Blocks, setHead bool) (int, error)
 		trieDiffNodes, trieBufNodes, _ := bc.triedb.Size()
 		stats.report(chain, it.index, snapDiffItems, snapBufItems, trieDiffNodes, trieBufNodes, setHead)
 
+		// Move the pipelined followup onto the freshly committed state
+		if followup != nil {
+			pending = bc.rebase(block, followup)
+		}
+
 		if !setHead {
 			// After merge we expect few side chains. Simply count
 			// all blocks the CL gives us for GC processing time
@@ -2286,6 +2411,7 @@ func (bc *BlockChain) InsertBlockWithoutSetHead(block *types.Block) error {
 	if !bc.chainmu.TryLock() {
 		return errChainStopped
 	}
+	defer bc.sendStateDiffs() // Runs after the unlock
 	defer bc.chainmu.Unlock()
 
 	_, err := bc.insertChain(types.Blocks{block}, false)
@@ -2299,6 +2425,7 @@ func (bc *BlockChain) SetCanonical(head *types.Block) (common.Hash, error) {
 	if !bc.chainmu.TryLock() {
 		return common.Hash{}, errChainStopped
 	}
+	defer bc.sendStateDiffs() // Runs after the unlock
 	defer bc.chainmu.Unlock()
 
 	// Re-execute the reorged chain in case the head state is missing.
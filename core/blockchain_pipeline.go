@@ -0,0 +1,127 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	pipelineExecuteTimer = metrics.NewRegisteredTimer("chain/pipeline/executes", nil)
+	pipelineRebaseTimer  = metrics.NewRegisteredTimer("chain/pipeline/rebases", nil)
+	pipelineDiscardMeter = metrics.NewRegisteredMeter("chain/pipeline/discards", nil)
+)
+
+// pipelineChain resolves the ancestors of a block executed on top of a parent
+// which is not yet written to the database.
+type pipelineChain struct {
+	*BlockChain
+	parent *types.Header
+}
+
+// GetHeader retrieves a block header from the database by hash and number,
+// falling back to the pending parent.
+func (c *pipelineChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if number == c.parent.Number.Uint64() && hash == c.parent.Hash() {
+		return c.parent
+	}
+	return c.BlockChain.GetHeader(hash, number)
+}
+
+// speculation is the result of a block executed on the uncommitted post-state
+// of its parent.
+type speculation struct {
+	block   *types.Block
+	statedb *state.StateDB
+	res     *ProcessResult
+	writes  []*state.TxWrites
+	ptime   time.Duration
+	err     error
+}
+
+// speculate starts executing the block in the background on a fork of the post
+// state of its parent, which must be finalised but may not be committed yet. A
+// nil channel is returned if the configured processor doesn't support it.
+func (bc *BlockChain) speculate(block *types.Block, parent *types.Header, statedb *state.StateDB) chan *speculation {
+	processor, ok := bc.processor.(*StateProcessor)
+	if !ok {
+		return nil
+	}
+	var (
+		spec   = statedb.Speculate()
+		result = make(chan *speculation, 1)
+	)
+	go func(start time.Time) {
+		if bc.contention != nil {
+			spec.StartWriteLog()
+		}
+		res, err := processor.process(block, spec, bc.vmConfig, &pipelineChain{bc, parent})
+		writes := spec.StopWriteLog()
+		if err == nil {
+			spec.Finalise(bc.chainConfig.IsEIP158(block.Number()))
+		}
+		ptime := time.Since(start)
+		pipelineExecuteTimer.Update(ptime)
+
+		result <- &speculation{
+			block:   block,
+			statedb: spec,
+			res:     res,
+			writes:  writes,
+			ptime:   ptime,
+			err:     err,
+		}
+	}(time.Now())
+	return result
+}
+
+// countExecution records an execution of the block's transactions. Executions
+// are only started by the importing goroutine, holding the chain mutex.
+func (bc *BlockChain) countExecution(hash common.Hash) {
+	n, _ := bc.executions.Get(hash)
+	bc.executions.Add(hash, n+1)
+}
+
+// rebase waits for the speculative execution of the followup block and moves
+// its state changes onto the committed state of the parent. Nil is returned if
+// the followup has to be executed regularly instead, e.g. because it failed.
+func (bc *BlockChain) rebase(parent *types.Block, followup chan *speculation) *speculation {
+	spec := <-followup
+	if spec.err != nil {
+		// The error is reported once the block is executed regularly
+		log.Debug("Speculative block execution failed", "number", spec.block.Number(), "hash", spec.block.Hash(), "err", spec.err)
+		pipelineDiscardMeter.Mark(1)
+		return nil
+	}
+	start := time.Now()
+	statedb, err := spec.statedb.Rebase(parent.Root())
+	if err != nil {
+		log.Debug("Failed to rebase speculative state", "number", spec.block.Number(), "hash", spec.block.Hash(), "err", err)
+		pipelineDiscardMeter.Mark(1)
+		return nil
+	}
+	pipelineRebaseTimer.UpdateSince(start)
+
+	spec.statedb = statedb
+	return spec
+}
@@ -0,0 +1,262 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// newPipelineTestChain generates a chain where every block modifies state the
+// previous block modified too, creates and destructs accounts, reads storage
+// without modifying it and reads the hashes of recent blocks.
+func newPipelineTestChain(t *testing.T, n int) (*Genesis, []*types.Block) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		counter = common.HexToAddress("0xc0ffee")
+		hasher  = common.HexToAddress("0xbeef")
+		killed  = common.HexToAddress("0xdead")
+		reader  = common.HexToAddress("0xfeed")
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: types.GenesisAlloc{
+				addr: {Balance: big.NewInt(params.Ether)},
+				// Increments slot 0 on every call
+				counter: {
+					Code: []byte{
+						byte(vm.PUSH1), 0, byte(vm.SLOAD),
+						byte(vm.PUSH1), 1, byte(vm.ADD),
+						byte(vm.PUSH1), 0, byte(vm.SSTORE),
+					},
+				},
+				// Stores the hash of the grandparent block keyed by block number
+				hasher: {
+					Code: []byte{
+						byte(vm.NUMBER), byte(vm.PUSH1), 2, byte(vm.SWAP1), byte(vm.SUB), byte(vm.BLOCKHASH),
+						byte(vm.NUMBER), byte(vm.SSTORE),
+					},
+				},
+				// Reads the slot keyed by block number without modifying it
+				reader: {
+					Code:    []byte{byte(vm.NUMBER), byte(vm.SLOAD), byte(vm.POP)},
+					Storage: map[common.Hash]common.Hash{{}: common.BytesToHash([]byte{1})},
+				},
+				// Self-destructs when called, wiping its storage
+				killed: {
+					Code:    []byte{byte(vm.PUSH1), 0, byte(vm.SELFDESTRUCT)},
+					Storage: map[common.Hash]common.Hash{{}: common.BytesToHash([]byte{1})},
+					Balance: big.NewInt(1),
+				},
+			},
+		}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), n, func(i int, b *BlockGen) {
+		send := func(to *common.Address, value int64, data []byte) {
+			tx, err := types.SignNewTx(key, signer, &types.LegacyTx{
+				Nonce:    b.TxNonce(addr),
+				To:       to,
+				Value:    big.NewInt(value),
+				Gas:      100000,
+				GasPrice: b.header.BaseFee,
+				Data:     data,
+			})
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			b.AddTx(tx)
+		}
+		send(&reader, 1, nil)
+		send(&counter, 0, nil)
+		send(&hasher, 0, nil)
+
+		// Fund a fresh account in every block
+		fresh := common.BigToAddress(big.NewInt(int64(0x1000 + i)))
+		send(&fresh, 1, nil)
+
+		// Create a contract destructing itself during construction
+		send(nil, 0, []byte{byte(vm.PUSH1), 0, byte(vm.SELFDESTRUCT)})
+
+		if i == 2 {
+			send(&killed, 0, nil)
+		}
+	})
+	return gspec, blocks
+}
+
+// Tests that importing blocks with pipelining enabled yields the same chain and
+// state as the regular import.
+func TestPipelinedImport(t *testing.T) {
+	testPipelinedImport(t, rawdb.HashScheme, true)
+	testPipelinedImport(t, rawdb.PathScheme, true)
+}
+
+// Tests pipelined imports without snapshots, where the storage tries shared by
+// the speculative states are loaded and hashed concurrently.
+func TestPipelinedImportNoSnapshot(t *testing.T) {
+	testPipelinedImport(t, rawdb.HashScheme, false)
+	testPipelinedImport(t, rawdb.PathScheme, false)
+}
+
+func testPipelinedImport(t *testing.T, scheme string, snapshots bool) {
+	gspec, blocks := newPipelineTestChain(t, 8)
+
+	cacheConfig := DefaultCacheConfigWithScheme(scheme)
+	cacheConfig.PipelinedImport = true
+	if !snapshots {
+		cacheConfig.SnapshotLimit = 0
+	}
+
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), cacheConfig, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// Every block is checked against the state root of its header on import
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != blocks[len(blocks)-1].Hash() {
+		t.Fatalf("head mismatch: have %d, want %d", head.Number, len(blocks))
+	}
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatalf("failed to retrieve head state: %v", err)
+	}
+	if have := statedb.GetState(common.HexToAddress("0xc0ffee"), common.Hash{}); have != common.BytesToHash([]byte{8}) {
+		t.Errorf("counter mismatch: have %x, want 8", have)
+	}
+	if have, want := statedb.GetState(common.HexToAddress("0xbeef"), common.BytesToHash([]byte{8})), blocks[5].Hash(); have != want {
+		t.Errorf("block hash mismatch: have %x, want %x", have, want)
+	}
+	if statedb.Exist(common.HexToAddress("0xdead")) {
+		t.Errorf("destructed account still exists")
+	}
+	// Every block is executed once, the followups on the state of their parent
+	for _, block := range blocks {
+		if n := chain.BlockExecutions(block.Hash()); n != 1 {
+			t.Errorf("block %d: execution count mismatch: have %d, want 1", block.NumberU64(), n)
+		}
+	}
+}
+
+// Tests that a block executed on top of an invalid parent is discarded.
+func TestPipelinedImportBadParent(t *testing.T) {
+	gspec, blocks := newPipelineTestChain(t, 4)
+
+	// Corrupt the state root of the third block and rechain the fourth on top
+	header := blocks[2].Header()
+	header.Root = common.Hash{0x01}
+	blocks[2] = blocks[2].WithSeal(header)
+
+	header = blocks[3].Header()
+	header.ParentHash = blocks[2].Hash()
+	blocks[3] = blocks[3].WithSeal(header)
+
+	cacheConfig := DefaultCacheConfigWithScheme(rawdb.HashScheme)
+	cacheConfig.PipelinedImport = true
+
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), cacheConfig, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	n, err := chain.InsertChain(blocks)
+	if err == nil || n != 2 {
+		t.Fatalf("bad block not rejected: index %d, err %v", n, err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != blocks[1].Hash() {
+		t.Errorf("head mismatch: have %d, want 2", head.Number)
+	}
+	if chain.HasBlock(blocks[3].Hash(), blocks[3].NumberU64()) {
+		t.Errorf("child of bad block imported")
+	}
+	if n := chain.BlockExecutions(blocks[3].Hash()); n != 1 {
+		t.Errorf("discarded child execution count mismatch: have %d, want 1", n)
+	}
+}
+
+// Tests that a followup block failing its speculative execution, due to a
+// transaction conflicting with one of its parent, is executed again regularly
+// and rejected.
+func TestPipelinedImportConflictingFollowup(t *testing.T) {
+	gspec, blocks := newPipelineTestChain(t, 3)
+
+	// Replay the first transaction of the second block in the third one
+	txs := append(blocks[2].Transactions(), blocks[1].Transactions()[0])
+	blocks[2] = types.NewBlock(blocks[2].Header(), txs, nil, nil, trie.NewStackTrie(nil))
+
+	cacheConfig := DefaultCacheConfigWithScheme(rawdb.HashScheme)
+	cacheConfig.PipelinedImport = true
+
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), cacheConfig, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	n, err := chain.InsertChain(blocks)
+	if err == nil || n != 2 || !strings.Contains(err.Error(), "nonce too low") {
+		t.Fatalf("conflicting block not rejected: index %d, err %v", n, err)
+	}
+	if n := chain.BlockExecutions(blocks[2].Hash()); n != 2 {
+		t.Errorf("conflicting block execution count mismatch: have %d, want 2", n)
+	}
+	if n := chain.BlockExecutions(blocks[1].Hash()); n != 1 {
+		t.Errorf("parent execution count mismatch: have %d, want 1", n)
+	}
+}
+
+// Tests that a followup block whose body doesn't match its header is rejected
+// the same way as without pipelining.
+func TestPipelinedImportBadBody(t *testing.T) {
+	gspec, blocks := newPipelineTestChain(t, 4)
+
+	// Drop a transaction from the body of the third block
+	txs := blocks[2].Transactions()
+	blocks[2] = blocks[2].WithBody(txs[:len(txs)-1], nil)
+
+	cacheConfig := DefaultCacheConfigWithScheme(rawdb.HashScheme)
+	cacheConfig.PipelinedImport = true
+
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), cacheConfig, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	n, err := chain.InsertChain(blocks)
+	if err == nil || n != 2 || !strings.Contains(err.Error(), "transaction root hash mismatch") {
+		t.Fatalf("bad body not rejected: index %d, err %v", n, err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != blocks[1].Hash() {
+		t.Errorf("head mismatch: have %d, want 2", head.Number)
+	}
+}
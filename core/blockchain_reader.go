@@ -262,6 +262,9 @@ func (bc *BlockChain) GetAncestor(hash common.Hash, number, ancestor uint64, max
 // indexing for transactions is still in progress. The transaction might be
 // reachable shortly once it's indexed.
 //
+// ErrHistoryPruned will be returned if the transaction is in a block whose body
+// has been pruned from the chain history.
+//
 // A null will be returned in the transaction is not found and background
 // transaction indexing is already finished. The transaction is not existent
 // from the node's perspective.
@@ -272,6 +275,11 @@ func (bc *BlockChain) GetTransactionLookup(hash common.Hash) (*rawdb.LegacyTxLoo
 	}
 	tx, blockHash, blockNumber, txIndex := rawdb.ReadTransaction(bc.db, hash)
 	if tx == nil {
+		// The transaction might be referenced by a stale index of a block
+		// whose body is already pruned.
+		if number := rawdb.ReadTxLookupEntry(bc.db, hash); number != nil && *number < bc.HistoryCutoff() {
+			return nil, nil, ErrHistoryPruned
+		}
 		progress, err := bc.TxIndexProgress()
 		if err != nil {
 			return nil, nil, nil
@@ -364,6 +372,20 @@ func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
 	return state.New(root, bc.stateCache, bc.snaps)
 }
 
+// SnapshotStateAt returns a new read-only state of a particular point in time,
+// which is served purely from the state snapshot without resolving any trie. It
+// returns state.ErrSnapshotUnavailable if the state isn't in the snapshot.
+func (bc *BlockChain) SnapshotStateAt(root common.Hash) (*state.StateDB, error) {
+	return state.New(root, state.NewSnapshotDatabase(bc.stateCache, bc.snaps), nil)
+}
+
+// HistoricStateAt returns a new read-only state of a historical point in time,
+// which is served by the indexed state histories. It's only supported in the
+// path-based scheme with the state history index enabled.
+func (bc *BlockChain) HistoricStateAt(root common.Hash) (*state.StateDB, error) {
+	return state.New(root, state.NewHistoricDatabase(bc.stateCache), nil)
+}
+
 // Config retrieves the chain's fork configuration.
 func (bc *BlockChain) Config() *params.ChainConfig { return bc.chainConfig }
 
@@ -405,6 +427,12 @@ func (bc *BlockChain) GetVMConfig() *vm.Config {
 	return &bc.vmConfig
 }
 
+// HistoryCutoff returns the number of the first block whose body and receipts
+// are retained, zero if the chain history is not pruned.
+func (bc *BlockChain) HistoryCutoff() uint64 {
+	return HistoryCutoff(bc.db)
+}
+
 // TxIndexProgress returns the transaction indexing progress.
 func (bc *BlockChain) TxIndexProgress() (TxIndexProgress, error) {
 	if bc.txIndexer == nil {
@@ -418,6 +446,25 @@ func (bc *BlockChain) TrieDB() *triedb.Database {
 	return bc.triedb
 }
 
+// ContentionReport returns the hottest accounts and storage slots, in terms of
+// transactions writing them within the same block, over the tracked window of
+// recently processed blocks.
+func (bc *BlockChain) ContentionReport(limit int) (*ContentionReport, error) {
+	if bc.contention == nil {
+		return nil, errors.New("contention tracking is disabled")
+	}
+	return bc.contention.report(limit), nil
+}
+
+// BlockExecutions returns how many times the transactions of a recently
+// imported block were executed during import, counting the speculative
+// executions of pipelined blocks even if they got discarded. Zero is returned
+// if the block wasn't imported recently.
+func (bc *BlockChain) BlockExecutions(hash common.Hash) uint64 {
+	n, _ := bc.executions.Get(hash)
+	return n
+}
+
 // HeaderChain returns the underlying header chain.
 func (bc *BlockChain) HeaderChain() *HeaderChain {
 	return bc.hc
@@ -448,6 +495,12 @@ func (bc *BlockChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscript
 	return bc.scope.Track(bc.logsFeed.Subscribe(ch))
 }
 
+// SubscribeStateDiffEvent registers a subscription of StateDiffEvent. The state
+// changes are only gathered for the blocks committed while subscribed.
+func (bc *BlockChain) SubscribeStateDiffEvent(ch chan<- StateDiffEvent) event.Subscription {
+	return bc.stateDiffScope.Track(bc.stateDiffFeed.Subscribe(ch))
+}
+
 // SubscribeBlockProcessingEvent registers a subscription of bool where true means
 // block processing has started while false means it has stopped.
 func (bc *BlockChain) SubscribeBlockProcessingEvent(ch chan<- bool) event.Subscription {
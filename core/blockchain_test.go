@@ -17,11 +17,13 @@
 package core
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math/big"
 	"math/rand"
 	"os"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -163,14 +165,14 @@ func testBlockChainImport(chain types.Blocks, blockchain *BlockChain) error {
 		if err != nil {
 			return err
 		}
-		receipts, _, usedGas, err := blockchain.processor.Process(block, statedb, vm.Config{})
+		res, err := blockchain.processor.Process(block, statedb, vm.Config{})
 		if err != nil {
-			blockchain.reportBlock(block, receipts, err)
+			blockchain.reportBlock(block, nil, err)
 			return err
 		}
-		err = blockchain.validator.ValidateState(block, statedb, receipts, usedGas)
+		err = blockchain.validator.ValidateState(block, statedb, res)
 		if err != nil {
-			blockchain.reportBlock(block, receipts, err)
+			blockchain.reportBlock(block, res.Receipts, err)
 			return err
 		}
 
@@ -4326,3 +4328,258 @@ func TestEIP3651(t *testing.T) {
 		t.Fatalf("sender balance incorrect: expected %d, got %d", expected, actual)
 	}
 }
+
+// TestEIP7702 deploys two delegation designations and calls them. It writes one
+// value to storage which is verified after.
+func TestEIP7702(t *testing.T) {
+	var (
+		config  = *params.MergedTestChainConfig
+		engine  = beacon.NewFaker()
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		key2, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2   = crypto.PubkeyToAddress(key2.PublicKey)
+		aa      = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		bb      = common.HexToAddress("0x000000000000000000000000000000000000bbbb")
+		funds   = new(big.Int).Mul(common.Big1, big.NewInt(params.Ether))
+	)
+	config.PragueTime = u64(0)
+	signer := types.LatestSigner(&config)
+
+	// 0xaaaa calls into addr2, then stores the code size of addr2 in slot 3
+	code := []byte{byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.DUP1), byte(vm.DUP1), byte(vm.DUP1), byte(vm.PUSH20)}
+	code = append(code, addr2.Bytes()...)
+	code = append(code, byte(vm.GAS), byte(vm.CALL), byte(vm.POP))
+	code = append(code, byte(vm.PUSH20))
+	code = append(code, addr2.Bytes()...)
+	code = append(code, byte(vm.EXTCODESIZE), byte(vm.PUSH1), 3, byte(vm.SSTORE))
+
+	gspec := &Genesis{
+		Config: &config,
+		Alloc: types.GenesisAlloc{
+			addr1: {Balance: funds},
+			addr2: {Balance: funds},
+			aa:    {Code: code},
+			// 0xbbbb stores 0x42 into slot 0x42
+			bb: {Code: []byte{byte(vm.PUSH1), 0x42, byte(vm.DUP1), byte(vm.SSTORE)}},
+		},
+	}
+	addRequestContracts(gspec.Alloc)
+
+	// The authorizations chain up as follows:
+	//  1. tx -> addr1, which is delegated to 0xaaaa
+	//  2. addr1:0xaaaa calls into addr2:0xbbbb
+	//  3. addr2:0xbbbb writes to storage
+	auth1, _ := types.SignSetCode(key1, types.SetCodeAuthorization{
+		ChainID: *uint256.MustFromBig(gspec.Config.ChainID),
+		Address: aa,
+		Nonce:   1, // The sender's nonce is bumped before the authorization is applied
+	})
+	auth2, _ := types.SignSetCode(key2, types.SetCodeAuthorization{
+		Address: bb,
+		Nonce:   0,
+	})
+	// An authorization with a mismatching nonce is skipped
+	auth3, _ := types.SignSetCode(key2, types.SetCodeAuthorization{
+		Address: aa,
+		Nonce:   5,
+	})
+	_, blocks, _ := GenerateChainWithGenesis(gspec, engine, 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(aa)
+		b.AddTx(types.MustSignNewTx(key1, signer, &types.SetCodeTx{
+			ChainID:   uint256.MustFromBig(gspec.Config.ChainID),
+			Nonce:     0,
+			To:        addr1,
+			Gas:       500000,
+			GasFeeCap: uint256.MustFromBig(newGwei(5)),
+			GasTipCap: uint256.NewInt(2),
+			AuthList:  []types.SetCodeAuthorization{auth1, auth2, auth3},
+		}))
+	})
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	// Verify delegation designations were deployed
+	state, _ := chain.State()
+	if have, want := state.GetCode(addr1), types.AddressToDelegation(auth1.Address); !bytes.Equal(have, want) {
+		t.Fatalf("addr1 code incorrect: have %x, want %x", have, want)
+	}
+	if have, want := state.GetCode(addr2), types.AddressToDelegation(auth2.Address); !bytes.Equal(have, want) {
+		t.Fatalf("addr2 code incorrect: have %x, want %x", have, want)
+	}
+	if have := state.GetNonce(addr2); have != 1 {
+		t.Fatalf("addr2 nonce incorrect: have %d, want 1", have)
+	}
+	// Verify delegated code executed
+	if have, want := state.GetState(addr2, common.HexToHash("0x42")), common.HexToHash("0x42"); have != want {
+		t.Fatalf("addr2 storage wrong: have %x, want %x", have, want)
+	}
+	// The code of a delegated account is the designator itself
+	if have := state.GetState(addr1, common.HexToHash("0x03")); have != common.BigToHash(big.NewInt(23)) {
+		t.Fatalf("delegated code size wrong: have %x, want 23", have)
+	}
+}
+
+// Tests that the parent block hash is recorded in the EIP-2935 history storage
+// contract and that BLOCKHASH is served from it after Prague.
+func TestEIP2935(t *testing.T) {
+	var (
+		config = *params.MergedTestChainConfig
+		engine = beacon.NewFaker()
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		aa     = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		funds  = new(big.Int).Mul(common.Big1, big.NewInt(params.Ether))
+	)
+	config.PragueTime = u64(0)
+	signer := types.LatestSigner(&config)
+
+	gspec := &Genesis{
+		Config: &config,
+		Alloc: types.GenesisAlloc{
+			addr: {Balance: funds},
+			// 0xaaaa stores BLOCKHASH(NUMBER-1) into slot NUMBER
+			aa: {Code: []byte{
+				byte(vm.PUSH1), 1, byte(vm.NUMBER), byte(vm.SUB), byte(vm.BLOCKHASH),
+				byte(vm.NUMBER), byte(vm.SSTORE),
+			}},
+			params.HistoryStorageAddress: {Nonce: 1, Code: params.HistoryStorageCode},
+		},
+	}
+	addRequestContracts(gspec.Alloc)
+	_, blocks, _ := GenerateChainWithGenesis(gspec, engine, 3, func(i int, b *BlockGen) {
+		b.AddTx(types.MustSignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID:   gspec.Config.ChainID,
+			Nonce:     uint64(i),
+			To:        &aa,
+			Gas:       100000,
+			GasFeeCap: newGwei(5),
+			GasTipCap: big.NewInt(2),
+		}))
+	})
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	state, _ := chain.State()
+	for _, block := range blocks {
+		var (
+			number = block.NumberU64()
+			parent = block.ParentHash()
+		)
+		slot := common.BigToHash(new(big.Int).SetUint64((number - 1) % params.HistoryServeWindow))
+		if have := state.GetState(params.HistoryStorageAddress, slot); have != parent {
+			t.Fatalf("block %d: history storage wrong: have %x, want %x", number, have, parent)
+		}
+		if have := state.GetState(aa, common.BigToHash(block.Number())); have != parent {
+			t.Fatalf("block %d: blockhash wrong: have %x, want %x", number, have, parent)
+		}
+	}
+}
+
+// addRequestContracts deploys EIP-7002 and EIP-7251 system contracts which
+// dequeue no requests, as required by Prague blocks.
+func addRequestContracts(alloc types.GenesisAlloc) {
+	alloc[params.WithdrawalQueueAddress] = types.Account{Nonce: 1, Code: []byte{byte(vm.STOP)}}
+	alloc[params.ConsolidationQueueAddress] = types.Account{Nonce: 1, Code: []byte{byte(vm.STOP)}}
+}
+
+// TestEIP7685 verifies that execution-layer requests emitted by the system
+// contracts are committed to in the header and validated on import.
+func TestEIP7685(t *testing.T) {
+	var (
+		config = *params.MergedTestChainConfig
+		engine = beacon.NewFaker()
+	)
+	config.PragueTime = u64(0)
+
+	gspec := &Genesis{
+		Config: &config,
+		Alloc: types.GenesisAlloc{
+			// The mock withdrawal queue returns 32 bytes of 0xff as its request data
+			params.WithdrawalQueueAddress: {Nonce: 1, Code: []byte{
+				byte(vm.PUSH32), 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				byte(vm.PUSH1), 0, byte(vm.MSTORE),
+				byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN),
+			}},
+		},
+	}
+	gspec.Alloc[params.ConsolidationQueueAddress] = types.Account{Nonce: 1, Code: []byte{byte(vm.STOP)}}
+	_, blocks, _ := GenerateChainWithGenesis(gspec, engine, 2, func(i int, b *BlockGen) {})
+
+	want := types.CalcRequestsHash([][]byte{append([]byte{types.WithdrawalRequestType}, bytes.Repeat([]byte{0xff}, 32)...)})
+	for _, block := range blocks {
+		if have := block.RequestsHash(); have == nil || *have != want {
+			t.Fatalf("block %d: requests hash mismatch: have %v, want %x", block.NumberU64(), have, want)
+		}
+	}
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// A block committing to the wrong requests must be rejected
+	header := blocks[0].Header()
+	header.RequestsHash = &types.EmptyRequestsHash
+	bad := blocks[0].WithSeal(header)
+	if _, err := chain.InsertChain(types.Blocks{bad}); err == nil {
+		t.Fatal("expected block with invalid requests hash to be rejected")
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+}
+
+// TestEIP7685Revert verifies that blocks are rejected if a request system
+// contract reverts.
+func TestEIP7685Revert(t *testing.T) {
+	var (
+		config = *params.MergedTestChainConfig
+		engine = beacon.NewFaker()
+	)
+	config.PragueTime = u64(0)
+
+	gspec := &Genesis{
+		Config: &config,
+		Alloc: types.GenesisAlloc{
+			// The mock consolidation queue reverts unless the coinbase is zero
+			params.ConsolidationQueueAddress: {Nonce: 1, Code: []byte{
+				byte(vm.COINBASE), byte(vm.ISZERO), byte(vm.PUSH1), 9, byte(vm.JUMPI),
+				byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.REVERT),
+				byte(vm.JUMPDEST), byte(vm.STOP),
+			}},
+		},
+	}
+	gspec.Alloc[params.WithdrawalQueueAddress] = types.Account{Nonce: 1, Code: []byte{byte(vm.STOP)}}
+	_, blocks, _ := GenerateChainWithGenesis(gspec, engine, 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{})
+	})
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	header := blocks[0].Header()
+	header.Coinbase = common.Address{0x01}
+	bad := blocks[0].WithSeal(header)
+	if _, err := chain.InsertChain(types.Blocks{bad}); err == nil || !strings.Contains(err.Error(), "system call") {
+		t.Fatalf("expected block with reverting request contract to be rejected, have %v", err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+}
@@ -32,9 +32,14 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
 	"github.com/holiman/uint256"
 )
 
+// verkleTrieConfig is the trie database configuration of generated chains
+// whose state is stored in a verkle tree.
+var verkleTrieConfig = &triedb.Config{IsVerkle: true, PathDB: pathdb.Defaults}
+
 // BlockGen creates blocks for testing.
 // See GenerateChain for a detailed explanation.
 type BlockGen struct {
@@ -111,12 +116,18 @@ func (b *BlockGen) SetParentBeaconRoot(root common.Hash) {
 // customized rules.
 // - bc:       enables the ability to query historical block hashes for BLOCKHASH
 // - vmConfig: extends the flexibility for customizing evm rules, e.g. enable extra EIPs
+//
+// Without a chain, BLOCKHASH resolves the blocks generated so far.
 func (b *BlockGen) addTx(bc *BlockChain, vmConfig vm.Config, tx *types.Transaction) {
 	if b.gasPool == nil {
 		b.SetCoinbase(common.Address{})
 	}
+	var chain ChainContext = b.cm
+	if bc != nil {
+		chain = bc
+	}
 	b.statedb.SetTxContext(tx.Hash(), len(b.txs))
-	receipt, err := ApplyTransaction(b.cm.config, bc, &b.header.Coinbase, b.gasPool, b.statedb, b.header, tx, &b.header.GasUsed, vmConfig)
+	receipt, err := ApplyTransaction(b.cm.config, chain, &b.header.Coinbase, b.gasPool, b.statedb, b.header, tx, &b.header.GasUsed, vmConfig)
 	if err != nil {
 		panic(err)
 	}
@@ -340,11 +351,32 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 		if config.DAOForkSupport && config.DAOForkBlock != nil && config.DAOForkBlock.Cmp(b.header.Number) == 0 {
 			misc.ApplyDAOHardFork(statedb)
 		}
+		if config.IsPrague(b.header.Number, b.header.Time) {
+			// EIP-2935
+			blockContext := NewEVMBlockContext(b.header, cm, &b.header.Coinbase, config, statedb)
+			vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, config, vm.Config{})
+			ProcessParentBlockHash(b.header.ParentHash, vmenv, statedb)
+		}
 		// Execute any user modifications to the block
 		if gen != nil {
 			gen(i, b)
 		}
 
+		if config.IsPrague(b.header.Number, b.header.Time) {
+			// Collect the EIP-7685 requests and commit to them in the header
+			var logs []*types.Log
+			for _, r := range b.receipts {
+				logs = append(logs, r.Logs...)
+			}
+			blockContext := NewEVMBlockContext(b.header, cm, &b.header.Coinbase, config, statedb)
+			vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, config, vm.Config{})
+			requests, err := ProcessRequests(config, logs, vmenv, statedb)
+			if err != nil {
+				panic(fmt.Sprintf("failed to process requests: %v", err))
+			}
+			reqHash := types.CalcRequestsHash(requests)
+			b.header.RequestsHash = &reqHash
+		}
 		block, err := b.engine.FinalizeAndAssemble(cm, b.header, statedb, b.txs, b.uncles, b.receipts, b.withdrawals)
 		if err != nil {
 			panic(err)
@@ -361,8 +393,13 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 		return block, b.receipts
 	}
 
-	// Forcibly use hash-based state scheme for retaining all nodes in disk.
-	triedb := triedb.NewDatabase(db, triedb.HashDefaults)
+	// Forcibly use hash-based state scheme for retaining all nodes in disk,
+	// unless the state is stored in a verkle tree which requires path-based.
+	trieConfig := triedb.HashDefaults
+	if config.IsVerkle(parent.Number(), parent.Time()) {
+		trieConfig = verkleTrieConfig
+	}
+	triedb := triedb.NewDatabase(db, trieConfig)
 	defer triedb.Close()
 
 	for i := 0; i < n; i++ {
@@ -407,7 +444,11 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 // then generate chain on top.
 func GenerateChainWithGenesis(genesis *Genesis, engine consensus.Engine, n int, gen func(int, *BlockGen)) (ethdb.Database, []*types.Block, []types.Receipts) {
 	db := rawdb.NewMemoryDatabase()
-	triedb := triedb.NewDatabase(db, triedb.HashDefaults)
+	trieConfig := triedb.HashDefaults
+	if genesis.IsVerkle() {
+		trieConfig = verkleTrieConfig
+	}
+	triedb := triedb.NewDatabase(db, trieConfig)
 	defer triedb.Close()
 	_, err := genesis.Commit(db, triedb)
 	if err != nil {
@@ -0,0 +1,312 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// contentionMetricsTop is the number of hottest accounts and slots exported
+// through the metrics system.
+const contentionMetricsTop = 10
+
+var (
+	contendedAccountsGauge = metrics.NewRegisteredGauge("chain/contention/accounts", nil)
+	contendedSlotsGauge    = metrics.NewRegisteredGauge("chain/contention/slots", nil)
+	contentionMaxTxsGauge  = metrics.NewRegisteredGauge("chain/contention/maxtxs", nil)
+
+	contentionTopAccountGauges = newContentionTopMetrics("chain/contention/account/top")
+	contentionTopSlotGauges    = newContentionTopMetrics("chain/contention/slot/top")
+)
+
+// contentionTopMetrics is a set of rank-indexed metrics reporting the hottest
+// entries of the sliding window. The gauge carries the number of contending
+// transactions, the info gauge the identity (address and slot) of the entry.
+type contentionTopMetrics struct {
+	txs  []metrics.Gauge
+	info []metrics.GaugeInfo
+}
+
+func newContentionTopMetrics(prefix string) *contentionTopMetrics {
+	m := &contentionTopMetrics{
+		txs:  make([]metrics.Gauge, contentionMetricsTop),
+		info: make([]metrics.GaugeInfo, contentionMetricsTop),
+	}
+	for i := 0; i < contentionMetricsTop; i++ {
+		m.txs[i] = metrics.NewRegisteredGauge(fmt.Sprintf("%s%d", prefix, i), nil)
+		m.info[i] = metrics.NewRegisteredGaugeInfo(fmt.Sprintf("%s%d/info", prefix, i), nil)
+	}
+	return m
+}
+
+// update publishes the given (sorted) entries, clearing the unused ranks.
+func (m *contentionTopMetrics) update(entries []*ContentionEntry) {
+	for i := 0; i < contentionMetricsTop; i++ {
+		if i >= len(entries) {
+			m.txs[i].Update(0)
+			m.info[i].Update(metrics.GaugeInfoValue{})
+			continue
+		}
+		entry := entries[i]
+		m.txs[i].Update(int64(entry.Txs))
+
+		info := metrics.GaugeInfoValue{
+			"address": entry.Address.Hex(),
+			"senders": strconv.FormatUint(entry.Senders, 10),
+			"blocks":  strconv.FormatUint(entry.Blocks, 10),
+		}
+		if entry.Slot != nil {
+			info["slot"] = entry.Slot.Hex()
+		}
+		m.info[i].Update(info)
+	}
+}
+
+// ContentionEntry is the aggregated write contention of a single account or
+// storage slot over the tracked window of blocks. Only blocks in which the
+// entry was written by at least two transactions are accounted.
+type ContentionEntry struct {
+	Address    common.Address `json:"address"`
+	Slot       *common.Hash   `json:"slot,omitempty"`
+	Blocks     uint64         `json:"blocks"`     // Number of blocks in which the entry was contended
+	Txs        uint64         `json:"txs"`        // Writing transactions, summed over the contended blocks
+	Senders    uint64         `json:"senders"`    // Distinct writing senders, summed over the contended blocks
+	MaxTxs     uint64         `json:"maxTxs"`     // Maximum number of writing transactions in a single block
+	MaxSenders uint64         `json:"maxSenders"` // Maximum number of distinct writing senders in a single block
+}
+
+// ContentionReport is the write contention summary over the window of recently
+// processed blocks, the hottest entries first.
+type ContentionReport struct {
+	FromBlock uint64             `json:"fromBlock"`
+	ToBlock   uint64             `json:"toBlock"`
+	Accounts  []*ContentionEntry `json:"accounts"`
+	Slots     []*ContentionEntry `json:"slots"`
+}
+
+// contentionKey identifies an account (zero slot, isSlot unset) or a storage slot.
+type contentionKey struct {
+	addr   common.Address
+	slot   common.Hash
+	isSlot bool
+}
+
+// contentionStat is the write contention of a single key within one block.
+type contentionStat struct {
+	txs     uint64
+	senders uint64
+}
+
+// blockContention is the contended keys of a single block.
+type blockContention struct {
+	number uint64
+	stats  map[contentionKey]contentionStat
+}
+
+// contentionTracker maintains which accounts and storage slots are written by
+// multiple transactions and senders within the blocks of a sliding window.
+type contentionTracker struct {
+	config *params.ChainConfig
+	window uint64
+	blocks []*blockContention // Tracked blocks, oldest first
+	lock   sync.RWMutex
+}
+
+// newContentionTracker creates a tracker for the given number of recent blocks.
+func newContentionTracker(config *params.ChainConfig, window uint64) *contentionTracker {
+	return &contentionTracker{
+		config: config,
+		window: window,
+	}
+}
+
+// add accounts the transaction write sets of a freshly processed block and
+// refreshes the contention metrics.
+func (t *contentionTracker) add(block *types.Block, writes []*state.TxWrites) {
+	var (
+		signer  = types.MakeSigner(t.config, block.Number(), block.Time())
+		txs     = block.Transactions()
+		senders = make(map[int]common.Address)
+		writers = make(map[contentionKey][]int)
+
+		// The coinbase and, on Optimism, the fee vaults are credited by every
+		// transaction, don't report them as contended.
+		feeRecipients = map[common.Address]struct{}{block.Coinbase(): {}}
+	)
+	if t.config.Optimism != nil {
+		feeRecipients[params.OptimismBaseFeeRecipient] = struct{}{}
+		feeRecipients[params.OptimismL1FeeRecipient] = struct{}{}
+	}
+	for _, w := range writes {
+		if w.TxIndex >= len(txs) {
+			continue
+		}
+		// The sender's own account is written by every transaction (fees and
+		// nonce), don't report it as contended either.
+		from, err := types.Sender(signer, txs[w.TxIndex])
+		if err != nil {
+			continue
+		}
+		senders[w.TxIndex] = from
+
+		seen := make(map[contentionKey]struct{})
+		for _, addr := range w.Accounts {
+			if addr == from {
+				continue
+			}
+			if _, ok := feeRecipients[addr]; ok {
+				continue
+			}
+			seen[contentionKey{addr: addr}] = struct{}{}
+		}
+		for addr, slots := range w.Slots {
+			for _, slot := range slots {
+				seen[contentionKey{addr: addr, slot: slot, isSlot: true}] = struct{}{}
+			}
+		}
+		for key := range seen {
+			writers[key] = append(writers[key], w.TxIndex)
+		}
+	}
+	var (
+		stats    = make(map[contentionKey]contentionStat)
+		accounts int64
+		slots    int64
+		maxTxs   uint64
+	)
+	for key, indices := range writers {
+		if len(indices) < 2 {
+			continue
+		}
+		distinct := make(map[common.Address]struct{})
+		for _, index := range indices {
+			distinct[senders[index]] = struct{}{}
+		}
+		stats[key] = contentionStat{txs: uint64(len(indices)), senders: uint64(len(distinct))}
+		if key.isSlot {
+			slots++
+		} else {
+			accounts++
+		}
+		if uint64(len(indices)) > maxTxs {
+			maxTxs = uint64(len(indices))
+		}
+	}
+	contendedAccountsGauge.Update(accounts)
+	contendedSlotsGauge.Update(slots)
+	contentionMaxTxsGauge.Update(int64(maxTxs))
+
+	t.lock.Lock()
+	// Drop any blocks reorged out by the new one, then the ones outside the window
+	number := block.NumberU64()
+	for len(t.blocks) > 0 && t.blocks[len(t.blocks)-1].number >= number {
+		t.blocks = t.blocks[:len(t.blocks)-1]
+	}
+	t.blocks = append(t.blocks, &blockContention{number: number, stats: stats})
+	for len(t.blocks) > 0 && t.blocks[0].number+t.window <= number {
+		t.blocks = t.blocks[1:]
+	}
+	t.lock.Unlock()
+
+	if metrics.Enabled {
+		report := t.report(contentionMetricsTop)
+		contentionTopAccountGauges.update(report.Accounts)
+		contentionTopSlotGauges.update(report.Slots)
+	}
+}
+
+// report aggregates the tracked window and returns the given number of the
+// hottest accounts and slots, ordered by the number of contending transactions.
+func (t *contentionTracker) report(limit int) *ContentionReport {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	report := new(ContentionReport)
+	if len(t.blocks) == 0 {
+		return report
+	}
+	report.FromBlock = t.blocks[0].number
+	report.ToBlock = t.blocks[len(t.blocks)-1].number
+
+	entries := make(map[contentionKey]*ContentionEntry)
+	for _, block := range t.blocks {
+		for key, stat := range block.stats {
+			entry := entries[key]
+			if entry == nil {
+				entry = &ContentionEntry{Address: key.addr}
+				if key.isSlot {
+					slot := key.slot
+					entry.Slot = &slot
+				}
+				entries[key] = entry
+			}
+			entry.Blocks++
+			entry.Txs += stat.txs
+			entry.Senders += stat.senders
+			if stat.txs > entry.MaxTxs {
+				entry.MaxTxs = stat.txs
+			}
+			if stat.senders > entry.MaxSenders {
+				entry.MaxSenders = stat.senders
+			}
+		}
+	}
+	for key, entry := range entries {
+		if key.isSlot {
+			report.Slots = append(report.Slots, entry)
+		} else {
+			report.Accounts = append(report.Accounts, entry)
+		}
+	}
+	report.Accounts = sortContention(report.Accounts, limit)
+	report.Slots = sortContention(report.Slots, limit)
+	return report
+}
+
+// sortContention orders the entries by contending transactions, then distinct
+// senders, then identity for a stable output, and caps them to the limit.
+func sortContention(entries []*ContentionEntry, limit int) []*ContentionEntry {
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Txs != b.Txs {
+			return a.Txs > b.Txs
+		}
+		if a.Senders != b.Senders {
+			return a.Senders > b.Senders
+		}
+		if a.Address != b.Address {
+			return a.Address.Cmp(b.Address) < 0
+		}
+		if a.Slot == nil || b.Slot == nil {
+			return a.Slot == nil && b.Slot != nil
+		}
+		return a.Slot.Cmp(*b.Slot) < 0
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
@@ -0,0 +1,136 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Tests that the write contention of storage slots hammered by multiple senders
+// is tracked over the configured window of blocks.
+func TestContentionReport(t *testing.T) {
+	var (
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		key2, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2   = crypto.PubkeyToAddress(key2.PublicKey)
+		counter = common.HexToAddress("0xc0ffee")
+		funds   = big.NewInt(params.Ether)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: types.GenesisAlloc{
+				addr1: {Balance: funds},
+				addr2: {Balance: funds},
+				// The counter contract increments slot 0 on every call
+				counter: {
+					Code: []byte{
+						byte(vm.PUSH1), 0, byte(vm.SLOAD),
+						byte(vm.PUSH1), 1, byte(vm.ADD),
+						byte(vm.PUSH1), 0, byte(vm.SSTORE),
+					},
+				},
+			},
+		}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 4, func(i int, b *BlockGen) {
+		for _, key := range []*ecdsa.PrivateKey{key1, key1, key2} {
+			from := crypto.PubkeyToAddress(key.PublicKey)
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(from), counter, common.Big0, 100000, b.header.BaseFee, nil), signer, key)
+			b.AddTx(tx)
+		}
+	})
+	cacheConfig := DefaultCacheConfigWithScheme(rawdb.HashScheme)
+	cacheConfig.ContentionWindow = 3
+
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), cacheConfig, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	report, err := chain.ContentionReport(10)
+	if err != nil {
+		t.Fatalf("failed to retrieve contention report: %v", err)
+	}
+	if report.FromBlock != 2 || report.ToBlock != 4 {
+		t.Fatalf("window mismatch: have [%d, %d], want [2, 4]", report.FromBlock, report.ToBlock)
+	}
+	if len(report.Slots) != 1 {
+		t.Fatalf("contended slot count mismatch: have %d, want 1", len(report.Slots))
+	}
+	want := ContentionEntry{
+		Address:    counter,
+		Slot:       new(common.Hash),
+		Blocks:     3,
+		Txs:        9,
+		Senders:    6,
+		MaxTxs:     3,
+		MaxSenders: 2,
+	}
+	if have := *report.Slots[0]; have.Address != want.Address || *have.Slot != *want.Slot || have.Blocks != want.Blocks ||
+		have.Txs != want.Txs || have.Senders != want.Senders || have.MaxTxs != want.MaxTxs || have.MaxSenders != want.MaxSenders {
+		t.Fatalf("slot contention mismatch: have %+v, want %+v", have, want)
+	}
+	// Neither the senders nor the coinbase, written by every transaction, may
+	// be reported as contended
+	for _, entry := range report.Accounts {
+		switch entry.Address {
+		case addr1, addr2, blocks[0].Coinbase():
+			t.Errorf("account %x reported as contended: %+v", entry.Address, entry)
+		}
+	}
+	// On Optimism, the fee vaults are credited by every transaction as well
+	var (
+		opConfig = params.OptimismTestConfig
+		opSigner = types.LatestSigner(opConfig)
+		opTxs    []*types.Transaction
+		writes   []*state.TxWrites
+		target   = common.HexToAddress("0xbeef")
+	)
+	for i, key := range []*ecdsa.PrivateKey{key1, key2} {
+		tx, _ := types.SignTx(types.NewTransaction(0, target, common.Big1, 21000, big.NewInt(1), nil), opSigner, key)
+		opTxs = append(opTxs, tx)
+		writes = append(writes, &state.TxWrites{
+			TxHash:   tx.Hash(),
+			TxIndex:  i,
+			Accounts: []common.Address{crypto.PubkeyToAddress(key.PublicKey), target, params.OptimismBaseFeeRecipient, params.OptimismL1FeeRecipient},
+		})
+	}
+	header := &types.Header{Number: big.NewInt(10), Time: 10, BaseFee: big.NewInt(1)}
+	tracker := newContentionTracker(opConfig, 1)
+	tracker.add(types.NewBlock(header, opTxs, nil, nil, trie.NewStackTrie(nil)), writes)
+
+	report = tracker.report(10)
+	if len(report.Accounts) != 1 || report.Accounts[0].Address != target {
+		t.Fatalf("optimism contended accounts mismatch: have %+v, want only %x", report.Accounts, target)
+	}
+}
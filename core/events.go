@@ -18,6 +18,7 @@ package core
 
 import (
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
@@ -41,3 +42,10 @@ type ChainSideEvent struct {
 }
 
 type ChainHeadEvent struct{ Block *types.Block }
+
+// StateDiffEvent is posted when the state changes of a block are committed,
+// irrespective of the block becoming canonical.
+type StateDiffEvent struct {
+	Header *types.Header
+	Diff   *state.StateDiff
+}
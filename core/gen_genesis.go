@@ -35,6 +35,7 @@ func (g Genesis) MarshalJSON() ([]byte, error) {
 		ExcessBlobGas *math.HexOrDecimal64                              `json:"excessBlobGas"`
 		BlobGasUsed   *math.HexOrDecimal64                              `json:"blobGasUsed"`
 		StateHash     *common.Hash                                `json:"stateHash,omitempty"`
+		AllocFile     string                                      `json:"allocFile,omitempty"`
 	}
 	var enc Genesis
 	enc.Config = g.Config
@@ -58,6 +59,7 @@ func (g Genesis) MarshalJSON() ([]byte, error) {
 	enc.ExcessBlobGas = (*math.HexOrDecimal64)(g.ExcessBlobGas)
 	enc.BlobGasUsed = (*math.HexOrDecimal64)(g.BlobGasUsed)
 	enc.StateHash = g.StateHash
+	enc.AllocFile = g.AllocFile
 	return json.Marshal(&enc)
 }
 
@@ -80,6 +82,7 @@ func (g *Genesis) UnmarshalJSON(input []byte) error {
 		ExcessBlobGas *math.HexOrDecimal64                        `json:"excessBlobGas"`
 		BlobGasUsed   *math.HexOrDecimal64                        `json:"blobGasUsed"`
 		StateHash     *common.Hash                                `json:"stateHash,omitempty"`
+		AllocFile     *string                                     `json:"allocFile,omitempty"`
 	}
 	var dec Genesis
 	if err := json.Unmarshal(input, &dec); err != nil {
@@ -139,5 +142,8 @@ func (g *Genesis) UnmarshalJSON(input []byte) error {
 	if dec.StateHash != nil {
 		g.StateHash = dec.StateHash
 	}
+	if dec.AllocFile != nil {
+		g.AllocFile = *dec.AllocFile
+	}
 	return nil
 }
@@ -79,6 +79,14 @@ type Genesis struct {
 	// Chains with history pruning, or extraordinarily large genesis allocation (e.g. after a regenesis event)
 	// may utilize this to get started, and then state-sync the latest state, while still verifying the header chain.
 	StateHash *common.Hash `json:"stateHash,omitempty"`
+
+	// AllocFile points to a file streaming the genesis allocation, for chains with a state
+	// too large to be held in memory (e.g. regenerated from a state dump). It holds the
+	// accounts as JSON lines in the format of `geth dump --iterative`, ordered by the hash
+	// of their address. It cannot be used along with Alloc or StateHash.
+	AllocFile string `json:"allocFile,omitempty"`
+
+	allocRoot *common.Hash // State root of the allocation file, cached after the first pass
 }
 
 func ReadGenesis(db ethdb.Database) (*Genesis, error) {
@@ -220,6 +228,7 @@ func (e *GenesisMismatchError) Error() string {
 // ChainOverrides contains the changes to chain config.
 type ChainOverrides struct {
 	OverrideCancun *uint64
+	OverridePrague *uint64
 	OverrideVerkle *uint64
 	// optimism
 	OverrideOptimismCanyon  *uint64
@@ -250,6 +259,13 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, triedb *triedb.Database, g
 	if genesis != nil && genesis.Config == nil {
 		return params.AllEthashProtocolChanges, common.Hash{}, errGenesisNoConfig
 	}
+	// Stream the genesis allocation file upfront, surfacing any error in it
+	// before the genesis block is assembled.
+	if genesis != nil && genesis.AllocFile != "" {
+		if _, err := genesis.allocFileRoot(); err != nil {
+			return genesis.Config, common.Hash{}, err
+		}
+	}
 	applyOverrides := func(config *params.ChainConfig) {
 		if config != nil {
 			// If applying the superchain-registry to a known OP-Stack chain,
@@ -276,6 +292,9 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, triedb *triedb.Database, g
 			if overrides != nil && overrides.OverrideCancun != nil {
 				config.CancunTime = overrides.OverrideCancun
 			}
+			if overrides != nil && overrides.OverridePrague != nil {
+				config.PragueTime = overrides.OverridePrague
+			}
 			if overrides != nil && overrides.OverrideVerkle != nil {
 				config.VerkleTime = overrides.OverrideVerkle
 			}
@@ -410,6 +429,11 @@ func LoadChainConfig(db ethdb.Database, genesis *Genesis) (*params.ChainConfig,
 		// config is missing(initialize the empty leveldb with an
 		// external ancient chain segment), ensure the provided genesis
 		// is matched.
+		if stored != (common.Hash{}) && genesis.AllocFile != "" {
+			if _, err := genesis.allocFileRoot(); err != nil {
+				return nil, err
+			}
+		}
 		if stored != (common.Hash{}) && genesis.ToBlock().Hash() != stored {
 			return nil, &GenesisMismatchError{stored, genesis.ToBlock().Hash()}
 		}
@@ -443,6 +467,28 @@ func (g *Genesis) IsVerkle() bool {
 	return g.Config.IsVerkle(new(big.Int).SetUint64(g.Number), g.Timestamp)
 }
 
+// isVerkleAtGenesis reports whether the state of the chain is stored in a
+// verkle tree from genesis, either according to the provided genesis or to
+// the one already stored in the database. Converting an existing merkle state
+// to a verkle tree is not supported.
+func isVerkleAtGenesis(db ethdb.Database, genesis *Genesis) (bool, error) {
+	if genesis != nil {
+		if genesis.Config == nil {
+			return false, errGenesisNoConfig
+		}
+		return genesis.IsVerkle(), nil
+	}
+	ghash := rawdb.ReadCanonicalHash(db, 0)
+	if ghash == (common.Hash{}) {
+		return false, nil
+	}
+	config, header := rawdb.ReadChainConfig(db, ghash), rawdb.ReadHeader(db, ghash, 0)
+	if config == nil || header == nil {
+		return false, nil
+	}
+	return config.IsVerkle(header.Number, header.Time), nil
+}
+
 // ToBlock returns the genesis block according to genesis specification.
 func (g *Genesis) ToBlock() *types.Block {
 	var root common.Hash
@@ -453,6 +499,10 @@ func (g *Genesis) ToBlock() *types.Block {
 				"and non-empty state-allocation", *g.StateHash))
 		}
 		root = *g.StateHash
+	} else if g.AllocFile != "" {
+		if root, err = g.allocFileRoot(); err != nil {
+			panic(err)
+		}
 	} else if root, err = hashAlloc(&g.Alloc, g.IsVerkle()); err != nil {
 		panic(err)
 	}
@@ -505,6 +555,9 @@ func (g *Genesis) ToBlock() *types.Block {
 				head.BlobGasUsed = new(uint64)
 			}
 		}
+		if conf.IsPrague(num, g.Timestamp) {
+			head.RequestsHash = &types.EmptyRequestsHash
+		}
 	}
 	return types.NewBlock(head, nil, nil, nil, trie.NewStackTrie(nil)).WithWithdrawals(withdrawals)
 }
@@ -529,7 +582,11 @@ func (g *Genesis) Commit(db ethdb.Database, triedb *triedb.Database) (*types.Blo
 	// All the checks has passed, flushAlloc the states derived from the genesis
 	// specification as well as the specification itself into the provided
 	// database.
-	if err := flushAlloc(&g.Alloc, db, triedb, block.Hash()); err != nil {
+	if g.AllocFile != "" {
+		if err := flushAllocFile(g.AllocFile, db, triedb, block.Root(), block.Hash()); err != nil {
+			return nil, err
+		}
+	} else if err := flushAlloc(&g.Alloc, db, triedb, block.Hash()); err != nil {
 		return nil, err
 	}
 	rawdb.WriteTd(db, block.Hash(), block.NumberU64(), block.Difficulty())
@@ -623,6 +680,8 @@ func DeveloperGenesisBlock(gasLimit uint64, faucet *common.Address) *Genesis {
 			common.BytesToAddress([]byte{7}): {Balance: big.NewInt(1)}, // ECScalarMul
 			common.BytesToAddress([]byte{8}): {Balance: big.NewInt(1)}, // ECPairing
 			common.BytesToAddress([]byte{9}): {Balance: big.NewInt(1)}, // BLAKE2b
+			// Pre-deploy EIP-2935 history contract.
+			params.HistoryStorageAddress: {Nonce: 1, Code: params.HistoryStorageCode},
 		},
 	}
 	if faucet != nil {
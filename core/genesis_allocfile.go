@@ -0,0 +1,279 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/holiman/uint256"
+)
+
+// allocFileSlot is a storage slot of an account in a genesis allocation file.
+type allocFileSlot struct {
+	hash  common.Hash
+	value []byte // RLP-encoded slot value, as stored in the trie
+}
+
+// allocFileAccount is an account read from a genesis allocation file.
+type allocFileAccount struct {
+	address common.Address
+	hash    common.Hash
+	account types.StateAccount // Account with the storage root yet to be computed
+	root    *common.Hash       // Storage root announced by the file, if any
+	code    []byte
+	slots   []allocFileSlot // Storage slots ordered by hash
+}
+
+// allocFileReader iterates over the accounts of a genesis allocation file,
+// checking their integrity and ordering.
+type allocFileReader struct {
+	file  *os.File
+	dec   *json.Decoder
+	root  *common.Hash // State root announced by the file, if any
+	last  *common.Hash // Hash of the last account read, nil if none yet
+	count uint64       // Number of accounts read
+}
+
+// openAllocFile opens a genesis allocation file, gzip-compressed if it has the
+// .gz extension.
+func openAllocFile(path string) (*allocFileReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		if reader, err = gzip.NewReader(file); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return &allocFileReader{file: file, dec: json.NewDecoder(reader)}, nil
+}
+
+// next reads the next account from the file, returning io.EOF if there are no
+// more accounts.
+func (r *allocFileReader) next() (*allocFileAccount, error) {
+	var entry state.DumpAccount
+	if err := r.dec.Decode(&entry); err != nil {
+		return nil, err
+	}
+	if entry.Address == nil {
+		// The state root heading the dumps is accepted as the first entry only
+		if r.count == 0 && r.root == nil && entry.Balance == "" && len(entry.Root) == common.HashLength {
+			root := common.BytesToHash(entry.Root)
+			r.root = &root
+			return r.next()
+		}
+		return nil, fmt.Errorf("genesis account %d has no address", r.count)
+	}
+	acc := &allocFileAccount{
+		address: *entry.Address,
+		hash:    crypto.Keccak256Hash(entry.Address.Bytes()),
+		code:    entry.Code,
+	}
+	if len(entry.AddressHash) > 0 && !bytes.Equal(entry.AddressHash, acc.hash[:]) {
+		return nil, fmt.Errorf("address hash mismatch of genesis account %x", acc.address)
+	}
+	if r.last != nil && bytes.Compare(acc.hash[:], r.last[:]) <= 0 {
+		return nil, fmt.Errorf("genesis account %x not ordered by address hash", acc.address)
+	}
+	balance, ok := math.ParseBig256(entry.Balance)
+	if !ok || balance.Sign() < 0 {
+		return nil, fmt.Errorf("invalid balance of genesis account %x: %q", acc.address, entry.Balance)
+	}
+	acc.account.Balance, _ = uint256.FromBig(balance)
+	acc.account.Nonce = entry.Nonce
+	acc.account.CodeHash = crypto.Keccak256(entry.Code)
+	if len(entry.CodeHash) > 0 && !bytes.Equal(entry.CodeHash, acc.account.CodeHash) {
+		return nil, fmt.Errorf("code hash mismatch of genesis account %x", acc.address)
+	}
+	if len(entry.Root) > 0 {
+		root := common.BytesToHash(entry.Root)
+		acc.root = &root
+	}
+	for key, val := range entry.Storage {
+		value := common.TrimLeftZeroes(common.FromHex(val))
+		if len(value) > common.HashLength {
+			return nil, fmt.Errorf("invalid slot %x of genesis account %x", key, acc.address)
+		}
+		if len(value) == 0 {
+			continue
+		}
+		blob, _ := rlp.EncodeToBytes(value)
+		acc.slots = append(acc.slots, allocFileSlot{hash: crypto.Keccak256Hash(key[:]), value: blob})
+	}
+	slices.SortFunc(acc.slots, func(a, b allocFileSlot) int {
+		return a.hash.Cmp(b.hash)
+	})
+	r.last = &acc.hash
+	r.count++
+	return acc, nil
+}
+
+// close releases the file resources.
+func (r *allocFileReader) close() error {
+	return r.file.Close()
+}
+
+// commitAllocFile streams the accounts of a genesis allocation file into stack
+// tries, returning the resulting state root. If a database is given, the trie
+// nodes and the contract codes are written into it as well, so the genesis state
+// is never held in memory as a whole.
+func commitAllocFile(path string, db ethdb.KeyValueStore, scheme string) (common.Hash, error) {
+	r, err := openAllocFile(path)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	defer r.close()
+
+	var (
+		batch  ethdb.Batch
+		start  = time.Now()
+		logged = time.Now()
+	)
+	onTrieNode := func(owner common.Hash) trie.OnTrieNode {
+		if batch == nil {
+			return nil
+		}
+		return func(path []byte, hash common.Hash, blob []byte) {
+			rawdb.WriteTrieNode(batch, owner, path, hash, blob, scheme)
+		}
+	}
+	if db != nil {
+		batch = db.NewBatch()
+	}
+	accTrie := trie.NewStackTrie(onTrieNode(common.Hash{}))
+	for {
+		acc, err := r.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return common.Hash{}, err
+		}
+		acc.account.Root = types.EmptyRootHash
+		if len(acc.slots) > 0 {
+			stTrie := trie.NewStackTrie(onTrieNode(acc.hash))
+			for _, slot := range acc.slots {
+				if err := stTrie.Update(slot.hash[:], slot.value); err != nil {
+					return common.Hash{}, err
+				}
+			}
+			acc.account.Root = stTrie.Hash()
+		}
+		if acc.root != nil && *acc.root != acc.account.Root {
+			return common.Hash{}, fmt.Errorf("storage root mismatch of genesis account %x: have %x, want %x", acc.address, acc.account.Root, *acc.root)
+		}
+		blob, err := rlp.EncodeToBytes(&acc.account)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		if err := accTrie.Update(acc.hash[:], blob); err != nil {
+			return common.Hash{}, err
+		}
+		if batch != nil {
+			if len(acc.code) > 0 {
+				rawdb.WriteCode(batch, common.BytesToHash(acc.account.CodeHash), acc.code)
+			}
+			if batch.ValueSize() > ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					return common.Hash{}, err
+				}
+				batch.Reset()
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Processing genesis allocation", "accounts", r.count, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	root := accTrie.Hash()
+	if r.root != nil && *r.root != root {
+		return common.Hash{}, fmt.Errorf("genesis state root mismatch: have %x, want %x", root, *r.root)
+	}
+	if batch != nil {
+		if err := batch.Write(); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	log.Info("Processed genesis allocation", "accounts", r.count, "root", root, "elapsed", common.PrettyDuration(time.Since(start)))
+	return root, nil
+}
+
+// allocFileRoot returns the state root of the genesis allocation file. As it
+// requires a full pass over the file, the root is cached after the first call.
+func (g *Genesis) allocFileRoot() (common.Hash, error) {
+	if g.allocRoot != nil {
+		return *g.allocRoot, nil
+	}
+	if len(g.Alloc) > 0 || g.StateHash != nil {
+		return common.Hash{}, errors.New("genesis allocation file cannot be used along with alloc or state hash")
+	}
+	if g.Config != nil && g.IsVerkle() {
+		return common.Hash{}, errors.New("genesis allocation file is not supported with verkle")
+	}
+	root, err := commitAllocFile(g.AllocFile, nil, "")
+	if err != nil {
+		return common.Hash{}, err
+	}
+	g.allocRoot = &root
+	return root, nil
+}
+
+// flushAllocFile is the streaming counterpart of flushAlloc, writing the states
+// of the genesis allocation file directly into the database.
+func flushAllocFile(path string, db ethdb.Database, triedb *triedb.Database, root common.Hash, blockhash common.Hash) error {
+	have, err := commitAllocFile(path, db, triedb.Scheme())
+	if err != nil {
+		return err
+	}
+	if have != root {
+		return fmt.Errorf("genesis allocation file changed: state root %x, want %x", have, root)
+	}
+	// The trie nodes were written bypassing the path database, reset its layers
+	// on top of the persisted state.
+	if triedb.Scheme() == rawdb.PathScheme && root != types.EmptyRootHash {
+		if err := triedb.Enable(root); err != nil {
+			return err
+		}
+	}
+	// The allocation itself is not persisted as the genesis specification, it's
+	// read back as a genesis with a state hash only.
+	rawdb.WriteGenesisStateSpec(db, blockhash, []byte("null"))
+	return nil
+}
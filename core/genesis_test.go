@@ -20,15 +20,20 @@ import (
 	"bytes"
 	"encoding/json"
 	"math/big"
+	"os"
+	"path/filepath"
 	"reflect"
+	"slices"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/triedb"
@@ -326,3 +331,127 @@ func TestVerkleGenesisCommit(t *testing.T) {
 		t.Fatal("could not find node")
 	}
 }
+
+// writeAllocFile writes the genesis allocation into a file in the format of the
+// iterative state dumps.
+func writeAllocFile(t *testing.T, path string, alloc types.GenesisAlloc, root *common.Hash) {
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create allocation file: %v", err)
+	}
+	defer file.Close()
+
+	enc := json.NewEncoder(file)
+	if root != nil {
+		enc.Encode(struct {
+			Root common.Hash `json:"root"`
+		}{*root})
+	}
+	var addrs []common.Address
+	for addr := range alloc {
+		addrs = append(addrs, addr)
+	}
+	slices.SortFunc(addrs, func(a, b common.Address) int {
+		return crypto.Keccak256Hash(a[:]).Cmp(crypto.Keccak256Hash(b[:]))
+	})
+	for _, addr := range addrs {
+		account := alloc[addr]
+		entry := state.DumpAccount{
+			Balance: account.Balance.String(),
+			Nonce:   account.Nonce,
+			Code:    account.Code,
+			Address: &addr,
+		}
+		if len(account.Storage) > 0 {
+			entry.Storage = make(map[common.Hash]string)
+			for key, value := range account.Storage {
+				entry.Storage[key] = common.Bytes2Hex(value[:])
+			}
+		}
+		enc.Encode(entry)
+	}
+}
+
+func TestGenesisAllocFile(t *testing.T) {
+	testGenesisAllocFile(t, rawdb.HashScheme)
+	testGenesisAllocFile(t, rawdb.PathScheme)
+}
+
+func testGenesisAllocFile(t *testing.T, scheme string) {
+	alloc := make(types.GenesisAlloc)
+	for i := 1; i <= 50; i++ {
+		account := types.Account{Balance: big.NewInt(int64(i)), Nonce: uint64(i)}
+		if i%3 == 0 {
+			account.Code = []byte{0x60, byte(i)}
+		}
+		if i%5 == 0 {
+			account.Storage = make(map[common.Hash]common.Hash)
+			for j := 1; j <= 10; j++ {
+				account.Storage[common.Hash{byte(j)}] = common.Hash{31: byte(i * j)}
+			}
+		}
+		alloc[common.Address{byte(i)}] = account
+	}
+	var (
+		want    = &Genesis{Config: params.TestChainConfig, Alloc: alloc}
+		block   = want.ToBlock()
+		root    = block.Root()
+		path    = filepath.Join(t.TempDir(), "alloc.jsonl")
+		genesis = &Genesis{Config: params.TestChainConfig, AllocFile: path}
+	)
+	writeAllocFile(t, path, alloc, &root)
+
+	db := rawdb.NewMemoryDatabase()
+	tdb := triedb.NewDatabase(db, newDbConfig(scheme))
+	_, hash, err := SetupGenesisBlock(db, tdb, genesis)
+	if err != nil {
+		t.Fatalf("Failed to setup genesis: %v", err)
+	}
+	if hash != block.Hash() {
+		t.Fatalf("Genesis hash mismatch: have %x, want %x", hash, block.Hash())
+	}
+	// The streamed state must be fully accessible
+	statedb, err := state.New(root, state.NewDatabaseWithNodeDB(db, tdb), nil)
+	if err != nil {
+		t.Fatalf("Failed to open genesis state: %v", err)
+	}
+	for addr, account := range alloc {
+		if statedb.GetBalance(addr).ToBig().Cmp(account.Balance) != 0 || statedb.GetNonce(addr) != account.Nonce {
+			t.Fatalf("Account %x mismatch", addr)
+		}
+		if !bytes.Equal(statedb.GetCode(addr), account.Code) {
+			t.Fatalf("Code of account %x mismatch", addr)
+		}
+		for key, value := range account.Storage {
+			if statedb.GetState(addr, key) != value {
+				t.Fatalf("Slot %x of account %x mismatch", key, addr)
+			}
+		}
+	}
+	if !tdb.Initialized(root) {
+		t.Fatal("Genesis state not initialized")
+	}
+	// Setting up the same genesis again is accepted
+	if _, _, err := SetupGenesisBlock(db, tdb, &Genesis{Config: params.TestChainConfig, AllocFile: path}); err != nil {
+		t.Fatalf("Failed to setup existing genesis: %v", err)
+	}
+	if _, err := ReadGenesis(db); err != nil {
+		t.Fatalf("Failed to read genesis: %v", err)
+	}
+	// Invalid allocation files are rejected
+	writeAllocFile(t, path, alloc, &common.Hash{0x1})
+	if _, _, err := SetupGenesisBlock(rawdb.NewMemoryDatabase(), tdb, &Genesis{Config: params.TestChainConfig, AllocFile: path}); err == nil {
+		t.Fatal("Allocation file with state root mismatch accepted")
+	}
+	writeAllocFile(t, path, alloc, nil)
+	blob, _ := os.ReadFile(path)
+	lines := bytes.Split(bytes.TrimSpace(blob), []byte("\n"))
+	lines[0], lines[1] = lines[1], lines[0]
+	os.WriteFile(path, bytes.Join(lines, []byte("\n")), 0644)
+	if _, _, err := SetupGenesisBlock(rawdb.NewMemoryDatabase(), tdb, &Genesis{Config: params.TestChainConfig, AllocFile: path}); err == nil {
+		t.Fatal("Unordered allocation file accepted")
+	}
+	if _, _, err := SetupGenesisBlock(rawdb.NewMemoryDatabase(), tdb, &Genesis{Config: params.TestChainConfig, Alloc: alloc, AllocFile: path}); err == nil {
+		t.Fatal("Allocation file along with alloc accepted")
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// HistoryPruneMerge is the history pruning boundary selecting the first block
+// after the merge.
+const HistoryPruneMerge = "merge"
+
+// errChainNotMerged is returned if the history is to be pruned up to the merge,
+// but the chain has not transitioned to proof-of-stake yet.
+var errChainNotMerged = errors.New("chain is not merged")
+
+// ValidateHistoryPrune checks the syntax of a history pruning boundary, either
+// a block number or "merge".
+func ValidateHistoryPrune(boundary string) error {
+	if boundary == HistoryPruneMerge {
+		return nil
+	}
+	if _, err := strconv.ParseUint(boundary, 10, 64); err != nil {
+		return fmt.Errorf("invalid history pruning boundary %q, block number or %q expected", boundary, HistoryPruneMerge)
+	}
+	return nil
+}
+
+// ResolveHistoryPrune resolves a history pruning boundary to the number of the
+// first block whose body and receipts are to be retained.
+func ResolveHistoryPrune(db ethdb.Reader, boundary string) (uint64, error) {
+	if err := ValidateHistoryPrune(boundary); err != nil {
+		return 0, err
+	}
+	if boundary != HistoryPruneMerge {
+		return strconv.ParseUint(boundary, 10, 64)
+	}
+	// Search the canonical chain for the first header without difficulty
+	head := rawdb.ReadHeadHeader(db)
+	if head == nil {
+		return 0, errors.New("no head header found")
+	}
+	if head.Difficulty.Sign() != 0 {
+		return 0, errChainNotMerged
+	}
+	var missing error
+	number := sort.Search(int(head.Number.Uint64()), func(i int) bool {
+		header := rawdb.ReadHeader(db, rawdb.ReadCanonicalHash(db, uint64(i)), uint64(i))
+		if header == nil {
+			missing = fmt.Errorf("missing header %d", i)
+			return true
+		}
+		return header.Difficulty.Sign() == 0
+	})
+	if missing != nil {
+		return 0, missing
+	}
+	return uint64(number), nil
+}
+
+// HistoryCutoff returns the number of the first block whose body and receipts
+// are retained in the database, zero if the chain history is not pruned.
+func HistoryCutoff(db ethdb.AncientReaderOp) uint64 {
+	tail, err := db.Tail()
+	if err != nil {
+		return 0 // No ancient store
+	}
+	return tail
+}
+
+// PruneHistory removes the block bodies and receipts below the cutoff from the
+// ancient store, along with the transaction indexes of those blocks. Headers and
+// canonical hashes are retained. Only blocks already moved into the ancient store
+// can be pruned.
+//
+// The transaction indexes are removed first, as their removal needs the bodies.
+// If the given channel is closed meanwhile, the pruning is aborted.
+func PruneHistory(db ethdb.Database, cutoff uint64, interrupt chan struct{}) error {
+	frozen, err := db.Ancients()
+	if err != nil {
+		return err
+	}
+	if cutoff > frozen {
+		return fmt.Errorf("history cutoff %d above the ancient store head %d", cutoff, frozen)
+	}
+	tail, err := db.Tail()
+	if err != nil {
+		return err
+	}
+	if cutoff <= tail {
+		return nil
+	}
+	if txTail := rawdb.ReadTxIndexTail(db); txTail != nil && *txTail < cutoff {
+		rawdb.UnindexTransactions(db, *txTail, cutoff, interrupt, true)
+		if txTail := rawdb.ReadTxIndexTail(db); txTail == nil || *txTail < cutoff {
+			return errors.New("history pruning interrupted")
+		}
+	}
+	if _, err := db.TruncateTail(cutoff); err != nil {
+		return err
+	}
+	log.Info("Pruned chain history", "cutoff", cutoff, "blocks", cutoff-tail)
+	return nil
+}
+
+// pruneHistory prunes the chain history below the cutoff, serialized with the
+// transaction indexing.
+func (bc *BlockChain) pruneHistory(cutoff uint64) error {
+	if bc.txIndexer != nil {
+		bc.txIndexer.lock.Lock()
+		defer bc.txIndexer.lock.Unlock()
+	}
+	return PruneHistory(bc.db, cutoff, bc.quit)
+}
+
+// historyPruneLoop prunes the chain history below the boundary in the background,
+// as the blocks are moved into the ancient store.
+func (bc *BlockChain) historyPruneLoop(boundary string) {
+	defer bc.wg.Done()
+
+	var (
+		cutoff   uint64
+		resolved bool
+
+		headCh = make(chan ChainHeadEvent)
+		sub    = bc.SubscribeChainHeadEvent(headCh)
+	)
+	defer sub.Unsubscribe()
+
+	prune := func() {
+		if !resolved {
+			var err error
+			if cutoff, err = ResolveHistoryPrune(bc.db, boundary); err != nil {
+				if !errors.Is(err, errChainNotMerged) {
+					log.Warn("Failed to resolve history pruning boundary", "boundary", boundary, "err", err)
+				}
+				return
+			}
+			resolved = true
+			log.Info("Resolved history pruning boundary", "boundary", boundary, "cutoff", cutoff)
+		}
+		frozen, err := bc.db.Ancients()
+		if err != nil {
+			return
+		}
+		if target := min(cutoff, frozen); target > HistoryCutoff(bc.db) {
+			if err := bc.pruneHistory(target); err != nil {
+				log.Warn("Failed to prune chain history", "cutoff", target, "err", err)
+			}
+		}
+	}
+	// Run the pruning in the background, not to block the chain head feed
+	var done chan struct{}
+	schedule := func() {
+		if done == nil {
+			done = make(chan struct{})
+			go func(done chan struct{}) {
+				defer close(done)
+				prune()
+			}(done)
+		}
+	}
+	schedule()
+	for {
+		select {
+		case <-headCh:
+			schedule()
+		case <-done:
+			done = nil
+		case <-bc.quit:
+			if done != nil {
+				<-done
+			}
+			return
+		}
+	}
+}
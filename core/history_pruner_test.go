@@ -0,0 +1,176 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the block bodies, receipts and transaction indexes below the cutoff
+// are pruned, while the headers and the history above are retained.
+func TestPruneHistory(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   types.GenesisAlloc{address: {Balance: big.NewInt(params.Ether)}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		engine = ethash.NewFaker()
+		signer = types.LatestSigner(gspec.Config)
+	)
+	_, blocks, receipts := GenerateChainWithGenesis(gspec, engine, 128, func(i int, gen *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), common.Address{0xde, 0xad}, big.NewInt(1), params.TxGas, gen.BaseFee(), nil), signer, key)
+		gen.AddTx(tx)
+	})
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	chain, err := NewBlockChain(db, DefaultCacheConfigWithScheme(rawdb.HashScheme), gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if n, err := chain.InsertHeaderChain(headers); err != nil {
+		t.Fatalf("failed to insert header %d: %v", n, err)
+	}
+	if n, err := chain.InsertReceiptChain(blocks, receipts, 100); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	rawdb.IndexTransactions(db, 0, 129, nil, false)
+
+	frozen, _ := db.Ancients()
+	if err := PruneHistory(db, frozen+1, nil); err == nil {
+		t.Fatal("pruning beyond the ancient store succeeded")
+	}
+	cutoff := uint64(64)
+	if err := chain.pruneHistory(cutoff); err != nil {
+		t.Fatalf("failed to prune history: %v", err)
+	}
+	if have := chain.HistoryCutoff(); have != cutoff {
+		t.Fatalf("wrong history cutoff: have %d, want %d", have, cutoff)
+	}
+	if tail := rawdb.ReadTxIndexTail(db); tail == nil || *tail != cutoff {
+		t.Fatalf("wrong tx index tail: have %v, want %d", tail, cutoff)
+	}
+	for _, block := range blocks {
+		var (
+			number = block.NumberU64()
+			pruned = number < cutoff
+		)
+		if chain.GetHeaderByNumber(number) == nil {
+			t.Fatalf("header %d missing", number)
+		}
+		if have := chain.GetBlockByNumber(number) == nil; have != pruned {
+			t.Errorf("block %d: pruned %t, want %t", number, have, pruned)
+		}
+		if have := chain.GetReceiptsByHash(block.Hash()) == nil; have != pruned {
+			t.Errorf("receipts %d: pruned %t, want %t", number, have, pruned)
+		}
+		if have := rawdb.ReadTxLookupEntry(db, block.Transactions()[0].Hash()) == nil; have != pruned {
+			t.Errorf("tx index %d: pruned %t, want %t", number, have, pruned)
+		}
+	}
+	// The indexing should be finished with the pruned blocks excluded
+	indexer := &txIndexer{db: db}
+	indexer.run(rawdb.ReadTxIndexTail(db), 128, make(chan struct{}), make(chan struct{}))
+	if tail := rawdb.ReadTxIndexTail(db); tail == nil || *tail != cutoff {
+		t.Fatalf("wrong tx index tail after indexing: have %v, want %d", tail, cutoff)
+	}
+	if progress := indexer.report(128, rawdb.ReadTxIndexTail(db)); !progress.Done() {
+		t.Fatalf("indexing not finished: %+v", progress)
+	}
+
+	// Stale indexes of pruned blocks should be reported
+	tx := blocks[10].Transactions()[0]
+	rawdb.WriteTxLookupEntries(db, blocks[10].NumberU64(), []common.Hash{tx.Hash()})
+	if _, _, err := chain.GetTransactionLookup(tx.Hash()); !errors.Is(err, ErrHistoryPruned) {
+		t.Errorf("stale transaction lookup: have %v, want %v", err, ErrHistoryPruned)
+	}
+	if err := chain.ExportN(io.Discard, 0, 100); !errors.Is(err, ErrHistoryPruned) {
+		t.Errorf("pruned export: have %v, want %v", err, ErrHistoryPruned)
+	}
+	if err := chain.ExportN(io.Discard, cutoff, 100); err != nil {
+		t.Errorf("failed to export retained blocks: %v", err)
+	}
+	// Pruning below the cutoff is a noop
+	if err := PruneHistory(db, cutoff/2, nil); err != nil {
+		t.Fatalf("failed to prune history: %v", err)
+	}
+	if have := HistoryCutoff(db); have != cutoff {
+		t.Fatalf("wrong history cutoff: have %d, want %d", have, cutoff)
+	}
+}
+
+func TestResolveHistoryPrune(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	write := func(number uint64, difficulty int64) {
+		header := &types.Header{Number: new(big.Int).SetUint64(number), Difficulty: big.NewInt(difficulty)}
+		rawdb.WriteHeader(db, header)
+		rawdb.WriteCanonicalHash(db, header.Hash(), number)
+		rawdb.WriteHeadHeaderHash(db, header.Hash())
+	}
+	for i := uint64(0); i < 10; i++ {
+		write(i, 1)
+	}
+	if _, err := ResolveHistoryPrune(db, HistoryPruneMerge); !errors.Is(err, errChainNotMerged) {
+		t.Fatalf("unmerged chain: have %v, want %v", err, errChainNotMerged)
+	}
+	for i := uint64(10); i < 20; i++ {
+		write(i, 0)
+	}
+	tests := []struct {
+		boundary string
+		cutoff   uint64
+		err      bool
+	}{
+		{HistoryPruneMerge, 10, false},
+		{"15", 15, false},
+		{"0", 0, false},
+		{"-1", 0, true},
+		{"shanghai", 0, true},
+	}
+	for _, test := range tests {
+		cutoff, err := ResolveHistoryPrune(db, test.boundary)
+		if (err != nil) != test.err {
+			t.Errorf("boundary %q: have error %v, want %t", test.boundary, err, test.err)
+		}
+		if cutoff != test.cutoff {
+			t.Errorf("boundary %q: have cutoff %d, want %d", test.boundary, cutoff, test.cutoff)
+		}
+	}
+}
@@ -164,6 +164,29 @@ func DeleteSnapshotGenerator(db ethdb.KeyValueWriter) {
 	}
 }
 
+// ReadSnapshotGeneratorRanges retrieves the serialized progress of the snapshot
+// generator account ranges saved at the last shutdown.
+func ReadSnapshotGeneratorRanges(db ethdb.KeyValueReader) []byte {
+	data, _ := db.Get(snapshotGeneratorRangesKey)
+	return data
+}
+
+// WriteSnapshotGeneratorRanges stores the serialized progress of the snapshot
+// generator account ranges to save at shutdown.
+func WriteSnapshotGeneratorRanges(db ethdb.KeyValueWriter, ranges []byte) {
+	if err := db.Put(snapshotGeneratorRangesKey, ranges); err != nil {
+		log.Crit("Failed to store snapshot generator ranges", "err", err)
+	}
+}
+
+// DeleteSnapshotGeneratorRanges deletes the serialized progress of the snapshot
+// generator account ranges saved at the last shutdown.
+func DeleteSnapshotGeneratorRanges(db ethdb.KeyValueWriter) {
+	if err := db.Delete(snapshotGeneratorRangesKey); err != nil {
+		log.Crit("Failed to remove snapshot generator ranges", "err", err)
+	}
+}
+
 // ReadSnapshotRecoveryNumber retrieves the block number of the last persisted
 // snapshot layer.
 func ReadSnapshotRecoveryNumber(db ethdb.KeyValueReader) *uint64 {
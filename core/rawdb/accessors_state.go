@@ -264,3 +264,142 @@ func WriteStateHistory(db ethdb.AncientWriter, id uint64, meta []byte, accountIn
 		return nil
 	})
 }
+
+// ReadStateHistoryIndexTail retrieves the id of the newest state history which
+// is not indexed. All the histories after it are indexed. Nil is returned if
+// the state histories are not indexed at all.
+func ReadStateHistoryIndexTail(db ethdb.KeyValueReader) *uint64 {
+	data, _ := db.Get(stateHistoryIndexTailKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteStateHistoryIndexTail stores the id of the newest unindexed state history
+// into database.
+func WriteStateHistoryIndexTail(db ethdb.KeyValueWriter, id uint64) {
+	if err := db.Put(stateHistoryIndexTailKey, encodeBlockNumber(id)); err != nil {
+		log.Crit("Failed to store the state history index tail", "err", err)
+	}
+}
+
+// DeleteStateHistoryIndexTail deletes the state history index tail, marking
+// the state histories as not indexed.
+func DeleteStateHistoryIndexTail(db ethdb.KeyValueWriter) {
+	if err := db.Delete(stateHistoryIndexTailKey); err != nil {
+		log.Crit("Failed to delete the state history index tail", "err", err)
+	}
+}
+
+// readNextStateHistory returns the first id greater than the given one among
+// the index entries with the provided key prefix.
+func readNextStateHistory(db ethdb.Iteratee, prefix []byte, id uint64) (uint64, bool) {
+	it := db.NewIterator(prefix, encodeBlockNumber(id+1))
+	defer it.Release()
+
+	if !it.Next() {
+		return 0, false
+	}
+	key := it.Key()
+	if len(key) != len(prefix)+8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(key[len(prefix):]), true
+}
+
+// ReadStateAccountHistoryIndex returns the id of the first state history after
+// the given one in which the account was modified.
+func ReadStateAccountHistoryIndex(db ethdb.Iteratee, address common.Address, id uint64) (uint64, bool) {
+	prefix := append(append([]byte{}, stateHistoryAccountIndexPrefix...), address.Bytes()...)
+	return readNextStateHistory(db, prefix, id)
+}
+
+// WriteStateAccountHistoryIndex marks the account as modified in the given
+// state history.
+func WriteStateAccountHistoryIndex(db ethdb.KeyValueWriter, address common.Address, id uint64) {
+	if err := db.Put(stateHistoryAccountIndexKey(address, id), nil); err != nil {
+		log.Crit("Failed to store account history index", "err", err)
+	}
+}
+
+// DeleteStateAccountHistoryIndex deletes the account index entry of the given
+// state history.
+func DeleteStateAccountHistoryIndex(db ethdb.KeyValueWriter, address common.Address, id uint64) {
+	if err := db.Delete(stateHistoryAccountIndexKey(address, id)); err != nil {
+		log.Crit("Failed to delete account history index", "err", err)
+	}
+}
+
+// ReadStateStorageHistoryIndex returns the id of the first state history after
+// the given one in which the storage slot was modified.
+func ReadStateStorageHistoryIndex(db ethdb.Iteratee, address common.Address, slot common.Hash, id uint64) (uint64, bool) {
+	prefix := append(append([]byte{}, stateHistoryStorageIndexPrefix...), address.Bytes()...)
+	return readNextStateHistory(db, append(prefix, slot.Bytes()...), id)
+}
+
+// WriteStateStorageHistoryIndex marks the storage slot as modified in the given
+// state history.
+func WriteStateStorageHistoryIndex(db ethdb.KeyValueWriter, address common.Address, slot common.Hash, id uint64) {
+	if err := db.Put(stateHistoryStorageIndexKey(address, slot, id), nil); err != nil {
+		log.Crit("Failed to store storage history index", "err", err)
+	}
+}
+
+// DeleteStateStorageHistoryIndex deletes the storage index entry of the given
+// state history.
+func DeleteStateStorageHistoryIndex(db ethdb.KeyValueWriter, address common.Address, slot common.Hash, id uint64) {
+	if err := db.Delete(stateHistoryStorageIndexKey(address, slot, id)); err != nil {
+		log.Crit("Failed to delete storage history index", "err", err)
+	}
+}
+
+// ReadStateIncompleteHistoryIndex returns the id of the first state history
+// after the given one in which the storage changes of the account are not
+// recorded.
+func ReadStateIncompleteHistoryIndex(db ethdb.Iteratee, address common.Address, id uint64) (uint64, bool) {
+	prefix := append(append([]byte{}, stateHistoryIncompleteIndexPrefix...), address.Bytes()...)
+	return readNextStateHistory(db, prefix, id)
+}
+
+// WriteStateIncompleteHistoryIndex marks the storage changes of the account as
+// not recorded in the given state history.
+func WriteStateIncompleteHistoryIndex(db ethdb.KeyValueWriter, address common.Address, id uint64) {
+	if err := db.Put(stateHistoryIncompleteIndexKey(address, id), nil); err != nil {
+		log.Crit("Failed to store incomplete history index", "err", err)
+	}
+}
+
+// DeleteStateIncompleteHistoryIndex deletes the incomplete index entry of the
+// given state history.
+func DeleteStateIncompleteHistoryIndex(db ethdb.KeyValueWriter, address common.Address, id uint64) {
+	if err := db.Delete(stateHistoryIncompleteIndexKey(address, id)); err != nil {
+		log.Crit("Failed to delete incomplete history index", "err", err)
+	}
+}
+
+// DeleteStateHistoryIndex wipes all the index entries of the state histories
+// along with the index tail from the database.
+func DeleteStateHistoryIndex(db ethdb.KeyValueStore) error {
+	batch := db.NewBatch()
+	for _, prefix := range [][]byte{stateHistoryAccountIndexPrefix, stateHistoryStorageIndexPrefix, stateHistoryIncompleteIndexPrefix} {
+		it := db.NewIterator(prefix, nil)
+		for it.Next() {
+			batch.Delete(it.Key())
+			if batch.ValueSize() > ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					it.Release()
+					return err
+				}
+				batch.Reset()
+			}
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return err
+		}
+	}
+	DeleteStateHistoryIndexTail(batch)
+	return batch.Write()
+}
@@ -480,3 +480,69 @@ func TestFreezerCloseSync(t *testing.T) {
 		t.Fatalf("want %v, have %v", have, want)
 	}
 }
+
+func TestFreezerPrunableTables(t *testing.T) {
+	t.Parallel()
+
+	var (
+		dir      = t.TempDir()
+		tables   = map[string]bool{"a": true, "b": true}
+		prunable = map[string]bool{"b": true}
+	)
+	f, err := newFreezer(dir, "", false, 2049, tables, prunable)
+	if err != nil {
+		t.Fatal("can't open freezer", err)
+	}
+	_, err = f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		for i := uint64(0); i < 100; i++ {
+			if err := op.AppendRaw("a", i, bytes.Repeat([]byte{byte(i)}, 100)); err != nil {
+				return err
+			}
+			if err := op.AppendRaw("b", i, bytes.Repeat([]byte{byte(i)}, 100)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal("can't write items", err)
+	}
+	if _, err := f.TruncateTail(50); err != nil {
+		t.Fatal("can't truncate tail", err)
+	}
+	check := func(f *Freezer) {
+		t.Helper()
+
+		if tail, _ := f.Tail(); tail != 50 {
+			t.Fatalf("wrong tail: have %d, want %d", tail, 50)
+		}
+		if frozen, _ := f.Ancients(); frozen != 100 {
+			t.Fatalf("wrong head: have %d, want %d", frozen, 100)
+		}
+		for i := uint64(0); i < 100; i++ {
+			if _, err := f.Ancient("a", i); err != nil {
+				t.Fatalf("unprunable item %d missing: %v", i, err)
+			}
+			_, err := f.Ancient("b", i)
+			if i < 50 && err != errOutOfBounds {
+				t.Fatalf("pruned item %d: have %v, want %v", i, err, errOutOfBounds)
+			}
+			if i >= 50 && err != nil {
+				t.Fatalf("retained item %d missing: %v", i, err)
+			}
+		}
+	}
+	check(f)
+	f.Close()
+
+	// Reopen the freezer, the unprunable tables must not be repaired
+	// to the tail of the prunable ones
+	for _, readonly := range []bool{false, true} {
+		f, err = newFreezer(dir, "", readonly, 2049, tables, prunable)
+		if err != nil {
+			t.Fatal("can't reopen freezer", err)
+		}
+		check(f)
+		f.Close()
+	}
+}
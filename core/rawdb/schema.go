@@ -64,6 +64,10 @@ var (
 	// snapshotGeneratorKey tracks the snapshot generation marker across restarts.
 	snapshotGeneratorKey = []byte("SnapshotGenerator")
 
+	// snapshotGeneratorRangesKey tracks the progress of the concurrently generated
+	// account ranges of the snapshot across restarts.
+	snapshotGeneratorRangesKey = []byte("SnapshotGeneratorRanges")
+
 	// snapshotRecoveryKey tracks the snapshot recovery marker across restarts.
 	snapshotRecoveryKey = []byte("SnapshotRecovery")
 
@@ -96,6 +100,10 @@ var (
 	// snapSyncStatusFlagKey flags that status of snap sync.
 	snapSyncStatusFlagKey = []byte("SnapSyncStatus")
 
+	// stateHistoryIndexTailKey tracks the id of the newest state history which
+	// is not indexed (for path-based only).
+	stateHistoryIndexTailKey = []byte("StateHistoryIndexTail")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -117,6 +125,11 @@ var (
 	trieNodeStoragePrefix = []byte("O") // trieNodeStoragePrefix + accountHash + hexPath -> trie node
 	stateIDPrefix         = []byte("L") // stateIDPrefix + state root -> state id
 
+	// Indexes of the state histories in path-based storage scheme.
+	stateHistoryAccountIndexPrefix    = []byte("mA") // stateHistoryAccountIndexPrefix + address + id (uint64 big endian) -> nil
+	stateHistoryStorageIndexPrefix    = []byte("mS") // stateHistoryStorageIndexPrefix + address + slot hash + id (uint64 big endian) -> nil
+	stateHistoryIncompleteIndexPrefix = []byte("mI") // stateHistoryIncompleteIndexPrefix + address + id (uint64 big endian) -> nil
+
 	PreimagePrefix = []byte("secure-key-")       // PreimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-")  // config prefix for the db
 	genesisPrefix  = []byte("ethereum-genesis-") // genesis state prefix for the db
@@ -265,6 +278,25 @@ func stateIDKey(root common.Hash) []byte {
 	return append(stateIDPrefix, root.Bytes()...)
 }
 
+// stateHistoryAccountIndexKey = stateHistoryAccountIndexPrefix + address + id (uint64 big endian)
+func stateHistoryAccountIndexKey(address common.Address, id uint64) []byte {
+	key := append(append([]byte{}, stateHistoryAccountIndexPrefix...), address.Bytes()...)
+	return append(key, encodeBlockNumber(id)...)
+}
+
+// stateHistoryStorageIndexKey = stateHistoryStorageIndexPrefix + address + slot hash + id (uint64 big endian)
+func stateHistoryStorageIndexKey(address common.Address, slot common.Hash, id uint64) []byte {
+	key := append(append([]byte{}, stateHistoryStorageIndexPrefix...), address.Bytes()...)
+	key = append(key, slot.Bytes()...)
+	return append(key, encodeBlockNumber(id)...)
+}
+
+// stateHistoryIncompleteIndexKey = stateHistoryIncompleteIndexPrefix + address + id (uint64 big endian)
+func stateHistoryIncompleteIndexKey(address common.Address, id uint64) []byte {
+	key := append(append([]byte{}, stateHistoryIncompleteIndexPrefix...), address.Bytes()...)
+	return append(key, encodeBlockNumber(id)...)
+}
+
 // accountTrieNodeKey = trieNodeAccountPrefix + nodePath.
 func accountTrieNodeKey(path []byte) []byte {
 	return append(trieNodeAccountPrefix, path...)
@@ -0,0 +1,304 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"maps"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie/utils"
+	"github.com/holiman/uint256"
+)
+
+// mode specifies how a tree location has been accessed: the first bit is set
+// if the location has been read, the second one if it has been written.
+type mode byte
+
+const (
+	AccessWitnessReadFlag  = mode(1)
+	AccessWitnessWriteFlag = mode(2)
+)
+
+var zeroTreeIndex uint256.Int
+
+// AccessEvents lists the locations of the state that are being accessed
+// during the production of a block, as defined by the stateless gas schedule
+// of EIP-4762. Every location is charged once per block for reading and once
+// for writing, in units of verkle tree stems (branches) and leaves (chunks).
+//
+// Chunk fill costs are not charged, as the access events have no view of the
+// state to tell whether a written leaf was previously empty.
+type AccessEvents struct {
+	branches map[branchAccessKey]mode
+	chunks   map[chunkAccessKey]mode
+
+	pointCache *utils.PointCache
+}
+
+// NewAccessEvents creates an empty access event list, deriving tree keys with
+// the given commitment cache.
+func NewAccessEvents(pointCache *utils.PointCache) *AccessEvents {
+	return &AccessEvents{
+		branches:   make(map[branchAccessKey]mode),
+		chunks:     make(map[chunkAccessKey]mode),
+		pointCache: pointCache,
+	}
+}
+
+// Merge is used to merge the access events that were generated during the
+// execution of a tx, with the accumulation of all access events that were
+// generated during the execution of all txs preceding this one in a block.
+func (ae *AccessEvents) Merge(other *AccessEvents) {
+	for k := range other.branches {
+		ae.branches[k] |= other.branches[k]
+	}
+	for k, chunk := range other.chunks {
+		ae.chunks[k] |= chunk
+	}
+}
+
+// Keys returns the sorted list of tree keys that were touched during the
+// buildup of the access witness.
+func (ae *AccessEvents) Keys() [][]byte {
+	keys := make([][]byte, 0, len(ae.chunks))
+	for chunk := range ae.chunks {
+		basePoint := ae.pointCache.Get(chunk.addr[:])
+		key := utils.GetTreeKeyWithEvaluatedAddress(basePoint, &chunk.treeIndex, chunk.leafKey)
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, bytes.Compare)
+	return keys
+}
+
+// Copy returns an independent copy of the access events.
+func (ae *AccessEvents) Copy() *AccessEvents {
+	return &AccessEvents{
+		branches:   maps.Clone(ae.branches),
+		chunks:     maps.Clone(ae.chunks),
+		pointCache: ae.pointCache,
+	}
+}
+
+// AddAccount returns the gas to be charged for each of the currently cold
+// member fields of an account.
+func (ae *AccessEvents) AddAccount(addr common.Address, isWrite bool) uint64 {
+	var gas uint64
+	gas += ae.touchAddressAndChargeGas(addr, zeroTreeIndex, utils.VersionLeafKey, isWrite)
+	gas += ae.touchAddressAndChargeGas(addr, zeroTreeIndex, utils.BalanceLeafKey, isWrite)
+	gas += ae.touchAddressAndChargeGas(addr, zeroTreeIndex, utils.NonceLeafKey, isWrite)
+	gas += ae.touchAddressAndChargeGas(addr, zeroTreeIndex, utils.CodeKeccakLeafKey, isWrite)
+	gas += ae.touchAddressAndChargeGas(addr, zeroTreeIndex, utils.CodeSizeLeafKey, isWrite)
+	return gas
+}
+
+// MessageCallGas returns the gas to be charged for each of the currently
+// cold member fields of an account, that need to be touched when making a
+// message call to that account.
+func (ae *AccessEvents) MessageCallGas(destination common.Address) uint64 {
+	var gas uint64
+	gas += ae.touchAddressAndChargeGas(destination, zeroTreeIndex, utils.VersionLeafKey, false)
+	gas += ae.touchAddressAndChargeGas(destination, zeroTreeIndex, utils.CodeSizeLeafKey, false)
+	return gas
+}
+
+// ValueTransferGas returns the gas to be charged for each of the currently
+// cold balance member fields of the caller and the callee accounts.
+func (ae *AccessEvents) ValueTransferGas(callerAddr, targetAddr common.Address) uint64 {
+	var gas uint64
+	gas += ae.touchAddressAndChargeGas(callerAddr, zeroTreeIndex, utils.BalanceLeafKey, true)
+	gas += ae.touchAddressAndChargeGas(targetAddr, zeroTreeIndex, utils.BalanceLeafKey, true)
+	return gas
+}
+
+// ContractCreateInitGas returns the access gas costs for the initialization of
+// a contract creation.
+func (ae *AccessEvents) ContractCreateInitGas(addr common.Address, createSendsValue bool) uint64 {
+	var gas uint64
+	gas += ae.touchAddressAndChargeGas(addr, zeroTreeIndex, utils.VersionLeafKey, true)
+	gas += ae.touchAddressAndChargeGas(addr, zeroTreeIndex, utils.NonceLeafKey, true)
+	if createSendsValue {
+		gas += ae.touchAddressAndChargeGas(addr, zeroTreeIndex, utils.BalanceLeafKey, true)
+	}
+	return gas
+}
+
+// ContractCreateCompletedGas returns the access gas costs for the code hash
+// and code size writes that complete a contract creation.
+func (ae *AccessEvents) ContractCreateCompletedGas(addr common.Address) uint64 {
+	var gas uint64
+	gas += ae.touchAddressAndChargeGas(addr, zeroTreeIndex, utils.CodeKeccakLeafKey, true)
+	gas += ae.touchAddressAndChargeGas(addr, zeroTreeIndex, utils.CodeSizeLeafKey, true)
+	return gas
+}
+
+// AddTxOrigin adds the member fields of the sender account to the access event
+// list, so that cold accesses are not charged, since they are covered by the
+// intrinsic gas.
+func (ae *AccessEvents) AddTxOrigin(originAddr common.Address) {
+	ae.touchAddressAndChargeGas(originAddr, zeroTreeIndex, utils.VersionLeafKey, false)
+	ae.touchAddressAndChargeGas(originAddr, zeroTreeIndex, utils.BalanceLeafKey, true)
+	ae.touchAddressAndChargeGas(originAddr, zeroTreeIndex, utils.NonceLeafKey, true)
+	ae.touchAddressAndChargeGas(originAddr, zeroTreeIndex, utils.CodeKeccakLeafKey, false)
+	ae.touchAddressAndChargeGas(originAddr, zeroTreeIndex, utils.CodeSizeLeafKey, false)
+}
+
+// AddTxDestination adds the member fields of the recipient account to the
+// access event list, so that cold accesses are not charged, since they are
+// covered by the intrinsic gas.
+func (ae *AccessEvents) AddTxDestination(addr common.Address, sendsValue bool) {
+	ae.touchAddressAndChargeGas(addr, zeroTreeIndex, utils.VersionLeafKey, false)
+	ae.touchAddressAndChargeGas(addr, zeroTreeIndex, utils.BalanceLeafKey, sendsValue)
+	ae.touchAddressAndChargeGas(addr, zeroTreeIndex, utils.NonceLeafKey, false)
+	ae.touchAddressAndChargeGas(addr, zeroTreeIndex, utils.CodeKeccakLeafKey, false)
+	ae.touchAddressAndChargeGas(addr, zeroTreeIndex, utils.CodeSizeLeafKey, false)
+}
+
+// SlotGas returns the amount of gas to be charged for a cold storage access.
+func (ae *AccessEvents) SlotGas(addr common.Address, slot common.Hash, isWrite bool) uint64 {
+	treeIndex, subIndex := utils.StorageIndex(slot.Bytes())
+	return ae.touchAddressAndChargeGas(addr, *treeIndex, subIndex, isWrite)
+}
+
+// VersionGas adds the account's version to the accessed data, and returns the
+// amount of gas that it costs.
+func (ae *AccessEvents) VersionGas(addr common.Address, isWrite bool) uint64 {
+	return ae.touchAddressAndChargeGas(addr, zeroTreeIndex, utils.VersionLeafKey, isWrite)
+}
+
+// BalanceGas adds the account's balance to the accessed data, and returns the
+// amount of gas that it costs.
+func (ae *AccessEvents) BalanceGas(addr common.Address, isWrite bool) uint64 {
+	return ae.touchAddressAndChargeGas(addr, zeroTreeIndex, utils.BalanceLeafKey, isWrite)
+}
+
+// NonceGas adds the account's nonce to the accessed data, and returns the
+// amount of gas that it costs.
+func (ae *AccessEvents) NonceGas(addr common.Address, isWrite bool) uint64 {
+	return ae.touchAddressAndChargeGas(addr, zeroTreeIndex, utils.NonceLeafKey, isWrite)
+}
+
+// CodeSizeGas adds the account's code size to the accessed data, and returns
+// the amount of gas that it costs.
+func (ae *AccessEvents) CodeSizeGas(addr common.Address, isWrite bool) uint64 {
+	return ae.touchAddressAndChargeGas(addr, zeroTreeIndex, utils.CodeSizeLeafKey, isWrite)
+}
+
+// CodeHashGas adds the account's code hash to the accessed data, and returns
+// the amount of gas that it costs.
+func (ae *AccessEvents) CodeHashGas(addr common.Address, isWrite bool) uint64 {
+	return ae.touchAddressAndChargeGas(addr, zeroTreeIndex, utils.CodeKeccakLeafKey, isWrite)
+}
+
+// CodeChunksRangeGas touches every code chunk in the given range of the code,
+// returning the witness gas costs of the cold ones.
+func (ae *AccessEvents) CodeChunksRangeGas(contractAddr common.Address, startPC, size uint64, codeLen uint64, isWrite bool) uint64 {
+	// Note that in the case where the copied code is outside the range of the
+	// contract code but touches the last leaf with contract code in it, the
+	// last leaf of code is not included in the witness. The account's code
+	// size is already in it, so a stateless verifier can see that the code
+	// from the last leaf is not needed.
+	if size == 0 || startPC > codeLen {
+		return 0
+	}
+	endPC := startPC + size
+	if endPC > codeLen {
+		endPC = codeLen
+	}
+	if endPC > 0 {
+		endPC -= 1 // endPC is the last bytecode that will be touched
+	}
+	var gas uint64
+	for chunkNumber := startPC / 31; chunkNumber <= endPC/31; chunkNumber++ {
+		treeIndex, subIndex := utils.CodeChunkIndex(uint256.NewInt(chunkNumber))
+
+		var overflow bool
+		gas, overflow = math.SafeAdd(gas, ae.touchAddressAndChargeGas(contractAddr, *treeIndex, subIndex, isWrite))
+		if overflow {
+			panic("overflow when adding gas")
+		}
+	}
+	return gas
+}
+
+// touchAddressAndChargeGas adds any missing access event to the access event
+// list, and returns the cold access cost to be charged, if need be.
+func (ae *AccessEvents) touchAddressAndChargeGas(addr common.Address, treeIndex uint256.Int, subIndex byte, isWrite bool) uint64 {
+	branchRead, chunkRead, branchWrite, chunkWrite := ae.touchAddress(addr, treeIndex, subIndex, isWrite)
+
+	var gas uint64
+	if branchRead {
+		gas += params.WitnessBranchReadCost
+	}
+	if chunkRead {
+		gas += params.WitnessChunkReadCost
+	}
+	if branchWrite {
+		gas += params.WitnessBranchWriteCost
+	}
+	if chunkWrite {
+		gas += params.WitnessChunkWriteCost
+	}
+	return gas
+}
+
+// touchAddress adds any missing access event to the access event list,
+// returning which of the stem and leaf reads and writes were cold.
+func (ae *AccessEvents) touchAddress(addr common.Address, treeIndex uint256.Int, subIndex byte, isWrite bool) (bool, bool, bool, bool) {
+	branchKey := branchAccessKey{addr: addr, treeIndex: treeIndex}
+	chunkKey := chunkAccessKey{branchAccessKey: branchKey, leafKey: subIndex}
+
+	// Read access
+	var branchRead, chunkRead bool
+	if _, ok := ae.branches[branchKey]; !ok {
+		branchRead = true
+		ae.branches[branchKey] = AccessWitnessReadFlag
+	}
+	if _, ok := ae.chunks[chunkKey]; !ok {
+		chunkRead = true
+		ae.chunks[chunkKey] = AccessWitnessReadFlag
+	}
+	// Write access
+	var branchWrite, chunkWrite bool
+	if isWrite {
+		if ae.branches[branchKey]&AccessWitnessWriteFlag == 0 {
+			branchWrite = true
+			ae.branches[branchKey] |= AccessWitnessWriteFlag
+		}
+		if ae.chunks[chunkKey]&AccessWitnessWriteFlag == 0 {
+			chunkWrite = true
+			ae.chunks[chunkKey] |= AccessWitnessWriteFlag
+		}
+	}
+	return branchRead, chunkRead, branchWrite, chunkWrite
+}
+
+// branchAccessKey identifies a stem of the verkle tree, i.e. a group of 256
+// leaves of an account.
+type branchAccessKey struct {
+	addr      common.Address
+	treeIndex uint256.Int
+}
+
+// chunkAccessKey identifies a leaf of the verkle tree.
+type chunkAccessKey struct {
+	branchAccessKey
+	leafKey byte
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie/utils"
+)
+
+var (
+	testAddr  = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+	testAddr2 = common.HexToAddress("0x000000000000000000000000000000000000bbbb")
+)
+
+func TestAccountHeaderGas(t *testing.T) {
+	ae := NewAccessEvents(utils.NewPointCache(1024))
+
+	// A cold read is charged for the branch and the leaf
+	if gas := ae.VersionGas(testAddr, false); gas != params.WitnessBranchReadCost+params.WitnessChunkReadCost {
+		t.Fatalf("cold read: have %d, want %d", gas, params.WitnessBranchReadCost+params.WitnessChunkReadCost)
+	}
+	// A warm read is free
+	if gas := ae.VersionGas(testAddr, false); gas != 0 {
+		t.Fatalf("warm read: have %d, want 0", gas)
+	}
+	// Another leaf of the same branch is only charged for the leaf
+	if gas := ae.BalanceGas(testAddr, false); gas != params.WitnessChunkReadCost {
+		t.Fatalf("read in warm branch: have %d, want %d", gas, params.WitnessChunkReadCost)
+	}
+	// Writing a read leaf is charged the write costs
+	if gas := ae.BalanceGas(testAddr, true); gas != params.WitnessBranchWriteCost+params.WitnessChunkWriteCost {
+		t.Fatalf("write of read leaf: have %d, want %d", gas, params.WitnessBranchWriteCost+params.WitnessChunkWriteCost)
+	}
+	// Writing another leaf of the written branch is only charged the leaf
+	if gas := ae.NonceGas(testAddr, true); gas != params.WitnessChunkReadCost+params.WitnessChunkWriteCost {
+		t.Fatalf("write in written branch: have %d, want %d", gas, params.WitnessChunkReadCost+params.WitnessChunkWriteCost)
+	}
+	// Once written, the leaf is free to read and write again
+	if gas := ae.NonceGas(testAddr, true); gas != 0 {
+		t.Fatalf("warm write: have %d, want 0", gas)
+	}
+	if gas := ae.NonceGas(testAddr, false); gas != 0 {
+		t.Fatalf("read of written leaf: have %d, want 0", gas)
+	}
+}
+
+func TestMessageCallGas(t *testing.T) {
+	ae := NewAccessEvents(utils.NewPointCache(1024))
+
+	// The version and code size of the callee are read
+	want := params.WitnessBranchReadCost + 2*params.WitnessChunkReadCost
+	if gas := ae.MessageCallGas(testAddr); gas != want {
+		t.Fatalf("cold call: have %d, want %d", gas, want)
+	}
+	if gas := ae.MessageCallGas(testAddr); gas != 0 {
+		t.Fatalf("warm call: have %d, want 0", gas)
+	}
+	// The fields of the transaction's origin and destination are free
+	ae.AddTxOrigin(testAddr2)
+	if gas := ae.ValueTransferGas(testAddr2, testAddr); gas != params.WitnessChunkReadCost+params.WitnessBranchWriteCost+params.WitnessChunkWriteCost {
+		t.Fatalf("value transfer: have %d, want %d", gas, params.WitnessChunkReadCost+params.WitnessBranchWriteCost+params.WitnessChunkWriteCost)
+	}
+}
+
+func TestCodeChunksRangeGas(t *testing.T) {
+	ae := NewAccessEvents(utils.NewPointCache(1024))
+
+	// Reading 2 chunks of a contract, the first one is in the header branch
+	want := params.WitnessBranchReadCost + 2*params.WitnessChunkReadCost
+	if gas := ae.CodeChunksRangeGas(testAddr, 0, 40, 100, false); gas != want {
+		t.Fatalf("cold chunks: have %d, want %d", gas, want)
+	}
+	// Overlapping range, only the third chunk is cold
+	if gas := ae.CodeChunksRangeGas(testAddr, 31, 40, 100, false); gas != params.WitnessChunkReadCost {
+		t.Fatalf("overlapping chunks: have %d, want %d", gas, params.WitnessChunkReadCost)
+	}
+	// Chunks outside of the code or empty ranges are not touched
+	if gas := ae.CodeChunksRangeGas(testAddr, 200, 40, 100, false); gas != 0 {
+		t.Fatalf("out of code: have %d, want 0", gas)
+	}
+	if gas := ae.CodeChunksRangeGas(testAddr, 93, 0, 100, false); gas != 0 {
+		t.Fatalf("empty range: have %d, want 0", gas)
+	}
+	if keys := ae.Keys(); len(keys) != 3 {
+		t.Fatalf("touched keys: have %d, want 3", len(keys))
+	}
+}
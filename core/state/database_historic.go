@@ -0,0 +1,149 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/trie/trienode"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
+)
+
+// errHistoricReadOnly is returned if a historical state is modified.
+var errHistoricReadOnly = errors.New("historical state is read-only")
+
+// historicDB is a read-only state database serving historical states, which
+// are no longer maintained by the path-based trie database, from the indexed
+// state histories. Contract codes are resolved by the wrapped database.
+type historicDB struct {
+	Database
+}
+
+// NewHistoricDatabase wraps the given state database to serve historical states
+// from the indexed state histories of its path-based trie database. The tries
+// opened by the returned database can't be modified, hashed or iterated.
+func NewHistoricDatabase(db Database) Database {
+	return &historicDB{Database: db}
+}
+
+// OpenTrie opens the main account trie of the historical state.
+func (db *historicDB) OpenTrie(root common.Hash) (Trie, error) {
+	reader, err := db.TrieDB().HistoricReader(root)
+	if err != nil {
+		return nil, err
+	}
+	return &historicTrie{reader: reader, root: root}, nil
+}
+
+// OpenStorageTrie opens the storage trie of an account in the historical state.
+func (db *historicDB) OpenStorageTrie(stateRoot common.Hash, address common.Address, root common.Hash, self Trie) (Trie, error) {
+	if tr, ok := self.(*historicTrie); ok {
+		return &historicTrie{reader: tr.reader, root: root}, nil
+	}
+	reader, err := db.TrieDB().HistoricReader(stateRoot)
+	if err != nil {
+		return nil, err
+	}
+	return &historicTrie{reader: reader, root: root}, nil
+}
+
+// CopyTrie returns the given trie, which is immutable.
+func (db *historicDB) CopyTrie(t Trie) Trie {
+	return t
+}
+
+// historicTrie implements the Trie interface on top of a historical state
+// reader. It's used for both account and storage tries.
+type historicTrie struct {
+	reader *pathdb.HistoricReader
+	root   common.Hash
+}
+
+// GetKey returns nil, the preimages are not tracked.
+func (t *historicTrie) GetKey([]byte) []byte {
+	return nil
+}
+
+// GetAccount returns the account in the historical state.
+func (t *historicTrie) GetAccount(address common.Address) (*types.StateAccount, error) {
+	return t.reader.Account(address)
+}
+
+// GetStorage returns the storage slot in the historical state.
+func (t *historicTrie) GetStorage(addr common.Address, key []byte) ([]byte, error) {
+	if t.root == types.EmptyRootHash {
+		return nil, nil
+	}
+	return t.reader.Storage(addr, crypto.Keccak256Hash(key))
+}
+
+// UpdateAccount implements Trie, returning an error as the state is read-only.
+func (t *historicTrie) UpdateAccount(address common.Address, account *types.StateAccount) error {
+	return errHistoricReadOnly
+}
+
+// UpdateStorage implements Trie, returning an error as the state is read-only.
+func (t *historicTrie) UpdateStorage(addr common.Address, key, value []byte) error {
+	return errHistoricReadOnly
+}
+
+// DeleteAccount implements Trie, returning an error as the state is read-only.
+func (t *historicTrie) DeleteAccount(address common.Address) error {
+	return errHistoricReadOnly
+}
+
+// DeleteStorage implements Trie, returning an error as the state is read-only.
+func (t *historicTrie) DeleteStorage(addr common.Address, key []byte) error {
+	return errHistoricReadOnly
+}
+
+// UpdateContractCode implements Trie, returning an error as the state is read-only.
+func (t *historicTrie) UpdateContractCode(address common.Address, codeHash common.Hash, code []byte) error {
+	return errHistoricReadOnly
+}
+
+// Hash returns the root hash of the trie, which is never modified.
+func (t *historicTrie) Hash() common.Hash {
+	return t.root
+}
+
+// Commit implements Trie, returning an error as the state is read-only.
+func (t *historicTrie) Commit(collectLeaf bool) (common.Hash, *trienode.NodeSet, error) {
+	return common.Hash{}, nil, errHistoricReadOnly
+}
+
+// NodeIterator implements Trie, returning an error as historical states have
+// no trie nodes.
+func (t *historicTrie) NodeIterator(startKey []byte) (trie.NodeIterator, error) {
+	return nil, errors.New("historical state can't be iterated")
+}
+
+// Prove implements Trie, returning an error as historical states have no trie
+// nodes.
+func (t *historicTrie) Prove(key []byte, proofDb ethdb.KeyValueWriter) error {
+	return errors.New("historical state can't be proven")
+}
+
+// Witness returns nil, historical states have no trie nodes.
+func (t *historicTrie) Witness() map[string]struct{} {
+	return nil
+}
@@ -0,0 +1,215 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/trie/trienode"
+)
+
+var (
+	// ErrSnapshotUnavailable is returned by the snapshot-only state database if
+	// the requested state is not served by the snapshot, or if the snapshot
+	// layer became stale while reading from it.
+	ErrSnapshotUnavailable = errors.New("state snapshot unavailable")
+
+	// errSnapshotReadOnly is returned if a state served from the snapshot is
+	// modified.
+	errSnapshotReadOnly = errors.New("snapshot state is read-only")
+)
+
+// snapshotDB is a read-only state database serving the states purely from the
+// layers of the state snapshot, without resolving any trie node. Contract codes
+// are resolved by the wrapped database.
+type snapshotDB struct {
+	Database
+	snaps *snapshot.Tree
+}
+
+// NewSnapshotDatabase wraps the given state database to serve the states from
+// the given snapshot tree. It's meant for read-only execution on recent states,
+// the tries opened by the returned database can't be hashed, committed, iterated
+// or proven. Opening a state not available in the snapshot fails with
+// ErrSnapshotUnavailable.
+func NewSnapshotDatabase(db Database, snaps *snapshot.Tree) Database {
+	return &snapshotDB{Database: db, snaps: snaps}
+}
+
+// snapshot returns the snapshot layer of the given state.
+func (db *snapshotDB) snapshot(root common.Hash) (snapshot.Snapshot, error) {
+	if db.snaps == nil {
+		return nil, fmt.Errorf("%w: snapshot disabled", ErrSnapshotUnavailable)
+	}
+	if generating, err := db.snaps.Generating(); err != nil || generating {
+		return nil, fmt.Errorf("%w: snapshot not yet generated", ErrSnapshotUnavailable)
+	}
+	snap := db.snaps.Snapshot(root)
+	if snap == nil {
+		return nil, fmt.Errorf("%w: state %x not found", ErrSnapshotUnavailable, root)
+	}
+	return snap, nil
+}
+
+// OpenTrie opens the main account trie of the state.
+func (db *snapshotDB) OpenTrie(root common.Hash) (Trie, error) {
+	snap, err := db.snapshot(root)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshotTrie{snap: snap, root: root}, nil
+}
+
+// OpenStorageTrie opens the storage trie of an account in the state.
+func (db *snapshotDB) OpenStorageTrie(stateRoot common.Hash, address common.Address, root common.Hash, self Trie) (Trie, error) {
+	var snap snapshot.Snapshot
+	if tr, ok := self.(*snapshotTrie); ok {
+		snap = tr.snap
+	} else {
+		var err error
+		if snap, err = db.snapshot(stateRoot); err != nil {
+			return nil, err
+		}
+	}
+	return &snapshotTrie{snap: snap, root: root, owner: crypto.Keccak256Hash(address.Bytes())}, nil
+}
+
+// CopyTrie returns the given trie, which is immutable.
+func (db *snapshotDB) CopyTrie(t Trie) Trie {
+	return t
+}
+
+// snapshotTrie implements the Trie interface on top of a snapshot layer. It's
+// used for both account and storage tries.
+type snapshotTrie struct {
+	snap  snapshot.Snapshot
+	root  common.Hash
+	owner common.Hash // Hash of the account owning the storage, zero for the account trie
+}
+
+// snapshotError wraps the errors of the snapshot reads.
+func snapshotError(err error) error {
+	if errors.Is(err, snapshot.ErrSnapshotStale) || errors.Is(err, snapshot.ErrNotCoveredYet) {
+		return fmt.Errorf("%w: %v", ErrSnapshotUnavailable, err)
+	}
+	return err
+}
+
+// GetKey returns nil, the preimages are not tracked.
+func (t *snapshotTrie) GetKey([]byte) []byte {
+	return nil
+}
+
+// GetAccount returns the account from the snapshot.
+func (t *snapshotTrie) GetAccount(address common.Address) (*types.StateAccount, error) {
+	acc, err := t.snap.Account(crypto.Keccak256Hash(address.Bytes()))
+	if err != nil {
+		return nil, snapshotError(err)
+	}
+	if acc == nil {
+		return nil, nil
+	}
+	data := &types.StateAccount{
+		Nonce:    acc.Nonce,
+		Balance:  acc.Balance,
+		CodeHash: acc.CodeHash,
+		Root:     common.BytesToHash(acc.Root),
+	}
+	if len(data.CodeHash) == 0 {
+		data.CodeHash = types.EmptyCodeHash.Bytes()
+	}
+	if data.Root == (common.Hash{}) {
+		data.Root = types.EmptyRootHash
+	}
+	return data, nil
+}
+
+// GetStorage returns the storage slot from the snapshot.
+func (t *snapshotTrie) GetStorage(addr common.Address, key []byte) ([]byte, error) {
+	if t.root == types.EmptyRootHash {
+		return nil, nil
+	}
+	enc, err := t.snap.Storage(t.owner, crypto.Keccak256Hash(key))
+	if err != nil {
+		return nil, snapshotError(err)
+	}
+	if len(enc) == 0 {
+		return nil, nil
+	}
+	_, content, _, err := rlp.Split(enc)
+	return content, err
+}
+
+// UpdateAccount implements Trie, returning an error as the state is read-only.
+func (t *snapshotTrie) UpdateAccount(address common.Address, account *types.StateAccount) error {
+	return errSnapshotReadOnly
+}
+
+// UpdateStorage implements Trie, returning an error as the state is read-only.
+func (t *snapshotTrie) UpdateStorage(addr common.Address, key, value []byte) error {
+	return errSnapshotReadOnly
+}
+
+// DeleteAccount implements Trie, returning an error as the state is read-only.
+func (t *snapshotTrie) DeleteAccount(address common.Address) error {
+	return errSnapshotReadOnly
+}
+
+// DeleteStorage implements Trie, returning an error as the state is read-only.
+func (t *snapshotTrie) DeleteStorage(addr common.Address, key []byte) error {
+	return errSnapshotReadOnly
+}
+
+// UpdateContractCode implements Trie, returning an error as the state is read-only.
+func (t *snapshotTrie) UpdateContractCode(address common.Address, codeHash common.Hash, code []byte) error {
+	return errSnapshotReadOnly
+}
+
+// Hash returns the root hash of the trie, which is never modified.
+func (t *snapshotTrie) Hash() common.Hash {
+	return t.root
+}
+
+// Commit implements Trie, returning an error as the state is read-only.
+func (t *snapshotTrie) Commit(collectLeaf bool) (common.Hash, *trienode.NodeSet, error) {
+	return common.Hash{}, nil, errSnapshotReadOnly
+}
+
+// NodeIterator implements Trie, returning an error as snapshot states have no
+// trie nodes.
+func (t *snapshotTrie) NodeIterator(startKey []byte) (trie.NodeIterator, error) {
+	return nil, errors.New("snapshot state can't be iterated")
+}
+
+// Prove implements Trie, returning an error as snapshot states have no trie
+// nodes.
+func (t *snapshotTrie) Prove(key []byte, proofDb ethdb.KeyValueWriter) error {
+	return errors.New("snapshot state can't be proven")
+}
+
+// Witness returns nil, snapshot states have no trie nodes.
+func (t *snapshotTrie) Witness() map[string]struct{} {
+	return nil
+}
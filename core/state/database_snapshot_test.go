@@ -0,0 +1,119 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/holiman/uint256"
+)
+
+// Tests that the states served purely from the snapshot match the ones served
+// from the tries, without touching any trie node.
+func TestSnapshotDatabase(t *testing.T) {
+	var (
+		disk     = rawdb.NewMemoryDatabase()
+		tdb      = triedb.NewDatabase(disk, nil)
+		db       = NewDatabaseWithNodeDB(disk, tdb)
+		snaps, _ = snapshot.New(snapshot.Config{CacheSize: 10}, disk, tdb, types.EmptyRootHash)
+		state, _ = New(types.EmptyRootHash, db, snaps)
+
+		plain    = common.HexToAddress("0x01")
+		contract = common.HexToAddress("0x02")
+		deleted  = common.HexToAddress("0x03")
+		missing  = common.HexToAddress("0x04")
+		code     = []byte{0x60, 0x00, 0x60, 0x00}
+		slots    = []common.Hash{common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x03")}
+		full     = common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+	)
+	state.SetBalance(plain, uint256.NewInt(1))
+	state.SetNonce(plain, 2)
+	state.SetCode(contract, code)
+	state.SetState(contract, slots[0], common.HexToHash("0x01"))
+	state.SetState(contract, slots[1], full)
+	state.SetBalance(deleted, uint256.NewInt(3))
+	state.SetState(deleted, slots[0], full)
+	root1, _ := state.Commit(0, true)
+
+	state, _ = New(root1, db, snaps)
+	state.SetBalance(plain, uint256.NewInt(4))
+	state.SetState(contract, slots[0], common.Hash{})
+	state.SetState(contract, slots[2], full)
+	state.SelfDestruct(deleted)
+	root2, _ := state.Commit(1, true)
+
+	// Serve the codes from a separate database without any trie node
+	codedb := rawdb.NewMemoryDatabase()
+	rawdb.WriteCode(codedb, crypto.Keccak256Hash(code), code)
+	sdb := NewSnapshotDatabase(NewDatabase(codedb), snaps)
+
+	for _, root := range []common.Hash{root1, root2} {
+		want, _ := New(root, db, nil)
+		have, err := New(root, sdb, nil)
+		if err != nil {
+			t.Fatalf("failed to open snapshot state %x: %v", root, err)
+		}
+		for _, addr := range []common.Address{plain, contract, deleted, missing} {
+			if have.Exist(addr) != want.Exist(addr) {
+				t.Fatalf("state %x: existence mismatch for %x", root, addr)
+			}
+			if have.GetBalance(addr).Cmp(want.GetBalance(addr)) != 0 || have.GetNonce(addr) != want.GetNonce(addr) {
+				t.Fatalf("state %x: balance or nonce mismatch for %x", root, addr)
+			}
+			if !bytes.Equal(have.GetCode(addr), want.GetCode(addr)) || have.GetStorageRoot(addr) != want.GetStorageRoot(addr) {
+				t.Fatalf("state %x: code or storage root mismatch for %x", root, addr)
+			}
+			for _, slot := range slots {
+				if have.GetState(addr, slot) != want.GetState(addr, slot) {
+					t.Fatalf("state %x: slot %x mismatch for %x", root, slot, addr)
+				}
+			}
+		}
+		if err := have.Error(); err != nil {
+			t.Fatalf("state %x: unexpected error: %v", root, err)
+		}
+		// The state can be modified in memory
+		have.SetState(contract, slots[0], full)
+		if have.GetState(contract, slots[0]) != full || have.Error() != nil {
+			t.Fatalf("state %x: failed to modify snapshot state", root)
+		}
+	}
+	// States missing from the snapshot are rejected
+	if _, err := New(common.HexToHash("0xdeadbeef"), sdb, nil); !errors.Is(err, ErrSnapshotUnavailable) {
+		t.Fatalf("unexpected error for unknown state: %v", err)
+	}
+	if _, err := New(root1, NewSnapshotDatabase(db, nil), nil); !errors.Is(err, ErrSnapshotUnavailable) {
+		t.Fatalf("unexpected error without snapshot: %v", err)
+	}
+	// Reading from a stale layer fails cleanly
+	stale, _ := New(root1, sdb, nil)
+	if err := snaps.Cap(root2, 0); err != nil {
+		t.Fatalf("failed to flatten snapshot: %v", err)
+	}
+	stale.GetBalance(plain)
+	if err := stale.Error(); !errors.Is(err, ErrSnapshotUnavailable) {
+		t.Fatalf("unexpected error for stale state: %v", err)
+	}
+}
@@ -17,16 +17,24 @@
 package state
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math/big"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
+	"github.com/holiman/uint256"
 )
 
 // DumpConfig is a set of options to control what portions of the state will be
@@ -35,8 +43,20 @@ type DumpConfig struct {
 	SkipCode          bool
 	SkipStorage       bool
 	OnlyWithAddresses bool
-	Start             []byte
+	Start             []byte // Account hash or continuation token of a partial dump to start from
 	Max               uint64
+
+	Threads       int              // Number of key ranges dumped concurrently, the output is unordered if more than one
+	Addresses     []common.Address // Only dump the given accounts, if any
+	OnlyContracts bool             // Only dump the accounts with code
+	MinBalance    *uint256.Int     // Only dump the accounts with at least the given balance, if set
+	MaxStorage    uint64           // Maximum number of storage slots dumped per account (0 = no limit)
+}
+
+// Validate checks the consistency of the dump options.
+func (conf *DumpConfig) Validate() error {
+	_, err := parseDumpStart(conf.Start)
+	return err
 }
 
 // DumpCollector interface which the state trie calls during iteration
@@ -58,6 +78,9 @@ type DumpAccount struct {
 	Address     *common.Address        `json:"address,omitempty"` // Address only present in iterative (line-by-line) mode
 	AddressHash hexutil.Bytes          `json:"key,omitempty"`     // If we don't have address, we can output the key
 
+	// NextStorageKey is the hash of the first storage slot not dumped if the
+	// storage was truncated, nil if it's complete.
+	NextStorageKey *common.Hash `json:"nextStorageKey,omitempty"`
 }
 
 // Dump represents the full dump in a collected format, as one large map.
@@ -66,6 +89,8 @@ type Dump struct {
 	Accounts map[string]DumpAccount `json:"accounts"`
 	// Next can be set to represent that this dump is only partial, and Next
 	// is where an iterator should be positioned in order to continue the dump.
+	// For dumps iterating multiple key ranges concurrently, it's an opaque
+	// continuation token instead of an account hash.
 	Next []byte `json:"next,omitempty"` // nil if no more accounts
 }
 
@@ -111,102 +136,370 @@ func (d iterativeDump) OnRoot(root common.Hash) {
 	}{root})
 }
 
-// DumpToCollector iterates the state according to the given options and inserts
-// the items into a collector for aggregation or serialization.
-func (s *StateDB) DumpToCollector(c DumpCollector, conf *DumpConfig) (nextKey []byte) {
-	// Sanitize the input to allow nil configs
-	if conf == nil {
-		conf = new(DumpConfig)
+// dumpRange is a range of the account hash space still to be dumped.
+type dumpRange struct {
+	Next  []byte // Next account hash to dump, nil if the range is done
+	Limit []byte // First account hash after the range, empty for the last range
+}
+
+// parseDumpStart parses the start position of a dump into the ranges to dump.
+// It's either a single account hash, or the continuation token of a dump which
+// iterated multiple ranges.
+func parseDumpStart(start []byte) ([]*dumpRange, error) {
+	if len(start) <= common.HashLength {
+		return []*dumpRange{{Next: common.RightPadBytes(start, common.HashLength)}}, nil
 	}
+	var ranges []*dumpRange
+	if err := rlp.DecodeBytes(start, &ranges); err != nil {
+		return nil, fmt.Errorf("invalid continuation token: %v", err)
+	}
+	for i, rng := range ranges {
+		if len(rng.Next) != common.HashLength || (len(rng.Limit) != 0 && len(rng.Limit) != common.HashLength) {
+			return nil, errors.New("invalid continuation token: malformed range")
+		}
+		if len(rng.Limit) == 0 && i != len(ranges)-1 {
+			return nil, errors.New("invalid continuation token: unbounded range")
+		}
+		if len(rng.Limit) != 0 && bytes.Compare(rng.Next, rng.Limit) >= 0 {
+			return nil, errors.New("invalid continuation token: empty range")
+		}
+		if i > 0 && bytes.Compare(rng.Next, ranges[i-1].Limit) < 0 {
+			return nil, errors.New("invalid continuation token: overlapping ranges")
+		}
+	}
+	return ranges, nil
+}
+
+// splitDumpRange splits a range into n ranges of equal size.
+func splitDumpRange(rng *dumpRange, n int) []*dumpRange {
 	var (
-		missingPreimages int
-		accounts         uint64
-		start            = time.Now()
-		logged           = time.Now()
+		start = new(big.Int).SetBytes(rng.Next)
+		end   = new(big.Int).Lsh(common.Big1, 256)
 	)
-	log.Info("Trie dumping started", "root", s.trie.Hash())
-	c.OnRoot(s.trie.Hash())
+	if len(rng.Limit) > 0 {
+		end.SetBytes(rng.Limit)
+	}
+	step := new(big.Int).Div(new(big.Int).Sub(end, start), big.NewInt(int64(n)))
+	if step.Sign() == 0 {
+		return []*dumpRange{rng}
+	}
+	ranges := make([]*dumpRange, n)
+	for i := range ranges {
+		ranges[i] = &dumpRange{Next: common.BigToHash(start).Bytes()}
+		if i == n-1 {
+			ranges[i].Limit = rng.Limit
+		} else {
+			start.Add(start, step)
+			ranges[i].Limit = common.BigToHash(start).Bytes()
+		}
+	}
+	return ranges
+}
 
-	trieIt, err := s.trie.NodeIterator(conf.Start)
+// encodeDumpToken returns the position to continue a dump from, nil if all the
+// ranges are done. If only the last range is left, it's the next account hash
+// to dump, otherwise a token encoding all the unfinished ranges.
+func encodeDumpToken(ranges []*dumpRange) []byte {
+	var pending []*dumpRange
+	for _, rng := range ranges {
+		if rng.Next != nil {
+			pending = append(pending, rng)
+		}
+	}
+	switch {
+	case len(pending) == 0:
+		return nil
+	case len(pending) == 1 && len(pending[0].Limit) == 0:
+		return pending[0].Next
+	}
+	blob, err := rlp.EncodeToBytes(pending)
 	if err != nil {
-		log.Error("Trie dumping error", "err", err)
+		panic(err) // Cannot happen, here to catch dev errors
+	}
+	return blob
+}
+
+// nextDumpKey returns the account hash following the given one, nil if it's
+// the last possible one.
+func nextDumpKey(key []byte) []byte {
+	next := common.CopyBytes(key)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			return next
+		}
+	}
+	return nil
+}
+
+// dumpTarget is an account to be dumped with a known address.
+type dumpTarget struct {
+	hash common.Hash
+	addr common.Address
+}
+
+// dumper is the state of a dump, shared among the range iterators.
+type dumper struct {
+	s       *StateDB
+	c       DumpCollector
+	conf    *DumpConfig
+	targets []dumpTarget // Accounts to dump ordered by hash, nil to dump all
+
+	lock             sync.Mutex
+	accounts         uint64
+	missingPreimages int
+	start            time.Time
+	logged           time.Time
+}
+
+// full reports whether the maximum number of accounts has been dumped.
+func (d *dumper) full() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.conf.Max > 0 && d.accounts >= d.conf.Max
+}
+
+// emit passes an account to the collector. It returns false without collecting
+// the account if the maximum number of accounts was dumped meanwhile.
+func (d *dumper) emit(addr *common.Address, account DumpAccount) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.conf.Max > 0 && d.accounts >= d.conf.Max {
+		return false
+	}
+	d.c.OnAccount(addr, account)
+	d.accounts++
+	if time.Since(d.logged) > 8*time.Second {
+		log.Info("Trie dumping in progress", "at", account.AddressHash, "accounts", d.accounts,
+			"elapsed", common.PrettyDuration(time.Since(d.start)))
+		d.logged = time.Now()
+	}
+	return true
+}
+
+// iterate calls fn for the accounts of the range in hash order, until it returns
+// false or the range is exhausted.
+func (d *dumper) iterate(tr Trie, rng *dumpRange, fn func(key []byte, data *types.StateAccount, addr *common.Address) bool) error {
+	if d.targets != nil {
+		for _, target := range d.targets {
+			if bytes.Compare(target.hash[:], rng.Next) < 0 {
+				continue
+			}
+			if len(rng.Limit) > 0 && bytes.Compare(target.hash[:], rng.Limit) >= 0 {
+				break
+			}
+			data, err := tr.GetAccount(target.addr)
+			if err != nil {
+				return err
+			}
+			if data == nil {
+				continue
+			}
+			addr := target.addr
+			if !fn(target.hash.Bytes(), data, &addr) {
+				break
+			}
+		}
 		return nil
 	}
+	trieIt, err := tr.NodeIterator(rng.Next)
+	if err != nil {
+		return err
+	}
 	it := trie.NewIterator(trieIt)
 	for it.Next() {
+		if len(rng.Limit) > 0 && bytes.Compare(it.Key, rng.Limit) >= 0 {
+			break
+		}
 		var data types.StateAccount
 		if err := rlp.DecodeBytes(it.Value, &data); err != nil {
-			panic(err)
+			return err
 		}
-		var (
-			account = DumpAccount{
-				Balance:     data.Balance.String(),
-				Nonce:       data.Nonce,
-				Root:        data.Root[:],
-				CodeHash:    data.CodeHash,
-				AddressHash: it.Key,
-			}
-			address   *common.Address
-			addr      common.Address
-			addrBytes = s.trie.GetKey(it.Key)
-		)
-		if addrBytes == nil {
-			missingPreimages++
-			if conf.OnlyWithAddresses {
-				continue
-			}
-		} else {
-			addr = common.BytesToAddress(addrBytes)
-			address = &addr
-			account.Address = address
+		var addr *common.Address
+		if addrBytes := tr.GetKey(it.Key); addrBytes != nil {
+			addr = new(common.Address)
+			*addr = common.BytesToAddress(addrBytes)
 		}
-		obj := newObject(s, addr, &data)
-		if !conf.SkipCode {
-			account.Code = obj.Code()
+		if !fn(it.Key, &data, addr) {
+			break
 		}
-		if !conf.SkipStorage {
-			account.Storage = make(map[common.Hash]string)
-			tr, err := obj.getTrie()
-			if err != nil {
-				log.Error("Failed to load storage trie", "err", err)
-				continue
+	}
+	return it.Err
+}
+
+// account assembles the dump of an account, returning false if it's filtered.
+func (d *dumper) account(tr Trie, key []byte, data *types.StateAccount, addr *common.Address) (DumpAccount, bool) {
+	conf := d.conf
+	if addr == nil {
+		d.lock.Lock()
+		d.missingPreimages++
+		d.lock.Unlock()
+
+		if conf.OnlyWithAddresses {
+			return DumpAccount{}, false
+		}
+	}
+	hasCode := !bytes.Equal(data.CodeHash, types.EmptyCodeHash.Bytes())
+	if conf.OnlyContracts && !hasCode {
+		return DumpAccount{}, false
+	}
+	if conf.MinBalance != nil && data.Balance.Cmp(conf.MinBalance) < 0 {
+		return DumpAccount{}, false
+	}
+	var (
+		account = DumpAccount{
+			Balance:     data.Balance.String(),
+			Nonce:       data.Nonce,
+			Root:        data.Root[:],
+			CodeHash:    data.CodeHash,
+			Address:     addr,
+			AddressHash: key,
+		}
+		address common.Address
+	)
+	if addr != nil {
+		address = *addr
+	}
+	if !conf.SkipCode && hasCode {
+		code, err := d.s.db.ContractCode(address, common.BytesToHash(data.CodeHash))
+		if err != nil {
+			log.Error("Failed to load contract code", "hash", common.BytesToHash(data.CodeHash), "err", err)
+		}
+		account.Code = code
+	}
+	if !conf.SkipStorage {
+		account.Storage = make(map[common.Hash]string)
+		st, err := d.s.db.OpenStorageTrie(d.s.originalRoot, address, data.Root, tr)
+		if err != nil {
+			log.Error("Failed to load storage trie", "err", err)
+			return DumpAccount{}, false
+		}
+		trieIt, err := st.NodeIterator(nil)
+		if err != nil {
+			log.Error("Failed to create trie iterator", "err", err)
+			return DumpAccount{}, false
+		}
+		var (
+			storageIt = trie.NewIterator(trieIt)
+			slots     uint64
+		)
+		for storageIt.Next() {
+			if conf.MaxStorage > 0 && slots >= conf.MaxStorage {
+				next := common.BytesToHash(storageIt.Key)
+				account.NextStorageKey = &next
+				break
 			}
-			trieIt, err := tr.NodeIterator(nil)
+			_, content, _, err := rlp.Split(storageIt.Value)
 			if err != nil {
-				log.Error("Failed to create trie iterator", "err", err)
+				log.Error("Failed to decode the value returned by iterator", "error", err)
 				continue
 			}
-			storageIt := trie.NewIterator(trieIt)
-			for storageIt.Next() {
-				_, content, _, err := rlp.Split(storageIt.Value)
-				if err != nil {
-					log.Error("Failed to decode the value returned by iterator", "error", err)
-					continue
-				}
-				account.Storage[common.BytesToHash(s.trie.GetKey(storageIt.Key))] = common.Bytes2Hex(content)
-			}
+			account.Storage[common.BytesToHash(tr.GetKey(storageIt.Key))] = common.Bytes2Hex(content)
+			slots++
 		}
-		c.OnAccount(address, account)
-		accounts++
-		if time.Since(logged) > 8*time.Second {
-			log.Info("Trie dumping in progress", "at", it.Key, "accounts", accounts,
-				"elapsed", common.PrettyDuration(time.Since(start)))
-			logged = time.Now()
+	}
+	return account, true
+}
+
+// dumpRange dumps the accounts of a range, tracking its progress.
+func (d *dumper) dumpRange(rng *dumpRange) {
+	var (
+		tr   = d.s.db.CopyTrie(d.s.trie)
+		done = true
+	)
+	err := d.iterate(tr, rng, func(key []byte, data *types.StateAccount, addr *common.Address) bool {
+		if d.full() {
+			rng.Next, done = common.CopyBytes(key), false
+			return false
 		}
-		if conf.Max > 0 && accounts >= conf.Max {
-			if it.Next() {
-				nextKey = it.Key
-			}
-			break
+		if account, ok := d.account(tr, key, data, addr); ok && !d.emit(addr, account) {
+			rng.Next, done = common.CopyBytes(key), false
+			return false
 		}
+		rng.Next = nextDumpKey(key)
+		return true
+	})
+	if err != nil {
+		log.Error("Trie dumping error", "err", err)
+		return
 	}
-	if missingPreimages > 0 {
-		log.Warn("Dump incomplete due to missing preimages", "missing", missingPreimages)
+	if done {
+		rng.Next = nil
 	}
-	log.Info("Trie dumping complete", "accounts", accounts,
-		"elapsed", common.PrettyDuration(time.Since(start)))
+}
 
-	return nextKey
+// DumpToCollector iterates the state according to the given options and inserts
+// the items into a collector for aggregation or serialization. If the dump is
+// incomplete, the position to continue it from is returned.
+func (s *StateDB) DumpToCollector(c DumpCollector, conf *DumpConfig) (nextKey []byte) {
+	// Sanitize the input to allow nil configs
+	if conf == nil {
+		conf = new(DumpConfig)
+	}
+	ranges, err := parseDumpStart(conf.Start)
+	if err != nil {
+		log.Error("Trie dumping error", "err", err)
+		return nil
+	}
+	threads := conf.Threads
+	if threads < 1 {
+		threads = 1
+	}
+	if threads > 1 && len(ranges) == 1 {
+		ranges = splitDumpRange(ranges[0], threads)
+	}
+	d := &dumper{
+		s:      s,
+		c:      c,
+		conf:   conf,
+		start:  time.Now(),
+		logged: time.Now(),
+	}
+	if len(conf.Addresses) > 0 {
+		d.targets = make([]dumpTarget, 0, len(conf.Addresses))
+		for _, addr := range conf.Addresses {
+			d.targets = append(d.targets, dumpTarget{hash: crypto.Keccak256Hash(addr[:]), addr: addr})
+		}
+		slices.SortFunc(d.targets, func(a, b dumpTarget) int {
+			return a.hash.Cmp(b.hash)
+		})
+	}
+	log.Info("Trie dumping started", "root", s.trie.Hash())
+	c.OnRoot(s.trie.Hash())
+
+	if threads == 1 {
+		for _, rng := range ranges {
+			d.dumpRange(rng)
+		}
+	} else {
+		var (
+			tasks = make(chan *dumpRange, len(ranges))
+			wg    sync.WaitGroup
+		)
+		for _, rng := range ranges {
+			tasks <- rng
+		}
+		close(tasks)
+		for i := 0; i < threads; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for rng := range tasks {
+					d.dumpRange(rng)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	if d.missingPreimages > 0 {
+		log.Warn("Dump incomplete due to missing preimages", "missing", d.missingPreimages)
+	}
+	log.Info("Trie dumping complete", "accounts", d.accounts,
+		"elapsed", common.PrettyDuration(time.Since(d.start)))
+
+	return encodeDumpToken(ranges)
 }
 
 // RawDump returns the state. If the processing is aborted e.g. due to options
@@ -229,7 +522,78 @@ func (s *StateDB) Dump(opts *DumpConfig) []byte {
 	return json
 }
 
-// IterativeDump dumps out accounts as json-objects, delimited by linebreaks on stdout
-func (s *StateDB) IterativeDump(opts *DumpConfig, output *json.Encoder) {
-	s.DumpToCollector(iterativeDump{output}, opts)
+// IterativeDump dumps out accounts as json-objects, delimited by linebreaks on stdout.
+// If the dump is incomplete, the position to continue it from is returned.
+func (s *StateDB) IterativeDump(opts *DumpConfig, output *json.Encoder) (nextKey []byte) {
+	return s.DumpToCollector(iterativeDump{output}, opts)
+}
+
+// rlpDumpSlot is a storage slot in the RLP dumps.
+type rlpDumpSlot struct {
+	Key   common.Hash
+	Value []byte
+}
+
+// rlpDumpAccount is an account in the RLP dumps.
+type rlpDumpAccount struct {
+	Address        []byte // Empty if the preimage is missing
+	AddressHash    []byte
+	Balance        *big.Int
+	Nonce          uint64
+	Root           []byte
+	CodeHash       []byte
+	Code           []byte
+	Storage        []rlpDumpSlot // Ordered by key
+	NextStorageKey []byte        // Empty unless the storage was truncated
+}
+
+// rlpDump is a DumpCollector-implementation which dumps output as a stream of
+// RLP items.
+type rlpDump struct {
+	w   io.Writer
+	err error
+}
+
+// OnRoot implements DumpCollector interface
+func (d *rlpDump) OnRoot(root common.Hash) {
+	if d.err == nil {
+		d.err = rlp.Encode(d.w, root)
+	}
+}
+
+// OnAccount implements DumpCollector interface
+func (d *rlpDump) OnAccount(addr *common.Address, account DumpAccount) {
+	if d.err != nil {
+		return
+	}
+	balance, _ := new(big.Int).SetString(account.Balance, 10)
+	enc := rlpDumpAccount{
+		AddressHash: account.AddressHash,
+		Balance:     balance,
+		Nonce:       account.Nonce,
+		Root:        account.Root,
+		CodeHash:    account.CodeHash,
+		Code:        account.Code,
+	}
+	if addr != nil {
+		enc.Address = addr.Bytes()
+	}
+	for key, value := range account.Storage {
+		enc.Storage = append(enc.Storage, rlpDumpSlot{Key: key, Value: common.FromHex(value)})
+	}
+	slices.SortFunc(enc.Storage, func(a, b rlpDumpSlot) int {
+		return a.Key.Cmp(b.Key)
+	})
+	if account.NextStorageKey != nil {
+		enc.NextStorageKey = account.NextStorageKey.Bytes()
+	}
+	d.err = rlp.Encode(d.w, &enc)
+}
+
+// RLPDump dumps out the state root followed by the accounts as a stream of RLP
+// items. If the dump is incomplete, the position to continue it from is returned.
+func (s *StateDB) RLPDump(opts *DumpConfig, output io.Writer) (nextKey []byte, err error) {
+	d := &rlpDump{w: output}
+	nextKey = s.DumpToCollector(d, opts)
+	return nextKey, d.err
 }
@@ -0,0 +1,287 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"errors"
+	"maps"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+)
+
+// forkRef counts the states sharing the same account trie and block-level maps
+// after a Fork. The last state still holding a reference may take ownership of
+// them without copying.
+type forkRef struct {
+	refs atomic.Int32
+}
+
+// Fork creates an independent copy of the state in constant time. As opposed
+// to Copy, nothing is duplicated upfront: the account trie, the block-level
+// maps and all the state objects are shared between the two states, and each
+// of them is copied lazily by whichever side touches it first. Storage maps
+// and tries of a state object stay shared until the object's storage is
+// modified or loaded.
+//
+// Snapshots of the forked state cannot be applied to the fork. The forked and
+// the forking state may be used concurrently from different goroutines.
+func (s *StateDB) Fork() *StateDB {
+	if s.fork == nil {
+		s.fork = new(forkRef)
+		s.fork.refs.Store(1)
+	}
+	s.fork.refs.Add(1)
+
+	// Freeze all the live objects, they belong to neither state from now on
+	s.gen++
+
+	state := &StateDB{
+		db:                   s.db,
+		trie:                 s.trie,
+		originalRoot:         s.originalRoot,
+		accounts:             s.accounts,
+		storages:             s.storages,
+		accountsOrigin:       s.accountsOrigin,
+		storagesOrigin:       s.storagesOrigin,
+		stateObjects:         s.stateObjects,
+		stateObjectsPending:  s.stateObjectsPending,
+		stateObjectsDirty:    s.stateObjectsDirty,
+		stateObjectsDestruct: s.stateObjectsDestruct,
+		refund:               s.refund,
+		logs:                 s.logs,
+		logSize:              s.logSize,
+		preimages:            s.preimages,
+		accessList:           s.accessList.Copy(),
+		transientStorage:     s.transientStorage.Copy(),
+		journal:              newJournal(),
+		hasher:               crypto.NewKeccakState(),
+		fork:                 s.fork,
+		snaps:                s.snaps,
+		snap:                 s.snap,
+	}
+	// If the state is forked mid-transaction, the journal is not carried over,
+	// so apply the side effects it would have had on the commit up front. This
+	// needs the bookkeeping maps to be owned by the fork.
+	if len(s.journal.dirties) > 0 {
+		state.unshare()
+		for addr := range s.journal.dirties {
+			if _, exist := state.stateObjects[addr]; exist {
+				state.stateObjectsDirty[addr] = struct{}{}
+				state.stateObjectsPending[addr] = struct{}{}
+			}
+		}
+	}
+	if s.prefetcher != nil {
+		state.prefetcher = s.prefetcher.copy()
+	}
+	if s.witness != nil {
+		state.witness = s.witness.Copy()
+	}
+	if s.accessEvents != nil {
+		state.accessEvents = s.accessEvents.Copy()
+	}
+	return state
+}
+
+// unshare makes sure the account trie and the block-level maps are owned by
+// this state, copying them if they are still referenced by a forked state.
+func (s *StateDB) unshare() {
+	if s.fork == nil {
+		return
+	}
+	// The maps must be copied before the reference is released, otherwise the
+	// remaining holder could start modifying them while they're being read.
+	if s.fork.refs.Load() > 1 {
+		s.trie = s.db.CopyTrie(s.trie)
+		s.accounts = copySet(s.accounts)
+		s.storages = copy2DSet(s.storages)
+		s.accountsOrigin = copySet(s.accountsOrigin)
+		s.storagesOrigin = copy2DSet(s.storagesOrigin)
+		s.stateObjects = maps.Clone(s.stateObjects)
+		s.stateObjectsPending = maps.Clone(s.stateObjectsPending)
+		s.stateObjectsDirty = maps.Clone(s.stateObjectsDirty)
+		s.stateObjectsDestruct = maps.Clone(s.stateObjectsDestruct)
+		s.preimages = maps.Clone(s.preimages)
+
+		logs := make(map[common.Hash][]*types.Log, len(s.logs))
+		for hash, entries := range s.logs {
+			cpy := make([]*types.Log, len(entries))
+			for i, l := range entries {
+				cpy[i] = new(types.Log)
+				*cpy[i] = *l
+			}
+			logs[hash] = cpy
+		}
+		s.logs = logs
+		s.fork.refs.Add(-1)
+	}
+	s.fork = nil
+}
+
+// liveObject returns the live object of the given address, or nil if it's not
+// cached. Objects shared with a forked state are replaced by a private copy.
+func (s *StateDB) liveObject(addr common.Address) *stateObject {
+	obj := s.stateObjects[addr]
+	if obj != nil && (obj.db != s || obj.gen != s.gen) {
+		obj = obj.fork(s)
+		s.setStateObject(obj)
+	}
+	return obj
+}
+
+// Speculate forks the state for executing the next block on top of it, before
+// its changes have been hashed and committed. Only the modifications made to
+// the returned state are tracked, so that they can be transplanted onto the
+// committed state with Rebase. The state must be finalised beforehand.
+func (s *StateDB) Speculate() *StateDB {
+	state := s.Fork()
+	state.stateObjectsPending = make(map[common.Address]struct{})
+	state.stateObjectsDirty = make(map[common.Address]struct{})
+	state.speculative = make(map[common.Address]struct{})
+	return state
+}
+
+// Rebase opens the state with the given root, which must be the committed root
+// of the state this one was speculated from, and applies all the modifications
+// made to this speculative state on top. The changes are left unfinalised, the
+// same way they are after processing a block. The speculative state must have
+// been finalised beforehand and should not be used afterwards.
+func (s *StateDB) Rebase(root common.Hash) (*StateDB, error) {
+	if s.speculative == nil {
+		return nil, errors.New("state is not speculative")
+	}
+	state, err := New(root, s.db, s.snaps)
+	if err != nil {
+		return nil, err
+	}
+	for addr := range s.stateObjectsDirty {
+		obj := s.stateObjects[addr]
+		if obj == nil {
+			continue // ripeMD touched by a failed transaction, see Finalise
+		}
+		var dst *stateObject
+		if _, destructed := s.speculative[addr]; destructed {
+			// The account was destructed or (re)created. If it's gone for good,
+			// destruct it in the rebased state too, otherwise replace it with a
+			// fresh object to wipe any original storage.
+			if obj.deleted {
+				state.SelfDestruct(addr)
+				continue
+			}
+			dst, _ = state.createObject(addr)
+		} else {
+			dst = state.getOrNewStateObject(addr)
+		}
+		if dst.Balance().Cmp(obj.Balance()) != 0 {
+			dst.SetBalance(obj.Balance())
+		}
+		if dst.Nonce() != obj.Nonce() {
+			dst.SetNonce(obj.Nonce())
+		}
+		if !bytes.Equal(dst.CodeHash(), obj.CodeHash()) {
+			dst.SetCode(common.BytesToHash(obj.CodeHash()), obj.code)
+		}
+		// Pending slots might include ones already committed by the parent
+		// block, setting those is a noop.
+		for key, value := range obj.pendingStorage {
+			dst.SetState(key, value)
+		}
+	}
+	for hash, preimage := range s.preimages {
+		if _, ok := state.preimages[hash]; !ok {
+			state.preimages[hash] = preimage
+		}
+	}
+	// Attribute the reads done during execution to the rebased state
+	state.AccountReads += s.AccountReads
+	state.StorageReads += s.StorageReads
+	state.SnapshotAccountReads += s.SnapshotAccountReads
+	state.SnapshotStorageReads += s.SnapshotStorageReads
+
+	return state, nil
+}
+
+// Merge applies the modifications made to a speculative fork of this state on
+// top of it, while this state may have been modified since forking. The origin
+// must be an untouched fork of this state at the time of speculation. Balances
+// are applied as the difference to their origin, so that the blind credits of
+// different speculative states add up; all other fields and storage slots are
+// overwritten if modified. Merging is only sound if nothing read by the
+// speculative execution has been modified since, which is up to the caller to
+// check. The changes are left unfinalised.
+func (s *StateDB) Merge(spec, origin *StateDB) error {
+	if spec.speculative == nil {
+		return errors.New("state is not speculative")
+	}
+	for addr := range spec.stateObjectsDirty {
+		obj := spec.stateObjects[addr]
+		if obj == nil {
+			continue // ripeMD touched by a failed transaction, see Finalise
+		}
+		if _, destructed := spec.speculative[addr]; destructed {
+			// The account was destructed or (re)created, overwrite it in full
+			if obj.deleted {
+				s.SelfDestruct(addr)
+				continue
+			}
+			dst, _ := s.createObject(addr)
+			dst.SetBalance(obj.Balance())
+			dst.SetNonce(obj.Nonce())
+			dst.SetCode(common.BytesToHash(obj.CodeHash()), obj.Code())
+			for key, value := range obj.pendingStorage {
+				dst.SetState(key, value)
+			}
+			continue
+		}
+		dst := s.getOrNewStateObject(addr)
+		if prev := origin.GetBalance(addr); prev.Cmp(obj.Balance()) != 0 {
+			diff := new(uint256.Int).Sub(obj.Balance(), prev)
+			dst.SetBalance(diff.Add(diff, dst.Balance()))
+		}
+		if obj.Nonce() != origin.GetNonce(addr) {
+			dst.SetNonce(obj.Nonce())
+		}
+		if codeHash := common.BytesToHash(obj.CodeHash()); codeHash != origin.GetCodeHash(addr) {
+			dst.SetCode(codeHash, obj.Code())
+		}
+		// Pending storage includes the slots modified before speculation,
+		// only the ones changed since are merged.
+		for key, value := range obj.pendingStorage {
+			if value != origin.GetState(addr, key) {
+				dst.SetState(key, value)
+			}
+		}
+	}
+	for hash, preimage := range spec.preimages {
+		if _, ok := s.preimages[hash]; !ok {
+			s.AddPreimage(hash, preimage)
+		}
+	}
+	return nil
+}
+
+// Recreated reports whether the account was destructed, deleted for being
+// empty or (re)created since Speculate.
+func (s *StateDB) Recreated(addr common.Address) bool {
+	_, ok := s.speculative[addr]
+	return ok
+}
@@ -0,0 +1,171 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ResourceUsage is the resource accounting gathered by a StateDB while the
+// tracker is installed. Reads are split by the layer that served them: the
+// live object cache, the snapshot or the trie. Unlike the block level timers
+// on StateDB, the counters are collected regardless of metrics.EnabledExpensive.
+type ResourceUsage struct {
+	AccountCacheHits     uint64        `json:"accountCacheHits"`     // Account lookups served from live state objects
+	AccountSnapshotReads uint64        `json:"accountSnapshotReads"` // Account lookups served from the snapshot
+	AccountTrieReads     uint64        `json:"accountTrieReads"`     // Account lookups served from the account trie
+	AccountReadTime      time.Duration `json:"accountReadTime"`      // Time spent on snapshot and trie account reads
+	StorageCacheHits     uint64        `json:"storageCacheHits"`     // Slot lookups served from dirty, pending or origin storage
+	StorageSnapshotReads uint64        `json:"storageSnapshotReads"` // Slot lookups served from the snapshot
+	StorageTrieReads     uint64        `json:"storageTrieReads"`     // Slot lookups served from the storage trie
+	StorageReadTime      time.Duration `json:"storageReadTime"`      // Time spent on snapshot and trie slot reads
+	AccountWrites        uint64        `json:"accountWrites"`        // Accounts dirtied, counted once per Finalise
+	StorageWrites        uint64        `json:"storageWrites"`        // Slots dirtied, counted once per Finalise
+	CodeLoads            uint64        `json:"codeLoads"`            // Contract code (or code size) lookups hitting the database
+	CodeBytes            uint64        `json:"codeBytes"`            // Total size of the contract code loaded from the database
+}
+
+// Add accumulates the counters of other into u.
+func (u *ResourceUsage) Add(other *ResourceUsage) {
+	u.AccountCacheHits += other.AccountCacheHits
+	u.AccountSnapshotReads += other.AccountSnapshotReads
+	u.AccountTrieReads += other.AccountTrieReads
+	u.AccountReadTime += other.AccountReadTime
+	u.StorageCacheHits += other.StorageCacheHits
+	u.StorageSnapshotReads += other.StorageSnapshotReads
+	u.StorageTrieReads += other.StorageTrieReads
+	u.StorageReadTime += other.StorageReadTime
+	u.AccountWrites += other.AccountWrites
+	u.StorageWrites += other.StorageWrites
+	u.CodeLoads += other.CodeLoads
+	u.CodeBytes += other.CodeBytes
+}
+
+// SetResourceUsage installs the tracker accumulating the resource usage of
+// all subsequent state accesses. Passing nil disables the accounting. The
+// tracker is not inherited by copies of the state.
+func (s *StateDB) SetResourceUsage(usage *ResourceUsage) {
+	s.usage = usage
+}
+
+// ResourceUsage returns the currently installed resource tracker, or nil if
+// the accounting is disabled.
+func (s *StateDB) ResourceUsage() *ResourceUsage {
+	return s.usage
+}
+
+// ReadSet is the set of accounts and storage slots read through a StateDB.
+// Account reads cover the existence, balance, nonce and code of an account,
+// slot reads both the current and the committed value of a slot. Blind writes,
+// such as crediting an account, are not reads.
+type ReadSet struct {
+	Accounts map[common.Address]struct{}
+	Slots    map[common.Address]map[common.Hash]struct{}
+}
+
+// NewReadSet creates an empty read set.
+func NewReadSet() *ReadSet {
+	return &ReadSet{
+		Accounts: make(map[common.Address]struct{}),
+		Slots:    make(map[common.Address]map[common.Hash]struct{}),
+	}
+}
+
+// SetReadSet installs the set gathering all subsequent reads of the state.
+// Passing nil disables the gathering. The set is not inherited by copies or
+// forks of the state.
+func (s *StateDB) SetReadSet(reads *ReadSet) {
+	s.reads = reads
+}
+
+// readAccount adds an account to the installed read set, if any.
+func (s *StateDB) readAccount(addr common.Address) {
+	if s.reads != nil {
+		s.reads.Accounts[addr] = struct{}{}
+	}
+}
+
+// readSlot adds a storage slot to the installed read set, if any.
+func (s *StateDB) readSlot(addr common.Address, key common.Hash) {
+	if s.reads == nil {
+		return
+	}
+	slots := s.reads.Slots[addr]
+	if slots == nil {
+		slots = make(map[common.Hash]struct{})
+		s.reads.Slots[addr] = slots
+	}
+	slots[key] = struct{}{}
+}
+
+// TxWrites is the set of accounts and storage slots modified by a single
+// transaction.
+type TxWrites struct {
+	TxHash   common.Hash
+	TxIndex  int
+	Accounts []common.Address
+	Slots    map[common.Address][]common.Hash
+}
+
+// StartWriteLog instructs the state to gather the write set of every
+// transaction finalised from now on. Writes made outside of a transaction
+// context (i.e. system calls before the first SetTxContext) are not logged.
+func (s *StateDB) StartWriteLog() {
+	s.writeLog, s.writeLogging = nil, true
+}
+
+// StopWriteLog terminates the write set gathering and returns the write sets
+// logged since StartWriteLog, in transaction order.
+func (s *StateDB) StopWriteLog() []*TxWrites {
+	log := s.writeLog
+	s.writeLog, s.writeLogging = nil, false
+	return log
+}
+
+// logWrites appends the accounts and slots dirtied in the current transaction
+// to the write log. It must be called from Finalise, before the dirty storage
+// is moved to the pending set.
+func (s *StateDB) logWrites() {
+	if s.thash == (common.Hash{}) || len(s.journal.dirties) == 0 {
+		return
+	}
+	// Transactions may be finalised multiple times (e.g. pre-Byzantium through
+	// IntermediateRoot), merge those into the same entry.
+	var writes *TxWrites
+	if n := len(s.writeLog); n > 0 && s.writeLog[n-1].TxHash == s.thash {
+		writes = s.writeLog[n-1]
+	} else {
+		writes = &TxWrites{
+			TxHash:  s.thash,
+			TxIndex: s.txIndex,
+			Slots:   make(map[common.Address][]common.Hash),
+		}
+		s.writeLog = append(s.writeLog, writes)
+	}
+	for addr := range s.journal.dirties {
+		obj, exist := s.stateObjects[addr]
+		if !exist {
+			continue
+		}
+		writes.Accounts = append(writes.Accounts, addr)
+		for key := range obj.dirtyStorage {
+			writes.Slots[addr] = append(writes.Slots[addr], key)
+		}
+	}
+}
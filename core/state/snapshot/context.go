@@ -85,23 +85,41 @@ func (gs *generatorStats) Log(msg string, root common.Hash, marker []byte) {
 	log.Info(msg, ctx...)
 }
 
-// generatorContext carries a few global values to be shared by all generation functions.
+// merge adds the counters of the given stats to these, resetting them.
+func (gs *generatorStats) merge(other *generatorStats) {
+	gs.accounts += other.accounts
+	gs.slots += other.slots
+	gs.dangling += other.dangling
+	gs.storage += other.storage
+
+	other.accounts, other.slots, other.dangling, other.storage = 0, 0, 0, 0
+}
+
+// generatorContext carries a few values to be shared by all generation functions
+// of an account range.
 type generatorContext struct {
-	stats   *generatorStats     // Generation statistic collection
+	stats   *generatorStats     // Generation statistic collection of the range
 	db      ethdb.KeyValueStore // Key-value store containing the snapshot data
 	account *holdableIterator   // Iterator of account snapshot data
 	storage *holdableIterator   // Iterator of storage snapshot data
 	batch   ethdb.Batch         // Database batch for writing batch data atomically
-	logged  time.Time           // The timestamp when last generation progress was displayed
+	start   []byte              // First account hash of the range, nil if unbounded
+	limit   []byte              // First account hash after the range, nil if unbounded
+
+	gen  *generator      // Generator coordinating the ranges
+	rng  *generatorRange // Account range being generated
+	stop chan struct{}   // Channel closed when the generation is interrupted
 }
 
-// newGeneratorContext initializes the context for generation.
-func newGeneratorContext(stats *generatorStats, db ethdb.KeyValueStore, accMarker []byte, storageMarker []byte) *generatorContext {
+// newGeneratorContext initializes the context for generating the account range
+// [start, limit), with the snapshot iterators opened at the given positions.
+func newGeneratorContext(stats *generatorStats, db ethdb.KeyValueStore, accMarker []byte, storageMarker []byte, start []byte, limit []byte) *generatorContext {
 	ctx := &generatorContext{
-		stats:  stats,
-		db:     db,
-		batch:  db.NewBatch(),
-		logged: time.Now(),
+		stats: stats,
+		db:    db,
+		batch: db.NewBatch(),
+		start: start,
+		limit: limit,
 	}
 	ctx.openIterator(snapAccount, accMarker)
 	ctx.openIterator(snapStorage, storageMarker)
@@ -114,11 +132,57 @@ func newGeneratorContext(stats *generatorStats, db ethdb.KeyValueStore, accMarke
 func (ctx *generatorContext) openIterator(kind string, start []byte) {
 	if kind == snapAccount {
 		iter := ctx.db.NewIterator(rawdb.SnapshotAccountPrefix, start)
-		ctx.account = newHoldableIterator(rawdb.NewKeyLengthIterator(iter, 1+common.HashLength))
+		ctx.account = newHoldableIterator(newLimitIterator(rawdb.NewKeyLengthIterator(iter, 1+common.HashLength), ctx.limit))
 		return
 	}
 	iter := ctx.db.NewIterator(rawdb.SnapshotStoragePrefix, start)
-	ctx.storage = newHoldableIterator(rawdb.NewKeyLengthIterator(iter, 1+2*common.HashLength))
+	ctx.storage = newHoldableIterator(newLimitIterator(rawdb.NewKeyLengthIterator(iter, 1+2*common.HashLength), ctx.limit))
+}
+
+// limitIterator wraps a snapshot iterator to stop at the entries of the given
+// account hash or above.
+type limitIterator struct {
+	ethdb.Iterator
+	limit []byte
+	done  bool
+}
+
+// newLimitIterator wraps the given iterator to stop at the given account hash,
+// or returns the iterator itself if the limit is nil.
+func newLimitIterator(it ethdb.Iterator, limit []byte) ethdb.Iterator {
+	if limit == nil {
+		return it
+	}
+	return &limitIterator{Iterator: it, limit: limit}
+}
+
+// Next moves the iterator to the next key/value pair, returning whether the
+// iterator is exhausted or reached the limit.
+func (it *limitIterator) Next() bool {
+	if it.done || !it.Iterator.Next() {
+		return false
+	}
+	if bytes.Compare(it.Iterator.Key()[1:], it.limit) >= 0 {
+		it.done = true
+		return false
+	}
+	return true
+}
+
+// Key returns the key of the current key/value pair, or nil if done.
+func (it *limitIterator) Key() []byte {
+	if it.done {
+		return nil
+	}
+	return it.Iterator.Key()
+}
+
+// Value returns the value of the current key/value pair, or nil if done.
+func (it *limitIterator) Value() []byte {
+	if it.done {
+		return nil
+	}
+	return it.Iterator.Value()
 }
 
 // reopenIterator releases the specified snapshot iterator and re-open it
@@ -39,12 +39,27 @@ type diskLayer struct {
 	stale bool        // Signals that the layer became stale (state progressed)
 
 	genMarker  []byte                    // Marker for the state that's indexed during initial layer generation
+	genRanges  []*generatorRange         // Progress of the concurrently generated account ranges
 	genPending chan struct{}             // Notification channel when generation is done (test synchronicity)
 	genAbort   chan chan *generatorStats // Notification channel to abort generating the snapshot in this layer
 
 	lock sync.RWMutex
 }
 
+// genCovered reports whether the given account or storage key is already covered
+// by the generator. The caller must hold the lock, or ensure the generator is not
+// running.
+func (dl *diskLayer) genCovered(key []byte) bool {
+	if dl.genMarker == nil {
+		return true
+	}
+	marker := dl.genMarker
+	if dl.genRanges != nil {
+		marker = dl.genRanges[rangeIndex(dl.genRanges, key)].marker
+	}
+	return marker == nil || bytes.Compare(key, marker) <= 0
+}
+
 // Release releases underlying resources; specifically the fastcache requires
 // Reset() in order to not leak memory.
 // OBS: It does not invoke Close on the diskdb
@@ -104,7 +119,7 @@ func (dl *diskLayer) AccountRLP(hash common.Hash) ([]byte, error) {
 	}
 	// If the layer is being generated, ensure the requested hash has already been
 	// covered by the generator.
-	if dl.genMarker != nil && bytes.Compare(hash[:], dl.genMarker) > 0 {
+	if !dl.genCovered(hash[:]) {
 		return nil, ErrNotCoveredYet
 	}
 	// If we're in the disk layer, all diff layers missed
@@ -144,7 +159,7 @@ func (dl *diskLayer) Storage(accountHash, storageHash common.Hash) ([]byte, erro
 
 	// If the layer is being generated, ensure the requested hash has already been
 	// covered by the generator.
-	if dl.genMarker != nil && bytes.Compare(key, dl.genMarker) > 0 {
+	if !dl.genCovered(key) {
 		return nil, ErrNotCoveredYet
 	}
 	// If we're in the disk layer, all diff layers missed
@@ -0,0 +1,501 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"runtime"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/golang/snappy"
+)
+
+// The flat state export format is a stream of RLP items:
+//
+//	header  = [magic, version, root]
+//	chunk   = [payload, checksum]
+//	payload = snappy([account, ...])
+//	account = [hash, slim-account, code, [[slot-hash, slot-value], ...]]
+//
+// The accounts and their slots are ordered by hash. Storage which doesn't fit in
+// a chunk is continued in the next one by an account entry with the same hash and
+// an empty account body. Contract codes are only included the first time they
+// are referenced. The stream is terminated by a chunk with an empty payload.
+const (
+	exportMagic     = "gethflatstate"
+	exportVersion   = 1
+	exportChunkSize = 4 * 1024 * 1024 // Uncompressed size after which a chunk is flushed
+)
+
+// exportHeader is the leading item of the flat state export.
+type exportHeader struct {
+	Magic   string
+	Version uint64
+	Root    common.Hash
+}
+
+// exportChunk is a checksummed batch of accounts in the flat state export.
+type exportChunk struct {
+	Payload  []byte // Snappy compressed RLP list of accounts
+	Checksum uint32 // CRC32 checksum of the compressed payload
+}
+
+// exportAccount is an account entry in the flat state export.
+type exportAccount struct {
+	Hash    common.Hash
+	Account []byte // Slim RLP encoded account, empty for storage continuations
+	Code    []byte // Contract code, empty if not set or exported before
+	Slots   []exportSlot
+}
+
+// exportSlot is a storage slot entry in the flat state export.
+type exportSlot struct {
+	Hash  common.Hash
+	Value []byte // RLP encoded slot value, as stored in the snapshot
+}
+
+// ExportState streams the accounts, storage slots and contract codes of the
+// given state from the snapshot into w, in the flat state export format. The
+// codes are read from the given database.
+func ExportState(t *Tree, root common.Hash, codedb ethdb.KeyValueReader, w io.Writer) error {
+	return exportState(t, root, codedb, w, exportChunkSize)
+}
+
+// stateExporter accumulates the flat state entries into chunks.
+type stateExporter struct {
+	w     io.Writer
+	limit int
+
+	accounts []exportAccount
+	size     int
+}
+
+// addAccount appends a new account entry to the current chunk.
+func (e *stateExporter) addAccount(acct exportAccount) error {
+	e.accounts = append(e.accounts, acct)
+	e.size += common.HashLength + len(acct.Account) + len(acct.Code)
+	if e.size >= e.limit {
+		return e.flush()
+	}
+	return nil
+}
+
+// addSlot appends a storage slot to the last account of the current chunk,
+// starting a continuation entry if the chunk was flushed in between.
+func (e *stateExporter) addSlot(account common.Hash, slot exportSlot) error {
+	if len(e.accounts) == 0 {
+		e.accounts = append(e.accounts, exportAccount{Hash: account})
+	}
+	last := &e.accounts[len(e.accounts)-1]
+	last.Slots = append(last.Slots, slot)
+	e.size += common.HashLength + len(slot.Value)
+	if e.size >= e.limit {
+		return e.flush()
+	}
+	return nil
+}
+
+// flush writes out the accumulated entries as a chunk.
+func (e *stateExporter) flush() error {
+	if len(e.accounts) == 0 {
+		return nil
+	}
+	blob, err := rlp.EncodeToBytes(e.accounts)
+	if err != nil {
+		return err
+	}
+	e.accounts, e.size = e.accounts[:0], 0
+	return e.writeChunk(snappy.Encode(nil, blob))
+}
+
+// writeChunk writes a chunk with the given payload to the output.
+func (e *stateExporter) writeChunk(payload []byte) error {
+	return rlp.Encode(e.w, &exportChunk{Payload: payload, Checksum: crc32.ChecksumIEEE(payload)})
+}
+
+func exportState(t *Tree, root common.Hash, codedb ethdb.KeyValueReader, w io.Writer, limit int) error {
+	accIt, err := t.AccountIterator(root, common.Hash{})
+	if err != nil {
+		return err
+	}
+	defer accIt.Release()
+
+	if err := rlp.Encode(w, &exportHeader{Magic: exportMagic, Version: exportVersion, Root: root}); err != nil {
+		return err
+	}
+	var (
+		exporter = &stateExporter{w: w, limit: limit}
+		codes    = make(map[common.Hash]struct{})
+
+		start    = time.Now()
+		logged   = time.Now()
+		accounts uint64
+		slots    uint64
+	)
+	for accIt.Next() {
+		acct, err := types.FullAccount(accIt.Account())
+		if err != nil {
+			return err
+		}
+		entry := exportAccount{
+			Hash:    accIt.Hash(),
+			Account: common.CopyBytes(accIt.Account()),
+		}
+		if codeHash := common.BytesToHash(acct.CodeHash); codeHash != types.EmptyCodeHash {
+			if _, ok := codes[codeHash]; !ok {
+				entry.Code = rawdb.ReadCode(codedb, codeHash)
+				if len(entry.Code) == 0 {
+					return fmt.Errorf("missing code %x of account %x", codeHash, accIt.Hash())
+				}
+				codes[codeHash] = struct{}{}
+			}
+		}
+		if err := exporter.addAccount(entry); err != nil {
+			return err
+		}
+		if acct.Root != types.EmptyRootHash {
+			stIt, err := t.StorageIterator(root, accIt.Hash(), common.Hash{})
+			if err != nil {
+				return err
+			}
+			for stIt.Next() {
+				if err := exporter.addSlot(accIt.Hash(), exportSlot{Hash: stIt.Hash(), Value: common.CopyBytes(stIt.Slot())}); err != nil {
+					stIt.Release()
+					return err
+				}
+				slots++
+			}
+			err = stIt.Error()
+			stIt.Release()
+			if err != nil {
+				return err
+			}
+		}
+		accounts++
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Exporting state", "at", accIt.Hash(), "accounts", accounts, "slots", slots, "codes", len(codes), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := accIt.Error(); err != nil {
+		return err
+	}
+	if err := exporter.flush(); err != nil {
+		return err
+	}
+	if err := exporter.writeChunk(nil); err != nil {
+		return err
+	}
+	log.Info("Exported state", "root", root, "accounts", accounts, "slots", slots, "codes", len(codes), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// importWriter is a database writer flushing the written entries in batches.
+// It's not thread safe.
+type importWriter struct {
+	db    ethdb.KeyValueStore
+	batch ethdb.Batch
+}
+
+func newImportWriter(db ethdb.KeyValueStore) *importWriter {
+	return &importWriter{db: db, batch: db.NewBatch()}
+}
+
+// Put implements ethdb.KeyValueWriter, flushing the batch if it grew too large.
+func (w *importWriter) Put(key []byte, value []byte) error {
+	if err := w.batch.Put(key, value); err != nil {
+		return err
+	}
+	if w.batch.ValueSize() >= ethdb.IdealBatchSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// Delete implements ethdb.KeyValueWriter.
+func (w *importWriter) Delete(key []byte) error {
+	return w.batch.Delete(key)
+}
+
+// flush writes out the pending entries.
+func (w *importWriter) flush() error {
+	if err := w.batch.Write(); err != nil {
+		return err
+	}
+	w.batch.Reset()
+	return nil
+}
+
+// trieImporter generates a trie in the background from the leaves fed to it.
+type trieImporter struct {
+	in   chan trieKV
+	done chan error
+}
+
+// newTrieImporter starts generating the trie of the given owner, persisting the
+// nodes into the database and checking the resulting root against the expected
+// one once the feed is closed.
+func newTrieImporter(db ethdb.KeyValueStore, scheme string, owner common.Hash, root common.Hash) *trieImporter {
+	imp := &trieImporter{
+		in:   make(chan trieKV, 1024),
+		done: make(chan error, 1),
+	}
+	go func() {
+		var (
+			writer = newImportWriter(db)
+			out    = make(chan common.Hash, 1)
+		)
+		stackTrieGenerate(writer, scheme, owner, imp.in, out)
+		if hash := <-out; hash != root {
+			if owner == (common.Hash{}) {
+				imp.done <- fmt.Errorf("state root mismatch: have %x, want %x", hash, root)
+			} else {
+				imp.done <- fmt.Errorf("storage root mismatch of account %x: have %x, want %x", owner, hash, root)
+			}
+			return
+		}
+		imp.done <- writer.flush()
+	}()
+	return imp
+}
+
+// stateImporter rebuilds the snapshot and the tries from the flat state entries.
+type stateImporter struct {
+	db     ethdb.KeyValueStore
+	scheme string
+	snap   *importWriter
+
+	accountTrie *trieImporter
+	storageTrie *trieImporter // Storage trie of the last account, nil if it has no storage
+	results     chan error    // Semaphore limiting and collecting the storage trie generations
+	threads     int
+
+	last     common.Hash              // Hash of the last imported account
+	lastSlot common.Hash              // Hash of the last imported slot of the last account
+	started  bool                     // Whether any account was imported
+	codes    map[common.Hash]struct{} // Contract codes imported so far
+	accounts uint64
+	slots    uint64
+}
+
+// ImportState reads a flat state export from r and rebuilds the snapshot and the
+// tries of the state into the database, using the given trie node scheme. The
+// storage tries are generated concurrently. Any mismatch with the state root of
+// the export aborts the import, leaving the database without a valid snapshot.
+// The database must not contain a snapshot yet.
+func ImportState(r io.Reader, db ethdb.KeyValueStore, scheme string) (common.Hash, error) {
+	if rawdb.ReadSnapshotRoot(db) != (common.Hash{}) {
+		return common.Hash{}, errors.New("database already contains a state snapshot")
+	}
+	stream := rlp.NewStream(r, 0)
+
+	var header exportHeader
+	if err := stream.Decode(&header); err != nil {
+		return common.Hash{}, fmt.Errorf("failed to read header: %w", err)
+	}
+	if header.Magic != exportMagic {
+		return common.Hash{}, errors.New("not a flat state export")
+	}
+	if header.Version != exportVersion {
+		return common.Hash{}, fmt.Errorf("unsupported flat state export version %d", header.Version)
+	}
+	imp := &stateImporter{
+		db:          db,
+		scheme:      scheme,
+		snap:        newImportWriter(db),
+		accountTrie: newTrieImporter(db, scheme, common.Hash{}, header.Root),
+		threads:     runtime.NumCPU(),
+		codes:       make(map[common.Hash]struct{}),
+	}
+	imp.results = make(chan error, imp.threads)
+	for i := 0; i < imp.threads; i++ {
+		imp.results <- nil // fill the semaphore
+	}
+	var (
+		start  = time.Now()
+		logged = time.Now()
+		err    error
+	)
+	for {
+		var chunk exportChunk
+		if err = stream.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			err = fmt.Errorf("failed to read chunk: %w", err)
+			break
+		}
+		if crc32.ChecksumIEEE(chunk.Payload) != chunk.Checksum {
+			err = errors.New("chunk checksum mismatch")
+			break
+		}
+		if len(chunk.Payload) == 0 {
+			break // End of the export
+		}
+		if err = imp.importChunk(chunk.Payload); err != nil {
+			break
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Importing state", "at", imp.last, "accounts", imp.accounts, "slots", imp.slots, "codes", len(imp.codes), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := imp.finish(err); err != nil {
+		return common.Hash{}, err
+	}
+	// Everything matched, mark the snapshot as complete
+	batch := db.NewBatch()
+	journalProgress(batch, nil, nil, &generatorStats{accounts: imp.accounts, slots: imp.slots})
+	rawdb.WriteSnapshotRoot(batch, header.Root)
+	if err := batch.Write(); err != nil {
+		return common.Hash{}, err
+	}
+	log.Info("Imported state", "root", header.Root, "accounts", imp.accounts, "slots", imp.slots, "codes", len(imp.codes), "elapsed", common.PrettyDuration(time.Since(start)))
+	return header.Root, nil
+}
+
+// importChunk imports the accounts of a chunk payload.
+func (imp *stateImporter) importChunk(payload []byte) error {
+	blob, err := snappy.Decode(nil, payload)
+	if err != nil {
+		return err
+	}
+	var accounts []exportAccount
+	if err := rlp.DecodeBytes(blob, &accounts); err != nil {
+		return err
+	}
+	for _, acct := range accounts {
+		if err := imp.importAccount(acct); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importAccount imports an account entry, or the continuation of the storage of
+// the last account.
+func (imp *stateImporter) importAccount(entry exportAccount) error {
+	if len(entry.Account) == 0 {
+		if !imp.started || entry.Hash != imp.last || imp.storageTrie == nil {
+			return fmt.Errorf("unexpected storage continuation of account %x", entry.Hash)
+		}
+		return imp.importSlots(entry.Hash, entry.Slots)
+	}
+	if imp.started && bytes.Compare(entry.Hash[:], imp.last[:]) <= 0 {
+		return fmt.Errorf("account %x out of order", entry.Hash)
+	}
+	if err := imp.finishStorage(); err != nil {
+		return err
+	}
+	acct, err := types.FullAccount(entry.Account)
+	if err != nil {
+		return err
+	}
+	codeHash := common.BytesToHash(acct.CodeHash)
+	if len(entry.Code) > 0 {
+		if crypto.Keccak256Hash(entry.Code) != codeHash {
+			return fmt.Errorf("code hash mismatch of account %x", entry.Hash)
+		}
+		rawdb.WriteCode(imp.snap, codeHash, entry.Code)
+		imp.codes[codeHash] = struct{}{}
+	} else if codeHash != types.EmptyCodeHash {
+		if _, ok := imp.codes[codeHash]; !ok {
+			return fmt.Errorf("missing code %x of account %x", codeHash, entry.Hash)
+		}
+	}
+	full, err := rlp.EncodeToBytes(acct)
+	if err != nil {
+		return err
+	}
+	rawdb.WriteAccountSnapshot(imp.snap, entry.Hash, entry.Account)
+	imp.accountTrie.in <- trieKV{entry.Hash, full}
+	imp.last, imp.lastSlot, imp.started = entry.Hash, common.Hash{}, true
+	imp.accounts++
+
+	if acct.Root != types.EmptyRootHash {
+		// Wait until the semaphore allows us to continue, aborting if a
+		// previous storage trie failed
+		if err := <-imp.results; err != nil {
+			imp.results <- nil // finish will drain the results, add a noop back for this error
+			return err
+		}
+		imp.storageTrie = newTrieImporter(imp.db, imp.scheme, entry.Hash, acct.Root)
+	} else if len(entry.Slots) > 0 {
+		return fmt.Errorf("unexpected storage of account %x", entry.Hash)
+	}
+	return imp.importSlots(entry.Hash, entry.Slots)
+}
+
+// importSlots imports the storage slots of the last account.
+func (imp *stateImporter) importSlots(account common.Hash, slots []exportSlot) error {
+	for _, slot := range slots {
+		if imp.lastSlot != (common.Hash{}) && bytes.Compare(slot.Hash[:], imp.lastSlot[:]) <= 0 {
+			return fmt.Errorf("slot %x of account %x out of order", slot.Hash, account)
+		}
+		imp.lastSlot = slot.Hash
+		rawdb.WriteStorageSnapshot(imp.snap, account, slot.Hash, slot.Value)
+		imp.storageTrie.in <- trieKV{slot.Hash, slot.Value}
+		imp.slots++
+	}
+	return nil
+}
+
+// finishStorage closes the storage trie generation of the last account, leaving
+// its result to the semaphore.
+func (imp *stateImporter) finishStorage() error {
+	if imp.storageTrie == nil {
+		return nil
+	}
+	tr := imp.storageTrie
+	imp.storageTrie = nil
+
+	close(tr.in)
+	go func() { imp.results <- <-tr.done }()
+	return nil
+}
+
+// finish waits for all the trie generations to finish, returning the
+// first error encountered, including the given one.
+func (imp *stateImporter) finish(fail error) error {
+	if err := imp.finishStorage(); err != nil && fail == nil {
+		fail = err
+	}
+	close(imp.accountTrie.in)
+	if err := <-imp.accountTrie.done; err != nil && fail == nil {
+		fail = err
+	}
+	for i := 0; i < imp.threads; i++ {
+		if err := <-imp.results; err != nil && fail == nil {
+			fail = err
+		}
+	}
+	if err := imp.snap.flush(); err != nil && fail == nil {
+		fail = err
+	}
+	return fail
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
+	"github.com/holiman/uint256"
+)
+
+// Tests that a state exported from the snapshot can be imported into an empty
+// database, rebuilding both the snapshot and the tries.
+func TestExportImportState(t *testing.T) {
+	testExportImportState(t, rawdb.HashScheme)
+	testExportImportState(t, rawdb.PathScheme)
+}
+
+func testExportImportState(t *testing.T, scheme string) {
+	var (
+		helper = newHelper(scheme)
+		code   = []byte{0x60, 0x01, 0x60, 0x02}
+		keys   []string
+		vals   []string
+	)
+	for i := 0; i < 100; i++ {
+		keys = append(keys, fmt.Sprintf("key-%d", i))
+		vals = append(vals, fmt.Sprintf("val-%d", i))
+	}
+	rawdb.WriteCode(helper.diskdb, crypto.Keccak256Hash(code), code)
+	for i, name := range []string{"acc-1", "acc-2", "acc-3", "acc-4"} {
+		acct := &types.StateAccount{Balance: uint256.NewInt(uint64(i)), Root: types.EmptyRootHash, CodeHash: types.EmptyCodeHash.Bytes()}
+		if i%2 == 0 {
+			// Two contracts sharing the code, with large storage
+			acct.Root = helper.makeStorageTrie(hashData([]byte(name)), keys, vals, true)
+			acct.CodeHash = crypto.Keccak256(code)
+		}
+		helper.addTrieAccount(name, acct)
+	}
+	root := helper.Commit()
+	snaps, err := New(Config{CacheSize: 16}, helper.diskdb, helper.triedb, root)
+	if err != nil {
+		t.Fatalf("failed to generate snapshot: %v", err)
+	}
+	// Export with tiny chunks to split the storage across multiple chunks
+	var export bytes.Buffer
+	if err := exportState(snaps, root, helper.diskdb, &export, 256); err != nil {
+		t.Fatalf("failed to export state: %v", err)
+	}
+	db := rawdb.NewMemoryDatabase()
+	imported, err := ImportState(bytes.NewReader(export.Bytes()), db, scheme)
+	if err != nil {
+		t.Fatalf("failed to import state: %v", err)
+	}
+	if imported != root || rawdb.ReadSnapshotRoot(db) != root {
+		t.Fatalf("imported root mismatch: have %x, want %x", imported, root)
+	}
+	// The imported snapshot must be complete and identical to the original one
+	config := triedb.HashDefaults
+	if scheme == rawdb.PathScheme {
+		config = &triedb.Config{PathDB: pathdb.Defaults}
+	}
+	tdb := triedb.NewDatabase(db, config)
+	isnaps, err := New(Config{CacheSize: 16, NoBuild: true}, db, tdb, root)
+	if err != nil {
+		t.Fatalf("failed to load imported snapshot: %v", err)
+	}
+	var reexport bytes.Buffer
+	if err := exportState(isnaps, root, db, &reexport, 256); err != nil {
+		t.Fatalf("failed to export imported state: %v", err)
+	}
+	if !bytes.Equal(export.Bytes(), reexport.Bytes()) {
+		t.Fatal("re-exported state mismatch")
+	}
+	// The imported tries must be complete
+	tr, err := trie.New(trie.StateTrieID(root), tdb)
+	if err != nil {
+		t.Fatalf("failed to open imported trie: %v", err)
+	}
+	it, _ := tr.NodeIterator(nil)
+	for it.Next(true) {
+	}
+	if it.Error() != nil {
+		t.Fatalf("imported account trie incomplete: %v", it.Error())
+	}
+	stRoot := helper.makeStorageTrie(common.Hash{}, keys, vals, false)
+	st, err := trie.New(trie.StorageTrieID(root, hashData([]byte("acc-3")), stRoot), tdb)
+	if err != nil {
+		t.Fatalf("failed to open imported storage trie: %v", err)
+	}
+	for i, key := range keys {
+		if val, err := st.Get(crypto.Keccak256([]byte(key))); err != nil || string(val) != vals[i] {
+			t.Fatalf("imported slot %s mismatch: %q, %v", key, val, err)
+		}
+	}
+	// Importing into a database with a snapshot is rejected
+	if _, err := ImportState(bytes.NewReader(export.Bytes()), db, scheme); err == nil {
+		t.Fatal("import into database with snapshot succeeded")
+	}
+	// Corrupted and truncated exports are rejected
+	corrupted := common.CopyBytes(export.Bytes())
+	corrupted[len(corrupted)/2] ^= 0xff
+	if _, err := ImportState(bytes.NewReader(corrupted), rawdb.NewMemoryDatabase(), scheme); err == nil {
+		t.Fatal("corrupted export imported")
+	}
+	truncated := export.Bytes()[:export.Len()-3] // Drop the terminating chunk
+	if _, err := ImportState(bytes.NewReader(truncated), rawdb.NewMemoryDatabase(), scheme); err == nil {
+		t.Fatal("truncated export imported")
+	}
+}
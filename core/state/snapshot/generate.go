@@ -62,9 +62,10 @@ func generateSnapshot(diskdb ethdb.KeyValueStore, triedb *triedb.Database, cache
 		stats     = &generatorStats{start: time.Now()}
 		batch     = diskdb.NewBatch()
 		genMarker = []byte{} // Initialized but empty!
+		genRanges = newGeneratorRanges(generatorRanges, genMarker)
 	)
 	rawdb.WriteSnapshotRoot(batch, root)
-	journalProgress(batch, genMarker, stats)
+	journalProgress(batch, genMarker, genRanges, stats)
 	if err := batch.Write(); err != nil {
 		log.Crit("Failed to write initialized state marker", "err", err)
 	}
@@ -74,6 +75,7 @@ func generateSnapshot(diskdb ethdb.KeyValueStore, triedb *triedb.Database, cache
 		root:       root,
 		cache:      fastcache.New(cache * 1024 * 1024),
 		genMarker:  genMarker,
+		genRanges:  genRanges,
 		genPending: make(chan struct{}),
 		genAbort:   make(chan chan *generatorStats),
 	}
@@ -83,7 +85,9 @@ func generateSnapshot(diskdb ethdb.KeyValueStore, triedb *triedb.Database, cache
 }
 
 // journalProgress persists the generator stats into the database to resume later.
-func journalProgress(db ethdb.KeyValueWriter, marker []byte, stats *generatorStats) {
+// The progress of the concurrently generated account ranges is persisted along,
+// if available.
+func journalProgress(db ethdb.KeyValueWriter, marker []byte, ranges []*generatorRange, stats *generatorStats) {
 	// Write out the generator marker. Note it's a standalone disk layer generator
 	// which is not mixed with journal. It's ok if the generator is persisted while
 	// journal is not.
@@ -113,6 +117,12 @@ func journalProgress(db ethdb.KeyValueWriter, marker []byte, stats *generatorSta
 	}
 	log.Debug("Journalled generator progress", "progress", logstr)
 	rawdb.WriteSnapshotGenerator(db, blob)
+
+	if marker == nil || ranges == nil {
+		rawdb.DeleteSnapshotGeneratorRanges(db)
+	} else {
+		rawdb.WriteSnapshotGeneratorRanges(db, encodeRanges(marker, ranges))
+	}
 }
 
 // proofResult contains the output of range proving which can be used
@@ -226,9 +236,10 @@ func (dl *diskLayer) proveRange(ctx *generatorContext, trieId *trie.ID, prefix [
 		}
 	}(time.Now())
 
-	// The snap state is exhausted, pass the entire key/val set for verification
+	// The snap state is exhausted, pass the entire key/val set for verification.
+	// It's only possible if the entire trie is covered, not a range of it.
 	root := trieId.Root
-	if origin == nil && !diskMore {
+	if origin == nil && !diskMore && (kind == snapStorage || ctx.limit == nil) {
 		stackTr := trie.NewStackTrie(nil)
 		for i, key := range keys {
 			if err := stackTr.Update(key, vals[i]); err != nil {
@@ -247,7 +258,7 @@ func (dl *diskLayer) proveRange(ctx *generatorContext, trieId *trie.ID, prefix [
 	// Snap state is chunked, generate edge proofs for verification.
 	tr, err := trie.New(trieId, dl.triedb)
 	if err != nil {
-		ctx.stats.Log("Trie missing, state snapshotting paused", dl.root, dl.genMarker)
+		ctx.gen.log("Trie missing, state snapshotting paused")
 		return nil, errMissingTrie
 	}
 	// Generate the Merkle proofs for the first and last element
@@ -378,7 +389,7 @@ func (dl *diskLayer) generateRange(ctx *generatorContext, trieId *trie.ID, prefi
 	if tr == nil {
 		tr, err = trie.New(trieId, dl.triedb)
 		if err != nil {
-			ctx.stats.Log("Trie missing, state snapshotting paused", dl.root, dl.genMarker)
+			ctx.gen.log("Trie missing, state snapshotting paused")
 			return false, nil, errMissingTrie
 		}
 	}
@@ -409,6 +420,10 @@ func (dl *diskLayer) generateRange(ctx *generatorContext, trieId *trie.ID, prefi
 			trieMore = true
 			break
 		}
+		// Stop at the end of the account range, the rest belongs to others
+		if kind == snapAccount && ctx.limit != nil && bytes.Compare(iter.Key, ctx.limit) >= 0 {
+			break
+		}
 		count++
 		write := true
 		created++
@@ -477,41 +492,30 @@ func (dl *diskLayer) generateRange(ctx *generatorContext, trieId *trie.ID, prefi
 // checkAndFlush checks if an interruption signal is received or the
 // batch size has exceeded the allowance.
 func (dl *diskLayer) checkAndFlush(ctx *generatorContext, current []byte) error {
-	var abort chan *generatorStats
+	var abort bool
 	select {
-	case abort = <-dl.genAbort:
+	case <-ctx.stop:
+		abort = true
 	default:
 	}
-	if ctx.batch.ValueSize() > ethdb.IdealBatchSize || abort != nil {
-		if bytes.Compare(current, dl.genMarker) < 0 {
-			log.Error("Snapshot generator went backwards", "current", fmt.Sprintf("%x", current), "genMarker", fmt.Sprintf("%x", dl.genMarker))
+	if ctx.batch.ValueSize() > ethdb.IdealBatchSize || abort {
+		if bytes.Compare(current, ctx.rng.marker) < 0 {
+			log.Error("Snapshot generator went backwards", "current", fmt.Sprintf("%x", current), "genMarker", fmt.Sprintf("%x", ctx.rng.marker))
 		}
 		// Flush out the batch anyway no matter it's empty or not.
 		// It's possible that all the states are recovered and the
 		// generation indeed makes progress.
-		journalProgress(ctx.batch, current, ctx.stats)
-
-		if err := ctx.batch.Write(); err != nil {
+		if err := ctx.gen.flush(ctx, current); err != nil {
 			return err
 		}
-		ctx.batch.Reset()
-
-		dl.lock.Lock()
-		dl.genMarker = current
-		dl.lock.Unlock()
-
-		if abort != nil {
-			ctx.stats.Log("Aborting state snapshot generation", dl.root, current)
-			return newAbortErr(abort) // bubble up an error for interruption
+		if abort {
+			return errGeneratorAborted // bubble up an error for interruption
 		}
 		// Don't hold the iterators too long, release them to let compactor works
 		ctx.reopenIterator(snapAccount)
 		ctx.reopenIterator(snapStorage)
 	}
-	if time.Since(ctx.logged) > 8*time.Second {
-		ctx.stats.Log("Generating state snapshot", dl.root, current)
-		ctx.logged = time.Now()
-	}
+	ctx.gen.report()
 	return nil
 }
 
@@ -563,9 +567,10 @@ func generateStorages(ctx *generatorContext, dl *diskLayer, stateRoot common.Has
 }
 
 // generateAccounts generates the missing snapshot accounts as well as their
-// storage slots in the main trie. It's supposed to restart the generation
-// from the given origin position.
-func generateAccounts(ctx *generatorContext, dl *diskLayer, accMarker []byte) error {
+// storage slots in the main trie, within the account range of the context.
+// It's supposed to restart the generation from the given origin position,
+// with the full generation marker of the interruption.
+func generateAccounts(ctx *generatorContext, dl *diskLayer, accMarker []byte, genMarker []byte) error {
 	onAccount := func(key []byte, val []byte, write bool, delete bool) error {
 		// Make sure to clear all dangling storages before this account
 		account := common.BytesToHash(key)
@@ -608,8 +613,8 @@ func generateAccounts(ctx *generatorContext, dl *diskLayer, accMarker []byte) er
 		// If the snap generation goes here after interrupted, genMarker may go backward
 		// when last genMarker is consisted of accountHash and storageHash
 		marker := account[:]
-		if accMarker != nil && bytes.Equal(marker, accMarker) && len(dl.genMarker) > common.HashLength {
-			marker = dl.genMarker[:]
+		if accMarker != nil && bytes.Equal(marker, accMarker) && len(genMarker) > common.HashLength {
+			marker = genMarker
 		}
 		// If we've exceeded our batch allowance or termination was requested, flush to disk
 		if err := dl.checkAndFlush(ctx, marker); err != nil {
@@ -623,8 +628,8 @@ func generateAccounts(ctx *generatorContext, dl *diskLayer, accMarker []byte) er
 			ctx.removeStorageAt(account)
 		} else {
 			var storeMarker []byte
-			if accMarker != nil && bytes.Equal(account[:], accMarker) && len(dl.genMarker) > common.HashLength {
-				storeMarker = dl.genMarker[common.HashLength:]
+			if accMarker != nil && bytes.Equal(account[:], accMarker) && len(genMarker) > common.HashLength {
+				storeMarker = genMarker[common.HashLength:]
 			}
 			if err := generateStorages(ctx, dl, dl.root, account, acc.Root, storeMarker); err != nil {
 				return err
@@ -641,6 +646,9 @@ func generateAccounts(ctx *generatorContext, dl *diskLayer, accMarker []byte) er
 		accountRange = 1
 	}
 	origin := common.CopyBytes(accMarker)
+	if origin == nil {
+		origin = common.CopyBytes(ctx.start)
+	}
 	for {
 		id := trie.StateTrieID(dl.root)
 		exhausted, last, err := dl.generateRange(ctx, id, rawdb.SnapshotAccountPrefix, snapAccount, origin, accountRange, onAccount, types.FullAccountRLP)
@@ -651,7 +659,7 @@ func generateAccounts(ctx *generatorContext, dl *diskLayer, accMarker []byte) er
 
 		// Last step, cleanup the storages after the last account.
 		// All the left storages should be treated as dangling.
-		if origin == nil || exhausted {
+		if origin == nil || exhausted || (ctx.limit != nil && bytes.Compare(origin, ctx.limit) >= 0) {
 			ctx.removeStorageLeft()
 			break
 		}
@@ -660,69 +668,6 @@ func generateAccounts(ctx *generatorContext, dl *diskLayer, accMarker []byte) er
 	return nil
 }
 
-// generate is a background thread that iterates over the state and storage tries,
-// constructing the state snapshot. All the arguments are purely for statistics
-// gathering and logging, since the method surfs the blocks as they arrive, often
-// being restarted.
-func (dl *diskLayer) generate(stats *generatorStats) {
-	var (
-		accMarker []byte
-		abort     chan *generatorStats
-	)
-	if len(dl.genMarker) > 0 { // []byte{} is the start, use nil for that
-		accMarker = dl.genMarker[:common.HashLength]
-	}
-	stats.Log("Resuming state snapshot generation", dl.root, dl.genMarker)
-
-	// Initialize the global generator context. The snapshot iterators are
-	// opened at the interrupted position because the assumption is held
-	// that all the snapshot data are generated correctly before the marker.
-	// Even if the snapshot data is updated during the interruption (before
-	// or at the marker), the assumption is still held.
-	// For the account or storage slot at the interruption, they will be
-	// processed twice by the generator(they are already processed in the
-	// last run) but it's fine.
-	ctx := newGeneratorContext(stats, dl.diskdb, accMarker, dl.genMarker)
-	defer ctx.close()
-
-	if err := generateAccounts(ctx, dl, accMarker); err != nil {
-		// Extract the received interruption signal if exists
-		if aerr, ok := err.(*abortErr); ok {
-			abort = aerr.abort
-		}
-		// Aborted by internal error, wait the signal
-		if abort == nil {
-			abort = <-dl.genAbort
-		}
-		abort <- stats
-		return
-	}
-	// Snapshot fully generated, set the marker to nil.
-	// Note even there is nothing to commit, persist the
-	// generator anyway to mark the snapshot is complete.
-	journalProgress(ctx.batch, nil, stats)
-	if err := ctx.batch.Write(); err != nil {
-		log.Error("Failed to flush batch", "err", err)
-
-		abort = <-dl.genAbort
-		abort <- stats
-		return
-	}
-	ctx.batch.Reset()
-
-	log.Info("Generated state snapshot", "accounts", stats.accounts, "slots", stats.slots,
-		"storage", stats.storage, "dangling", stats.dangling, "elapsed", common.PrettyDuration(time.Since(stats.start)))
-
-	dl.lock.Lock()
-	dl.genMarker = nil
-	close(dl.genPending)
-	dl.lock.Unlock()
-
-	// Someone will be looking for us, wait it out
-	abort = <-dl.genAbort
-	abort <- nil
-}
-
 // increaseKey increase the input key by one bit. Return nil if the entire
 // addition operation overflows.
 func increaseKey(key []byte) []byte {
@@ -734,17 +679,3 @@ func increaseKey(key []byte) []byte {
 	}
 	return nil
 }
-
-// abortErr wraps an interruption signal received to represent the
-// generation is aborted by external processes.
-type abortErr struct {
-	abort chan *generatorStats
-}
-
-func newAbortErr(abort chan *generatorStats) error {
-	return &abortErr{abort: abort}
-}
-
-func (err *abortErr) Error() string {
-	return "aborted"
-}
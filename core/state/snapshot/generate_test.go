@@ -17,6 +17,7 @@
 package snapshot
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"testing"
@@ -967,3 +968,147 @@ func testGenerateBrokenSnapshotWithDanglingStorage(t *testing.T, scheme string)
 	snap.genAbort <- stop
 	<-stop
 }
+
+// Tests the splitting of the account hash space into generator ranges and the
+// persistence of their progress.
+func TestGenerateRanges(t *testing.T) {
+	// Ranges derived from a contiguous marker
+	marker := append(common.Hash{0x50}.Bytes(), common.Hash{0x01}.Bytes()...)
+	ranges := newGeneratorRanges(4, marker)
+	if ranges[0].marker != nil || ranges[1].marker == nil || !bytes.Equal(ranges[1].marker, marker) {
+		t.Fatalf("covered ranges mismatch: %x, %x", ranges[0].marker, ranges[1].marker)
+	}
+	if len(ranges[2].marker) != 0 || ranges[2].marker == nil || len(ranges[3].marker) != 0 || ranges[3].marker == nil {
+		t.Fatalf("uncovered ranges mismatch: %x, %x", ranges[2].marker, ranges[3].marker)
+	}
+	if !bytes.Equal(ranges[2].start, common.Hash{0x80}.Bytes()) || !bytes.Equal(ranges[2].limit, common.Hash{0xc0}.Bytes()) || ranges[3].limit != nil {
+		t.Fatalf("range bounds mismatch: %x-%x, %x", ranges[2].start, ranges[2].limit, ranges[3].limit)
+	}
+	if idx := rangeIndex(ranges, common.Hash{0xbf}.Bytes()); idx != 2 {
+		t.Fatalf("range index mismatch: have %d, want 2", idx)
+	}
+	if have := contiguousMarker(ranges); !bytes.Equal(have, marker) {
+		t.Fatalf("contiguous marker mismatch: have %x, want %x", have, marker)
+	}
+	// Finish the second range, everything before the third one is covered
+	ranges[1].marker = nil
+	want := common.HexToHash("0x7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff").Bytes()
+	if have := contiguousMarker(ranges); !bytes.Equal(have, want) {
+		t.Fatalf("contiguous marker mismatch: have %x, want %x", have, want)
+	}
+	// Persist and reload the progress
+	ranges[3].marker = common.Hash{0xd0}.Bytes()
+	db := rawdb.NewMemoryDatabase()
+	journalProgress(db, want, ranges, nil)
+
+	loaded := loadRanges(db, want)
+	if len(loaded) != len(ranges) {
+		t.Fatalf("loaded range count mismatch: have %d, want %d", len(loaded), len(ranges))
+	}
+	for i := range ranges {
+		if (loaded[i].marker == nil) != (ranges[i].marker == nil) || !bytes.Equal(loaded[i].marker, ranges[i].marker) {
+			t.Fatalf("range %d: loaded marker mismatch: have %x, want %x", i, loaded[i].marker, ranges[i].marker)
+		}
+	}
+	// Progress not belonging to the generator marker is discarded
+	if loaded := loadRanges(db, []byte{}); len(loaded) != generatorRanges || len(loaded[len(loaded)-1].marker) != 0 {
+		t.Fatal("mismatching range progress loaded")
+	}
+	// Progress is dropped once the generation finished
+	journalProgress(db, nil, ranges, nil)
+	if blob := rawdb.ReadSnapshotGeneratorRanges(db); blob != nil {
+		t.Fatalf("range progress not deleted: %x", blob)
+	}
+}
+
+// Tests that the snapshot generation resumes the account ranges from their own
+// persisted progress, skipping the finished ones.
+func TestGenerateResumeRanges(t *testing.T) {
+	testGenerateResumeRanges(t, rawdb.HashScheme)
+	testGenerateResumeRanges(t, rawdb.PathScheme)
+}
+
+func testGenerateResumeRanges(t *testing.T, scheme string) {
+	var (
+		helper = newHelper(scheme)
+		keys   = []string{"key-1", "key-2", "key-3"}
+		vals   = []string{"val-1", "val-2", "val-3"}
+		stRoot = helper.makeStorageTrie(common.Hash{}, keys, vals, false)
+	)
+	for i := 0; i < 256; i++ {
+		name := fmt.Sprintf("acc-%d", i)
+		acct := &types.StateAccount{Balance: uint256.NewInt(uint64(i)), Root: types.EmptyRootHash, CodeHash: types.EmptyCodeHash.Bytes()}
+		if i%4 == 0 {
+			helper.makeStorageTrie(hashData([]byte(name)), keys, vals, true)
+			acct.Root = stRoot
+		}
+		helper.addTrieAccount(name, acct)
+	}
+	root, snap := helper.CommitAndGenerate()
+	select {
+	case <-snap.genPending:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Snapshot generation failed")
+	}
+	stop := make(chan *generatorStats)
+	snap.genAbort <- stop
+	<-stop
+
+	// Wipe the first and the third quarter of the snapshot, interrupt the second
+	// one in the middle and mark the last one done, planting a bogus account in
+	// it which must survive the generation.
+	ranges := newGeneratorRanges(4, []byte{})
+	ranges[3].marker = nil
+
+	var inSecond []common.Hash
+	it := rawdb.NewKeyLengthIterator(helper.diskdb.NewIterator(rawdb.SnapshotAccountPrefix, nil), 1+common.HashLength)
+	for it.Next() {
+		hash := common.BytesToHash(it.Key()[1:])
+		switch rangeIndex(ranges, hash[:]) {
+		case 0, 2:
+			rawdb.DeleteAccountSnapshot(helper.diskdb, hash)
+			st := rawdb.IterateStorageSnapshots(helper.diskdb, hash)
+			for st.Next() {
+				helper.diskdb.Delete(st.Key())
+			}
+			st.Release()
+		case 1:
+			inSecond = append(inSecond, hash)
+		}
+	}
+	it.Release()
+	ranges[1].marker = inSecond[len(inSecond)/2].Bytes()
+	for _, hash := range inSecond[len(inSecond)/2+1:] {
+		rawdb.DeleteAccountSnapshot(helper.diskdb, hash)
+	}
+	bogus := common.Hash{0xff, 0xff}
+	rawdb.WriteAccountSnapshot(helper.diskdb, bogus, types.SlimAccountRLP(types.StateAccount{Balance: uint256.NewInt(1), Root: types.EmptyRootHash, CodeHash: types.EmptyCodeHash.Bytes()}))
+
+	batch := helper.diskdb.NewBatch()
+	journalProgress(batch, contiguousMarker(ranges), ranges, &generatorStats{})
+	if err := batch.Write(); err != nil {
+		t.Fatalf("Failed to write generator progress: %v", err)
+	}
+	layer, _, err := loadSnapshot(helper.diskdb, helper.triedb, root, 16, false, false)
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+	snap = layer.(*diskLayer)
+	select {
+	case <-snap.genPending:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Snapshot generation failed")
+	}
+	if rawdb.ReadAccountSnapshot(helper.diskdb, bogus) == nil {
+		t.Fatal("Finished range regenerated")
+	}
+	if blob := rawdb.ReadSnapshotGeneratorRanges(helper.diskdb); blob != nil {
+		t.Fatal("Range progress not deleted")
+	}
+	rawdb.DeleteAccountSnapshot(helper.diskdb, bogus)
+	checkSnapRoot(t, snap, root)
+
+	stop = make(chan *generatorStats)
+	snap.genAbort <- stop
+	<-stop
+}
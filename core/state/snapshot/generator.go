@@ -0,0 +1,357 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// generatorRanges is the number of account hash ranges the snapshot is split
+// into and generated concurrently. It must be a power of two, at most 256.
+var generatorRanges = 16
+
+// errGeneratorAborted is returned by the range generators if the generation was
+// interrupted.
+var errGeneratorAborted = errors.New("aborted")
+
+// generatorRange is a part of the account hash space which is generated
+// independently of the others.
+type generatorRange struct {
+	start []byte // First account hash of the range, nil for the first range
+	limit []byte // First account hash after the range, nil for the last range
+
+	// marker is the generation progress within the range, in the same format as
+	// the generation marker of the disk layer: nil if the range is done, empty
+	// if it's not started yet.
+	marker []byte
+}
+
+// newGeneratorRanges splits the account hash space into n ranges, with the
+// progress derived from the given contiguous generation marker.
+func newGeneratorRanges(n int, marker []byte) []*generatorRange {
+	ranges := make([]*generatorRange, n)
+	for i := range ranges {
+		rng := &generatorRange{}
+		if i > 0 {
+			rng.start = make([]byte, common.HashLength)
+			rng.start[0] = byte(i * 256 / n)
+		}
+		if i < n-1 {
+			rng.limit = make([]byte, common.HashLength)
+			rng.limit[0] = byte((i + 1) * 256 / n)
+		}
+		switch {
+		case marker == nil:
+			// Generation finished
+		case len(marker) == 0 || (rng.start != nil && bytes.Compare(marker, rng.start) < 0):
+			rng.marker = []byte{}
+		case rng.limit != nil && bytes.Compare(marker, rng.limit) >= 0:
+			// Range fully covered by the marker
+		default:
+			rng.marker = common.CopyBytes(marker)
+		}
+		ranges[i] = rng
+	}
+	return ranges
+}
+
+// rangeIndex returns the index of the range containing the given key.
+func rangeIndex(ranges []*generatorRange, key []byte) int {
+	if len(key) == 0 {
+		return 0
+	}
+	return int(key[0]) * len(ranges) / 256
+}
+
+// contiguousMarker returns the generation marker below which all the ranges
+// are completely generated, nil if all the ranges are done.
+func contiguousMarker(ranges []*generatorRange) []byte {
+	for _, rng := range ranges {
+		if rng.marker == nil {
+			continue
+		}
+		if len(rng.marker) > 0 || rng.start == nil {
+			return rng.marker
+		}
+		// Nothing generated in the range, everything before it is covered
+		marker := common.CopyBytes(rng.start)
+		for i := len(marker) - 1; i >= 0; i-- {
+			marker[i]--
+			if marker[i] != 0xff {
+				break
+			}
+		}
+		return marker
+	}
+	return nil
+}
+
+// journalRange is the persisted progress of a generator range.
+type journalRange struct {
+	Done   bool
+	Marker []byte
+}
+
+// journalRanges is the persisted progress of all the generator ranges.
+type journalRanges struct {
+	Marker []byte // Contiguous generation marker the ranges belong to
+	Ranges []journalRange
+}
+
+// encodeRanges serializes the progress of the given ranges.
+func encodeRanges(marker []byte, ranges []*generatorRange) []byte {
+	entry := journalRanges{Marker: marker}
+	for _, rng := range ranges {
+		entry.Ranges = append(entry.Ranges, journalRange{Done: rng.marker == nil, Marker: rng.marker})
+	}
+	blob, err := rlp.EncodeToBytes(entry)
+	if err != nil {
+		panic(err) // Cannot happen, here to catch dev errors
+	}
+	return blob
+}
+
+// loadRanges restores the progress of the generator ranges persisted along the
+// given generation marker. If the progress is missing or doesn't belong to the
+// marker, it's derived from the marker itself.
+func loadRanges(db ethdb.KeyValueReader, marker []byte) []*generatorRange {
+	var entry journalRanges
+	if blob := rawdb.ReadSnapshotGeneratorRanges(db); len(blob) > 0 {
+		if err := rlp.DecodeBytes(blob, &entry); err != nil {
+			log.Warn("Failed to decode snapshot generator ranges", "err", err)
+		}
+	}
+	n := len(entry.Ranges)
+	if n == 0 || n > 256 || n&(n-1) != 0 || !bytes.Equal(entry.Marker, marker) {
+		return newGeneratorRanges(generatorRanges, marker)
+	}
+	ranges := newGeneratorRanges(n, nil)
+	for i, rng := range ranges {
+		if !entry.Ranges[i].Done {
+			rng.marker = entry.Ranges[i].Marker
+			if rng.marker == nil {
+				rng.marker = []byte{}
+			}
+		}
+	}
+	return ranges
+}
+
+// generator coordinates the concurrent generation of the account ranges of a
+// disk layer.
+type generator struct {
+	dl     *diskLayer
+	stats  *generatorStats // Statistics of all the ranges, merged on flush
+	logged time.Time       // The timestamp when last generation progress was displayed
+	lock   sync.Mutex      // Lock serializing the progress updates
+	stop   chan struct{}   // Channel closed to interrupt all the range generators
+}
+
+// run generates the snapshot of an account range, resuming from its marker.
+func (gen *generator) run(rng *generatorRange) error {
+	select {
+	case <-gen.stop:
+		return errGeneratorAborted
+	default:
+	}
+	var (
+		accMarker []byte
+		accStart  = rng.start
+		stStart   = rng.start
+	)
+	if len(rng.marker) > 0 {
+		accMarker = common.CopyBytes(rng.marker[:common.HashLength])
+		accStart, stStart = accMarker, rng.marker
+	}
+	ctx := newGeneratorContext(&generatorStats{}, gen.dl.diskdb, accStart, stStart, rng.start, rng.limit)
+	defer ctx.close()
+
+	ctx.gen, ctx.rng, ctx.stop = gen, rng, gen.stop
+	if err := generateAccounts(ctx, gen.dl, accMarker, rng.marker); err != nil {
+		return err
+	}
+	// The range is complete, flush the leftovers and mark it done
+	return gen.flush(ctx, nil)
+}
+
+// flush writes out the batch of a range generator, then persists its progress
+// along with the progress of all the other ranges.
+func (gen *generator) flush(ctx *generatorContext, marker []byte) error {
+	if err := ctx.batch.Write(); err != nil {
+		return err
+	}
+	ctx.batch.Reset()
+
+	gen.lock.Lock()
+	defer gen.lock.Unlock()
+
+	gen.stats.merge(ctx.stats)
+
+	dl := gen.dl
+	dl.lock.Lock()
+	ctx.rng.marker = common.CopyBytes(marker)
+	if contiguous := contiguousMarker(dl.genRanges); contiguous != nil {
+		dl.genMarker = contiguous
+	}
+	dl.lock.Unlock()
+
+	batch := dl.diskdb.NewBatch()
+	journalProgress(batch, dl.genMarker, dl.genRanges, gen.stats)
+	return batch.Write()
+}
+
+// log prints the progress of the generation with the given message.
+func (gen *generator) log(msg string) {
+	gen.lock.Lock()
+	defer gen.lock.Unlock()
+
+	gen.stats.Log(msg, gen.dl.root, gen.dl.genMarker)
+	gen.logged = time.Now()
+}
+
+// report prints the progress of the generation if enough time passed since
+// the last report.
+func (gen *generator) report() {
+	gen.lock.Lock()
+	defer gen.lock.Unlock()
+
+	if time.Since(gen.logged) > 8*time.Second {
+		gen.stats.Log("Generating state snapshot", gen.dl.root, gen.dl.genMarker)
+		gen.logged = time.Now()
+	}
+}
+
+// generate is a background thread that iterates over the state and storage tries,
+// constructing the state snapshot. The account ranges are generated concurrently.
+// All the arguments are purely for statistics gathering and logging, since the
+// method surfs the blocks as they arrive, often being restarted.
+func (dl *diskLayer) generate(stats *generatorStats) {
+	dl.lock.Lock()
+	if dl.genRanges == nil {
+		dl.genRanges = newGeneratorRanges(generatorRanges, dl.genMarker)
+	}
+	dl.lock.Unlock()
+
+	gen := &generator{
+		dl:     dl,
+		stats:  stats,
+		logged: time.Now(),
+		stop:   make(chan struct{}),
+	}
+	stats.Log("Resuming state snapshot generation", dl.root, dl.genMarker)
+
+	// Assign the unfinished ranges to the workers. The snapshot iterators of
+	// each range are opened at the interrupted position because the assumption
+	// is held that all the snapshot data are generated correctly before the
+	// marker. For the account or storage slot at the interruption, they will be
+	// processed twice by the generator (they are already processed in the last
+	// run) but it's fine.
+	var pending []*generatorRange
+	for _, rng := range dl.genRanges {
+		if rng.marker != nil {
+			pending = append(pending, rng)
+		}
+	}
+	var (
+		tasks   = make(chan *generatorRange, len(pending))
+		results = make(chan error, len(pending))
+		threads = runtime.NumCPU()
+	)
+	for _, rng := range pending {
+		tasks <- rng
+	}
+	close(tasks)
+	if threads > len(pending) {
+		threads = len(pending)
+	}
+	for i := 0; i < threads; i++ {
+		go func() {
+			for rng := range tasks {
+				results <- gen.run(rng)
+			}
+		}()
+	}
+	// Wait for all the ranges to finish, interrupting them on the first failure
+	// or if requested
+	var (
+		abort   chan *generatorStats
+		aborts  = dl.genAbort
+		failed  bool
+		stopped bool
+	)
+	for remaining := len(pending); remaining > 0; {
+		select {
+		case abort = <-aborts:
+			aborts = nil
+		case err := <-results:
+			remaining--
+			if err == nil {
+				continue
+			}
+			if err != errGeneratorAborted {
+				log.Error("Failed to generate snapshot range", "err", err)
+			}
+			failed = true
+		}
+		if !stopped && (abort != nil || failed) {
+			close(gen.stop)
+			stopped = true
+		}
+	}
+	if abort != nil || failed {
+		gen.log("Aborting state snapshot generation")
+		if abort == nil {
+			abort = <-dl.genAbort // Aborted by internal error, wait the signal
+		}
+		abort <- stats
+		return
+	}
+	// Snapshot fully generated, set the marker to nil.
+	// Note even there is nothing to commit, persist the
+	// generator anyway to mark the snapshot is complete.
+	batch := dl.diskdb.NewBatch()
+	journalProgress(batch, nil, nil, stats)
+	if err := batch.Write(); err != nil {
+		log.Error("Failed to flush batch", "err", err)
+
+		abort = <-dl.genAbort
+		abort <- stats
+		return
+	}
+	log.Info("Generated state snapshot", "accounts", stats.accounts, "slots", stats.slots,
+		"storage", stats.storage, "dangling", stats.dangling, "elapsed", common.PrettyDuration(time.Since(stats.start)))
+
+	dl.lock.Lock()
+	dl.genMarker = nil
+	dl.genRanges = nil
+	close(dl.genPending)
+	dl.lock.Unlock()
+
+	// Someone will be looking for us, wait it out
+	abort = <-dl.genAbort
+	abort <- nil
+}
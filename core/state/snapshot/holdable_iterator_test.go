@@ -118,7 +118,7 @@ func TestReopenIterator(t *testing.T) {
 		}
 	}
 	// Iterate over the database with the given configs and verify the results
-	ctx, idx := newGeneratorContext(&generatorStats{}, db, nil, nil), -1
+	ctx, idx := newGeneratorContext(&generatorStats{}, db, nil, nil, nil, nil), -1
 
 	idx++
 	ctx.account.Next()
@@ -170,6 +170,7 @@ func loadSnapshot(diskdb ethdb.KeyValueStore, triedb *triedb.Database, root comm
 		if base.genMarker == nil {
 			base.genMarker = []byte{}
 		}
+		base.genRanges = loadRanges(diskdb, base.genMarker)
 	}
 	// Everything loaded correctly, resume any suspended operations
 	// if the background generation is allowed
@@ -213,7 +214,7 @@ func (dl *diskLayer) Journal(buffer *bytes.Buffer) (common.Hash, error) {
 		return common.Hash{}, ErrSnapshotStale
 	}
 	// Ensure the generator stats is written even if none was ran this cycle
-	journalProgress(dl.diskdb, dl.genMarker, stats)
+	journalProgress(dl.diskdb, dl.genMarker, dl.genRanges, stats)
 
 	log.Debug("Journalled disk layer", "root", dl.root)
 	return dl.root, nil
@@ -296,6 +296,7 @@ func (t *Tree) Disable() {
 	rawdb.DeleteSnapshotRoot(batch)
 	rawdb.DeleteSnapshotJournal(batch)
 	rawdb.DeleteSnapshotGenerator(batch)
+	rawdb.DeleteSnapshotGeneratorRanges(batch)
 	rawdb.DeleteSnapshotRecoveryNumber(batch)
 	// Note, we don't delete the sync progress
 
@@ -555,7 +556,7 @@ func diffToDisk(bottom *diffLayer) *diskLayer {
 	// Destroy all the destructed accounts from the database
 	for hash := range bottom.destructSet {
 		// Skip any account not covered yet by the snapshot
-		if base.genMarker != nil && bytes.Compare(hash[:], base.genMarker) > 0 {
+		if !base.genCovered(hash[:]) {
 			continue
 		}
 		// Remove all storage slots
@@ -584,7 +585,7 @@ func diffToDisk(bottom *diffLayer) *diskLayer {
 	// Push all updated accounts into the database
 	for hash, data := range bottom.accountData {
 		// Skip any account not covered yet by the snapshot
-		if base.genMarker != nil && bytes.Compare(hash[:], base.genMarker) > 0 {
+		if !base.genCovered(hash[:]) {
 			continue
 		}
 		// Push the account to disk
@@ -608,15 +609,13 @@ func diffToDisk(bottom *diffLayer) *diskLayer {
 	// Push all the storage slots into the database
 	for accountHash, storage := range bottom.storageData {
 		// Skip any account not covered yet by the snapshot
-		if base.genMarker != nil && bytes.Compare(accountHash[:], base.genMarker) > 0 {
+		if !base.genCovered(accountHash[:]) {
 			continue
 		}
-		// Generation might be mid-account, track that case too
-		midAccount := base.genMarker != nil && bytes.Equal(accountHash[:], base.genMarker[:common.HashLength])
-
 		for storageHash, data := range storage {
-			// Skip any slot not covered yet by the snapshot
-			if midAccount && bytes.Compare(storageHash[:], base.genMarker[common.HashLength:]) > 0 {
+			// Skip any slot not covered yet by the snapshot, generation might
+			// be mid-account
+			if !base.genCovered(append(accountHash[:], storageHash[:]...)) {
 				continue
 			}
 			if len(data) > 0 {
@@ -635,7 +634,7 @@ func diffToDisk(bottom *diffLayer) *diskLayer {
 	rawdb.WriteSnapshotRoot(batch, bottom.root)
 
 	// Write out the generator progress marker and report
-	journalProgress(batch, base.genMarker, stats)
+	journalProgress(batch, base.genMarker, base.genRanges, stats)
 
 	// Flush all the updates in the single db operation. Ensure the
 	// disk layer transition is atomic.
@@ -649,6 +648,7 @@ func diffToDisk(bottom *diffLayer) *diskLayer {
 		diskdb:     base.diskdb,
 		triedb:     base.triedb,
 		genMarker:  base.genMarker,
+		genRanges:  base.genRanges,
 		genPending: base.genPending,
 	}
 	// If snapshot generation hasn't finished yet, port over all the starts and
@@ -860,6 +860,12 @@ func (t *Tree) generating() (bool, error) {
 	return layer.genMarker != nil, nil
 }
 
+// Generating reports whether the snapshot is still under construction, in which
+// case not all the states can be served from it yet.
+func (t *Tree) Generating() (bool, error) {
+	return t.generating()
+}
+
 // DiskRoot is a external helper function to return the disk layer root.
 func (t *Tree) DiskRoot() common.Hash {
 	t.lock.Lock()
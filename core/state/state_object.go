@@ -89,6 +89,13 @@ type stateObject struct {
 
 	// Flag whether the object was created in the current transaction
 	created bool
+
+	// Copy-on-write markers of forked states. The object is owned by its state
+	// as long as the state hasn't been forked since the object was cached. The
+	// storage maps and trie of a forked object are still shared with the object
+	// it was forked from until the first storage access.
+	gen           uint64
+	sharedStorage bool
 }
 
 // empty returns whether the account is considered empty.
@@ -110,6 +117,7 @@ func newObject(db *StateDB, address common.Address, acct *types.StateAccount) *s
 		address:        address,
 		addrHash:       crypto.Keccak256Hash(address[:]),
 		origin:         origin,
+		gen:            db.gen,
 		data:           *acct,
 		originStorage:  make(Storage),
 		pendingStorage: make(Storage),
@@ -142,6 +150,12 @@ func (s *stateObject) touch() {
 // if it's not loaded previously. An error will be returned if trie can't
 // be loaded.
 func (s *stateObject) getTrie() (Trie, error) {
+	s.ownStorage()
+
+	// In verkle mode the storage is part of the single tree of the state
+	if s.db.db.TrieDB().IsVerkle() {
+		return s.db.trie, nil
+	}
 	if s.trie == nil {
 		// Try fetching from prefetcher first
 		if s.data.Root != types.EmptyRootHash && s.db.prefetcher != nil {
@@ -164,6 +178,9 @@ func (s *stateObject) GetState(key common.Hash) common.Hash {
 	// If we have a dirty value for this state entry, return it
 	value, dirty := s.dirtyStorage[key]
 	if dirty {
+		if s.db.usage != nil {
+			s.db.usage.StorageCacheHits++
+		}
 		return value
 	}
 	// Otherwise return the entry's original value
@@ -174,9 +191,15 @@ func (s *stateObject) GetState(key common.Hash) common.Hash {
 func (s *stateObject) GetCommittedState(key common.Hash) common.Hash {
 	// If we have a pending write or clean cached, return that
 	if value, pending := s.pendingStorage[key]; pending {
+		if s.db.usage != nil {
+			s.db.usage.StorageCacheHits++
+		}
 		return value
 	}
 	if value, cached := s.originStorage[key]; cached {
+		if s.db.usage != nil {
+			s.db.usage.StorageCacheHits++
+		}
 		return value
 	}
 	// If the object was destructed in *this* block (and potentially resurrected),
@@ -200,6 +223,10 @@ func (s *stateObject) GetCommittedState(key common.Hash) common.Hash {
 		if metrics.EnabledExpensive {
 			s.db.SnapshotStorageReads += time.Since(start)
 		}
+		if s.db.usage != nil {
+			s.db.usage.StorageSnapshotReads++
+			s.db.usage.StorageReadTime += time.Since(start)
+		}
 		if len(enc) > 0 {
 			_, content, _, err := rlp.Split(enc)
 			if err != nil {
@@ -220,12 +247,17 @@ func (s *stateObject) GetCommittedState(key common.Hash) common.Hash {
 		if metrics.EnabledExpensive {
 			s.db.StorageReads += time.Since(start)
 		}
+		if s.db.usage != nil {
+			s.db.usage.StorageTrieReads++
+			s.db.usage.StorageReadTime += time.Since(start)
+		}
 		if err != nil {
 			s.db.setError(err)
 			return common.Hash{}
 		}
 		value.SetBytes(val)
 	}
+	s.ownStorage()
 	s.originStorage[key] = value
 	return value
 }
@@ -247,12 +279,16 @@ func (s *stateObject) SetState(key, value common.Hash) {
 }
 
 func (s *stateObject) setState(key, value common.Hash) {
+	s.ownStorage()
 	s.dirtyStorage[key] = value
 }
 
 // finalise moves all dirty storage slots into the pending area to be hashed or
 // committed later. It is invoked at the end of every transaction.
 func (s *stateObject) finalise(prefetch bool) {
+	if len(s.dirtyStorage) > 0 {
+		s.ownStorage()
+	}
 	slotsToPrefetch := make([][]byte, 0, len(s.dirtyStorage))
 	for key, value := range s.dirtyStorage {
 		s.pendingStorage[key] = value
@@ -278,8 +314,13 @@ func (s *stateObject) updateTrie() (Trie, error) {
 	// Make sure all dirty slots are finalized into the pending storage area
 	s.finalise(false)
 
-	// Short circuit if nothing changed, don't bother with hashing anything
+	// Short circuit if nothing changed, don't bother with hashing anything.
+	// The trie might still be shared with a forked object, take ownership as
+	// the caller may hash it.
 	if len(s.pendingStorage) == 0 {
+		if s.trie != nil {
+			s.ownStorage()
+		}
 		return s.trie, nil
 	}
 	// Track the amount of time wasted on updating the storage trie
@@ -369,6 +410,10 @@ func (s *stateObject) updateRoot() {
 	if err != nil || tr == nil {
 		return
 	}
+	// The storage root is meaningless in verkle mode, skip hashing the tree
+	if s.db.db.TrieDB().IsVerkle() {
+		return
+	}
 	// Track the amount of time wasted on hashing the storage trie
 	if metrics.EnabledExpensive {
 		defer func(start time.Time) { s.db.StorageHashes += time.Since(start) }(time.Now())
@@ -385,6 +430,8 @@ func (s *stateObject) commit() (*trienode.NodeSet, error) {
 		s.origin = s.data.Copy()
 		return nil, nil
 	}
+	s.ownStorage()
+
 	// Track the amount of time wasted on committing the storage trie
 	if metrics.EnabledExpensive {
 		defer func(start time.Time) { s.db.StorageCommits += time.Since(start) }(time.Now())
@@ -459,6 +506,31 @@ func (s *stateObject) deepCopy(db *StateDB) *stateObject {
 	return obj
 }
 
+// fork creates a copy of the object owned by the given forked state, sharing
+// the storage maps and trie with the original until they're first accessed.
+func (s *stateObject) fork(db *StateDB) *stateObject {
+	obj := *s
+	obj.db = db
+	obj.gen = db.gen
+	obj.sharedStorage = true
+	return &obj
+}
+
+// ownStorage makes sure the storage maps and trie of a forked object are not
+// shared with the object it was forked from.
+func (s *stateObject) ownStorage() {
+	if !s.sharedStorage {
+		return
+	}
+	if s.trie != nil {
+		s.trie = s.db.db.CopyTrie(s.trie)
+	}
+	s.originStorage = s.originStorage.Copy()
+	s.pendingStorage = s.pendingStorage.Copy()
+	s.dirtyStorage = s.dirtyStorage.Copy()
+	s.sharedStorage = false
+}
+
 //
 // Attribute accessors
 //
@@ -480,7 +552,14 @@ func (s *stateObject) Code() []byte {
 	if err != nil {
 		s.db.setError(fmt.Errorf("can't load code hash %x: %v", s.CodeHash(), err))
 	}
+	if s.db.usage != nil {
+		s.db.usage.CodeLoads++
+		s.db.usage.CodeBytes += uint64(len(code))
+	}
 	s.code = code
+	if s.db.witness != nil {
+		s.db.witness.AddCode(code)
+	}
 	return code
 }
 
@@ -494,10 +573,17 @@ func (s *stateObject) CodeSize() int {
 	if bytes.Equal(s.CodeHash(), types.EmptyCodeHash.Bytes()) {
 		return 0
 	}
+	// Stateless execution needs the whole code to derive its size
+	if s.db.witness != nil {
+		return len(s.Code())
+	}
 	size, err := s.db.db.ContractCodeSize(s.address, common.BytesToHash(s.CodeHash()))
 	if err != nil {
 		s.db.setError(fmt.Errorf("can't load code size %x: %v", s.CodeHash(), err))
 	}
+	if s.db.usage != nil {
+		s.db.usage.CodeLoads++
+	}
 	return size
 }
 
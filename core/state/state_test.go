@@ -19,6 +19,7 @@ package state
 import (
 	"bytes"
 	"encoding/json"
+	"reflect"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -26,6 +27,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/triedb"
 	"github.com/holiman/uint256"
 )
@@ -135,6 +137,106 @@ func TestIterativeDump(t *testing.T) {
 	}
 }
 
+// Tests that concurrent, filtered and resumed dumps collect the same accounts
+// as a plain sequential dump.
+func TestDumpRanges(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	tdb := NewDatabaseWithConfig(db, &triedb.Config{Preimages: true})
+	sdb, _ := New(types.EmptyRootHash, tdb, nil)
+
+	var addrs []common.Address
+	for i := 0; i < 200; i++ {
+		addr := common.BytesToAddress([]byte{byte(i), 0xff})
+		sdb.SetBalance(addr, uint256.NewInt(uint64(i)))
+		if i%10 == 0 {
+			sdb.SetCode(addr, []byte{0x60, byte(i)})
+			for j := 1; j <= 5; j++ {
+				sdb.SetState(addr, common.Hash{byte(j)}, common.Hash{byte(i), byte(j)})
+			}
+		}
+		addrs = append(addrs, addr)
+	}
+	root, _ := sdb.Commit(0, false)
+	sdb, _ = New(root, tdb, nil)
+
+	want := sdb.RawDump(nil)
+	if len(want.Accounts) != len(addrs) || want.Next != nil {
+		t.Fatalf("sequential dump mismatch: %d accounts, next %x", len(want.Accounts), want.Next)
+	}
+	if have := sdb.RawDump(&DumpConfig{Threads: 4}); !reflect.DeepEqual(have, want) {
+		t.Fatal("concurrent dump mismatch")
+	}
+	// Resume partial dumps until completion, both sequentially and concurrently
+	for _, threads := range []int{1, 4} {
+		var (
+			accounts = make(map[string]DumpAccount)
+			conf     = &DumpConfig{Threads: threads, Max: 30}
+			dumps    int
+		)
+		for {
+			dump := sdb.RawDump(conf)
+			for addr, account := range dump.Accounts {
+				if _, ok := accounts[addr]; ok {
+					t.Fatalf("threads %d: account %s dumped twice", threads, addr)
+				}
+				accounts[addr] = account
+			}
+			if dumps++; dump.Next == nil {
+				break
+			}
+			if threads == 1 && len(dump.Next) != common.HashLength {
+				t.Fatalf("sequential dump continues from %x", dump.Next)
+			}
+			conf.Start = dump.Next
+		}
+		if !reflect.DeepEqual(accounts, want.Accounts) {
+			t.Fatalf("threads %d: resumed dump mismatch", threads)
+		}
+		if dumps < len(addrs)/30 {
+			t.Fatalf("threads %d: dump not interrupted: %d dumps", threads, dumps)
+		}
+	}
+	// Filter the accounts and limit their storage
+	dump := sdb.RawDump(&DumpConfig{Threads: 2, Addresses: []common.Address{addrs[3], addrs[50], {0x01}}})
+	if len(dump.Accounts) != 2 || !reflect.DeepEqual(dump.Accounts[addrs[50].String()], want.Accounts[addrs[50].String()]) {
+		t.Fatalf("address filtered dump mismatch: %v", dump.Accounts)
+	}
+	dump = sdb.RawDump(&DumpConfig{OnlyContracts: true, MinBalance: uint256.NewInt(100), MaxStorage: 2})
+	if len(dump.Accounts) != 10 {
+		t.Fatalf("contract filtered dump mismatch: %d accounts", len(dump.Accounts))
+	}
+	for addr, account := range dump.Accounts {
+		if len(account.Code) == 0 || len(account.Storage) != 2 || account.NextStorageKey == nil {
+			t.Fatalf("account %s: truncated dump mismatch", addr)
+		}
+	}
+	// Dump as RLP stream
+	var buf bytes.Buffer
+	if next, err := sdb.RLPDump(&DumpConfig{Threads: 4}, &buf); next != nil || err != nil {
+		t.Fatalf("failed to dump as RLP: %x, %v", next, err)
+	}
+	var (
+		stream = rlp.NewStream(&buf, 0)
+		head   common.Hash
+	)
+	if err := stream.Decode(&head); err != nil || head != root {
+		t.Fatalf("RLP dump root mismatch: %x, %v", head, err)
+	}
+	for i := 0; i < len(addrs); i++ {
+		var account rlpDumpAccount
+		if err := stream.Decode(&account); err != nil {
+			t.Fatalf("failed to decode RLP dump account %d: %v", i, err)
+		}
+		if want := want.Accounts[common.BytesToAddress(account.Address).String()]; want.Balance != account.Balance.String() || len(want.Storage) != len(account.Storage) {
+			t.Fatalf("RLP dump account %x mismatch", account.Address)
+		}
+	}
+	// Invalid continuation tokens are rejected
+	if err := (&DumpConfig{Start: make([]byte, 40)}).Validate(); err == nil {
+		t.Fatal("invalid continuation token accepted")
+	}
+}
+
 func TestNull(t *testing.T) {
 	s := newStateEnv()
 	address := common.HexToAddress("0x823140710bf13990e4500136726d8b55")
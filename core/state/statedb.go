@@ -25,6 +25,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/stateless"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
@@ -33,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/trie"
 	"github.com/ethereum/go-ethereum/trie/trienode"
 	"github.com/ethereum/go-ethereum/trie/triestate"
+	"github.com/ethereum/go-ethereum/trie/utils"
 	"github.com/holiman/uint256"
 )
 
@@ -111,6 +113,9 @@ type StateDB struct {
 	// Transient storage
 	transientStorage transientStorage
 
+	// Verkle tree locations accessed in the block, nil unless in verkle mode
+	accessEvents *AccessEvents
+
 	// Journal of state modifications. This is the backbone of
 	// Snapshot and RevertToSnapshot.
 	journal        *journal
@@ -136,6 +141,34 @@ type StateDB struct {
 	AccountDeleted int
 	StorageDeleted int
 
+	// Optional resource tracker, nil unless installed via SetResourceUsage
+	usage *ResourceUsage
+
+	// Optional set of the accounts and slots read, nil unless installed via
+	// SetReadSet
+	reads *ReadSet
+
+	// Per-transaction write sets, only gathered between StartWriteLog and
+	// StopWriteLog
+	writeLog     []*TxWrites
+	writeLogging bool
+
+	// Copy-on-write tracking of forked states, see Fork
+	fork *forkRef // Non-nil if the trie and block-level maps may be shared
+	gen  uint64   // Number of forks, objects cached before the last are shared
+
+	// Accounts destructed or (re)created since Speculate, nil if not speculative
+	speculative map[common.Address]struct{}
+
+	// Execution witness gathering the accessed trie nodes and codes, nil if
+	// not installed via SetWitness
+	witness *stateless.Witness
+
+	// Whether commits assemble the state diff of the committed changes, and the
+	// diff of the last commit, see TrackStateDiff
+	trackDiff     bool
+	committedDiff *StateDiff
+
 	// Testing hooks
 	onCommit func(states *triestate.Set) // Hook invoked when commit is performed
 }
@@ -169,9 +202,37 @@ func New(root common.Hash, db Database, snaps *snapshot.Tree) (*StateDB, error)
 	if sdb.snaps != nil {
 		sdb.snap = sdb.snaps.Snapshot(root)
 	}
+	if db.TrieDB().IsVerkle() {
+		sdb.accessEvents = NewAccessEvents(db.PointCache())
+	}
 	return sdb, nil
 }
 
+// SetWitness installs a witness collecting every trie node and contract code
+// accessed by the state. It must be called before the state is accessed. The
+// snapshot is detached, so that all reads resolve the trie nodes needed for a
+// stateless re-execution.
+func (s *StateDB) SetWitness(witness *stateless.Witness) {
+	s.witness = witness
+	s.snap = nil
+}
+
+// Witness retrieves the current state witness being collected.
+func (s *StateDB) Witness() *stateless.Witness {
+	return s.witness
+}
+
+// AccessEvents returns the verkle tree locations accessed in the block so far,
+// or nil if the state is not stored in a verkle tree.
+func (s *StateDB) AccessEvents() *AccessEvents {
+	return s.accessEvents
+}
+
+// PointCache returns the cache of evaluated verkle tree key commitments.
+func (s *StateDB) PointCache() *utils.PointCache {
+	return s.db.PointCache()
+}
+
 // StartPrefetcher initializes a new trie prefetcher to pull in nodes from the
 // state trie concurrently while the state is mutated so that when we reach the
 // commit phase, most of the needed data is already hot.
@@ -207,6 +268,7 @@ func (s *StateDB) Error() error {
 }
 
 func (s *StateDB) AddLog(log *types.Log) {
+	s.unshare()
 	s.journal.append(addLogChange{txhash: s.thash})
 
 	log.TxHash = s.thash
@@ -219,6 +281,7 @@ func (s *StateDB) AddLog(log *types.Log) {
 // GetLogs returns the logs matching the specified transaction hash, and annotates
 // them with the given blockNumber and blockHash.
 func (s *StateDB) GetLogs(hash common.Hash, blockNumber uint64, blockHash common.Hash) []*types.Log {
+	s.unshare()
 	logs := s.logs[hash]
 	for _, l := range logs {
 		l.BlockNumber = blockNumber
@@ -238,6 +301,7 @@ func (s *StateDB) Logs() []*types.Log {
 // AddPreimage records a SHA3 preimage seen by the VM.
 func (s *StateDB) AddPreimage(hash common.Hash, preimage []byte) {
 	if _, ok := s.preimages[hash]; !ok {
+		s.unshare()
 		s.journal.append(addPreimageChange{hash: hash})
 		pi := make([]byte, len(preimage))
 		copy(pi, preimage)
@@ -269,18 +333,21 @@ func (s *StateDB) SubRefund(gas uint64) {
 // Exist reports whether the given account address exists in the state.
 // Notably this also returns true for self-destructed accounts.
 func (s *StateDB) Exist(addr common.Address) bool {
+	s.readAccount(addr)
 	return s.getStateObject(addr) != nil
 }
 
 // Empty returns whether the state object is either non-existent
 // or empty according to the EIP161 specification (balance = nonce = code = 0)
 func (s *StateDB) Empty(addr common.Address) bool {
+	s.readAccount(addr)
 	so := s.getStateObject(addr)
 	return so == nil || so.empty()
 }
 
 // GetBalance retrieves the balance from the given address or 0 if object not found
 func (s *StateDB) GetBalance(addr common.Address) *uint256.Int {
+	s.readAccount(addr)
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.Balance()
@@ -290,6 +357,7 @@ func (s *StateDB) GetBalance(addr common.Address) *uint256.Int {
 
 // GetNonce retrieves the nonce from the given address or 0 if object not found
 func (s *StateDB) GetNonce(addr common.Address) uint64 {
+	s.readAccount(addr)
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.Nonce()
@@ -301,6 +369,7 @@ func (s *StateDB) GetNonce(addr common.Address) uint64 {
 // GetStorageRoot retrieves the storage root from the given address or empty
 // if object not found.
 func (s *StateDB) GetStorageRoot(addr common.Address) common.Hash {
+	s.readAccount(addr)
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.Root()
@@ -314,6 +383,7 @@ func (s *StateDB) TxIndex() int {
 }
 
 func (s *StateDB) GetCode(addr common.Address) []byte {
+	s.readAccount(addr)
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.Code()
@@ -322,6 +392,7 @@ func (s *StateDB) GetCode(addr common.Address) []byte {
 }
 
 func (s *StateDB) GetCodeSize(addr common.Address) int {
+	s.readAccount(addr)
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.CodeSize()
@@ -330,6 +401,7 @@ func (s *StateDB) GetCodeSize(addr common.Address) int {
 }
 
 func (s *StateDB) GetCodeHash(addr common.Address) common.Hash {
+	s.readAccount(addr)
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return common.BytesToHash(stateObject.CodeHash())
@@ -339,6 +411,7 @@ func (s *StateDB) GetCodeHash(addr common.Address) common.Hash {
 
 // GetState retrieves a value from the given account's storage trie.
 func (s *StateDB) GetState(addr common.Address, hash common.Hash) common.Hash {
+	s.readSlot(addr, hash)
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.GetState(hash)
@@ -348,6 +421,7 @@ func (s *StateDB) GetState(addr common.Address, hash common.Hash) common.Hash {
 
 // GetCommittedState retrieves a value from the given account's committed storage trie.
 func (s *StateDB) GetCommittedState(addr common.Address, hash common.Hash) common.Hash {
+	s.readSlot(addr, hash)
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.GetCommittedState(hash)
@@ -361,6 +435,7 @@ func (s *StateDB) Database() Database {
 }
 
 func (s *StateDB) HasSelfDestructed(addr common.Address) bool {
+	s.readAccount(addr)
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.selfDestructed
@@ -559,7 +634,10 @@ func (s *StateDB) getStateObject(addr common.Address) *stateObject {
 // destructed object instead of wiping all knowledge about the state object.
 func (s *StateDB) getDeletedStateObject(addr common.Address) *stateObject {
 	// Prefer live objects if any is available
-	if obj := s.stateObjects[addr]; obj != nil {
+	if obj := s.liveObject(addr); obj != nil {
+		if s.usage != nil {
+			s.usage.AccountCacheHits++
+		}
 		return obj
 	}
 	// If no live objects are available, attempt to use snapshots
@@ -570,6 +648,10 @@ func (s *StateDB) getDeletedStateObject(addr common.Address) *stateObject {
 		if metrics.EnabledExpensive {
 			s.SnapshotAccountReads += time.Since(start)
 		}
+		if s.usage != nil {
+			s.usage.AccountSnapshotReads++
+			s.usage.AccountReadTime += time.Since(start)
+		}
 		if err == nil {
 			if acc == nil {
 				return nil
@@ -590,12 +672,18 @@ func (s *StateDB) getDeletedStateObject(addr common.Address) *stateObject {
 	}
 	// If snapshot unavailable or reading from it failed, load from the database
 	if data == nil {
+		s.unshare()
+
 		start := time.Now()
 		var err error
 		data, err = s.trie.GetAccount(addr)
 		if metrics.EnabledExpensive {
 			s.AccountReads += time.Since(start)
 		}
+		if s.usage != nil {
+			s.usage.AccountTrieReads++
+			s.usage.AccountReadTime += time.Since(start)
+		}
 		if err != nil {
 			s.setError(fmt.Errorf("getDeleteStateObject (%x) error: %w", addr.Bytes(), err))
 			return nil
@@ -611,6 +699,7 @@ func (s *StateDB) getDeletedStateObject(addr common.Address) *stateObject {
 }
 
 func (s *StateDB) setStateObject(object *stateObject) {
+	s.unshare()
 	s.stateObjects[object.Address()] = object
 }
 
@@ -627,6 +716,7 @@ func (s *StateDB) getOrNewStateObject(addr common.Address) *stateObject {
 // the given address, it is overwritten and returned as the second return value.
 func (s *StateDB) createObject(addr common.Address) (newobj, prev *stateObject) {
 	prev = s.getDeletedStateObject(addr) // Note, prev might have been deleted, we need that!
+	s.unshare()
 	newobj = newObject(s, addr, nil)
 	if prev == nil {
 		s.journal.append(createObjectChange{account: &addr})
@@ -635,10 +725,14 @@ func (s *StateDB) createObject(addr common.Address) (newobj, prev *stateObject)
 		// account and storage data should be cleared as well. Note, it must
 		// be done here, otherwise the destruction event of "original account"
 		// will be lost.
+		if s.witness != nil && prev.trie != nil {
+			s.witness.AddState(prev.trie.Witness())
+		}
 		_, prevdestruct := s.stateObjectsDestruct[prev.address]
 		if !prevdestruct {
 			s.stateObjectsDestruct[prev.address] = prev.origin
 		}
+
 		// There may be some cached account/storage data already since IntermediateRoot
 		// will be called for each transaction before byzantium fork which will always
 		// cache the latest account/storage data.
@@ -752,8 +846,8 @@ func (s *StateDB) Copy() *StateDB {
 	// along with their original values.
 	state.accounts = copySet(s.accounts)
 	state.storages = copy2DSet(s.storages)
-	state.accountsOrigin = copySet(state.accountsOrigin)
-	state.storagesOrigin = copy2DSet(state.storagesOrigin)
+	state.accountsOrigin = copySet(s.accountsOrigin)
+	state.storagesOrigin = copy2DSet(s.storagesOrigin)
 
 	// Deep copy the logs occurred in the scope of block
 	for hash, logs := range s.logs {
@@ -783,6 +877,12 @@ func (s *StateDB) Copy() *StateDB {
 	if s.prefetcher != nil {
 		state.prefetcher = s.prefetcher.copy()
 	}
+	if s.witness != nil {
+		state.witness = s.witness.Copy()
+	}
+	if s.accessEvents != nil {
+		state.accessEvents = s.accessEvents.Copy()
+	}
 	return state
 }
 
@@ -806,6 +906,7 @@ func (s *StateDB) RevertToSnapshot(revid int) {
 	snapshot := s.validRevisions[idx].journalIndex
 
 	// Replay the journal to undo changes and remove invalidated snapshots
+	s.unshare()
 	s.journal.revert(s, snapshot)
 	s.validRevisions = s.validRevisions[:idx]
 }
@@ -820,9 +921,13 @@ func (s *StateDB) GetRefund() uint64 {
 // into the tries just yet. Only IntermediateRoot or Commit will do that.
 func (s *StateDB) Finalise(deleteEmptyObjects bool) {
 	addressesToPrefetch := make([][]byte, 0, len(s.journal.dirties))
+	s.unshare()
+	if s.writeLogging {
+		s.logWrites()
+	}
 	for addr := range s.journal.dirties {
-		obj, exist := s.stateObjects[addr]
-		if !exist {
+		obj := s.liveObject(addr)
+		if obj == nil {
 			// ripeMD is 'touched' at block 1714175, in tx 0x1237f737031e40bcde4a8b7e717b2d15e3ecadfe49bb1bbc71ee9deb09c6fcf2
 			// That tx goes out of gas, and although the notion of 'touched' does not exist there, the
 			// touch-event will still be recorded in the journal. Since ripeMD is a special snowflake,
@@ -848,8 +953,17 @@ func (s *StateDB) Finalise(deleteEmptyObjects bool) {
 			delete(s.accountsOrigin, obj.address) // Clear out any previously updated account data (may be recreated via a resurrect)
 			delete(s.storagesOrigin, obj.address) // Clear out any previously updated storage data (may be recreated via a resurrect)
 		} else {
+			if s.usage != nil {
+				s.usage.StorageWrites += uint64(len(obj.dirtyStorage))
+			}
 			obj.finalise(true) // Prefetch slots in the background
 		}
+		if s.usage != nil {
+			s.usage.AccountWrites++
+		}
+		if s.speculative != nil && (obj.deleted || obj.created) {
+			s.speculative[addr] = struct{}{}
+		}
 		obj.created = false
 		s.stateObjectsPending[addr] = struct{}{}
 		s.stateObjectsDirty[addr] = struct{}{}
@@ -893,7 +1007,7 @@ func (s *StateDB) IntermediateRoot(deleteEmptyObjects bool) common.Hash {
 	// first, giving the account prefetches just a few more milliseconds of time
 	// to pull useful data from disk.
 	for addr := range s.stateObjectsPending {
-		if obj := s.stateObjects[addr]; !obj.deleted {
+		if obj := s.liveObject(addr); !obj.deleted {
 			obj.updateRoot()
 		}
 	}
@@ -907,7 +1021,7 @@ func (s *StateDB) IntermediateRoot(deleteEmptyObjects bool) common.Hash {
 	}
 	usedAddrs := make([][]byte, 0, len(s.stateObjectsPending))
 	for addr := range s.stateObjectsPending {
-		if obj := s.stateObjects[addr]; obj.deleted {
+		if obj := s.liveObject(addr); obj.deleted {
 			s.deleteStateObject(obj)
 			s.AccountDeleted += 1
 		} else {
@@ -926,7 +1040,19 @@ func (s *StateDB) IntermediateRoot(deleteEmptyObjects bool) common.Hash {
 	if metrics.EnabledExpensive {
 		defer func(start time.Time) { s.AccountHashes += time.Since(start) }(time.Now())
 	}
-	return s.trie.Hash()
+	root := s.trie.Hash()
+
+	// Gather all the trie nodes resolved so far into the witness. The tries
+	// are hashed already, so every node needed to derive the root is included.
+	if s.witness != nil {
+		s.witness.AddState(s.trie.Witness())
+		for _, obj := range s.stateObjects {
+			if obj.trie != nil {
+				s.witness.AddState(obj.trie.Witness())
+			}
+		}
+	}
+	return root
 }
 
 // SetTxContext sets the current transaction hash and index which are
@@ -1094,13 +1220,7 @@ func (s *StateDB) deleteStorage(addr common.Address, addrHash common.Hash, root
 // In case (d), **original** account along with its storages should be deleted,
 // with their values be tracked as original value.
 func (s *StateDB) handleDestruction(nodes *trienode.MergedNodeSet) (map[common.Address]struct{}, error) {
-	// Short circuit if geth is running with hash mode. This procedure can consume
-	// considerable time and storage deletion isn't supported in hash mode, thus
-	// preemptively avoiding unnecessary expenses.
 	incomplete := make(map[common.Address]struct{})
-	if s.db.TrieDB().Scheme() == rawdb.HashScheme {
-		return incomplete, nil
-	}
 	for addr, prev := range s.stateObjectsDestruct {
 		// The original account was non-existing, and it's marked as destructed
 		// in the scope of block. It can be case (a) or (b).
@@ -1117,8 +1237,17 @@ func (s *StateDB) handleDestruction(nodes *trienode.MergedNodeSet) (map[common.A
 		// It can overwrite the data in s.accountsOrigin set by 'updateStateObject'.
 		s.accountsOrigin[addr] = types.SlimAccountRLP(*prev) // case (c) or (d)
 
-		// Short circuit if the storage was empty.
-		if prev.Root == types.EmptyRootHash {
+		// Short circuit if the storage was empty. In verkle mode the storage
+		// is part of the single tree and can't be iterated, it's left behind.
+		if prev.Root == types.EmptyRootHash || s.db.TrieDB().IsVerkle() {
+			continue
+		}
+		// Storage deletion isn't supported in hash mode and the procedure can
+		// consume considerable time, preemptively avoid the expenses by marking
+		// the storage as incomplete.
+		if s.db.TrieDB().Scheme() == rawdb.HashScheme {
+			incomplete[addr] = struct{}{}
+			delete(s.storagesOrigin, addr)
 			continue
 		}
 		// Remove storage slots belong to the account.
@@ -1183,7 +1312,7 @@ func (s *StateDB) Commit(block uint64, deleteEmptyObjects bool) (common.Hash, er
 	}
 	// Handle all state updates afterwards
 	for addr := range s.stateObjectsDirty {
-		obj := s.stateObjects[addr]
+		obj := s.liveObject(addr)
 		if obj.deleted {
 			continue
 		}
@@ -1272,10 +1401,15 @@ func (s *StateDB) Commit(block uint64, deleteEmptyObjects bool) (common.Hash, er
 	if origin == (common.Hash{}) {
 		origin = types.EmptyRootHash
 	}
+	states := triestate.New(s.accountsOrigin, s.storagesOrigin, incomplete)
+	if s.trackDiff {
+		if s.committedDiff, err = s.stateDiff(origin, root, states); err != nil {
+			return common.Hash{}, err
+		}
+	}
 	if root != origin {
 		start := time.Now()
-		set := triestate.New(s.accountsOrigin, s.storagesOrigin, incomplete)
-		if err := s.db.TrieDB().Update(root, origin, block, nodes, set); err != nil {
+		if err := s.db.TrieDB().Update(root, origin, block, nodes, states); err != nil {
 			return common.Hash{}, err
 		}
 		s.originalRoot = root
@@ -1283,7 +1417,7 @@ func (s *StateDB) Commit(block uint64, deleteEmptyObjects bool) (common.Hash, er
 			s.TrieDBCommits += time.Since(start)
 		}
 		if s.onCommit != nil {
-			s.onCommit(set)
+			s.onCommit(states)
 		}
 	}
 	// Clear all internal flags at the end of commit operation.
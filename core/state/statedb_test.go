@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/big"
 	"math/rand"
 	"reflect"
 	"strings"
@@ -1189,3 +1190,305 @@ func TestDeleteStorage(t *testing.T) {
 		t.Fatalf("difference found:\nfast: %v\nslow: %v\n", fastRes, slowRes)
 	}
 }
+
+func TestResourceUsage(t *testing.T) {
+	var (
+		disk     = rawdb.NewMemoryDatabase()
+		tdb      = triedb.NewDatabase(disk, nil)
+		db       = NewDatabaseWithNodeDB(disk, tdb)
+		snaps, _ = snapshot.New(snapshot.Config{CacheSize: 10}, disk, tdb, types.EmptyRootHash)
+		state, _ = New(types.EmptyRootHash, db, snaps)
+		addr     = common.HexToAddress("0x1")
+		slotA    = common.HexToHash("0x1")
+		slotB    = common.HexToHash("0x2")
+	)
+	state.SetBalance(addr, uint256.NewInt(1))
+	state.SetCode(addr, []byte{0x1, 0x2, 0x3})
+	state.SetState(addr, slotA, common.BytesToHash([]byte{0x1}))
+	root, _ := state.Commit(0, true)
+
+	// Read the state back through the snapshot and the tries
+	for _, snapped := range []bool{true, false} {
+		var usage ResourceUsage
+		if snapped {
+			state, _ = New(root, db, snaps)
+		} else {
+			state, _ = New(root, db, nil)
+		}
+		state.SetResourceUsage(&usage)
+
+		state.GetBalance(addr)
+		state.GetCode(addr)
+		state.GetState(addr, slotA)
+		state.GetState(addr, slotA)
+		state.SetState(addr, slotB, common.BytesToHash([]byte{0x2}))
+		state.Finalise(true)
+
+		want := ResourceUsage{
+			AccountCacheHits: 4, // GetCode, 2x GetState, SetState
+			StorageCacheHits: 1,
+			AccountWrites:    1,
+			StorageWrites:    1,
+			CodeLoads:        1,
+			CodeBytes:        3,
+		}
+		if snapped {
+			want.AccountSnapshotReads, want.StorageSnapshotReads = 1, 2
+		} else {
+			want.AccountTrieReads, want.StorageTrieReads = 1, 2
+		}
+		usage.AccountReadTime, usage.StorageReadTime = 0, 0
+		if usage != want {
+			t.Errorf("snapshot %v: usage mismatch: have %+v, want %+v", snapped, usage, want)
+		}
+	}
+}
+
+// Tests that forked states share their contents with the parent, but modifying
+// either side is isolated from the other, also when done concurrently.
+func TestFork(t *testing.T) {
+	db := NewDatabase(rawdb.NewMemoryDatabase())
+	orig, _ := New(types.EmptyRootHash, db, nil)
+	for i := byte(0); i < 16; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		orig.AddBalance(addr, uint256.NewInt(uint64(i)))
+		orig.SetState(addr, common.Hash{}, common.BytesToHash([]byte{i}))
+	}
+	root, _ := orig.Commit(0, false)
+	orig, _ = New(root, db, nil)
+
+	// Warm up some objects and leave uncommitted changes behind
+	for i := byte(0); i < 8; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		orig.AddBalance(addr, uint256.NewInt(1))
+		orig.GetState(addr, common.Hash{})
+	}
+	orig.Finalise(true)
+
+	fork := orig.Fork()
+	ffork := fork.Fork()
+
+	// Modify all states concurrently, each in its own way
+	modify := func(state *StateDB, n uint64) {
+		for i := byte(0); i < 16; i++ {
+			addr := common.BytesToAddress([]byte{i})
+			state.AddBalance(addr, uint256.NewInt(n))
+			state.SetState(addr, common.Hash{}, common.BytesToHash([]byte{i, byte(n)}))
+		}
+		state.IntermediateRoot(true)
+	}
+	var wg sync.WaitGroup
+	for n, state := range map[uint64]*StateDB{10: orig, 20: fork, 30: ffork} {
+		wg.Add(1)
+		go func(state *StateDB, n uint64) {
+			defer wg.Done()
+			modify(state, n)
+		}(state, n)
+	}
+	wg.Wait()
+
+	for n, state := range map[uint64]*StateDB{10: orig, 20: fork, 30: ffork} {
+		for i := byte(0); i < 16; i++ {
+			addr := common.BytesToAddress([]byte{i})
+			want := uint64(i) + n
+			if i < 8 {
+				want++
+			}
+			if have := state.GetBalance(addr); have.Uint64() != want {
+				t.Errorf("state %d, account %d: balance mismatch: have %v, want %d", n, i, have, want)
+			}
+			if have, want := state.GetState(addr, common.Hash{}), common.BytesToHash([]byte{i, byte(n)}); have != want {
+				t.Errorf("state %d, account %d: storage mismatch: have %x, want %x", n, i, have, want)
+			}
+		}
+	}
+	// The fork must commit to the same root as a deep copy would
+	copy, _ := New(root, db, nil)
+	for i := byte(0); i < 8; i++ {
+		copy.AddBalance(common.BytesToAddress([]byte{i}), uint256.NewInt(1))
+	}
+	copy.Finalise(true)
+	copy = copy.Copy()
+	modify(copy, 20)
+
+	want, err := copy.Commit(1, true)
+	if err != nil {
+		t.Fatalf("failed to commit copy: %v", err)
+	}
+	if have, err := fork.Commit(1, true); err != nil || have != want {
+		t.Fatalf("fork root mismatch: have %x, want %x (err %v)", have, want, err)
+	}
+}
+
+// Tests that forked states can hash their loaded, but unmodified storage tries
+// concurrently, while the other side modifies or copies them.
+func TestForkConcurrentHash(t *testing.T) {
+	db := NewDatabase(rawdb.NewMemoryDatabase())
+	orig, _ := New(types.EmptyRootHash, db, nil)
+	for i := byte(0); i < 16; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		orig.SetBalance(addr, uint256.NewInt(1))
+		for j := byte(0); j < 16; j++ {
+			orig.SetState(addr, common.Hash{j}, common.Hash{i, j})
+		}
+	}
+	root, _ := orig.Commit(0, false)
+	orig, _ = New(root, db, nil)
+
+	// Load the storage tries, but only modify the accounts
+	for i := byte(0); i < 16; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		orig.GetState(addr, common.Hash{})
+		orig.AddBalance(addr, uint256.NewInt(1))
+	}
+	orig.Finalise(true)
+
+	var (
+		fork  = orig.Fork()
+		ffork = fork.Fork()
+		roots = make([]common.Hash, 3)
+		wg    sync.WaitGroup
+	)
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		roots[0] = orig.IntermediateRoot(true)
+	}()
+	go func() {
+		defer wg.Done()
+		roots[1] = fork.IntermediateRoot(true)
+	}()
+	go func() {
+		defer wg.Done()
+		// Copy the shared tries while the others hash them
+		roots[2] = ffork.Copy().IntermediateRoot(true)
+	}()
+	wg.Wait()
+
+	if roots[0] != roots[1] || roots[0] != roots[2] {
+		t.Fatalf("root mismatch: %x", roots)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	var (
+		db       = NewDatabase(rawdb.NewMemoryDatabase())
+		state, _ = New(types.EmptyRootHash, db, nil)
+		shared   = common.HexToAddress("0x01")
+		created  = common.HexToAddress("0x02")
+	)
+	state.AddBalance(shared, uint256.NewInt(100))
+	state.SetState(shared, common.Hash{1}, common.Hash{1})
+	state.Finalise(true)
+
+	// Speculate two transactions crediting the same account blindly and
+	// writing different slots, one of them creating an account too
+	var (
+		origin = state.Fork()
+		spec1  = state.Speculate()
+		spec2  = state.Speculate()
+		reads  = NewReadSet()
+	)
+	spec1.AddBalance(shared, uint256.NewInt(5))
+	spec1.SetState(shared, common.Hash{2}, common.Hash{2})
+	spec1.Finalise(true)
+
+	spec2.SetReadSet(reads)
+	spec2.AddBalance(shared, uint256.NewInt(7))
+	spec2.GetState(shared, common.Hash{3})
+	spec2.SetState(shared, common.Hash{3}, common.Hash{3})
+	spec2.CreateAccount(created)
+	spec2.SetNonce(created, 1)
+	spec2.Finalise(true)
+
+	if _, ok := reads.Accounts[shared]; ok {
+		t.Error("blind credit recorded as a read")
+	}
+	if _, ok := reads.Slots[shared][common.Hash{3}]; !ok {
+		t.Error("slot read not recorded")
+	}
+	if spec1.Recreated(shared) || !spec2.Recreated(created) {
+		t.Error("recreated accounts mismatch")
+	}
+	for _, spec := range []*StateDB{spec1, spec2} {
+		if err := state.Merge(spec, origin); err != nil {
+			t.Fatal(err)
+		}
+		state.Finalise(true)
+	}
+	if have := state.GetBalance(shared); have.Uint64() != 112 {
+		t.Errorf("balance mismatch: have %v, want 112", have)
+	}
+	for i := byte(1); i <= 3; i++ {
+		if have, want := state.GetState(shared, common.Hash{i}), (common.Hash{i}); have != want {
+			t.Errorf("slot %d mismatch: have %x, want %x", i, have, want)
+		}
+	}
+	if nonce := state.GetNonce(created); nonce != 1 {
+		t.Errorf("created account nonce mismatch: have %d, want 1", nonce)
+	}
+}
+
+func TestHistoricState(t *testing.T) {
+	disk, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		tdb   = triedb.NewDatabase(disk, &triedb.Config{PathDB: &pathdb.Config{StateHistoryIndex: true}})
+		sdb   = NewDatabaseWithNodeDB(disk, tdb)
+		addr  = common.HexToAddress("0xaa")
+		other = common.HexToAddress("0xbb")
+		code  = []byte{0x60, 0x00}
+		root  = types.EmptyRootHash
+		roots []common.Hash
+	)
+	defer tdb.Close()
+
+	// Commit a few blocks into the disk layer, creating the state histories
+	for block := uint64(1); block <= 4; block++ {
+		state, _ := New(root, sdb, nil)
+		state.SetBalance(addr, uint256.NewInt(block))
+		state.SetState(addr, common.Hash{1}, common.BigToHash(new(big.Int).SetUint64(block)))
+		switch block {
+		case 1:
+			state.SetCode(addr, code)
+		case 3:
+			state.SetNonce(other, 1)
+		}
+		if root, err = state.Commit(block, true); err != nil {
+			t.Fatal(err)
+		}
+		if err := tdb.Commit(root, false); err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+	// The historical states are not available in the live database anymore
+	if _, err := New(roots[1], sdb, nil); err == nil {
+		t.Fatal("historical state available in the live database")
+	}
+	historic := NewHistoricDatabase(sdb)
+	for i, root := range roots {
+		block := uint64(i + 1)
+		state, err := New(root, historic, nil)
+		if err != nil {
+			t.Fatalf("block %d: %v", block, err)
+		}
+		if balance := state.GetBalance(addr); balance.Uint64() != block {
+			t.Errorf("block %d: balance mismatch: have %v, want %d", block, balance, block)
+		}
+		if slot := state.GetState(addr, common.Hash{1}); slot != common.BigToHash(new(big.Int).SetUint64(block)) {
+			t.Errorf("block %d: slot mismatch: have %x", block, slot)
+		}
+		if have := state.GetCode(addr); !bytes.Equal(have, code) {
+			t.Errorf("block %d: code mismatch: have %x", block, have)
+		}
+		if exist := state.Exist(other); exist != (block >= 3) {
+			t.Errorf("block %d: account existence mismatch: have %v", block, exist)
+		}
+		if err := state.Error(); err != nil {
+			t.Errorf("block %d: %v", block, err)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie/trienode"
+	"github.com/ethereum/go-ethereum/trie/triestate"
+)
+
+// StateDiff is the set of state changes made on top of a state root, usually
+// by a single block. It's derived from the state set handed to the trie database
+// on commit, which holds the original values of the mutated accounts and slots,
+// extended with their new values.
+type StateDiff struct {
+	ParentRoot common.Hash                     `json:"parentRoot"` // State root the changes are applied on
+	Root       common.Hash                     `json:"root"`       // State root after applying the changes
+	Accounts   map[common.Address]*AccountDiff `json:"accounts"`   // Accounts changed in any way
+}
+
+// AccountDiff is the change of a single account.
+type AccountDiff struct {
+	Prev *types.StateAccount // Account before the changes, nil if it didn't exist
+	Post *types.StateAccount // Account after the changes, nil if it was deleted
+
+	// Destructed is set if the original account was self-destructed or deleted
+	// as empty, wiping its storage. The account may have been recreated after.
+	Destructed bool
+
+	// Incomplete is set if the wiped storage of a destructed account couldn't
+	// be gathered, either as it's too large or as the storage deletion is not
+	// supported by the trie database. Storage is left empty in this case.
+	Incomplete bool
+
+	Code    []byte                       // New contract code, nil if the code didn't change
+	Storage map[common.Hash]*StorageDiff // Changed slots, keyed by the hash of the slot key
+}
+
+// StorageDiff is the change of a single storage slot.
+type StorageDiff struct {
+	Key  *common.Hash `json:"key,omitempty"` // Plain slot key, nil if unknown (slot wiped by a destruction)
+	Prev common.Hash  `json:"prev"`          // Value before the changes, zero if the slot was empty
+	Post common.Hash  `json:"post"`          // Value after the changes, zero if the slot was deleted
+}
+
+// diffAccount is the JSON representation of an account in a state diff.
+type diffAccount struct {
+	Nonce    hexutil.Uint64 `json:"nonce"`
+	Balance  *hexutil.U256  `json:"balance"`
+	Root     common.Hash    `json:"root"`
+	CodeHash hexutil.Bytes  `json:"codeHash"`
+}
+
+func newDiffAccount(acct *types.StateAccount) *diffAccount {
+	if acct == nil {
+		return nil
+	}
+	return &diffAccount{
+		Nonce:    hexutil.Uint64(acct.Nonce),
+		Balance:  (*hexutil.U256)(acct.Balance),
+		Root:     acct.Root,
+		CodeHash: acct.CodeHash,
+	}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a *AccountDiff) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Prev       *diffAccount                 `json:"prev"`
+		Post       *diffAccount                 `json:"post"`
+		Destructed bool                         `json:"destructed,omitempty"`
+		Incomplete bool                         `json:"incomplete,omitempty"`
+		Code       hexutil.Bytes                `json:"code,omitempty"`
+		Storage    map[common.Hash]*StorageDiff `json:"storage,omitempty"`
+	}{
+		Prev:       newDiffAccount(a.Prev),
+		Post:       newDiffAccount(a.Post),
+		Destructed: a.Destructed,
+		Incomplete: a.Incomplete,
+		Code:       a.Code,
+		Storage:    a.Storage,
+	})
+}
+
+// TrackStateDiff makes the following commits assemble the state diff of the
+// committed changes, see CommittedDiff. It must be called before Commit.
+func (s *StateDB) TrackStateDiff() {
+	s.trackDiff = true
+}
+
+// CommittedDiff returns the state changes made by the last commit, nil if the
+// state diff is not tracked, see TrackStateDiff.
+func (s *StateDB) CommittedDiff() *StateDiff {
+	return s.committedDiff
+}
+
+// StateDiff returns the state changes made since the state was opened or last
+// committed, hashing the pending changes first. The changes are derived from
+// the same state set a commit would produce, thus the destructed storage is
+// gathered and the state should be discarded, not committed, afterwards.
+func (s *StateDB) StateDiff(deleteEmptyObjects bool) (*StateDiff, error) {
+	root := s.IntermediateRoot(deleteEmptyObjects)
+	if s.dbErr != nil {
+		return nil, s.dbErr
+	}
+	incomplete, err := s.handleDestruction(trienode.NewMergedNodeSet())
+	if err != nil {
+		return nil, err
+	}
+	parent := s.originalRoot
+	if parent == (common.Hash{}) {
+		parent = types.EmptyRootHash
+	}
+	if root == (common.Hash{}) {
+		root = types.EmptyRootHash
+	}
+	return s.stateDiff(parent, root, triestate.New(s.accountsOrigin, s.storagesOrigin, incomplete))
+}
+
+// stateDiff assembles the state diff from the state set of the pending changes,
+// which carries the original values, and the live state holding the new ones.
+func (s *StateDB) stateDiff(parent, root common.Hash, set *triestate.Set) (*StateDiff, error) {
+	diff := &StateDiff{
+		ParentRoot: parent,
+		Root:       root,
+		Accounts:   make(map[common.Address]*AccountDiff),
+	}
+	for addr, data := range set.Accounts {
+		acct := new(AccountDiff)
+		if data != nil {
+			prev, err := types.FullAccount(data)
+			if err != nil {
+				return nil, err
+			}
+			acct.Prev = prev
+		}
+		obj := s.liveObject(addr)
+		if obj != nil && !obj.deleted {
+			acct.Post = obj.data.Copy()
+		}
+		if acct.Prev == nil && acct.Post == nil {
+			continue // Created and deleted within the block
+		}
+		_, destructed := s.stateObjectsDestruct[addr]
+		acct.Destructed = destructed && acct.Prev != nil
+		_, acct.Incomplete = set.Incomplete[addr]
+
+		if acct.Post != nil && (acct.Prev == nil || !bytes.Equal(acct.Prev.CodeHash, acct.Post.CodeHash)) {
+			if !bytes.Equal(acct.Post.CodeHash, types.EmptyCodeHash.Bytes()) {
+				acct.Code = common.CopyBytes(obj.Code())
+			}
+		}
+		storage, err := s.storageDiff(obj, addr, set.Storages[addr])
+		if err != nil {
+			return nil, err
+		}
+		acct.Storage = storage
+
+		if !acct.Destructed && acct.Code == nil && len(acct.Storage) == 0 && sameAccount(acct.Prev, acct.Post) {
+			continue // Touched but unchanged
+		}
+		diff.Accounts[addr] = acct
+	}
+	return diff, nil
+}
+
+// storageDiff assembles the changed slots of the given account from the original
+// values of its mutated slots, keyed by the slot hashes.
+func (s *StateDB) storageDiff(obj *stateObject, addr common.Address, origins map[common.Hash][]byte) (map[common.Hash]*StorageDiff, error) {
+	if len(origins) == 0 {
+		return nil, nil
+	}
+	// The new values are cached by slot hash until commit, the plain keys are
+	// only known for the slots accessed by the live object.
+	var (
+		posts = s.storages[crypto.Keccak256Hash(addr.Bytes())]
+		keys  = make(map[common.Hash]common.Hash)
+	)
+	if obj != nil {
+		for key := range obj.originStorage {
+			keys[crypto.HashData(s.hasher, key[:])] = key
+		}
+	}
+	storage := make(map[common.Hash]*StorageDiff)
+	for hash, origin := range origins {
+		prev, err := decodeSlot(origin)
+		if err != nil {
+			return nil, err
+		}
+		post, err := decodeSlot(posts[hash])
+		if err != nil {
+			return nil, err
+		}
+		if prev == post {
+			continue
+		}
+		slot := &StorageDiff{Prev: prev, Post: post}
+		if key, ok := keys[hash]; ok {
+			slot.Key = &key
+		}
+		storage[hash] = slot
+	}
+	return storage, nil
+}
+
+// decodeSlot decodes a storage slot value in its prefix-zero trimmed rlp format,
+// nil meaning the slot is empty.
+func decodeSlot(enc []byte) (common.Hash, error) {
+	if len(enc) == 0 {
+		return common.Hash{}, nil
+	}
+	_, content, _, err := rlp.Split(enc)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(content), nil
+}
+
+// sameAccount reports whether the two, possibly nil, accounts are identical.
+func sameAccount(a, b *types.StateAccount) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Nonce == b.Nonce && a.Balance.Eq(b.Balance) && a.Root == b.Root && bytes.Equal(a.CodeHash, b.CodeHash)
+}
@@ -0,0 +1,194 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
+	"github.com/holiman/uint256"
+)
+
+func TestStateDiff(t *testing.T) {
+	testStateDiff(t, rawdb.HashScheme)
+	testStateDiff(t, rawdb.PathScheme)
+}
+
+func testStateDiff(t *testing.T, scheme string) {
+	var (
+		disk   = rawdb.NewMemoryDatabase()
+		config = triedb.HashDefaults
+	)
+	if scheme == rawdb.PathScheme {
+		config = &triedb.Config{PathDB: pathdb.Defaults}
+	}
+	db := NewDatabaseWithNodeDB(disk, triedb.NewDatabase(disk, config))
+
+	var (
+		updated   = common.HexToAddress("0x01") // Balance and storage modified
+		destroyed = common.HexToAddress("0x02") // Self-destructed
+		revived   = common.HexToAddress("0x03") // Self-destructed and recreated
+		created   = common.HexToAddress("0x04") // Deployed
+		touched   = common.HexToAddress("0x05") // Touched without changes
+
+		one   = common.HexToHash("0x01")
+		two   = common.HexToHash("0x02")
+		three = common.HexToHash("0x03")
+	)
+	// Create the initial state
+	state, _ := New(types.EmptyRootHash, db, nil)
+	state.SetBalance(updated, uint256.NewInt(1))
+	state.SetState(updated, one, one)
+	state.SetState(updated, two, two)
+	state.SetBalance(destroyed, uint256.NewInt(2))
+	state.SetState(destroyed, one, one)
+	state.SetBalance(revived, uint256.NewInt(3))
+	state.SetState(revived, one, one)
+	state.SetBalance(touched, uint256.NewInt(4))
+	parent, _ := state.Commit(0, false)
+
+	// Modify the state, hashing the intermediate roots in between to mimic the
+	// pre-byzantium transaction processing
+	state, _ = New(parent, db, nil)
+
+	state.AddBalance(updated, uint256.NewInt(10))
+	state.SetState(updated, one, three) // Changed
+	state.SetState(updated, two, common.Hash{})
+	state.SetState(updated, three, three) // Created
+	state.SetState(revived, one, two)
+	state.AddBalance(touched, new(uint256.Int))
+	state.IntermediateRoot(true)
+
+	state.SelfDestruct(destroyed)
+	state.SelfDestruct(revived)
+	state.IntermediateRoot(true)
+
+	state.CreateAccount(revived)
+	state.SetBalance(revived, uint256.NewInt(5))
+	state.SetState(revived, two, two)
+	state.SetCode(created, []byte{0x1, 0x2})
+	state.SetState(created, one, one)
+	state.Finalise(true)
+
+	// Gather the diff of the uncommitted changes on a copy, it has to match the
+	// one assembled by the commit
+	diff, err := state.Copy().StateDiff(true)
+	if err != nil {
+		t.Fatalf("failed to gather state diff: %v", err)
+	}
+	state.TrackStateDiff()
+	root, err := state.Commit(1, true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if !reflect.DeepEqual(diff, state.CommittedDiff()) {
+		t.Fatal("uncommitted and committed state diffs mismatch")
+	}
+	if diff.ParentRoot != parent || diff.Root != root {
+		t.Fatalf("roots mismatch: have %x -> %x, want %x -> %x", diff.ParentRoot, diff.Root, parent, root)
+	}
+	if len(diff.Accounts) != 4 {
+		t.Fatalf("account count mismatch: have %d, want 4", len(diff.Accounts))
+	}
+	if _, ok := diff.Accounts[touched]; ok {
+		t.Fatal("unchanged account in diff")
+	}
+	// Check the regularly updated account
+	acct := diff.Accounts[updated]
+	if acct.Prev.Balance.Uint64() != 1 || acct.Post.Balance.Uint64() != 11 || acct.Destructed || acct.Incomplete || acct.Code != nil {
+		t.Fatalf("updated account mismatch: %+v", acct)
+	}
+	checkSlots(t, "updated", acct.Storage, []slotDiff{
+		{one, one, three, true},
+		{two, two, common.Hash{}, true},
+		{three, common.Hash{}, three, true},
+	})
+	// Check the destructed accounts. The storage deletion is only supported in
+	// path mode, where the wiped slots are reported without their plain keys.
+	hash := scheme == rawdb.HashScheme
+
+	acct = diff.Accounts[destroyed]
+	if acct.Prev.Balance.Uint64() != 2 || acct.Post != nil || !acct.Destructed || acct.Incomplete != hash {
+		t.Fatalf("destructed account mismatch: %+v", acct)
+	}
+	if hash {
+		checkSlots(t, "destructed", acct.Storage, nil)
+	} else {
+		checkSlots(t, "destructed", acct.Storage, []slotDiff{
+			{one, one, common.Hash{}, false},
+		})
+	}
+	acct = diff.Accounts[revived]
+	if acct.Prev.Balance.Uint64() != 3 || acct.Post.Balance.Uint64() != 5 || !acct.Destructed || acct.Incomplete != hash {
+		t.Fatalf("revived account mismatch: %+v", acct)
+	}
+	if hash {
+		checkSlots(t, "revived", acct.Storage, nil)
+	} else {
+		checkSlots(t, "revived", acct.Storage, []slotDiff{
+			{one, one, common.Hash{}, false},
+			{two, common.Hash{}, two, true},
+		})
+	}
+	// Check the deployed contract
+	acct = diff.Accounts[created]
+	if acct.Prev != nil || acct.Post == nil || acct.Destructed || !bytes.Equal(acct.Code, []byte{0x1, 0x2}) {
+		t.Fatalf("created account mismatch: %+v", acct)
+	}
+	checkSlots(t, "created", acct.Storage, []slotDiff{
+		{one, common.Hash{}, one, true},
+	})
+	// A commit without changes yields an empty diff
+	state, _ = New(root, db, nil)
+	state.TrackStateDiff()
+	if _, err := state.Commit(2, true); err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if diff := state.CommittedDiff(); len(diff.Accounts) != 0 || diff.ParentRoot != root || diff.Root != root {
+		t.Fatalf("empty commit state diff mismatch: %+v", diff)
+	}
+}
+
+// slotDiff is an expected slot change, known reports whether the plain key is
+// expected to be resolved.
+type slotDiff struct {
+	key, prev, post common.Hash
+	known           bool
+}
+
+func checkSlots(t *testing.T, name string, have map[common.Hash]*StorageDiff, want []slotDiff) {
+	t.Helper()
+	if len(have) != len(want) {
+		t.Fatalf("%s: slot count mismatch: have %d, want %d", name, len(have), len(want))
+	}
+	for _, slot := range want {
+		diff := have[crypto.Keccak256Hash(slot.key[:])]
+		if diff == nil || diff.Prev != slot.prev || diff.Post != slot.post {
+			t.Fatalf("%s: slot %x mismatch: have %+v, want %x -> %x", name, slot.key, diff, slot.prev, slot.post)
+		}
+		if (diff.Key != nil) != slot.known || (diff.Key != nil && *diff.Key != slot.key) {
+			t.Fatalf("%s: slot %x key mismatch: have %v, known %v", name, slot.key, diff.Key, slot.known)
+		}
+	}
+}
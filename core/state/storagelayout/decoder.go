@@ -0,0 +1,469 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storagelayout
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+)
+
+const (
+	// maxDecodeDepth is the maximum nesting of the decoded values. Deeper values
+	// are reported as truncated.
+	maxDecodeDepth = 64
+
+	// maxIndexSlots is the maximum number of storage slots scanned for the
+	// preimages of the mapping entries.
+	maxIndexSlots = 1 << 20
+
+	// maxCollectionLength is the maximum number of array elements, mapping
+	// entries or 32 byte chunks of strings decoded per collection, whatever the
+	// limit requested. The lengths are read from storage, so they can't be
+	// trusted.
+	maxCollectionLength = 1 << 16
+
+	// maxDecodeValues and maxDecodeBytes are the maximum number of values and
+	// bytes of strings decoded per call. Nested collections multiply their
+	// lengths, so limiting each of them is not enough.
+	maxDecodeValues = 1 << 17
+	maxDecodeBytes  = 1 << 22
+)
+
+// Storage is the storage of a contract to decode.
+type Storage interface {
+	// Slot returns the value of a storage slot.
+	Slot(slot common.Hash) (common.Hash, error)
+
+	// Slots calls fn with all the non-empty slots of the contract, until fn
+	// returns false. It returns the number of slots skipped due to their missing
+	// preimage.
+	Slots(fn func(slot common.Hash) bool) (int, error)
+
+	// Preimage returns the preimage of a hash, nil if unknown.
+	Preimage(hash common.Hash) []byte
+}
+
+// Value is a decoded state variable, struct member, array element or mapping
+// entry.
+type Value struct {
+	Name      string      `json:"name,omitempty"`    // Name of the variable or struct member
+	Key       any         `json:"key,omitempty"`     // Key of the mapping entry
+	Type      string      `json:"type"`              // Solidity type
+	Slot      common.Hash `json:"slot"`              // Slot holding the value
+	Offset    uint64      `json:"offset,omitempty"`  // Offset of the value within its slot in bytes
+	Value     any         `json:"value,omitempty"`   // Value of value types, strings and bytes
+	Length    *big.Int    `json:"length,omitempty"`  // Length of dynamic arrays, strings and bytes
+	Members   []*Value    `json:"members,omitempty"` // Struct members, array elements or mapping entries
+	Truncated bool        `json:"truncated,omitempty"`
+}
+
+// Result is the decoded storage of a contract.
+type Result struct {
+	Variables []*Value `json:"variables"`
+
+	// MissingPreimages is the number of storage slots whose preimage is unknown.
+	// The mapping entries stored in them could not be recovered.
+	MissingPreimages int `json:"missingPreimages"`
+
+	// Incomplete is set if the storage holds too many slots to be scanned for
+	// the mapping entries, or too many values to be decoded in one call. Some
+	// of them might be missing.
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// mappingEntry is a mapping entry recovered from the preimages.
+type mappingEntry struct {
+	key  []byte
+	slot common.Hash
+}
+
+// decoder decodes the storage of a contract according to its layout.
+type decoder struct {
+	ctx      context.Context
+	layout   *Layout
+	storage  Storage
+	limit    uint64 // Maximum number of elements or entries decoded per collection
+	maxSlots int    // Maximum number of storage slots scanned for mapping entries
+	values   uint64 // Number of values left to decode
+	bytes    uint64 // Number of bytes of strings left to decode
+
+	entries    map[common.Hash][]mappingEntry // Mapping entries by mapping slot, indexed on first use
+	missing    int
+	incomplete bool
+}
+
+// Decode decodes the storage of a contract according to its layout. The mapping
+// entries are recovered from the preimages of the storage slots. The number of
+// decoded array elements, mapping entries and bytes of strings is limited by the
+// given limit per collection, or by maxCollectionLength if zero. The decoding is aborted if the
+// context is cancelled.
+func Decode(ctx context.Context, layout *Layout, storage Storage, limit uint64) (*Result, error) {
+	d := &decoder{
+		ctx:      ctx,
+		layout:   layout,
+		storage:  storage,
+		limit:    limit,
+		maxSlots: maxIndexSlots,
+		values:   maxDecodeValues,
+		bytes:    maxDecodeBytes,
+	}
+	return d.run()
+}
+
+// run decodes all the state variables of the layout.
+func (d *decoder) run() (*Result, error) {
+	res := &Result{Variables: make([]*Value, 0, len(d.layout.Storage))}
+	for _, v := range d.layout.Storage {
+		val, err := d.decode(v.Type, v.slot, v.Offset, 0)
+		if err != nil {
+			return nil, err
+		}
+		val.Name = v.Label
+		res.Variables = append(res.Variables, val)
+	}
+	res.MissingPreimages, res.Incomplete = d.missing, d.incomplete
+	return res, nil
+}
+
+// decode decodes a value of the given type stored at the given position and
+// nesting depth.
+func (d *decoder) decode(id string, slot common.Hash, offset uint64, depth int) (*Value, error) {
+	if err := d.ctx.Err(); err != nil {
+		return nil, err
+	}
+	typ := d.layout.Types[id]
+	val := &Value{
+		Type:   typ.Label,
+		Slot:   slot,
+		Offset: offset,
+	}
+	// Recursive types, e.g. structs holding an array of themselves, may nest
+	// as deep as the storage content says. Stop descending at some point.
+	if depth >= maxDecodeDepth && !typ.isValue() {
+		val.Truncated = true
+		return val, nil
+	}
+	if d.values == 0 {
+		val.Truncated, d.incomplete = true, true
+		return val, nil
+	}
+	d.values--
+
+	switch typ.Encoding {
+	case encodingInplace:
+		switch {
+		case len(typ.Members) > 0:
+			for _, member := range typ.Members {
+				child, err := d.decode(member.Type, addSlot(slot, member.slot), member.Offset, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				child.Name = member.Label
+				val.Members = append(val.Members, child)
+			}
+		case typ.Base != "":
+			if err := d.decodeArray(val, typ.Base, slot, new(big.Int).SetUint64(typ.length), depth); err != nil {
+				return nil, err
+			}
+		default:
+			word, err := d.storage.Slot(slot)
+			if err != nil {
+				return nil, err
+			}
+			val.Value = decodeValue(id, word[common.HashLength-offset-typ.size:common.HashLength-offset])
+		}
+	case encodingBytes:
+		if err := d.decodeBytes(val, id, slot); err != nil {
+			return nil, err
+		}
+	case encodingDynamicArray:
+		word, err := d.storage.Slot(slot)
+		if err != nil {
+			return nil, err
+		}
+		val.Length = word.Big()
+		if err := d.decodeArray(val, typ.Base, crypto.Keccak256Hash(slot[:]), val.Length, depth); err != nil {
+			return nil, err
+		}
+	case encodingMapping:
+		entries, err := d.mapping(slot)
+		if err != nil {
+			return nil, err
+		}
+		keyType := d.layout.Types[typ.Key]
+		for _, entry := range entries {
+			key, ok := decodeKey(typ.Key, keyType, entry.key)
+			if !ok {
+				continue
+			}
+			if uint64(len(val.Members)) >= d.maxLength() {
+				val.Truncated = true
+				break
+			}
+			if d.values == 0 {
+				val.Truncated, d.incomplete = true, true
+				break
+			}
+			child, err := d.decode(typ.Value, entry.slot, 0, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			child.Key = key
+			val.Members = append(val.Members, child)
+		}
+	}
+	return val, nil
+}
+
+// decodeArray decodes the elements of an array starting at the given slot.
+func (d *decoder) decodeArray(val *Value, base string, slot common.Hash, length *big.Int, depth int) error {
+	n, limit := length.Uint64(), d.maxLength()
+	if !length.IsUint64() || n > limit {
+		n, val.Truncated = limit, true
+	}
+	typ := d.layout.Types[base]
+	for i := uint64(0); i < n; i++ {
+		if d.values == 0 {
+			val.Truncated, d.incomplete = true, true
+			break
+		}
+		var (
+			pos    common.Hash
+			offset uint64
+		)
+		if typ.isValue() {
+			// Value types are packed, as many as they fit in a slot
+			perSlot := common.HashLength / typ.size
+			pos, offset = advanceSlot(slot, i/perSlot), (i%perSlot)*typ.size
+		} else {
+			// Anything else occupies whole slots
+			pos = advanceSlot(slot, i*((typ.size+common.HashLength-1)/common.HashLength))
+		}
+		child, err := d.decode(base, pos, offset, depth+1)
+		if err != nil {
+			return err
+		}
+		val.Members = append(val.Members, child)
+	}
+	return nil
+}
+
+// decodeBytes decodes a string or a byte array. Short ones are stored in their
+// slot along with their length, long ones starting at the hash of the slot.
+func (d *decoder) decodeBytes(val *Value, id string, slot common.Hash) error {
+	word, err := d.storage.Slot(slot)
+	if err != nil {
+		return err
+	}
+	var data []byte
+	if word[common.HashLength-1]&1 == 0 {
+		length := int(word[common.HashLength-1] / 2)
+		val.Length = big.NewInt(int64(length))
+		data = word[:min(length, common.HashLength-1)]
+	} else {
+		length := new(big.Int).Rsh(word.Big(), 1)
+		val.Length = length
+		n, limit := length.Uint64(), d.maxLength()*common.HashLength
+		if !length.IsUint64() || n > limit {
+			n, val.Truncated = limit, true
+		}
+		if n > d.bytes {
+			n, val.Truncated, d.incomplete = d.bytes, true, true
+		}
+		d.bytes -= n
+
+		start := crypto.Keccak256Hash(slot[:])
+		for i := uint64(0); uint64(len(data)) < n; i++ {
+			if err := d.ctx.Err(); err != nil {
+				return err
+			}
+			chunk, err := d.storage.Slot(advanceSlot(start, i))
+			if err != nil {
+				return err
+			}
+			data = append(data, chunk[:min(n-uint64(len(data)), common.HashLength)]...)
+		}
+	}
+	val.Value = decodeDynamic(id, data)
+	return nil
+}
+
+// maxLength returns the maximum number of elements decoded per collection.
+func (d *decoder) maxLength() uint64 {
+	if d.limit == 0 || d.limit > maxCollectionLength {
+		return maxCollectionLength
+	}
+	return d.limit
+}
+
+// mapping returns the entries of the mapping at the given slot, indexing the
+// preimages of all the storage slots on first use.
+func (d *decoder) mapping(slot common.Hash) ([]mappingEntry, error) {
+	if d.entries == nil {
+		if err := d.index(); err != nil {
+			return nil, err
+		}
+	}
+	return d.entries[slot], nil
+}
+
+// index recovers the mapping entries of the storage from the preimages of the
+// slots. The slot of a mapping entry is the hash of its key and the slot of the
+// mapping, so its preimage reveals both. At most maxSlots slots are scanned.
+func (d *decoder) index() error {
+	// Mapping entries spanning multiple slots might be stored at their tail
+	// only, check the preimages of the slots before as well.
+	span := uint64(1)
+	for _, typ := range d.layout.Types {
+		if typ.Encoding == encodingMapping {
+			span = max(span, (d.layout.Types[typ.Value].size+common.HashLength-1)/common.HashLength)
+		}
+	}
+	var (
+		entries = make(map[common.Hash][]mappingEntry)
+		visited = make(map[common.Hash]bool)
+		scanned int
+		ctxErr  error
+	)
+	var add func(slot common.Hash)
+	add = func(slot common.Hash) {
+		if visited[slot] {
+			return
+		}
+		visited[slot] = true
+
+		preimage := d.storage.Preimage(slot)
+		if len(preimage) < common.HashLength {
+			return
+		}
+		parent := common.BytesToHash(preimage[len(preimage)-common.HashLength:])
+		entries[parent] = append(entries[parent], mappingEntry{
+			key:  preimage[:len(preimage)-common.HashLength],
+			slot: slot,
+		})
+		// The parent might be an entry of an outer mapping, which is not
+		// necessarily a non-empty slot itself
+		add(parent)
+	}
+	missing, err := d.storage.Slots(func(slot common.Hash) bool {
+		if scanned >= d.maxSlots {
+			d.incomplete = true
+			return false
+		}
+		if ctxErr = d.ctx.Err(); ctxErr != nil {
+			return false
+		}
+		scanned++
+
+		for i := uint64(0); i < span; i++ {
+			add(subSlot(slot, i))
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if ctxErr != nil {
+		return ctxErr
+	}
+	for _, list := range entries {
+		slices.SortFunc(list, func(a, b mappingEntry) int {
+			if c := bytes.Compare(a.key, b.key); c != 0 {
+				return c
+			}
+			return a.slot.Cmp(b.slot)
+		})
+	}
+	d.entries, d.missing = entries, missing
+	return nil
+}
+
+// decodeValue decodes a value type from its bytes in storage.
+func decodeValue(id string, data []byte) any {
+	switch {
+	case id == "t_bool":
+		return data[len(data)-1] != 0
+	case strings.HasPrefix(id, "t_address"), strings.HasPrefix(id, "t_contract"):
+		return common.BytesToAddress(data)
+	case strings.HasPrefix(id, "t_uint"), strings.HasPrefix(id, "t_enum"):
+		return new(big.Int).SetBytes(data).String()
+	case strings.HasPrefix(id, "t_int"):
+		v := new(big.Int).SetBytes(data)
+		if data[0]&0x80 != 0 {
+			v.Sub(v, new(big.Int).Lsh(common.Big1, uint(8*len(data))))
+		}
+		return v.String()
+	default:
+		// Fixed size byte arrays, function pointers and anything unknown
+		return hexutil.Bytes(common.CopyBytes(data))
+	}
+}
+
+// decodeDynamic decodes a string or a byte array.
+func decodeDynamic(id string, data []byte) any {
+	if strings.HasPrefix(id, "t_string") && utf8.Valid(data) {
+		return string(data)
+	}
+	return hexutil.Bytes(data)
+}
+
+// decodeKey decodes a mapping key from the preimage of the entry slot, reporting
+// whether it's valid for the key type.
+func decodeKey(id string, typ *Type, key []byte) (any, bool) {
+	if typ.Encoding == encodingBytes {
+		return decodeDynamic(id, key), true
+	}
+	if len(key) != common.HashLength || !typ.isValue() {
+		return nil, false
+	}
+	// Keys are padded to a full word, fixed size byte arrays to the right
+	if strings.HasPrefix(id, "t_bytes") {
+		return decodeValue(id, key[:typ.size]), true
+	}
+	return decodeValue(id, key[common.HashLength-typ.size:]), true
+}
+
+// addSlot returns the slot at the given distance after a slot, wrapping around.
+func addSlot(slot common.Hash, n common.Hash) common.Hash {
+	var a, b uint256.Int
+	a.SetBytes32(slot[:])
+	b.SetBytes32(n[:])
+	return a.Add(&a, &b).Bytes32()
+}
+
+// subSlot returns the slot at the given distance before a slot, wrapping around.
+func subSlot(slot common.Hash, n uint64) common.Hash {
+	var a uint256.Int
+	a.SetBytes32(slot[:])
+	return a.Sub(&a, uint256.NewInt(n)).Bytes32()
+}
+
+// advanceSlot returns the slot at the given distance after a slot, wrapping around.
+func advanceSlot(slot common.Hash, n uint64) common.Hash {
+	var a uint256.Int
+	a.SetBytes32(slot[:])
+	return a.Add(&a, uint256.NewInt(n)).Bytes32()
+}
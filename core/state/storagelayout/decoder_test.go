@@ -0,0 +1,451 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storagelayout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+// testLayout is the storage layout of the following contract, as emitted by solc.
+//
+//	contract Test {
+//	    struct Entry { uint64 x; bytes32 y; }
+//
+//	    uint128 a;
+//	    bool b;
+//	    int8 c;
+//	    address owner;
+//	    string name;
+//	    string desc;
+//	    uint256[] list;
+//	    mapping(address => uint256) balances;
+//	    mapping(address => mapping(uint256 => Entry)) entries;
+//	    uint16[3] fixed;
+//	    mapping(string => bool) flags;
+//	}
+const testLayout = `{
+  "storage": [
+    {"label": "a", "offset": 0, "slot": "0", "type": "t_uint128"},
+    {"label": "b", "offset": 16, "slot": "0", "type": "t_bool"},
+    {"label": "c", "offset": 17, "slot": "0", "type": "t_int8"},
+    {"label": "owner", "offset": 0, "slot": "1", "type": "t_address"},
+    {"label": "name", "offset": 0, "slot": "2", "type": "t_string_storage"},
+    {"label": "desc", "offset": 0, "slot": "3", "type": "t_string_storage"},
+    {"label": "list", "offset": 0, "slot": "4", "type": "t_array(t_uint256)dyn_storage"},
+    {"label": "balances", "offset": 0, "slot": "5", "type": "t_mapping(t_address,t_uint256)"},
+    {"label": "entries", "offset": 0, "slot": "6", "type": "t_mapping(t_address,t_mapping(t_uint256,t_struct(Entry)10_storage))"},
+    {"label": "fixed", "offset": 0, "slot": "7", "type": "t_array(t_uint16)3_storage"},
+    {"label": "flags", "offset": 0, "slot": "8", "type": "t_mapping(t_string_memory_ptr,t_bool)"}
+  ],
+  "types": {
+    "t_address": {"encoding": "inplace", "label": "address", "numberOfBytes": "20"},
+    "t_array(t_uint16)3_storage": {"base": "t_uint16", "encoding": "inplace", "label": "uint16[3]", "numberOfBytes": "32"},
+    "t_array(t_uint256)dyn_storage": {"base": "t_uint256", "encoding": "dynamic_array", "label": "uint256[]", "numberOfBytes": "32"},
+    "t_bool": {"encoding": "inplace", "label": "bool", "numberOfBytes": "1"},
+    "t_bytes32": {"encoding": "inplace", "label": "bytes32", "numberOfBytes": "32"},
+    "t_int8": {"encoding": "inplace", "label": "int8", "numberOfBytes": "1"},
+    "t_mapping(t_address,t_mapping(t_uint256,t_struct(Entry)10_storage))": {"encoding": "mapping", "key": "t_address", "label": "mapping(address => mapping(uint256 => struct Test.Entry))", "numberOfBytes": "32", "value": "t_mapping(t_uint256,t_struct(Entry)10_storage)"},
+    "t_mapping(t_address,t_uint256)": {"encoding": "mapping", "key": "t_address", "label": "mapping(address => uint256)", "numberOfBytes": "32", "value": "t_uint256"},
+    "t_mapping(t_string_memory_ptr,t_bool)": {"encoding": "mapping", "key": "t_string_memory_ptr", "label": "mapping(string => bool)", "numberOfBytes": "32", "value": "t_bool"},
+    "t_mapping(t_uint256,t_struct(Entry)10_storage)": {"encoding": "mapping", "key": "t_uint256", "label": "mapping(uint256 => struct Test.Entry)", "numberOfBytes": "32", "value": "t_struct(Entry)10_storage"},
+    "t_string_memory_ptr": {"encoding": "bytes", "label": "string", "numberOfBytes": "32"},
+    "t_string_storage": {"encoding": "bytes", "label": "string", "numberOfBytes": "32"},
+    "t_struct(Entry)10_storage": {"encoding": "inplace", "label": "struct Test.Entry", "numberOfBytes": "64", "members": [
+      {"label": "x", "offset": 0, "slot": "0", "type": "t_uint64"},
+      {"label": "y", "offset": 0, "slot": "1", "type": "t_bytes32"}
+    ]},
+    "t_uint128": {"encoding": "inplace", "label": "uint128", "numberOfBytes": "16"},
+    "t_uint16": {"encoding": "inplace", "label": "uint16", "numberOfBytes": "2"},
+    "t_uint256": {"encoding": "inplace", "label": "uint256", "numberOfBytes": "32"},
+    "t_uint64": {"encoding": "inplace", "label": "uint64", "numberOfBytes": "8"}
+  }
+}`
+
+// testContract writes the storage of the test contract into a state, recording
+// the preimages of the mapping slots as the EVM does.
+func testContract(t *testing.T) Storage {
+	var (
+		db       = rawdb.NewMemoryDatabase()
+		tdb      = triedb.NewDatabase(db, &triedb.Config{Preimages: true})
+		sdb, _   = state.New(types.EmptyRootHash, state.NewDatabaseWithNodeDB(db, tdb), nil)
+		contract = common.HexToAddress("0xc0ffee")
+	)
+	sdb.SetNonce(contract, 1)
+
+	set := func(slot common.Hash, value []byte) {
+		sdb.SetState(contract, slot, common.BytesToHash(value))
+	}
+	mapping := func(key []byte, parent common.Hash) common.Hash {
+		preimage := append(common.CopyBytes(key), parent[:]...)
+		hash := crypto.Keccak256Hash(preimage)
+		sdb.AddPreimage(hash, preimage)
+		return hash
+	}
+	// Packed value types: a = 7, b = true, c = -5
+	set(slotAt(0), common.FromHex("0xfb0100000000000000000000000000000007"))
+	set(slotAt(1), testAlice[:])
+
+	// Short and long strings
+	set(slotAt(2), append([]byte("hello"), append(make([]byte, 26), 10)...))
+	set(slotAt(3), []byte{byte(2*len(testDesc) + 1)})
+	start := crypto.Keccak256Hash(slotAt(3).Bytes())
+	set(start, []byte(testDesc[:32]))
+	set(advanceSlot(start, 1), append([]byte(testDesc[32:]), make([]byte, 64-len(testDesc))...))
+
+	// Dynamic array of three elements
+	set(slotAt(4), []byte{3})
+	start = crypto.Keccak256Hash(slotAt(4).Bytes())
+	for i := uint64(0); i < 3; i++ {
+		set(advanceSlot(start, i), []byte{byte(10 + i)})
+	}
+	// Mappings, including an entry with only its second slot set
+	set(mapping(common.LeftPadBytes(testAlice[:], 32), slotAt(5)), []byte{100})
+	set(mapping(common.LeftPadBytes(testBob[:], 32), slotAt(5)), []byte{200})
+
+	inner := mapping(common.LeftPadBytes(testBob[:], 32), slotAt(6))
+	entry := mapping(slotAt(42).Bytes(), inner)
+	set(entry, []byte{1})
+	set(advanceSlot(entry, 1), common.HexToHash("0xff").Bytes())
+	entry = mapping(slotAt(43).Bytes(), inner)
+	set(advanceSlot(entry, 1), common.HexToHash("0xee").Bytes())
+
+	// Packed static array: [1, 2, 3]
+	set(slotAt(7), common.FromHex("0x000300020001"))
+	set(mapping([]byte("enabled"), slotAt(8)), []byte{1})
+
+	root, err := sdb.Commit(0, false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	rawdb.WritePreimages(db, sdb.Preimages())
+	if err := tdb.Commit(root, false); err != nil {
+		t.Fatalf("failed to commit trie database: %v", err)
+	}
+	sdb, _ = state.New(root, state.NewDatabaseWithNodeDB(db, tdb), nil)
+	storage, err := NewTrieStorage(db, tdb, root, contract, sdb.GetStorageRoot(contract))
+	if err != nil {
+		t.Fatalf("failed to open storage: %v", err)
+	}
+	return storage
+}
+
+var (
+	testAlice = common.HexToAddress("0xa11ce")
+	testBob   = common.HexToAddress("0xb0b")
+	testDesc  = strings.Repeat("abcd", 10)
+)
+
+func slotAt(n uint64) common.Hash {
+	return common.BigToHash(new(big.Int).SetUint64(n))
+}
+
+// testView is the relevant part of a decoded value, for comparisons.
+type testView struct {
+	Key       any        `json:"key,omitempty"`
+	Value     any        `json:"value,omitempty"`
+	Length    *big.Int   `json:"length,omitempty"`
+	Members   []testView `json:"members,omitempty"`
+	Truncated bool       `json:"truncated,omitempty"`
+}
+
+func view(vals []*Value) string {
+	var convert func(vals []*Value) []testView
+	convert = func(vals []*Value) []testView {
+		var views []testView
+		for _, val := range vals {
+			views = append(views, testView{val.Key, val.Value, val.Length, convert(val.Members), val.Truncated})
+		}
+		return views
+	}
+	blob, _ := json.Marshal(convert(vals))
+	return string(blob)
+}
+
+func TestDecode(t *testing.T) {
+	layout, err := Parse([]byte(testLayout))
+	if err != nil {
+		t.Fatalf("failed to parse layout: %v", err)
+	}
+	storage := testContract(t)
+
+	res, err := Decode(context.Background(), layout, storage, 0)
+	if err != nil {
+		t.Fatalf("failed to decode storage: %v", err)
+	}
+	if res.MissingPreimages != 0 {
+		t.Errorf("missing preimages: have %d, want 0", res.MissingPreimages)
+	}
+	want := map[string]string{
+		"a":        `[{"value":"7"}]`,
+		"b":        `[{"value":true}]`,
+		"c":        `[{"value":"-5"}]`,
+		"owner":    `[{"value":"0x00000000000000000000000000000000000a11ce"}]`,
+		"name":     `[{"value":"hello","length":5}]`,
+		"desc":     `[{"value":"` + testDesc + `","length":40}]`,
+		"list":     `[{"length":3,"members":[{"value":"10"},{"value":"11"},{"value":"12"}]}]`,
+		"balances": `[{"members":[{"key":"0x0000000000000000000000000000000000000b0b","value":"200"},{"key":"0x00000000000000000000000000000000000a11ce","value":"100"}]}]`,
+		"entries": `[{"members":[{"key":"0x0000000000000000000000000000000000000b0b","members":[` +
+			`{"key":"42","members":[{"value":"1"},{"value":"0x00000000000000000000000000000000000000000000000000000000000000ff"}]},` +
+			`{"key":"43","members":[{"value":"0"},{"value":"0x00000000000000000000000000000000000000000000000000000000000000ee"}]}]}]}]`,
+		"fixed": `[{"members":[{"value":"1"},{"value":"2"},{"value":"3"}]}]`,
+		"flags": `[{"members":[{"key":"enabled","value":true}]}]`,
+	}
+	if len(res.Variables) != len(want) {
+		t.Fatalf("variable count mismatch: have %d, want %d", len(res.Variables), len(want))
+	}
+	for _, val := range res.Variables {
+		if have := view([]*Value{val}); have != want[val.Name] {
+			t.Errorf("variable %s mismatch:\nhave %s\nwant %s", val.Name, have, want[val.Name])
+		}
+	}
+	// Collections should be truncated to the limit
+	res, err = Decode(context.Background(), layout, storage, 1)
+	if err != nil {
+		t.Fatalf("failed to decode storage: %v", err)
+	}
+	for _, val := range res.Variables {
+		switch val.Name {
+		case "list", "balances", "fixed", "desc":
+			if !val.Truncated {
+				t.Errorf("variable %s not truncated", val.Name)
+			}
+		case "name", "flags":
+			if val.Truncated {
+				t.Errorf("variable %s truncated", val.Name)
+			}
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		`{"storage": [{"label": "a", "offset": 0, "slot": "0", "type": "t_missing"}], "types": {}}`,
+		`{"storage": [{"label": "a", "offset": 0, "slot": "x", "type": "t_bool"}], "types": {"t_bool": {"encoding": "inplace", "label": "bool", "numberOfBytes": "1"}}}`,
+		`{"storage": [{"label": "a", "offset": 31, "slot": "0", "type": "t_uint16"}], "types": {"t_uint16": {"encoding": "inplace", "label": "uint16", "numberOfBytes": "2"}}}`,
+		`{"storage": [], "types": {"t_x": {"encoding": "unknown", "label": "x", "numberOfBytes": "1"}}}`,
+		`{"storage": [], "types": {"t_m": {"encoding": "mapping", "key": "t_k", "value": "t_v", "label": "m", "numberOfBytes": "32"}}}`,
+		`{"storage": [], "types": {"t_a": {"encoding": "inplace", "base": "t_a", "label": "a[]", "numberOfBytes": "32"}}}`,
+		`{"storage": [], "types": {"t_a": {"encoding": "inplace", "base": "t_a", "label": "a[2]", "numberOfBytes": "32"}}}`,
+		`{"storage": [], "types": {"t_s": {"encoding": "inplace", "label": "struct S", "numberOfBytes": "32", "members": [{"label": "s", "offset": 0, "slot": "0", "type": "t_s"}]}}}`,
+		`{"storage": [], "types": {"t_s": {"encoding": "inplace", "label": "struct S", "numberOfBytes": "64", "members": [{"label": "a", "offset": 0, "slot": "0", "type": "t_a"}]}, "t_a": {"encoding": "inplace", "base": "t_s", "label": "struct S[1]", "numberOfBytes": "64"}}}`,
+	}
+	for i, test := range tests {
+		if _, err := Parse([]byte(test)); err == nil {
+			t.Errorf("test %d: expected error", i)
+		}
+	}
+}
+
+// nodeLayout is the storage layout of a struct containing itself through a
+// dynamic array, which is allowed.
+//
+//	contract Tree {
+//	    struct Node { Node[] children; }
+//	    Node root;
+//	}
+const nodeLayout = `{
+  "storage": [
+    {"label": "root", "offset": 0, "slot": "0", "type": "t_struct(Node)3_storage"}
+  ],
+  "types": {
+    "t_array(t_struct(Node)3_storage)dyn_storage": {"base": "t_struct(Node)3_storage", "encoding": "dynamic_array", "label": "struct Tree.Node[]", "numberOfBytes": "32"},
+    "t_struct(Node)3_storage": {"encoding": "inplace", "label": "struct Tree.Node", "numberOfBytes": "32", "members": [
+      {"label": "children", "offset": 0, "slot": "0", "type": "t_array(t_struct(Node)3_storage)dyn_storage"}
+    ]}
+  }
+}`
+
+// filledStorage is a storage with all its slots set to one.
+type filledStorage struct{}
+
+func (filledStorage) Slot(slot common.Hash) (common.Hash, error)        { return common.Hash{31: 1}, nil }
+func (filledStorage) Slots(fn func(slot common.Hash) bool) (int, error) { return 0, nil }
+func (filledStorage) Preimage(hash common.Hash) []byte                  { return nil }
+
+// Tests that recursive types are only decoded up to the maximum depth.
+func TestDecodeDepth(t *testing.T) {
+	layout, err := Parse([]byte(nodeLayout))
+	if err != nil {
+		t.Fatalf("failed to parse layout: %v", err)
+	}
+	res, err := Decode(context.Background(), layout, filledStorage{}, 0)
+	if err != nil {
+		t.Fatalf("failed to decode storage: %v", err)
+	}
+	var (
+		val   = res.Variables[0]
+		depth int
+	)
+	for len(val.Members) > 0 {
+		val = val.Members[0]
+		depth++
+	}
+	if depth != maxDecodeDepth {
+		t.Errorf("depth mismatch: have %d, want %d", depth, maxDecodeDepth)
+	}
+	if !val.Truncated {
+		t.Error("deepest value not truncated")
+	}
+}
+
+// Tests that the decoding is aborted when the context is cancelled.
+func TestDecodeCancel(t *testing.T) {
+	layout, err := Parse([]byte(testLayout))
+	if err != nil {
+		t.Fatalf("failed to parse layout: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := Decode(ctx, layout, testContract(t), 0); !errors.Is(err, context.Canceled) {
+		t.Fatalf("error mismatch: have %v, want %v", err, context.Canceled)
+	}
+}
+
+// Tests that at most the maximum number of slots are scanned for mapping
+// entries, and that the result is flagged incomplete if there are more.
+func TestDecodeSlotLimit(t *testing.T) {
+	layout, err := Parse([]byte(testLayout))
+	if err != nil {
+		t.Fatalf("failed to parse layout: %v", err)
+	}
+	storage := testContract(t)
+
+	d := &decoder{ctx: context.Background(), layout: layout, storage: storage, maxSlots: 1}
+	res, err := d.run()
+	if err != nil {
+		t.Fatalf("failed to decode storage: %v", err)
+	}
+	if !res.Incomplete {
+		t.Error("result not flagged incomplete")
+	}
+	var entries int
+	for _, val := range res.Variables {
+		if val.Name == "balances" || val.Name == "entries" || val.Name == "flags" {
+			entries += len(val.Members)
+		}
+	}
+	if entries > 1 {
+		t.Errorf("too many mapping entries recovered: have %d, want at most 1", entries)
+	}
+	// The full scan should not be flagged
+	res, err = Decode(context.Background(), layout, storage, 0)
+	if err != nil {
+		t.Fatalf("failed to decode storage: %v", err)
+	}
+	if res.Incomplete {
+		t.Error("full scan flagged incomplete")
+	}
+}
+
+// Tests that layouts decoded straight from JSON, as the RPC arguments are, check
+// the struct members against the sizes of their types whatever the map order.
+func TestUnmarshalMemberSize(t *testing.T) {
+	const blob = `{"storage": [{"label": "s", "offset": 0, "slot": "0", "type": "t_struct(S)"}], "types": {
+		"t_struct(S)": {"encoding": "inplace", "label": "struct S", "numberOfBytes": "64", "members": [{"label": "x", "offset": 31, "slot": "0", "type": "t_uint256"}]},
+		"t_uint256": {"encoding": "inplace", "label": "uint256", "numberOfBytes": "32"}}}`
+
+	for i := 0; i < 100; i++ {
+		var layout Layout
+		if err := json.Unmarshal([]byte(blob), &layout); err == nil {
+			t.Fatalf("run %d: expected error", i)
+		}
+	}
+}
+
+// hugeStorage is a storage with all its slots holding the largest length of a
+// long string.
+type hugeStorage struct{}
+
+func (hugeStorage) Slot(slot common.Hash) (common.Hash, error) {
+	return common.HexToHash("0x7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"), nil
+}
+func (hugeStorage) Slots(fn func(slot common.Hash) bool) (int, error) { return 0, nil }
+func (hugeStorage) Preimage(hash common.Hash) []byte                  { return nil }
+
+// Tests that the lengths read from storage are capped even without a limit.
+func TestDecodeLengthCap(t *testing.T) {
+	const blob = `{"storage": [
+		{"label": "s", "offset": 0, "slot": "0", "type": "t_string_storage"},
+		{"label": "l", "offset": 0, "slot": "1", "type": "t_array(t_uint256)dyn_storage"}], "types": {
+		"t_string_storage": {"encoding": "bytes", "label": "string", "numberOfBytes": "32"},
+		"t_array(t_uint256)dyn_storage": {"base": "t_uint256", "encoding": "dynamic_array", "label": "uint256[]", "numberOfBytes": "32"},
+		"t_uint256": {"encoding": "inplace", "label": "uint256", "numberOfBytes": "32"}}}`
+
+	layout, err := Parse([]byte(blob))
+	if err != nil {
+		t.Fatalf("failed to parse layout: %v", err)
+	}
+	res, err := Decode(context.Background(), layout, hugeStorage{}, 0)
+	if err != nil {
+		t.Fatalf("failed to decode storage: %v", err)
+	}
+	str, list := res.Variables[0], res.Variables[1]
+	if !str.Truncated || len(str.Value.(hexutil.Bytes)) != maxCollectionLength*common.HashLength {
+		t.Errorf("string not capped: truncated %v", str.Truncated)
+	}
+	if !list.Truncated || len(list.Members) != maxCollectionLength {
+		t.Errorf("array not capped: have %d elements, truncated %v", len(list.Members), list.Truncated)
+	}
+}
+
+// Tests that the values decoded from nested static arrays, multiplying their
+// lengths, are bounded per call.
+func TestDecodeNestedArrays(t *testing.T) {
+	const blob = `{"storage": [{"label": "a", "offset": 0, "slot": "0", "type": "t_array(t_array(t_array(t_uint256)1024_storage)1024_storage)1024_storage"}], "types": {
+		"t_array(t_array(t_array(t_uint256)1024_storage)1024_storage)1024_storage": {"base": "t_array(t_array(t_uint256)1024_storage)1024_storage", "encoding": "inplace", "label": "uint256[1024][1024][1024]", "numberOfBytes": "34359738368"},
+		"t_array(t_array(t_uint256)1024_storage)1024_storage": {"base": "t_array(t_uint256)1024_storage", "encoding": "inplace", "label": "uint256[1024][1024]", "numberOfBytes": "33554432"},
+		"t_array(t_uint256)1024_storage": {"base": "t_uint256", "encoding": "inplace", "label": "uint256[1024]", "numberOfBytes": "32768"},
+		"t_uint256": {"encoding": "inplace", "label": "uint256", "numberOfBytes": "32"}}}`
+
+	layout, err := Parse([]byte(blob))
+	if err != nil {
+		t.Fatalf("failed to parse layout: %v", err)
+	}
+	res, err := Decode(context.Background(), layout, filledStorage{}, 0)
+	if err != nil {
+		t.Fatalf("failed to decode storage: %v", err)
+	}
+	if !res.Incomplete {
+		t.Error("result not flagged incomplete")
+	}
+	var count func(vals []*Value) int
+	count = func(vals []*Value) int {
+		n := len(vals)
+		for _, val := range vals {
+			n += count(val.Members)
+		}
+		return n
+	}
+	// Each collection on the path to the exhausting value adds a truncated one
+	if n := count(res.Variables); n > maxDecodeValues+maxDecodeDepth {
+		t.Errorf("too many values decoded: have %d, want at most %d", n, maxDecodeValues+maxDecodeDepth)
+	}
+}
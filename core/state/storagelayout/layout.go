@@ -0,0 +1,209 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package storagelayout decodes the storage of Solidity contracts according to
+// the storage layout emitted by the compiler.
+package storagelayout
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Type encodings of the storage layout.
+const (
+	encodingInplace      = "inplace"
+	encodingMapping      = "mapping"
+	encodingDynamicArray = "dynamic_array"
+	encodingBytes        = "bytes"
+)
+
+// Variable is a state variable or a struct member in a storage layout.
+type Variable struct {
+	Label  string `json:"label"`
+	Offset uint64 `json:"offset"` // Offset within the slot in bytes
+	Slot   string `json:"slot"`   // Slot in decimal, relative to the struct for members
+	Type   string `json:"type"`   // Identifier of the type in the layout
+
+	slot common.Hash
+}
+
+// Type is a type definition in a storage layout.
+type Type struct {
+	Encoding      string      `json:"encoding"`
+	Label         string      `json:"label"`
+	NumberOfBytes string      `json:"numberOfBytes"`
+	Key           string      `json:"key,omitempty"`     // Key type of mappings
+	Value         string      `json:"value,omitempty"`   // Value type of mappings
+	Base          string      `json:"base,omitempty"`    // Element type of arrays
+	Members       []*Variable `json:"members,omitempty"` // Members of structs
+
+	size   uint64 // Number of bytes occupied in storage
+	length uint64 // Number of elements of static arrays
+}
+
+// Layout is the storage layout of a contract, in the format emitted by solc with
+// the storageLayout output selection.
+type Layout struct {
+	Storage []*Variable      `json:"storage"`
+	Types   map[string]*Type `json:"types"`
+}
+
+// Parse decodes and validates a storage layout.
+func Parse(blob []byte) (*Layout, error) {
+	var layout Layout
+	if err := json.Unmarshal(blob, &layout); err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, validating the layout.
+func (l *Layout) UnmarshalJSON(input []byte) error {
+	type layout Layout
+	var dec layout
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	*l = Layout(dec)
+	return l.validate()
+}
+
+// validate checks the consistency of the layout, parsing the numeric fields.
+func (l *Layout) validate() error {
+	if l.Types == nil && len(l.Storage) > 0 {
+		return fmt.Errorf("missing types in storage layout")
+	}
+	// Size all the types first, the struct members are checked against the
+	// sizes of their types.
+	for id, typ := range l.Types {
+		size, err := strconv.ParseUint(typ.NumberOfBytes, 10, 64)
+		if err != nil {
+			return fmt.Errorf("type %s: invalid size %q", id, typ.NumberOfBytes)
+		}
+		typ.size = size
+	}
+	for id, typ := range l.Types {
+		var err error
+		switch typ.Encoding {
+		case encodingInplace:
+			switch {
+			case len(typ.Members) > 0:
+				if err := l.validateVariables(typ.Members); err != nil {
+					return fmt.Errorf("type %s: %v", id, err)
+				}
+			case typ.Base != "":
+				start := strings.LastIndex(typ.Label, "[")
+				if start < 0 || !strings.HasSuffix(typ.Label, "]") {
+					return fmt.Errorf("type %s: missing array length", id)
+				}
+				if typ.length, err = strconv.ParseUint(typ.Label[start+1:len(typ.Label)-1], 10, 64); err != nil {
+					return fmt.Errorf("type %s: invalid array length: %v", id, err)
+				}
+				if _, ok := l.Types[typ.Base]; !ok {
+					return fmt.Errorf("type %s: unknown element type %s", id, typ.Base)
+				}
+			default:
+				if typ.size == 0 || typ.size > common.HashLength {
+					return fmt.Errorf("type %s: invalid value size %d", id, typ.size)
+				}
+			}
+		case encodingMapping:
+			if _, ok := l.Types[typ.Key]; !ok {
+				return fmt.Errorf("type %s: unknown key type %q", id, typ.Key)
+			}
+			if _, ok := l.Types[typ.Value]; !ok {
+				return fmt.Errorf("type %s: unknown value type %q", id, typ.Value)
+			}
+		case encodingDynamicArray:
+			if _, ok := l.Types[typ.Base]; !ok {
+				return fmt.Errorf("type %s: unknown element type %q", id, typ.Base)
+			}
+		case encodingBytes:
+		default:
+			return fmt.Errorf("type %s: unknown encoding %q", id, typ.Encoding)
+		}
+	}
+	// Structs and static arrays are laid out in place, so they can't contain
+	// themselves other than through a mapping or a dynamic array.
+	visited := make(map[string]bool)
+	for id := range l.Types {
+		if err := l.checkRecursion(id, visited, make(map[string]bool)); err != nil {
+			return err
+		}
+	}
+	return l.validateVariables(l.Storage)
+}
+
+// checkRecursion checks that the in-place type doesn't contain itself in place,
+// tracking the types on the current path in stack.
+func (l *Layout) checkRecursion(id string, visited, stack map[string]bool) error {
+	if stack[id] {
+		return fmt.Errorf("type %s: recursive in-place type", id)
+	}
+	if visited[id] {
+		return nil
+	}
+	visited[id], stack[id] = true, true
+	defer delete(stack, id)
+
+	typ := l.Types[id]
+	if typ.Encoding != encodingInplace {
+		return nil
+	}
+	if typ.Base != "" {
+		if err := l.checkRecursion(typ.Base, visited, stack); err != nil {
+			return err
+		}
+	}
+	for _, member := range typ.Members {
+		if err := l.checkRecursion(member.Type, visited, stack); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateVariables checks the state variables or struct members, parsing their
+// slots.
+func (l *Layout) validateVariables(vars []*Variable) error {
+	for _, v := range vars {
+		typ, ok := l.Types[v.Type]
+		if !ok {
+			return fmt.Errorf("variable %s: unknown type %q", v.Label, v.Type)
+		}
+		slot, ok := new(big.Int).SetString(v.Slot, 10)
+		if !ok || slot.Sign() < 0 || slot.BitLen() > 256 {
+			return fmt.Errorf("variable %s: invalid slot %q", v.Label, v.Slot)
+		}
+		v.slot = common.BigToHash(slot)
+
+		if typ.isValue() && v.Offset+typ.size > common.HashLength {
+			return fmt.Errorf("variable %s: value exceeds its slot", v.Label)
+		}
+	}
+	return nil
+}
+
+// isValue reports whether the type is a value type, packed within a slot.
+func (t *Type) isValue() bool {
+	return t.Encoding == encodingInplace && len(t.Members) == 0 && t.Base == ""
+}
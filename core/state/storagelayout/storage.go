@@ -0,0 +1,87 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package storagelayout
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+// trieStorage is a Storage backed by the storage trie of a contract. The slots
+// are enumerated through the preimages of the trie keys, which are recorded if
+// the preimage cache is enabled. The preimages of the mapping slots are recorded
+// by the EVM if preimage recording is enabled.
+type trieStorage struct {
+	db     ethdb.KeyValueReader
+	triedb *triedb.Database
+	trie   *trie.StateTrie
+}
+
+// NewTrieStorage opens the storage trie of a contract in the given state.
+func NewTrieStorage(db ethdb.KeyValueReader, triedb *triedb.Database, stateRoot common.Hash, address common.Address, storageRoot common.Hash) (Storage, error) {
+	id := trie.StorageTrieID(stateRoot, crypto.Keccak256Hash(address.Bytes()), storageRoot)
+	tr, err := trie.NewStateTrie(id, triedb)
+	if err != nil {
+		return nil, err
+	}
+	return &trieStorage{db: db, triedb: triedb, trie: tr}, nil
+}
+
+// Slot implements Storage, returning the value of a storage slot.
+func (s *trieStorage) Slot(slot common.Hash) (common.Hash, error) {
+	blob, err := s.trie.GetStorage(common.Address{}, slot[:])
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(blob), nil
+}
+
+// Slots implements Storage, iterating the slots of the storage trie.
+func (s *trieStorage) Slots(fn func(slot common.Hash) bool) (int, error) {
+	nodeIt, err := s.trie.NodeIterator(nil)
+	if err != nil {
+		return 0, err
+	}
+	var (
+		it      = trie.NewIterator(nodeIt)
+		missing int
+	)
+	for it.Next() {
+		slot := s.Preimage(common.BytesToHash(it.Key))
+		if len(slot) != common.HashLength {
+			missing++
+			continue
+		}
+		if !fn(common.BytesToHash(slot)) {
+			break
+		}
+	}
+	return missing, it.Err
+}
+
+// Preimage implements Storage, looking up the preimage of a hash in the trie
+// database or in the persisted preimages.
+func (s *trieStorage) Preimage(hash common.Hash) []byte {
+	if preimage := s.triedb.Preimage(hash); preimage != nil {
+		return preimage
+	}
+	return rawdb.ReadPreimage(s.db, hash)
+}
@@ -54,10 +54,23 @@ func NewStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consen
 // the transaction messages using the statedb and applying any rewards to both
 // the processor (coinbase) and any included uncles.
 //
-// Process returns the receipts and logs accumulated during the process and
-// returns the amount of gas that was used in the process. If any of the
-// transactions failed to execute due to insufficient gas it will return an error.
-func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
+// Process returns the receipts, logs and execution layer requests accumulated
+// during the process and the amount of gas that was used in the process. If any
+// of the transactions failed to execute due to insufficient gas it will return
+// an error.
+func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (*ProcessResult, error) {
+	return p.process(block, statedb, cfg, p.bc)
+}
+
+// processChain is the chain access needed for processing a block.
+type processChain interface {
+	ChainContext
+	consensus.ChainHeaderReader
+}
+
+// process is Process resolving the ancestor headers of the block through the
+// given chain instead of the canonical one.
+func (p *StateProcessor) process(block *types.Block, statedb *state.StateDB, cfg vm.Config, chain processChain) (*ProcessResult, error) {
 	var (
 		receipts    types.Receipts
 		usedGas     = new(uint64)
@@ -72,24 +85,36 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		misc.ApplyDAOHardFork(statedb)
 	}
 	misc.EnsureCreate2Deployer(p.config, block.Time(), statedb)
+	context := NewEVMBlockContext(header, chain, nil, p.config, statedb)
+
+	// Pull the ancestor headers into the witness as their hashes are requested
+	if witness := statedb.Witness(); witness != nil {
+		getHash := context.GetHash
+		context.GetHash = func(n uint64) common.Hash {
+			witness.AddBlockHash(n)
+			return getHash(n)
+		}
+	}
 	var (
-		context = NewEVMBlockContext(header, p.bc, nil, p.config, statedb)
-		vmenv   = vm.NewEVM(context, vm.TxContext{}, statedb, p.config, cfg)
-		signer  = types.MakeSigner(p.config, header.Number, header.Time)
+		vmenv  = vm.NewEVM(context, vm.TxContext{}, statedb, p.config, cfg)
+		signer = types.MakeSigner(p.config, header.Number, header.Time)
 	)
 	if beaconRoot := block.BeaconRoot(); beaconRoot != nil {
 		ProcessBeaconBlockRoot(*beaconRoot, vmenv, statedb)
 	}
+	if p.config.IsPrague(block.Number(), block.Time()) {
+		ProcessParentBlockHash(block.ParentHash(), vmenv, statedb)
+	}
 	// Iterate over and process the individual transactions
 	for i, tx := range block.Transactions() {
 		msg, err := TransactionToMessage(tx, signer, header.BaseFee)
 		if err != nil {
-			return nil, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+			return nil, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
 		}
 		statedb.SetTxContext(tx.Hash(), i)
 		receipt, err := applyTransaction(msg, p.config, gp, statedb, blockNumber, blockHash, tx, usedGas, vmenv)
 		if err != nil {
-			return nil, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+			return nil, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
 		}
 		receipts = append(receipts, receipt)
 		allLogs = append(allLogs, receipt.Logs...)
@@ -97,12 +122,25 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 	// Fail if Shanghai not enabled and len(withdrawals) is non-zero.
 	withdrawals := block.Withdrawals()
 	if len(withdrawals) > 0 && !p.config.IsShanghai(block.Number(), block.Time()) {
-		return nil, nil, 0, errors.New("withdrawals before shanghai")
+		return nil, errors.New("withdrawals before shanghai")
+	}
+	// Read requests if Prague is enabled.
+	var requests [][]byte
+	if p.config.IsPrague(block.Number(), block.Time()) {
+		var err error
+		if requests, err = ProcessRequests(p.config, allLogs, vmenv, statedb); err != nil {
+			return nil, err
+		}
 	}
 	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
-	p.engine.Finalize(p.bc, header, statedb, block.Transactions(), block.Uncles(), withdrawals)
+	p.engine.Finalize(chain, header, statedb, block.Transactions(), block.Uncles(), withdrawals)
 
-	return receipts, allLogs, *usedGas, nil
+	return &ProcessResult{
+		Receipts: receipts,
+		Requests: requests,
+		Logs:     allLogs,
+		GasUsed:  *usedGas,
+	}, nil
 }
 
 func applyTransaction(msg *Message, config *params.ChainConfig, gp *GasPool, statedb *state.StateDB, blockNumber *big.Int, blockHash common.Hash, tx *types.Transaction, usedGas *uint64, evm *vm.EVM) (*types.Receipt, error) {
@@ -120,6 +158,11 @@ func applyTransaction(msg *Message, config *params.ChainConfig, gp *GasPool, sta
 	if err != nil {
 		return nil, err
 	}
+	// A suspended transaction is not finalised, it can't be part of a block
+	if result.Suspended() {
+		return nil, result.Err
+	}
+	mergeAccessEvents(statedb, evm)
 
 	// Update the state with pending changes.
 	var root []byte
@@ -187,6 +230,14 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *commo
 	return applyTransaction(msg, config, gp, statedb, header.Number, header.Hash(), tx, usedGas, vmenv)
 }
 
+// mergeAccessEvents collects the verkle tree locations accessed by the last
+// message executed in the EVM into the block's, if in verkle mode.
+func mergeAccessEvents(statedb *state.StateDB, evm *vm.EVM) {
+	if ae := statedb.AccessEvents(); ae != nil && evm.AccessEvents != nil {
+		ae.Merge(evm.AccessEvents)
+	}
+}
+
 // ProcessBeaconBlockRoot applies the EIP-4788 system call to the beacon block root
 // contract. This method is exported to be used in tests.
 func ProcessBeaconBlockRoot(beaconRoot common.Hash, vmenv *vm.EVM, statedb *state.StateDB) {
@@ -204,5 +255,111 @@ func ProcessBeaconBlockRoot(beaconRoot common.Hash, vmenv *vm.EVM, statedb *stat
 	vmenv.Reset(NewEVMTxContext(msg), statedb)
 	statedb.AddAddressToAccessList(params.BeaconRootsStorageAddress)
 	_, _, _ = vmenv.Call(vm.AccountRef(msg.From), *msg.To, msg.Data, 30_000_000, common.U2560)
+	mergeAccessEvents(statedb, vmenv)
+	statedb.Finalise(true)
+}
+
+// ProcessParentBlockHash stores the parent block hash in the history storage
+// contract as per EIP-2935.
+func ProcessParentBlockHash(prevHash common.Hash, vmenv *vm.EVM, statedb *state.StateDB) {
+	msg := &Message{
+		From:      params.SystemAddress,
+		GasLimit:  30_000_000,
+		GasPrice:  common.Big0,
+		GasFeeCap: common.Big0,
+		GasTipCap: common.Big0,
+		To:        &params.HistoryStorageAddress,
+		Data:      prevHash.Bytes(),
+	}
+	vmenv.Reset(NewEVMTxContext(msg), statedb)
+	statedb.AddAddressToAccessList(params.HistoryStorageAddress)
+	_, _, _ = vmenv.Call(vm.AccountRef(msg.From), *msg.To, msg.Data, 30_000_000, common.U2560)
+	mergeAccessEvents(statedb, vmenv)
 	statedb.Finalise(true)
 }
+
+// ProcessRequests collects the EIP-7685 execution layer requests of a block
+// after all of its transactions have been applied: the EIP-6110 deposits from
+// the logs of the deposit contract, followed by the EIP-7002 withdrawal and
+// EIP-7251 consolidation requests dequeued from their system contracts.
+func ProcessRequests(config *params.ChainConfig, logs []*types.Log, vmenv *vm.EVM, statedb *state.StateDB) ([][]byte, error) {
+	requests := [][]byte{}
+	if err := ParseDepositLogs(&requests, logs, config); err != nil {
+		return nil, err
+	}
+	if err := ProcessWithdrawalQueue(&requests, vmenv, statedb); err != nil {
+		return nil, err
+	}
+	if err := ProcessConsolidationQueue(&requests, vmenv, statedb); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// depositTopic is the topic of the DepositEvent emitted by the deposit contract,
+// keccak256("DepositEvent(bytes,bytes,bytes,bytes,bytes)").
+var depositTopic = common.HexToHash("0x649bbc62d0e31342afea4e5cd82d4049e7e1ee912fc0889aa790803be39038c5")
+
+// ParseDepositLogs extracts the EIP-6110 deposit requests from the logs emitted
+// by the deposit contract and appends them as a single request.
+func ParseDepositLogs(requests *[][]byte, logs []*types.Log, config *params.ChainConfig) error {
+	deposits := []byte{types.DepositRequestType}
+	for _, log := range logs {
+		if log.Address == config.DepositContractAddress && len(log.Topics) > 0 && log.Topics[0] == depositTopic {
+			request, err := types.DepositLogToRequest(log.Data)
+			if err != nil {
+				return fmt.Errorf("unable to parse deposit data: %v", err)
+			}
+			deposits = append(deposits, request...)
+		}
+	}
+	if len(deposits) > 1 {
+		*requests = append(*requests, deposits)
+	}
+	return nil
+}
+
+// ProcessWithdrawalQueue calls the EIP-7002 withdrawal queue contract and
+// appends the dequeued withdrawal requests.
+func ProcessWithdrawalQueue(requests *[][]byte, vmenv *vm.EVM, statedb *state.StateDB) error {
+	return processRequestsSystemCall(requests, vmenv, statedb, types.WithdrawalRequestType, params.WithdrawalQueueAddress)
+}
+
+// ProcessConsolidationQueue calls the EIP-7251 consolidation queue contract and
+// appends the dequeued consolidation requests.
+func ProcessConsolidationQueue(requests *[][]byte, vmenv *vm.EVM, statedb *state.StateDB) error {
+	return processRequestsSystemCall(requests, vmenv, statedb, types.ConsolidationRequestType, params.ConsolidationQueueAddress)
+}
+
+// processRequestsSystemCall invokes a request system contract and appends its
+// output, prefixed with the request type, if any requests were returned. The
+// block is invalid if the contract is missing or its call fails.
+func processRequestsSystemCall(requests *[][]byte, vmenv *vm.EVM, statedb *state.StateDB, requestType byte, addr common.Address) error {
+	if statedb.GetCodeSize(addr) == 0 {
+		return fmt.Errorf("system contract %x has no code", addr)
+	}
+	msg := &Message{
+		From:      params.SystemAddress,
+		GasLimit:  30_000_000,
+		GasPrice:  common.Big0,
+		GasFeeCap: common.Big0,
+		GasTipCap: common.Big0,
+		To:        &addr,
+	}
+	vmenv.Reset(NewEVMTxContext(msg), statedb)
+	statedb.AddAddressToAccessList(addr)
+	ret, _, err := vmenv.Call(vm.AccountRef(msg.From), *msg.To, msg.Data, 30_000_000, common.U2560)
+	mergeAccessEvents(statedb, vmenv)
+	statedb.Finalise(true)
+	if err != nil {
+		return fmt.Errorf("system call to %x failed: %v", addr, err)
+	}
+	if len(ret) == 0 {
+		return nil
+	}
+	request := make([]byte, len(ret)+1)
+	request[0] = requestType
+	copy(request[1:], ret)
+	*requests = append(*requests, request)
+	return nil
+}
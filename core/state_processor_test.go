@@ -29,6 +29,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/misc/eip1559"
 	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -422,3 +423,94 @@ func GenerateBadBlock(parent *types.Block, engine consensus.Engine, txs types.Tr
 	}
 	return types.NewBlock(header, txs, nil, receipts, trie.NewStackTrie(nil))
 }
+
+// Tests that a message suspended at a checkpoint is not charged any gas, and
+// that resuming it settles the gas the same way the uninterrupted execution does.
+func TestResumeMessage(t *testing.T) {
+	var (
+		sender   = common.HexToAddress("0x71562b71999873db5b286df957af199ec94617f7")
+		counter  = common.HexToAddress("0xc0ffee")
+		coinbase = common.HexToAddress("0xc0ba5e")
+	)
+	newState := func() *state.StateDB {
+		statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		statedb.SetBalance(sender, uint256.NewInt(params.Ether))
+		// The counter contract increments slot 0 on every call
+		statedb.SetCode(counter, []byte{
+			byte(vm.PUSH1), 0, byte(vm.SLOAD),
+			byte(vm.PUSH1), 1, byte(vm.ADD),
+			byte(vm.PUSH1), 0, byte(vm.SSTORE),
+		})
+		statedb.SetState(counter, common.Hash{}, common.BytesToHash([]byte{7}))
+		statedb.Finalise(true)
+		return statedb
+	}
+	newEVM := func(statedb *state.StateDB, checkpointer vm.CheckpointFunc) *vm.EVM {
+		vmctx := vm.BlockContext{
+			CanTransfer: CanTransfer,
+			Transfer:    Transfer,
+			Coinbase:    coinbase,
+			BlockNumber: new(big.Int),
+			BaseFee:     big.NewInt(1),
+			GasLimit:    params.GenesisGasLimit,
+		}
+		return vm.NewEVM(vmctx, vm.TxContext{Origin: sender, GasPrice: big.NewInt(2)}, statedb, params.TestChainConfig, vm.Config{Checkpointer: checkpointer})
+	}
+	msg := &Message{
+		From:      sender,
+		To:        &counter,
+		Value:     new(big.Int),
+		GasLimit:  100000,
+		GasPrice:  big.NewInt(2),
+		GasFeeCap: big.NewInt(2),
+		GasTipCap: big.NewInt(1),
+	}
+	// Run the reference execution without any checkpoints
+	refState := newState()
+	want, err := ApplyMessage(newEVM(refState, nil), msg, new(GasPool).AddGas(params.GenesisGasLimit))
+	if err != nil || want.Failed() {
+		t.Fatalf("reference execution failed: %v %v", err, want)
+	}
+	// Suspend at the SLOAD, nothing may be charged or paid
+	var (
+		statedb = newState()
+		gp      = new(GasPool).AddGas(params.GenesisGasLimit)
+		cp      *vm.Checkpoint
+	)
+	evm := newEVM(statedb, func(c *vm.Checkpoint) bool {
+		if cp == nil && c.Frames[len(c.Frames)-1].Op == vm.SLOAD {
+			cp = c
+			return true
+		}
+		return false
+	})
+	result, err := ApplyMessage(evm, msg, gp)
+	if err != nil {
+		t.Fatalf("suspended execution failed: %v", err)
+	}
+	if !result.Suspended() || result.UsedGas != 0 || result.RefundedGas != 0 {
+		t.Fatalf("unexpected suspended result: %+v", result)
+	}
+	if balance := statedb.GetBalance(coinbase); !balance.IsZero() {
+		t.Fatalf("coinbase paid for suspended execution: %v", balance)
+	}
+	// Resume and check that the outcome matches the uninterrupted execution
+	result, err = ResumeMessage(evm, msg, gp, cp)
+	if err != nil {
+		t.Fatalf("resumed execution failed: %v", err)
+	}
+	if result.Failed() || result.UsedGas != want.UsedGas || result.RefundedGas != want.RefundedGas {
+		t.Errorf("resumed result mismatch: have %+v, want %+v", result, want)
+	}
+	for _, addr := range []common.Address{sender, coinbase} {
+		if have, want := statedb.GetBalance(addr), refState.GetBalance(addr); !have.Eq(want) {
+			t.Errorf("balance mismatch %x: have %v, want %v", addr, have, want)
+		}
+	}
+	if have := statedb.GetState(counter, common.Hash{}); have != common.BytesToHash([]byte{8}) {
+		t.Errorf("counter mismatch: have %x, want 8", have)
+	}
+	if have, want := gp.Gas(), params.GenesisGasLimit-want.UsedGas; have != want {
+		t.Errorf("gas pool mismatch: have %d, want %d", have, want)
+	}
+}
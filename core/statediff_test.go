@@ -0,0 +1,170 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the state diffs are published for the imported blocks while
+// subscribed, and that they match the ones of re-executing the blocks.
+func TestStateDiffFeed(t *testing.T) {
+	var (
+		aa     = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		engine = beacon.NewFaker()
+
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		funds  = new(big.Int).Mul(common.Big1, big.NewInt(params.Ether))
+		config = *params.AllEthashProtocolChanges
+		gspec  = &Genesis{
+			Config: &config,
+			Alloc: types.GenesisAlloc{
+				addr: {Balance: funds},
+				// The address 0xAAAA stores the block number in slot zero
+				aa: {
+					Code:    []byte{byte(vm.NUMBER), byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)},
+					Storage: map[common.Hash]common.Hash{{}: common.HexToHash("0xff")},
+				},
+			},
+		}
+	)
+	gspec.Config.TerminalTotalDifficulty = common.Big0
+	gspec.Config.TerminalTotalDifficultyPassed = true
+	gspec.Config.ShanghaiTime = u64(0)
+	signer := types.LatestSigner(gspec.Config)
+
+	_, blocks, _ := GenerateChainWithGenesis(gspec, engine, 5, func(i int, b *BlockGen) {
+		tx, _ := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID:   gspec.Config.ChainID,
+			Nonce:     b.TxNonce(addr),
+			To:        &aa,
+			Gas:       100000,
+			GasFeeCap: newGwei(5),
+			GasTipCap: big.NewInt(2),
+		})
+		b.AddTx(tx)
+
+		// Fund a fresh account in every block
+		tx, _ = types.SignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID:   gspec.Config.ChainID,
+			Nonce:     b.TxNonce(addr),
+			To:        &common.Address{byte(i + 1)},
+			Value:     big.NewInt(1),
+			Gas:       params.TxGas,
+			GasFeeCap: newGwei(5),
+			GasTipCap: big.NewInt(2),
+		})
+		b.AddTx(tx)
+	})
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// Import the first block without subscribers, no diff should be gathered
+	if _, err := chain.InsertChain(blocks[:1]); err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+	diffs := make(chan StateDiffEvent, len(blocks))
+	sub := chain.SubscribeStateDiffEvent(diffs)
+	defer sub.Unsubscribe()
+
+	if n, err := chain.InsertChain(blocks[1:4]); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n+1, err)
+	}
+	for _, block := range blocks[1:4] {
+		ev := <-diffs
+		if ev.Header.Hash() != block.Hash() {
+			t.Fatalf("state diff block mismatch: have %d, want %d", ev.Header.Number, block.Number())
+		}
+		if ev.Diff.Root != block.Root() {
+			t.Fatalf("block %d: state diff root mismatch: have %x, want %x", block.Number(), ev.Diff.Root, block.Root())
+		}
+		// The contract slot moves from the previous block number to this one
+		slot := ev.Diff.Accounts[aa].Storage[crypto.Keccak256Hash(common.Hash{}.Bytes())]
+		if slot == nil || slot.Key == nil || *slot.Key != (common.Hash{}) {
+			t.Fatalf("block %d: contract slot missing: %+v", block.Number(), slot)
+		}
+		if slot.Prev != common.BigToHash(new(big.Int).Sub(block.Number(), common.Big1)) || slot.Post != common.BigToHash(block.Number()) {
+			t.Fatalf("block %d: contract slot mismatch: %+v", block.Number(), slot)
+		}
+		// The funded account is created
+		funded := ev.Diff.Accounts[common.Address{byte(block.NumberU64())}]
+		if funded == nil || funded.Prev != nil || funded.Post.Balance.Uint64() != 1 {
+			t.Fatalf("block %d: funded account mismatch: %+v", block.Number(), funded)
+		}
+		// Re-executing the block must yield the same changes
+		diff, err := chain.StateDiff(block)
+		if err != nil {
+			t.Fatalf("block %d: failed to re-execute: %v", block.Number(), err)
+		}
+		if !reflect.DeepEqual(diff, ev.Diff) {
+			t.Fatalf("block %d: re-executed state diff mismatch", block.Number())
+		}
+	}
+	select {
+	case ev := <-diffs:
+		t.Fatalf("unexpected state diff for block %d", ev.Header.Number)
+	default:
+	}
+	// A stalled subscriber may hold up the import, but not the chain mutex
+	stalled := make(chan StateDiffEvent)
+	stalledSub := chain.SubscribeStateDiffEvent(stalled)
+	defer stalledSub.Unsubscribe()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := chain.InsertChain(blocks[4:])
+		errc <- err
+	}()
+	for start := time.Now(); chain.CurrentBlock().Number.Uint64() != blocks[4].NumberU64(); time.Sleep(time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("block not imported")
+		}
+	}
+	locked := make(chan struct{})
+	go func() {
+		if chain.chainmu.TryLock() {
+			chain.chainmu.Unlock()
+		}
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("chain mutex held while delivering the state diff")
+	}
+	if ev := <-stalled; ev.Header.Hash() != blocks[4].Hash() {
+		t.Fatalf("state diff block mismatch: have %d, want %d", ev.Header.Number, blocks[4].Number())
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+}
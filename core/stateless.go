@@ -0,0 +1,157 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/stateless"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+// ExecutionWitness re-executes the given block on top of its parent state and
+// returns a witness of all the trie nodes, codes and ancestor headers accessed,
+// which is sufficient to execute the block statelessly.
+func (bc *BlockChain) ExecutionWitness(block *types.Block) (*stateless.Witness, error) {
+	if bc.triedb.IsVerkle() {
+		return nil, errors.New("execution witnesses are not supported in verkle mode")
+	}
+	witness, err := stateless.NewWitness(block.Header(), bc)
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := bc.StateAt(witness.Root())
+	if err != nil {
+		return nil, err
+	}
+	statedb.SetWitness(witness)
+
+	res, err := bc.processor.Process(block, statedb, vm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	// Validating the state hashes the tries, which pulls in the nodes needed
+	// to derive the post-state root.
+	if err := bc.validator.ValidateState(block, statedb, res); err != nil {
+		return nil, err
+	}
+	return witness, nil
+}
+
+// ExecuteStateless runs a block on top of the pre-state provided by a witness,
+// without access to any other state or chain data. It returns the post-state
+// root, or an error if the block is invalid or doesn't match the witness.
+func ExecuteStateless(config *params.ChainConfig, engine consensus.Engine, block *types.Block, witness *stateless.Witness) (common.Hash, error) {
+	if parent := witness.Headers[0]; parent.Hash() != block.ParentHash() {
+		return common.Hash{}, fmt.Errorf("witness parent mismatch: have %x, want %x", parent.Hash(), block.ParentHash())
+	}
+	if hash := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); hash != block.TxHash() {
+		return common.Hash{}, fmt.Errorf("transaction root hash mismatch (header value %x, calculated %x)", block.TxHash(), hash)
+	}
+	// Create and populate the state database to serve as the stateless backend
+	memdb := witness.MakeHashDB()
+	statedb, err := state.New(witness.Root(), state.NewDatabaseWithConfig(memdb, triedb.HashDefaults), nil)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	var (
+		chain     = newWitnessChain(config, engine, witness)
+		processor = NewStateProcessor(config, nil, engine)
+		validator = &BlockValidator{config: config, engine: engine}
+	)
+	res, err := processor.process(block, statedb, vm.Config{}, chain)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	// Any trie node or code missing from the witness surfaces as a database
+	// error, the block cannot be verified in that case.
+	if err := statedb.Error(); err != nil {
+		return common.Hash{}, fmt.Errorf("incomplete witness: %w", err)
+	}
+	if err := validator.ValidateState(block, statedb, res); err != nil {
+		return common.Hash{}, err
+	}
+	return block.Root(), nil
+}
+
+// witnessChain serves the ancestor headers needed to execute a block from the
+// headers embedded in a witness.
+type witnessChain struct {
+	config  *params.ChainConfig
+	engine  consensus.Engine
+	headers map[common.Hash]*types.Header
+	head    *types.Header
+}
+
+func newWitnessChain(config *params.ChainConfig, engine consensus.Engine, witness *stateless.Witness) *witnessChain {
+	headers := make(map[common.Hash]*types.Header, len(witness.Headers))
+	for _, header := range witness.Headers {
+		headers[header.Hash()] = header
+	}
+	return &witnessChain{
+		config:  config,
+		engine:  engine,
+		headers: headers,
+		head:    witness.Headers[0],
+	}
+}
+
+// Config retrieves the chain's fork configuration.
+func (c *witnessChain) Config() *params.ChainConfig { return c.config }
+
+// Engine retrieves the chain's consensus engine.
+func (c *witnessChain) Engine() consensus.Engine { return c.engine }
+
+// CurrentHeader retrieves the parent header of the block being executed.
+func (c *witnessChain) CurrentHeader() *types.Header { return c.head }
+
+// GetHeader retrieves a witness header by hash and number.
+func (c *witnessChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header := c.headers[hash]; header != nil && header.Number.Uint64() == number {
+		return header
+	}
+	return nil
+}
+
+// GetHeaderByHash retrieves a witness header by hash.
+func (c *witnessChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	return c.headers[hash]
+}
+
+// GetHeaderByNumber retrieves a witness header by number.
+func (c *witnessChain) GetHeaderByNumber(number uint64) *types.Header {
+	for _, header := range c.headers {
+		if header.Number.Uint64() == number {
+			return header
+		}
+	}
+	return nil
+}
+
+// GetTd is not supported by a witness, total difficulties are not included.
+func (c *witnessChain) GetTd(hash common.Hash, number uint64) *big.Int {
+	return nil
+}
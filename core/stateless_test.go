@@ -0,0 +1,143 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/stateless"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Tests that a witness generated while executing a block is sufficient to
+// re-execute it statelessly and derive the same post-state root.
+func TestExecuteStateless(t *testing.T) {
+	var (
+		aa     = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		bb     = common.HexToAddress("0x000000000000000000000000000000000000bbbb")
+		engine = beacon.NewFaker()
+
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		funds  = new(big.Int).Mul(common.Big1, big.NewInt(params.Ether))
+		config = *params.AllEthashProtocolChanges
+		gspec  = &Genesis{
+			Config: &config,
+			Alloc: types.GenesisAlloc{
+				addr: {Balance: funds},
+				// The address 0xAAAA stores the hash of the block three back
+				// and the code size of 0xBBBB
+				aa: {
+					Code: []byte{
+						byte(vm.PUSH1), 3, byte(vm.NUMBER), byte(vm.SUB), byte(vm.BLOCKHASH),
+						byte(vm.NUMBER), byte(vm.SSTORE),
+						byte(vm.PUSH2), 0xbb, 0xbb, byte(vm.EXTCODESIZE),
+						byte(vm.PUSH1), 0, byte(vm.SSTORE),
+						byte(vm.STOP),
+					},
+					Storage: map[common.Hash]common.Hash{{}: common.HexToHash("0xff")},
+				},
+				bb: {Code: []byte{byte(vm.PC), byte(vm.STOP)}},
+			},
+		}
+	)
+	gspec.Config.TerminalTotalDifficulty = common.Big0
+	gspec.Config.TerminalTotalDifficultyPassed = true
+	gspec.Config.ShanghaiTime = u64(0)
+	signer := types.LatestSigner(gspec.Config)
+
+	_, blocks, _ := GenerateChainWithGenesis(gspec, engine, 5, func(i int, b *BlockGen) {
+		tx, _ := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID:   gspec.Config.ChainID,
+			Nonce:     b.TxNonce(addr),
+			To:        &aa,
+			Gas:       100000,
+			GasFeeCap: newGwei(5),
+			GasTipCap: big.NewInt(2),
+		})
+		b.AddTx(tx)
+
+		// Fund a fresh account in every block
+		tx, _ = types.SignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID:   gspec.Config.ChainID,
+			Nonce:     b.TxNonce(addr),
+			To:        &common.Address{byte(i + 1)},
+			Value:     big.NewInt(1),
+			Gas:       params.TxGas,
+			GasFeeCap: newGwei(5),
+			GasTipCap: big.NewInt(2),
+		})
+		b.AddTx(tx)
+	})
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	block := blocks[len(blocks)-1]
+	witness, err := chain.ExecutionWitness(block)
+	if err != nil {
+		t.Fatalf("failed to generate witness: %v", err)
+	}
+	// The block hash lookup reaches three blocks back, the code of both
+	// contracts is accessed
+	if len(witness.Headers) != 3 {
+		t.Errorf("witness headers mismatch: have %d, want %d", len(witness.Headers), 3)
+	}
+	if len(witness.Codes) != 2 {
+		t.Errorf("witness codes mismatch: have %d, want %d", len(witness.Codes), 2)
+	}
+	// Round-trip the witness through its encoding and execute it statelessly
+	enc, err := rlp.EncodeToBytes(witness)
+	if err != nil {
+		t.Fatalf("failed to encode witness: %v", err)
+	}
+	decoded := new(stateless.Witness)
+	if err := rlp.DecodeBytes(enc, decoded); err != nil {
+		t.Fatalf("failed to decode witness: %v", err)
+	}
+	root, err := ExecuteStateless(gspec.Config, engine, block, decoded)
+	if err != nil {
+		t.Fatalf("failed to execute statelessly: %v", err)
+	}
+	if root != block.Root() {
+		t.Fatalf("post-state root mismatch: have %x, want %x", root, block.Root())
+	}
+	// Dropping any trie node must fail the execution
+	for node := range decoded.State {
+		delete(decoded.State, node)
+		break
+	}
+	if _, err := ExecuteStateless(gspec.Config, engine, block, decoded); err == nil {
+		t.Fatal("stateless execution succeeded with incomplete witness")
+	}
+	// A witness for another parent must be rejected
+	if _, err := ExecuteStateless(gspec.Config, engine, blocks[len(blocks)-2], witness); err == nil {
+		t.Fatal("stateless execution succeeded with mismatching witness")
+	}
+}
@@ -19,6 +19,7 @@ package core
 import (
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -46,6 +47,7 @@ type txIndexer struct {
 	//       and all others shouldn't.
 	limit    uint64
 	db       ethdb.Database
+	lock     sync.Mutex // Serializes the indexing tasks with the history pruning
 	progress chan chan TxIndexProgress
 	term     chan chan struct{}
 	closed   chan struct{}
@@ -79,10 +81,16 @@ func newTxIndexer(limit uint64, chain *BlockChain) *txIndexer {
 func (indexer *txIndexer) run(tail *uint64, head uint64, stop chan struct{}, done chan struct{}) {
 	defer func() { close(done) }()
 
+	indexer.lock.Lock()
+	defer indexer.lock.Unlock()
+
 	// Short circuit if chain is empty and nothing to index.
 	if head == 0 {
 		return
 	}
+	// The blocks below the history cutoff have no bodies left to index.
+	cutoff := HistoryCutoff(indexer.db)
+
 	// The tail flag is not existent, it means the node is just initialized
 	// and all blocks in the chain (part of them may from ancient store) are
 	// not indexed yet, index the chain according to the configured limit.
@@ -91,13 +99,13 @@ func (indexer *txIndexer) run(tail *uint64, head uint64, stop chan struct{}, don
 		if indexer.limit != 0 && head >= indexer.limit {
 			from = head - indexer.limit + 1
 		}
-		rawdb.IndexTransactions(indexer.db, from, head+1, stop, true)
+		rawdb.IndexTransactions(indexer.db, max(from, cutoff), head+1, stop, true)
 		return
 	}
 	// The tail flag is existent (which means indexes in [tail, head] should be
 	// present), while the whole chain are requested for indexing.
 	if indexer.limit == 0 || head < indexer.limit {
-		if *tail > 0 {
+		if *tail > cutoff {
 			// It can happen when chain is rewound to a historical point which
 			// is even lower than the indexes tail, recap the indexing target
 			// to new head to avoid reading non-existent block bodies.
@@ -105,18 +113,19 @@ func (indexer *txIndexer) run(tail *uint64, head uint64, stop chan struct{}, don
 			if end > head+1 {
 				end = head + 1
 			}
-			rawdb.IndexTransactions(indexer.db, 0, end, stop, true)
+			rawdb.IndexTransactions(indexer.db, cutoff, end, stop, true)
 		}
 		return
 	}
 	// The tail flag is existent, adjust the index range according to configured
 	// limit and the latest chain head.
-	if head-indexer.limit+1 < *tail {
+	from := max(head-indexer.limit+1, cutoff)
+	if from < *tail {
 		// Reindex a part of missing indices and rewind index tail to HEAD-limit
-		rawdb.IndexTransactions(indexer.db, head-indexer.limit+1, *tail, stop, true)
+		rawdb.IndexTransactions(indexer.db, from, *tail, stop, true)
 	} else {
 		// Unindex a part of stale indices and forward index tail to HEAD-limit
-		rawdb.UnindexTransactions(indexer.db, *tail, head-indexer.limit+1, stop, false)
+		rawdb.UnindexTransactions(indexer.db, *tail, from, stop, false)
 	}
 }
 
@@ -180,6 +189,10 @@ func (indexer *txIndexer) report(head uint64, tail *uint64) TxIndexProgress {
 	if indexer.limit == 0 || total > head {
 		total = head + 1 // genesis included
 	}
+	// The blocks below the history cutoff can't be indexed.
+	if cutoff := HistoryCutoff(indexer.db); cutoff <= head && head+1-cutoff < total {
+		total = head + 1 - cutoff
+	}
 	var indexed uint64
 	if tail != nil {
 		indexed = head - *tail + 1
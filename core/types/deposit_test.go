@@ -0,0 +1,92 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var depositABI = `[{"anonymous":false,"inputs":[{"indexed":false,"internalType":"bytes","name":"pubkey","type":"bytes"},{"indexed":false,"internalType":"bytes","name":"withdrawal_credentials","type":"bytes"},{"indexed":false,"internalType":"bytes","name":"amount","type":"bytes"},{"indexed":false,"internalType":"bytes","name":"signature","type":"bytes"},{"indexed":false,"internalType":"bytes","name":"index","type":"bytes"}],"name":"DepositEvent","type":"event"}]`
+
+// Tests that deposit event logs are unpacked into the EIP-6110 request layout.
+func TestDepositLogToRequest(t *testing.T) {
+	dep, err := abi.JSON(strings.NewReader(depositABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		pubkey    = bytes.Repeat([]byte{0x01}, 48)
+		creds     = common.HexToHash("0x010000000000000000000000000000000000000000000000000000000000aaaa")
+		amount    = make([]byte, 8)
+		signature = bytes.Repeat([]byte{0x02}, 96)
+		index     = make([]byte, 8)
+	)
+	binary.LittleEndian.PutUint64(amount, 32_000_000_000)
+	binary.LittleEndian.PutUint64(index, 7)
+
+	data, err := dep.Events["DepositEvent"].Inputs.Pack(pubkey, creds[:], amount, signature, index)
+	if err != nil {
+		t.Fatalf("failed to pack deposit: %v", err)
+	}
+	request, err := DepositLogToRequest(data)
+	if err != nil {
+		t.Fatalf("failed to unpack deposit: %v", err)
+	}
+	var want []byte
+	for _, field := range [][]byte{pubkey, creds[:], amount, signature, index} {
+		want = append(want, field...)
+	}
+	if !bytes.Equal(request, want) {
+		t.Fatalf("request mismatch:\nhave %x\nwant %x", request, want)
+	}
+	if _, err := DepositLogToRequest(data[1:]); err == nil {
+		t.Fatal("expected error for truncated deposit log")
+	}
+}
+
+// Tests the EIP-7685 requests commitment and the Engine API request checks.
+func TestCalcRequestsHash(t *testing.T) {
+	if have := CalcRequestsHash(nil); have != EmptyRequestsHash {
+		t.Fatalf("empty requests hash mismatch: have %x, want %x", have, EmptyRequestsHash)
+	}
+	// Requests consisting of only the type byte don't contribute to the hash
+	if have := CalcRequestsHash([][]byte{{DepositRequestType}}); have != EmptyRequestsHash {
+		t.Fatalf("empty request contributed to hash: have %x", have)
+	}
+	reqs := [][]byte{{DepositRequestType, 0x01}, {ConsolidationRequestType, 0x02}}
+	if CalcRequestsHash(reqs) == CalcRequestsHash(reqs[:1]) {
+		t.Fatal("requests hash doesn't commit to all requests")
+	}
+	if err := ValidateRequests(reqs); err != nil {
+		t.Fatalf("valid requests rejected: %v", err)
+	}
+	if err := ValidateRequests([][]byte{reqs[1], reqs[0]}); err == nil {
+		t.Fatal("out of order requests accepted")
+	}
+	if err := ValidateRequests([][]byte{reqs[0], reqs[0]}); err == nil {
+		t.Fatal("duplicate request type accepted")
+	}
+	if err := ValidateRequests([][]byte{{WithdrawalRequestType}}); err == nil {
+		t.Fatal("empty request accepted")
+	}
+}
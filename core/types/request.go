@@ -0,0 +1,66 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Request types as defined by EIP-7685. The first byte of every encoded
+// request is its type, followed by the type specific request data.
+const (
+	DepositRequestType       = 0x00 // EIP-6110
+	WithdrawalRequestType    = 0x01 // EIP-7002
+	ConsolidationRequestType = 0x02 // EIP-7251
+)
+
+// CalcRequestsHash computes the EIP-7685 commitment to a list of execution
+// layer requests, i.e. sha256(sha256(request_0) ++ ... ++ sha256(request_n)).
+// Requests without any data beyond their type byte are skipped.
+func CalcRequestsHash(requests [][]byte) common.Hash {
+	var (
+		h1, h2 = sha256.New(), sha256.New()
+		buf    common.Hash
+	)
+	for _, item := range requests {
+		if len(item) > 1 {
+			h1.Reset()
+			h1.Write(item)
+			h2.Write(h1.Sum(buf[:0]))
+		}
+	}
+	h2.Sum(buf[:0])
+	return buf
+}
+
+// ValidateRequests checks that the given list of requests is ordered by type,
+// contains every type at most once and carries no empty request, as required
+// for requests passed over the Engine API.
+func ValidateRequests(requests [][]byte) error {
+	for i, item := range requests {
+		if len(item) < 2 {
+			return fmt.Errorf("empty request at index %d", i)
+		}
+		if i > 0 && item[0] <= requests[i-1][0] {
+			return fmt.Errorf("request at index %d out of order (type %d after %d)", i, item[0], requests[i-1][0])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that blocks executed on a verkle state are charged the EIP-4762
+// witness costs, and that the generated chain can be imported.
+func TestProcessVerkle(t *testing.T) {
+	var (
+		aa     = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		bb     = common.HexToAddress("0x000000000000000000000000000000000000bbbb")
+		engine = beacon.NewFaker()
+
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		funds  = new(big.Int).Mul(common.Big1, big.NewInt(params.Ether))
+		config = *params.AllEthashProtocolChanges
+		gspec  = &Genesis{
+			Config: &config,
+			Alloc: types.GenesisAlloc{
+				addr: {Balance: funds},
+				// The address 0xAAAA stores the block number in slot 1
+				// and reads slot 0
+				aa: {
+					Code: []byte{
+						byte(vm.NUMBER), byte(vm.PUSH1), 1, byte(vm.SSTORE),
+						byte(vm.PUSH1), 0, byte(vm.SLOAD), byte(vm.POP),
+						byte(vm.STOP),
+					},
+					Storage: map[common.Hash]common.Hash{{}: common.HexToHash("0xff")},
+				},
+			},
+		}
+	)
+	gspec.Config.TerminalTotalDifficulty = common.Big0
+	gspec.Config.TerminalTotalDifficultyPassed = true
+	gspec.Config.ShanghaiTime = u64(0)
+	gspec.Config.VerkleTime = u64(0)
+	signer := types.LatestSigner(gspec.Config)
+
+	_, blocks, receipts := GenerateChainWithGenesis(gspec, engine, 2, func(i int, b *BlockGen) {
+		to, value := aa, common.Big0
+		if i == 1 {
+			to, value = bb, big.NewInt(1)
+		}
+		tx, _ := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID:   gspec.Config.ChainID,
+			Nonce:     b.TxNonce(addr),
+			To:        &to,
+			Value:     value,
+			Gas:       100000,
+			GasFeeCap: newGwei(5),
+			GasTipCap: big.NewInt(2),
+		})
+		b.AddTx(tx)
+	})
+	// The fields of the sender and recipient are covered by the intrinsic gas.
+	// The call to 0xAAAA is charged for the first code chunk, the read of the
+	// slot 0 and the write of the slot 1, which are in the branch of the
+	// account header and thus only charged a chunk read, and for the write
+	// the branch and chunk write costs.
+	want := []uint64{
+		params.TxGas + 3 + 3 + 2 + 2 + // NUMBER, PUSH1, PUSH1, POP
+			params.WitnessChunkReadCost + // code chunk
+			params.WitnessChunkReadCost + params.WitnessBranchWriteCost + params.WitnessChunkWriteCost + // SSTORE
+			params.WitnessChunkReadCost, // SLOAD
+		params.TxGas,
+	}
+	for i, receipt := range receipts {
+		if receipt[0].GasUsed != want[i] {
+			t.Errorf("block %d: gas used mismatch: have %d, want %d", i+1, receipt[0].GasUsed, want[i])
+		}
+	}
+	// Import the chain into a fresh verkle database
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), DefaultCacheConfigWithScheme(rawdb.PathScheme), gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if !chain.TrieDB().IsVerkle() {
+		t.Fatal("expected verkle trie database")
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	state, err := chain.State()
+	if err != nil {
+		t.Fatalf("failed to open head state: %v", err)
+	}
+	if have := state.GetState(aa, common.BigToHash(common.Big1)); have != common.BigToHash(common.Big1) {
+		t.Errorf("slot 1 mismatch: have %x, want %x", have, common.BigToHash(common.Big1))
+	}
+	if have := state.GetBalance(bb); have.Uint64() != 1 {
+		t.Errorf("balance mismatch: have %d, want 1", have)
+	}
+	// Verkle state can only be stored with the path scheme
+	if _, err := NewBlockChain(rawdb.NewMemoryDatabase(), DefaultCacheConfigWithScheme(rawdb.HashScheme), gspec, nil, engine, vm.Config{}, nil, nil); err == nil {
+		t.Fatal("expected error for verkle state with hash scheme")
+	}
+}
@@ -69,6 +69,21 @@ func codeBitmap(code []byte) bitvec {
 	return codeBitmapInternal(code, bits)
 }
 
+// JumpDests returns the positions of the valid jump destinations of legacy
+// code, that is the JUMPDEST instructions which aren't part of push data.
+func JumpDests(code []byte) []uint64 {
+	var (
+		bits  = codeBitmap(code)
+		dests []uint64
+	)
+	for pc, op := range code {
+		if OpCode(op) == JUMPDEST && bits.codeSegment(uint64(pc)) {
+			dests = append(dests, uint64(pc))
+		}
+	}
+	return dests
+}
+
 // codeBitmapInternal is the internal implementation of codeBitmap.
 // It exists for the purpose of being able to run benchmark tests
 // without dynamic allocations affecting the results.
@@ -116,3 +131,78 @@ func codeBitmapInternal(code, bits bitvec) bitvec {
 	}
 	return bits
 }
+
+// eofCodeBitmap collects data locations in EOF code, i.e. the immediates of
+// its instructions.
+func eofCodeBitmap(code []byte) bitvec {
+	// The bitmap is 4 bytes longer than necessary, in case the code
+	// ends with a PUSH32, the algorithm will set bits on the
+	// bitvector outside the bounds of the actual code.
+	bits := make(bitvec, len(code)/8+1+4)
+	return eofCodeBitmapInternal(code, bits)
+}
+
+// eofCodeBitmapInternal is the internal implementation of eofCodeBitmap.
+func eofCodeBitmapInternal(code, bits bitvec) bitvec {
+	for pc := uint64(0); pc < uint64(len(code)); {
+		var (
+			op      = OpCode(code[pc])
+			numbits uint16
+		)
+		pc++
+
+		if op == RJUMPV {
+			// RJUMPV is unique as it has a variable sized operand. The total
+			// size is determined by the count byte which immediately follows
+			// RJUMPV. Truncation is caught by code validation, so just mark
+			// as much of the code as is available.
+			end := uint64(len(code))
+			if pc >= end {
+				return bits
+			}
+			numbits = uint16(code[pc])*2 + 3
+			if pc+uint64(numbits) > end {
+				numbits = uint16(end - pc)
+			}
+		} else {
+			numbits = uint16(Immediates(op))
+			if numbits == 0 {
+				continue
+			}
+		}
+		if numbits >= 8 {
+			for ; numbits >= 16; numbits -= 16 {
+				bits.set16(pc)
+				pc += 16
+			}
+			for ; numbits >= 8; numbits -= 8 {
+				bits.set8(pc)
+				pc += 8
+			}
+		}
+		switch numbits {
+		case 1:
+			bits.set1(pc)
+			pc += 1
+		case 2:
+			bits.setN(set2BitsMask, pc)
+			pc += 2
+		case 3:
+			bits.setN(set3BitsMask, pc)
+			pc += 3
+		case 4:
+			bits.setN(set4BitsMask, pc)
+			pc += 4
+		case 5:
+			bits.setN(set5BitsMask, pc)
+			pc += 5
+		case 6:
+			bits.setN(set6BitsMask, pc)
+			pc += 6
+		case 7:
+			bits.setN(set7BitsMask, pc)
+			pc += 7
+		}
+	}
+	return bits
+}
@@ -0,0 +1,237 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package analysis
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// assembler builds bytecode with jumps to labels.
+type assembler struct {
+	code   []byte
+	labels map[string]int
+	refs   map[int]string
+}
+
+func newAssembler() *assembler {
+	return &assembler{labels: make(map[string]int), refs: make(map[int]string)}
+}
+
+func (a *assembler) op(ops ...vm.OpCode) *assembler {
+	for _, op := range ops {
+		a.code = append(a.code, byte(op))
+	}
+	return a
+}
+
+func (a *assembler) push(v ...byte) *assembler {
+	a.code = append(a.code, byte(vm.PUSH1)+byte(len(v)-1))
+	a.code = append(a.code, v...)
+	return a
+}
+
+// pushLabel pushes the position of a label as a PUSH2.
+func (a *assembler) pushLabel(name string) *assembler {
+	a.refs[len(a.code)+1] = name
+	return a.push(0, 0)
+}
+
+// label places a JUMPDEST with the given name.
+func (a *assembler) label(name string) *assembler {
+	a.labels[name] = len(a.code)
+	return a.op(vm.JUMPDEST)
+}
+
+func (a *assembler) bytes() []byte {
+	for pos, name := range a.refs {
+		binary.BigEndian.PutUint16(a.code[pos:], uint16(a.labels[name]))
+	}
+	return a.code
+}
+
+// testContract has two functions reading a mapping through an internal
+// function, which returns to different places:
+//
+//	0x11111111: reads slot 0, writes keccak(caller, 1), reads keccak(caller, 2)
+//	0x22222222: reads keccak(calldata[4], 2)
+func testContract() []byte {
+	a := newAssembler()
+	// Dispatcher
+	a.push(0).op(vm.CALLDATALOAD).push(0xe0).op(vm.SHR)
+	a.op(vm.DUP1).push(0x11, 0x11, 0x11, 0x11).op(vm.EQ).pushLabel("f1").op(vm.JUMPI)
+	a.op(vm.DUP1).push(0x22, 0x22, 0x22, 0x22).op(vm.EQ).pushLabel("f2").op(vm.JUMPI)
+	a.push(0).op(vm.DUP1, vm.REVERT)
+
+	// Function 0x11111111
+	a.label("f1")
+	a.push(0).op(vm.SLOAD, vm.POP)
+	a.op(vm.CALLER).push(0).op(vm.MSTORE).push(1).push(0x20).op(vm.MSTORE)
+	a.push(0x40).push(0).op(vm.KECCAK256).push(1).op(vm.SWAP1, vm.SSTORE)
+	a.pushLabel("ret1").op(vm.CALLER).pushLabel("get").op(vm.JUMP)
+	a.label("ret1").op(vm.POP, vm.STOP)
+
+	// Function 0x22222222
+	a.label("f2")
+	a.pushLabel("ret2").push(4).op(vm.CALLDATALOAD).pushLabel("get").op(vm.JUMP)
+	a.label("ret2").op(vm.POP, vm.STOP)
+
+	// Internal function reading the mapping at slot 2: [ret, key] -> [value]
+	a.label("get")
+	a.push(0).op(vm.MSTORE).push(2).push(0x20).op(vm.MSTORE)
+	a.push(0x40).push(0).op(vm.KECCAK256).op(vm.SLOAD)
+	a.op(vm.SWAP1, vm.JUMP)
+
+	return a.bytes()
+}
+
+func TestAnalyze(t *testing.T) {
+	prog := Analyze(testContract())
+	if !prog.Complete {
+		t.Fatal("analysis incomplete")
+	}
+	if len(prog.Functions) != 2 {
+		t.Fatalf("function count mismatch: have %d, want 2", len(prog.Functions))
+	}
+	want := map[[4]byte][]string{
+		{0x11, 0x11, 0x11, 0x11}: {
+			"read 0x0",
+			"write keccak(caller, 0x1)",
+			"read keccak(caller, 0x2)",
+		},
+		{0x22, 0x22, 0x22, 0x22}: {
+			"read keccak(calldata[4], 0x2)",
+		},
+	}
+	for _, fn := range prog.Functions {
+		var have []string
+		for _, access := range fn.Accesses {
+			kind := "read"
+			if access.Write {
+				kind = "write"
+			}
+			have = append(have, kind+" "+access.Slot.String())
+		}
+		if !reflect.DeepEqual(have, want[fn.Selector]) {
+			t.Errorf("function %x: accesses mismatch: have %v, want %v", fn.Selector, have, want[fn.Selector])
+		}
+	}
+	if len(prog.Accesses) != 0 {
+		t.Errorf("unexpected accesses outside of functions: %v", prog.Accesses)
+	}
+	// The internal function returns to both callers
+	for _, block := range prog.Blocks {
+		if block.Unresolved {
+			t.Errorf("unresolved jump in block %d", block.Start)
+		}
+		if block.Instructions[len(block.Instructions)-1].Op == vm.JUMP && block.Instructions[0].Op == vm.JUMPDEST && len(block.Succs) == 2 {
+			return
+		}
+	}
+	t.Error("internal function return not resolved to both callers")
+}
+
+func TestPredictAccesses(t *testing.T) {
+	var (
+		prog   = Analyze(testContract())
+		caller = common.HexToAddress("0xc0ffee")
+		key    = common.HexToAddress("0xdeadbeef")
+		slot   = func(key []byte, n byte) common.Hash {
+			return crypto.Keccak256Hash(common.LeftPadBytes(key, 32), common.LeftPadBytes([]byte{n}, 32))
+		}
+	)
+	slots, complete := prog.PredictAccesses(caller, []byte{0x11, 0x11, 0x11, 0x11})
+	if !complete {
+		t.Error("prediction of 0x11111111 incomplete")
+	}
+	if want := []common.Hash{{}, slot(caller.Bytes(), 1), slot(caller.Bytes(), 2)}; !reflect.DeepEqual(slots, want) {
+		t.Errorf("0x11111111 slots mismatch: have %v, want %v", slots, want)
+	}
+	calldata := append([]byte{0x22, 0x22, 0x22, 0x22}, common.LeftPadBytes(key.Bytes(), 32)...)
+	slots, complete = prog.PredictAccesses(caller, calldata)
+	if !complete {
+		t.Error("prediction of 0x22222222 incomplete")
+	}
+	if want := []common.Hash{slot(key.Bytes(), 2)}; !reflect.DeepEqual(slots, want) {
+		t.Errorf("0x22222222 slots mismatch: have %v, want %v", slots, want)
+	}
+	// Unknown functions access nothing
+	if slots, _ := prog.PredictAccesses(caller, []byte{0x33, 0x33, 0x33, 0x33}); len(slots) != 0 {
+		t.Errorf("unexpected slots of unknown function: %v", slots)
+	}
+}
+
+func TestUnresolvedJump(t *testing.T) {
+	// PUSH1 0 CALLDATALOAD JUMP JUMPDEST PUSH1 1 SLOAD
+	prog := Analyze(common.FromHex("600035565b600154"))
+	if prog.Complete {
+		t.Error("analysis with an unresolved jump is complete")
+	}
+	if !prog.Blocks[0].Unresolved {
+		t.Error("jump not marked unresolved")
+	}
+	if len(prog.Accesses) != 0 {
+		t.Errorf("unexpected accesses after unresolved jump: %v", prog.Accesses)
+	}
+}
+
+// Tests that nested hashes, whose sub-expressions are shared but rendered as
+// trees, are analysed in bounded time.
+func TestNestedHashes(t *testing.T) {
+	a := newAssembler().op(vm.CALLER)
+	for i := 0; i < 100; i++ {
+		a.op(vm.DUP1).push(0).op(vm.MSTORE).push(0x20).op(vm.MSTORE).push(0x40).push(0).op(vm.KECCAK256)
+	}
+	a.op(vm.SLOAD, vm.STOP)
+
+	done := make(chan *Program, 1)
+	go func() { done <- Analyze(a.bytes()) }()
+	select {
+	case prog := <-done:
+		if len(prog.Accesses) != 1 || prog.Accesses[0].Slot.Known() {
+			t.Errorf("deeply nested slot not unknown: %v", prog.Accesses)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("analysis of nested hashes timed out")
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Analyze(testContract()).WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dot := buf.String()
+	for _, want := range []string{
+		"digraph program {",
+		"function 0x11111111",
+		"SLOAD [0x11111111: keccak(caller, 0x2); 0x22222222: keccak(calldata[4], 0x2)]",
+		"b0 -> b",
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output misses %q:\n%s", want, dot)
+		}
+	}
+}
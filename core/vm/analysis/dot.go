@@ -0,0 +1,78 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package analysis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteDOT writes the control-flow graph in the Graphviz DOT format. Blocks
+// are labeled with their instructions and the predicted slots of their
+// storage accesses, function entries with their selectors.
+func (p *Program) WriteDOT(w io.Writer) error {
+	// Gather the annotations of the graph
+	var (
+		entries = make(map[uint64][]string)
+		slots   = make(map[uint64][]string)
+	)
+	for _, fn := range p.Functions {
+		entries[fn.Entry] = append(entries[fn.Entry], fmt.Sprintf("function 0x%x", fn.Selector))
+		for _, access := range fn.Accesses {
+			slots[access.PC] = append(slots[access.PC], fmt.Sprintf("0x%x: %v", fn.Selector, access.Slot))
+		}
+	}
+	for _, access := range p.Accesses {
+		slots[access.PC] = append(slots[access.PC], access.Slot.String())
+	}
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "digraph program {")
+	fmt.Fprintln(out, "\tnode [shape=box fontname=monospace];")
+	for _, block := range p.Blocks {
+		var label strings.Builder
+		for _, entry := range entries[block.Start] {
+			fmt.Fprintf(&label, "%s\\l", entry)
+		}
+		for _, in := range block.Instructions {
+			fmt.Fprintf(&label, "%05x: %v", in.PC, in.Op)
+			if in.Arg != nil {
+				fmt.Fprintf(&label, " 0x%x", in.Arg)
+			}
+			if len(slots[in.PC]) > 0 {
+				fmt.Fprintf(&label, " [%s]", strings.Join(slots[in.PC], "; "))
+			}
+			label.WriteString("\\l")
+		}
+		attrs := ""
+		switch {
+		case len(entries[block.Start]) > 0:
+			attrs = " style=bold"
+		case block.Unresolved:
+			attrs = " color=red"
+		}
+		fmt.Fprintf(out, "\tb%d [label=\"%s\"%s];\n", block.Start, label.String(), attrs)
+	}
+	for _, block := range p.Blocks {
+		for _, succ := range block.Succs {
+			fmt.Fprintf(out, "\tb%d -> b%d;\n", block.Start, succ)
+		}
+	}
+	fmt.Fprintln(out, "}")
+	return out.Flush()
+}
@@ -0,0 +1,485 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+const (
+	// maxBlockContexts is the number of distinct entry states a block is
+	// explored with. It bounds the exploration of loops with constant counters.
+	maxBlockContexts = 64
+
+	// maxSteps is the total number of blocks explored.
+	maxSteps = 100000
+)
+
+// state is the abstract state of the execution on entry of a block.
+type state struct {
+	block  *Block
+	fn     *Function // Function being executed, nil before the dispatcher matched
+	stack  []*Value
+	memory map[uint64]*Value // Words stored at constant offsets
+}
+
+// key returns a string identifying the state.
+func (s *state) key() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d|", s.block.Start)
+	if s.fn != nil {
+		fmt.Fprintf(&b, "%x", s.fn.Selector)
+	}
+	for _, v := range s.stack {
+		b.WriteString("|")
+		b.WriteString(v.String())
+	}
+	offsets := make([]uint64, 0, len(s.memory))
+	for offset := range s.memory {
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "|%d=%v", offset, s.memory[offset])
+	}
+	return b.String()
+}
+
+// explorer executes the code on abstract values along all the paths of the
+// control-flow graph, distinguishing the states blocks are entered with. This
+// resolves the jumps to return addresses pushed by internal function calls.
+type explorer struct {
+	prog      *Program
+	dests     map[uint64]bool // Valid jump destinations
+	visited   map[string]bool
+	contexts  map[*Block]int
+	succs     map[*Block]map[uint64]bool
+	accesses  map[*Function]map[string]bool
+	functions map[[4]byte]*Function
+	queue     []*state
+}
+
+func newExplorer(prog *Program) *explorer {
+	e := &explorer{
+		prog:      prog,
+		dests:     make(map[uint64]bool),
+		visited:   make(map[string]bool),
+		contexts:  make(map[*Block]int),
+		succs:     make(map[*Block]map[uint64]bool),
+		accesses:  make(map[*Function]map[string]bool),
+		functions: make(map[[4]byte]*Function),
+	}
+	for _, dest := range vm.JumpDests(prog.Code) {
+		e.dests[dest] = true
+	}
+	return e
+}
+
+// run explores the code from its first block.
+func (e *explorer) run() {
+	if len(e.prog.Blocks) == 0 {
+		return
+	}
+	e.queue = append(e.queue, &state{block: e.prog.Blocks[0], memory: make(map[uint64]*Value)})
+	for steps := 0; len(e.queue) > 0; steps++ {
+		if steps == maxSteps {
+			e.prog.Complete = false
+			break
+		}
+		s := e.queue[0]
+		e.queue = e.queue[1:]
+		e.execute(s)
+	}
+	// Collect the successors of the blocks
+	for block, succs := range e.succs {
+		for start := range succs {
+			block.Succs = append(block.Succs, start)
+		}
+		sort.Slice(block.Succs, func(i, j int) bool { return block.Succs[i] < block.Succs[j] })
+	}
+}
+
+// enter queues the exploration of the block starting at the given position.
+func (e *explorer) enter(from *Block, start uint64, fn *Function, stack []*Value, memory map[uint64]*Value) {
+	block := e.prog.Block(start)
+	if block == nil {
+		return
+	}
+	if e.succs[from] == nil {
+		e.succs[from] = make(map[uint64]bool)
+	}
+	e.succs[from][start] = true
+
+	s := &state{block: block, fn: fn, stack: stack, memory: memory}
+	key := s.key()
+	if e.visited[key] {
+		return
+	}
+	if e.contexts[block] == maxBlockContexts {
+		e.prog.Complete = false
+		return
+	}
+	e.visited[key] = true
+	e.contexts[block]++
+	e.queue = append(e.queue, s)
+}
+
+// function returns the function with the given selector and entry, creating
+// it on first use.
+func (e *explorer) function(selector [4]byte, entry uint64) *Function {
+	fn, ok := e.functions[selector]
+	if !ok {
+		fn = &Function{Selector: selector, Entry: entry}
+		e.functions[selector] = fn
+		e.prog.Functions = append(e.prog.Functions, fn)
+	}
+	return fn
+}
+
+// access records a storage access of the function being executed.
+func (e *explorer) access(fn *Function, pc uint64, write bool, slot *Value) {
+	if e.accesses[fn] == nil {
+		e.accesses[fn] = make(map[string]bool)
+	}
+	key := fmt.Sprintf("%d|%v|%v", pc, write, slot)
+	if e.accesses[fn][key] {
+		return
+	}
+	e.accesses[fn][key] = true
+
+	access := Access{PC: pc, Write: write, Slot: slot}
+	if fn == nil {
+		e.prog.Accesses = append(e.prog.Accesses, access)
+	} else {
+		fn.Accesses = append(fn.Accesses, access)
+	}
+}
+
+// execute executes a block on the abstract state and queues its successors.
+func (e *explorer) execute(s *state) {
+	var (
+		stack  = append([]*Value(nil), s.stack...)
+		memory = make(map[uint64]*Value, len(s.memory))
+	)
+	for offset, v := range s.memory {
+		memory[offset] = v
+	}
+	pop := func() *Value {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return v
+	}
+	push := func(v *Value) {
+		stack = append(stack, v)
+	}
+	for _, in := range s.block.Instructions {
+		pops, pushes := stackEffect(in.Op)
+		if len(stack) < pops || len(stack)-pops+pushes > int(params.StackLimit) {
+			// The path fails, or the entry state doesn't provide enough
+			// items because of the limits of the analysis.
+			e.prog.Complete = false
+			return
+		}
+		switch op := in.Op; {
+		case op == vm.PUSH0:
+			push(constant(new(uint256.Int)))
+
+		case op >= vm.PUSH1 && op <= vm.PUSH32:
+			push(constant(new(uint256.Int).SetBytes(in.Arg)))
+
+		case op >= vm.DUP1 && op <= vm.DUP16:
+			push(stack[len(stack)-int(op-vm.DUP1)-1])
+
+		case op >= vm.SWAP1 && op <= vm.SWAP16:
+			n := len(stack) - int(op-vm.SWAP1) - 2
+			stack[n], stack[len(stack)-1] = stack[len(stack)-1], stack[n]
+
+		case op == vm.POP:
+			pop()
+
+		case op == vm.CALLER:
+			push(&Value{Kind: Caller})
+
+		case op == vm.CALLDATALOAD:
+			if offset := pop(); offset.Kind == Const && offset.Const.IsUint64() {
+				push(&Value{Kind: CallData, Offset: offset.Const.Uint64()})
+			} else {
+				push(unknown)
+			}
+
+		case op == vm.MSTORE:
+			offset, v := pop(), pop()
+			if offset.Kind != Const || !offset.Const.IsUint64() {
+				clear(memory)
+				break
+			}
+			start := offset.Const.Uint64()
+			for stored := range memory {
+				if stored < start+32 && start < stored+32 {
+					delete(memory, stored)
+				}
+			}
+			memory[start] = v
+
+		case op == vm.MLOAD:
+			if offset := pop(); offset.Kind == Const && offset.Const.IsUint64() && memory[offset.Const.Uint64()] != nil {
+				push(memory[offset.Const.Uint64()])
+			} else {
+				push(unknown)
+			}
+
+		case op == vm.KECCAK256:
+			push(hashMemory(memory, pop(), pop()))
+
+		case op == vm.SLOAD:
+			e.access(s.fn, in.PC, false, pop())
+			push(unknown)
+
+		case op == vm.SSTORE:
+			e.access(s.fn, in.PC, true, pop())
+			pop()
+
+		case op == vm.JUMP:
+			if target := pop(); !e.jump(s, target, stack, memory, s.fn) {
+				e.unresolved(s.block)
+			}
+			return
+
+		case op == vm.JUMPI:
+			target, cond := pop(), pop()
+			taken, next := true, true
+			fn := s.fn
+			switch {
+			case cond.Kind == Const:
+				taken, next = !cond.Const.IsZero(), cond.Const.IsZero()
+			case cond.Kind == SelectorEq && target.Kind == Const && target.Const.IsUint64():
+				if selector, ok := selectorBytes(cond.Const); ok {
+					fn = e.function(selector, target.Const.Uint64())
+				}
+			}
+			if taken && !e.jump(s, target, stack, memory, fn) {
+				e.unresolved(s.block)
+			}
+			if next {
+				e.enter(s.block, s.block.End+1, s.fn, stack, memory)
+			}
+			return
+
+		case op == vm.STOP || op == vm.RETURN || op == vm.REVERT || op == vm.INVALID || op == vm.SELFDESTRUCT || !defined(op):
+			return
+
+		default:
+			args := make([]*Value, pops)
+			for i := range args {
+				args[i] = pop()
+			}
+			if pushes == 1 {
+				push(evaluate(op, args))
+			} else {
+				for i := 0; i < pushes; i++ {
+					push(unknown)
+				}
+			}
+			if writesMemory(op) {
+				clear(memory)
+			}
+		}
+	}
+	// The block ends before a JUMPDEST, execution continues with it
+	e.enter(s.block, s.block.End+1+uint64(len(s.block.Instructions[len(s.block.Instructions)-1].Arg)), s.fn, stack, memory)
+}
+
+// jump queues the destination of a jump, and returns false if it is unknown.
+func (e *explorer) jump(s *state, target *Value, stack []*Value, memory map[uint64]*Value, fn *Function) bool {
+	if target.Kind != Const || !target.Const.IsUint64() {
+		return false
+	}
+	if dest := target.Const.Uint64(); e.dests[dest] {
+		e.enter(s.block, dest, fn, stack, memory)
+	}
+	// Jumps to invalid destinations are resolved, they fail
+	return true
+}
+
+// unresolved marks a block as ending with a jump to an unknown target. The
+// paths continuing after the jump can't be explored.
+func (e *explorer) unresolved(block *Block) {
+	block.Unresolved = true
+	e.prog.Complete = false
+}
+
+// stackEffect returns the number of items an instruction pops and pushes.
+func stackEffect(op vm.OpCode) (int, int) {
+	minStack, maxStack := instructionSet[op].Stack()
+	return minStack, minStack + int(params.StackLimit) - maxStack
+}
+
+// writesMemory returns whether an instruction without special handling
+// modifies the memory.
+func writesMemory(op vm.OpCode) bool {
+	switch op {
+	case vm.MSTORE8, vm.CALLDATACOPY, vm.CODECOPY, vm.EXTCODECOPY, vm.RETURNDATACOPY, vm.MCOPY,
+		vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		return true
+	}
+	return false
+}
+
+// hashMemory returns the hash of the memory range, if all of its words are
+// known.
+func hashMemory(memory map[uint64]*Value, offset, size *Value) *Value {
+	if offset.Kind != Const || size.Kind != Const || !offset.Const.IsUint64() || !size.Const.IsUint64() {
+		return unknown
+	}
+	start, length := offset.Const.Uint64(), size.Const.Uint64()
+	if length == 0 || length%32 != 0 || length > 32*16 {
+		return unknown
+	}
+	words := make([]*Value, length/32)
+	for i := range words {
+		word := memory[start+32*uint64(i)]
+		if word == nil {
+			return unknown
+		}
+		words[i] = word
+	}
+	return expression(Keccak, nil, words)
+}
+
+// evaluate computes the result of an instruction popping the given arguments
+// and pushing a single item.
+func evaluate(op vm.OpCode, args []*Value) *Value {
+	// Fold the instructions on constants
+	consts := true
+	for _, arg := range args {
+		consts = consts && arg.Kind == Const
+	}
+	if consts && len(args) > 0 {
+		if c, ok := fold(op, args); ok {
+			return constant(c)
+		}
+		return unknown
+	}
+	switch op {
+	case vm.SHR:
+		// Solidity dispatchers extract the selector by shifting the first
+		// calldata word by 224 bits
+		if args[0].Kind == Const && args[0].Const.Eq(uint256.NewInt(224)) && args[1].Kind == CallData && args[1].Offset == 0 {
+			return &Value{Kind: Selector}
+		}
+	case vm.DIV:
+		// Older dispatchers divide the first calldata word by 2**224
+		if args[0].Kind == CallData && args[0].Offset == 0 && args[1].Kind == Const && args[1].Const.Eq(new(uint256.Int).Lsh(uint256.NewInt(1), 224)) {
+			return &Value{Kind: Selector}
+		}
+	case vm.EQ:
+		if args[0].Kind == Selector && args[1].Kind == Const {
+			return &Value{Kind: SelectorEq, Const: args[1].Const}
+		}
+		if args[1].Kind == Selector && args[0].Kind == Const {
+			return &Value{Kind: SelectorEq, Const: args[0].Const}
+		}
+	case vm.AND:
+		v, mask := args[0], args[1]
+		if v.Kind == Const {
+			v, mask = mask, v
+		}
+		if mask.Kind != Const || !v.Known() {
+			break
+		}
+		if v.Kind == Selector && mask.Const.Eq(uint256.NewInt(0xffffffff)) {
+			return v
+		}
+		if v.Kind == And {
+			return expression(And, new(uint256.Int).And(v.Const, mask.Const), v.Args)
+		}
+		return expression(And, mask.Const, []*Value{v})
+	case vm.ADD:
+		v, c := args[0], args[1]
+		if v.Kind == Const {
+			v, c = c, v
+		}
+		if c.Kind != Const || !v.Known() {
+			break
+		}
+		if v.Kind == Add {
+			return expression(Add, new(uint256.Int).Add(v.Const, c.Const), v.Args)
+		}
+		return expression(Add, c.Const, []*Value{v})
+	}
+	return unknown
+}
+
+// fold computes the result of an arithmetic instruction on constants.
+func fold(op vm.OpCode, args []*Value) (*uint256.Int, bool) {
+	x := new(uint256.Int)
+	switch op {
+	case vm.ADD:
+		return x.Add(args[0].Const, args[1].Const), true
+	case vm.SUB:
+		return x.Sub(args[0].Const, args[1].Const), true
+	case vm.MUL:
+		return x.Mul(args[0].Const, args[1].Const), true
+	case vm.DIV:
+		return x.Div(args[0].Const, args[1].Const), true
+	case vm.AND:
+		return x.And(args[0].Const, args[1].Const), true
+	case vm.OR:
+		return x.Or(args[0].Const, args[1].Const), true
+	case vm.XOR:
+		return x.Xor(args[0].Const, args[1].Const), true
+	case vm.NOT:
+		return x.Not(args[0].Const), true
+	case vm.SHL:
+		if args[0].Const.LtUint64(256) {
+			return x.Lsh(args[1].Const, uint(args[0].Const.Uint64())), true
+		}
+		return x, true
+	case vm.SHR:
+		if args[0].Const.LtUint64(256) {
+			return x.Rsh(args[1].Const, uint(args[0].Const.Uint64())), true
+		}
+		return x, true
+	case vm.EQ:
+		if args[0].Const.Eq(args[1].Const) {
+			x.SetOne()
+		}
+		return x, true
+	case vm.LT:
+		if args[0].Const.Lt(args[1].Const) {
+			x.SetOne()
+		}
+		return x, true
+	case vm.GT:
+		if args[0].Const.Gt(args[1].Const) {
+			x.SetOne()
+		}
+		return x, true
+	case vm.ISZERO:
+		if args[0].Const.IsZero() {
+			x.SetOne()
+		}
+		return x, true
+	}
+	return nil, false
+}
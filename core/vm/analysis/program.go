@@ -0,0 +1,195 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package analysis implements the static analysis of deployed EVM bytecode. It
+// builds the control-flow graph of the code, identifies the functions of the
+// selector dispatcher and, where possible, the storage slots accessed by each
+// function as expressions of the calldata and the caller.
+package analysis
+
+import (
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/asm"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// instructionSet is used for the stack effects of the instructions without
+// special handling in the analysis.
+var instructionSet, _ = vm.LookupInstructionSet(params.Rules{IsPrague: true})
+
+// Instruction is a disassembled instruction.
+type Instruction struct {
+	PC  uint64
+	Op  vm.OpCode
+	Arg []byte // Immediate of push instructions
+}
+
+// Block is a basic block of the control-flow graph.
+type Block struct {
+	Start        uint64 // Position of the first instruction
+	End          uint64 // Position of the last instruction
+	Instructions []Instruction
+	Succs        []uint64 // Starts of the successor blocks
+	Unresolved   bool     // Whether the block ends with a jump to an unknown target
+}
+
+// Access is a storage access of a function.
+type Access struct {
+	PC    uint64 // Position of the SLOAD or SSTORE
+	Write bool   // Whether the access is an SSTORE
+	Slot  *Value // Accessed slot
+}
+
+// Function is a function of the selector dispatcher.
+type Function struct {
+	Selector [4]byte
+	Entry    uint64 // Position the dispatcher jumps to
+	Accesses []Access
+}
+
+// Program is the result of the analysis of a piece of code.
+type Program struct {
+	Code      []byte
+	Blocks    []*Block    // Basic blocks ordered by position
+	Functions []*Function // Functions ordered by selector
+	Accesses  []Access    // Accesses outside of any function, e.g. in the fallback
+
+	// Complete is false if the analysis gave up on some paths, because of its
+	// limits or of stack underflows, in which case accesses might be missing.
+	Complete bool
+}
+
+// Analyze builds the control-flow graph of legacy code and predicts the
+// storage accesses of its functions.
+func Analyze(code []byte) *Program {
+	p := &Program{Code: code, Complete: true}
+	p.Blocks = splitBlocks(code)
+	newExplorer(p).run()
+	sort.Slice(p.Functions, func(i, j int) bool {
+		return string(p.Functions[i].Selector[:]) < string(p.Functions[j].Selector[:])
+	})
+	return p
+}
+
+// splitBlocks disassembles the code into basic blocks. A block starts at a
+// JUMPDEST or after an instruction which ends a block.
+func splitBlocks(code []byte) []*Block {
+	var (
+		blocks []*Block
+		block  *Block
+		it     = asm.NewInstructionIterator(code)
+	)
+	for it.Next() {
+		op := it.Op()
+		if op == vm.JUMPDEST && block != nil {
+			blocks = append(blocks, block)
+			block = nil
+		}
+		if block == nil {
+			block = &Block{Start: it.PC()}
+		}
+		block.End = it.PC()
+		block.Instructions = append(block.Instructions, Instruction{PC: it.PC(), Op: op, Arg: it.Arg()})
+		if endsBlock(op) {
+			blocks = append(blocks, block)
+			block = nil
+		}
+	}
+	if block != nil {
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// endsBlock returns whether the instruction is the last one of its block.
+func endsBlock(op vm.OpCode) bool {
+	switch op {
+	case vm.JUMP, vm.JUMPI, vm.STOP, vm.RETURN, vm.REVERT, vm.SELFDESTRUCT, vm.INVALID:
+		return true
+	}
+	return !defined(op)
+}
+
+// defined returns whether the instruction is defined for legacy code.
+func defined(op vm.OpCode) bool {
+	return op == vm.STOP || instructionSet[op].HasCost()
+}
+
+// Block returns the block starting at the given position, or nil if there is
+// none.
+func (p *Program) Block(start uint64) *Block {
+	i := sort.Search(len(p.Blocks), func(i int) bool { return p.Blocks[i].Start >= start })
+	if i < len(p.Blocks) && p.Blocks[i].Start == start {
+		return p.Blocks[i]
+	}
+	return nil
+}
+
+// Function returns the function with the given selector, or nil if the
+// dispatcher has none.
+func (p *Program) Function(selector [4]byte) *Function {
+	for _, fn := range p.Functions {
+		if fn.Selector == selector {
+			return fn
+		}
+	}
+	return nil
+}
+
+// PredictAccesses returns the storage slots accessed by a call with the given
+// caller and calldata, as far as they are known before execution. The
+// accesses outside of any function are included in every prediction. The
+// returned flag is false if some of the accesses depend on the execution.
+func (p *Program) PredictAccesses(caller common.Address, calldata []byte) ([]common.Hash, bool) {
+	var (
+		slots    []common.Hash
+		seen     = make(map[common.Hash]bool)
+		complete = p.Complete
+	)
+	add := func(accesses []Access) {
+		for _, access := range accesses {
+			slot, ok := access.Slot.Resolve(caller, calldata)
+			if !ok {
+				complete = false
+				continue
+			}
+			if hash := common.Hash(slot.Bytes32()); !seen[hash] {
+				seen[hash] = true
+				slots = append(slots, hash)
+			}
+		}
+	}
+	add(p.Accesses)
+	if len(calldata) >= 4 {
+		if fn := p.Function([4]byte(calldata[:4])); fn != nil {
+			add(fn.Accesses)
+		}
+	}
+	return slots, complete
+}
+
+// selectorBytes converts a constant compared with the selector.
+func selectorBytes(c *uint256.Int) ([4]byte, bool) {
+	if !c.IsUint64() || c.Uint64() > 0xffffffff {
+		return [4]byte{}, false
+	}
+	b := c.Bytes32()
+	return [4]byte(b[28:]), true
+}
@@ -0,0 +1,176 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package analysis
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+)
+
+// Kind is the kind of an abstract value.
+type Kind int
+
+const (
+	Unknown    Kind = iota // Value not known before execution
+	Const                  // Constant
+	Caller                 // Address of the caller
+	CallData               // Word of the calldata at a constant offset
+	Selector               // Function selector, the first 4 bytes of the calldata
+	SelectorEq             // Comparison of the function selector with a constant
+	Keccak                 // Keccak256 hash of a sequence of words
+	Add                    // Sum of a value and a constant
+	And                    // Value masked with a constant
+)
+
+// Value is an abstract value of the stack or memory, known as an expression of
+// the calldata and the caller of the execution.
+type Value struct {
+	Kind   Kind
+	Const  *uint256.Int // Constant, or the operand of Add and And
+	Offset uint64       // Calldata offset of a CallData value
+	Args   []*Value     // Hashed words of a Keccak value, or the operand of Add and And
+
+	nodes int // Number of nodes of the expression tree, zero for leaves
+}
+
+// maxValueNodes is the maximum number of nodes of an expression. Nested hashes
+// share their sub-expressions, but they are rendered and resolved as trees, so
+// their size may double with every level. Larger expressions are unknown.
+const maxValueNodes = 256
+
+var unknown = &Value{Kind: Unknown}
+
+// expression returns a value computed from the given arguments, or unknown if
+// the expression grows too large.
+func expression(kind Kind, c *uint256.Int, args []*Value) *Value {
+	nodes := 1
+	for _, arg := range args {
+		nodes += arg.size()
+	}
+	if nodes > maxValueNodes {
+		return unknown
+	}
+	return &Value{Kind: kind, Const: c, Args: args, nodes: nodes}
+}
+
+// size returns the number of nodes of the expression tree.
+func (v *Value) size() int {
+	if v.nodes == 0 {
+		return 1
+	}
+	return v.nodes
+}
+
+// constant returns a constant value.
+func constant(c *uint256.Int) *Value {
+	return &Value{Kind: Const, Const: c}
+}
+
+// Known returns whether the value can be resolved from the calldata and the
+// caller.
+func (v *Value) Known() bool {
+	switch v.Kind {
+	case Unknown, SelectorEq:
+		return false
+	}
+	for _, arg := range v.Args {
+		if !arg.Known() {
+			return false
+		}
+	}
+	return true
+}
+
+// Resolve computes the value for the given caller and calldata. It returns
+// false if the value is unknown before execution.
+func (v *Value) Resolve(caller common.Address, calldata []byte) (*uint256.Int, bool) {
+	switch v.Kind {
+	case Const:
+		return new(uint256.Int).Set(v.Const), true
+	case Caller:
+		return new(uint256.Int).SetBytes(caller.Bytes()), true
+	case CallData:
+		return new(uint256.Int).SetBytes(common.RightPadBytes(sliceCalldata(calldata, v.Offset), 32)), true
+	case Selector:
+		return new(uint256.Int).SetBytes(common.RightPadBytes(sliceCalldata(calldata, 0), 32)[:4]), true
+	case Keccak:
+		data := make([]byte, 0, 32*len(v.Args))
+		for _, arg := range v.Args {
+			word, ok := arg.Resolve(caller, calldata)
+			if !ok {
+				return nil, false
+			}
+			b := word.Bytes32()
+			data = append(data, b[:]...)
+		}
+		return new(uint256.Int).SetBytes(crypto.Keccak256(data)), true
+	case Add, And:
+		x, ok := v.Args[0].Resolve(caller, calldata)
+		if !ok {
+			return nil, false
+		}
+		if v.Kind == Add {
+			return x.Add(x, v.Const), true
+		}
+		return x.And(x, v.Const), true
+	}
+	return nil, false
+}
+
+// sliceCalldata returns up to 32 bytes of calldata from the given offset.
+func sliceCalldata(calldata []byte, offset uint64) []byte {
+	if offset >= uint64(len(calldata)) {
+		return nil
+	}
+	end := offset + 32
+	if end > uint64(len(calldata)) {
+		end = uint64(len(calldata))
+	}
+	return calldata[offset:end]
+}
+
+// String returns a canonical representation of the value, equal for equal
+// values.
+func (v *Value) String() string {
+	switch v.Kind {
+	case Const:
+		return v.Const.Hex()
+	case Caller:
+		return "caller"
+	case CallData:
+		return fmt.Sprintf("calldata[%d]", v.Offset)
+	case Selector:
+		return "selector"
+	case SelectorEq:
+		return fmt.Sprintf("selector == %s", v.Const.Hex())
+	case Keccak:
+		args := make([]string, len(v.Args))
+		for i, arg := range v.Args {
+			args[i] = arg.String()
+		}
+		return fmt.Sprintf("keccak(%s)", strings.Join(args, ", "))
+	case Add:
+		return fmt.Sprintf("%v + %s", v.Args[0], v.Const.Hex())
+	case And:
+		return fmt.Sprintf("%v & %s", v.Args[0], v.Const.Hex())
+	}
+	return "?"
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// blockCacheSize is the number of code analyses kept in the block cache.
+const blockCacheSize = 4096
+
+// blockCacheKey identifies the basic block analysis of a piece of code. The
+// analysis depends on the gas and stack requirements of the instruction set,
+// so it is cached per jump table. The tables with extra EIPs are interned, see
+// extendJumpTable.
+type blockCacheKey struct {
+	table *JumpTable
+	hash  common.Hash
+}
+
+// blockCache holds the basic block analyses of recently executed contracts.
+var blockCache = lru.NewCache[blockCacheKey, codeBlocks](blockCacheSize)
+
+// basicBlock is a sequence of instructions which, once entered, is executed
+// up to its last instruction unless an error occurs. Jumps can only target
+// the first instruction of a block, and the only instruction of a block that
+// may have a dynamic gas cost is the last one. The static gas and the stack
+// requirements of all the instructions can thus be checked on entry.
+type basicBlock struct {
+	start    uint64 // Position of the first instruction
+	last     uint64 // Position of the last instruction
+	gas      uint64 // Sum of the constant gas of the instructions
+	minStack int    // Stack height needed on entry to not underflow
+	maxStack int    // Stack height allowed on entry to not overflow
+}
+
+// codeBlocks is the basic block analysis of a piece of code, ordered by the
+// position of the blocks.
+type codeBlocks []basicBlock
+
+// find returns the index of the block starting at the given position, or -1
+// if no block starts there.
+func (blocks codeBlocks) find(pc uint64) int {
+	i := sort.Search(len(blocks), func(i int) bool { return blocks[i].start >= pc })
+	if i < len(blocks) && blocks[i].start == pc {
+		return i
+	}
+	return -1
+}
+
+// endsBlock returns whether the instruction has to be the last one of its
+// block: instructions changing the control flow, the ones that halt, and the
+// ones whose cost or result depends on the gas left after charging the
+// static gas.
+func endsBlock(op OpCode, operation *operation) bool {
+	if operation.dynamicGas != nil || operation.undefined {
+		return true
+	}
+	switch op {
+	case STOP, JUMP, JUMPI, RETURN, REVERT, SELFDESTRUCT, INVALID, GAS:
+		return true
+	}
+	return false
+}
+
+// analyzeBlocks splits legacy code into basic blocks for the given instruction
+// set. Every JUMPDEST starts a block, so that a jump destination is valid if
+// and only if it is the start of a block beginning with a JUMPDEST.
+func analyzeBlocks(code []byte, table *JumpTable) codeBlocks {
+	var (
+		blocks codeBlocks
+		block  basicBlock
+		height int  // Stack height change since the start of the block
+		open   bool // Whether the block has any instructions
+	)
+	for pc := uint64(0); pc < uint64(len(code)); {
+		op := OpCode(code[pc])
+		operation := table[op]
+
+		if op == JUMPDEST && open {
+			blocks = append(blocks, block)
+			open = false
+		}
+		if !open {
+			block = basicBlock{start: pc, maxStack: int(params.StackLimit)}
+			height, open = 0, true
+		}
+		block.last = pc
+		block.gas += operation.constantGas
+		if need := operation.minStack - height; need > block.minStack {
+			block.minStack = need
+		}
+		if limit := operation.maxStack - height; limit < block.maxStack {
+			block.maxStack = limit
+		}
+		height += int(params.StackLimit) - operation.maxStack
+
+		pc++
+		if op >= PUSH1 && op <= PUSH32 {
+			pc += uint64(op - PUSH0)
+		}
+		if endsBlock(op, operation) {
+			blocks = append(blocks, block)
+			open = false
+		}
+	}
+	if open {
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// codeBlocks returns the basic block analysis of the contract code. The
+// analysis of code in the state is cached by code hash, initcode is analysed
+// on every execution.
+func (in *EVMInterpreter) codeBlocks(contract *Contract) codeBlocks {
+	if contract.CodeHash == (common.Hash{}) {
+		return analyzeBlocks(contract.Code, in.table)
+	}
+	key := blockCacheKey{table: in.table, hash: contract.CodeHash}
+	if blocks, ok := blockCache.Get(key); ok {
+		return blocks
+	}
+	blocks := analyzeBlocks(contract.Code, in.table)
+	blockCache.Add(key, blocks)
+	return blocks
+}
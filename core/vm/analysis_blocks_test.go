@@ -0,0 +1,116 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+func TestAnalyzeBlocks(t *testing.T) {
+	code := common.FromHex("6001600201" + "5b600054" + "5000" + "615b5b")
+	want := codeBlocks{
+		// PUSH1 PUSH1 ADD, ended by the JUMPDEST
+		{start: 0, last: 4, gas: 9, minStack: 0, maxStack: 1022},
+		// JUMPDEST PUSH1 SLOAD, ended by the dynamic gas of SLOAD
+		{start: 5, last: 8, gas: 4, minStack: 0, maxStack: 1023},
+		// POP STOP
+		{start: 9, last: 10, gas: 2, minStack: 1, maxStack: 1024},
+		// PUSH2, the JUMPDESTs in the push data don't start blocks
+		{start: 11, last: 11, gas: 3, minStack: 0, maxStack: 1023},
+	}
+	blocks := analyzeBlocks(code, &shanghaiInstructionSet)
+	if !reflect.DeepEqual(blocks, want) {
+		t.Fatalf("blocks mismatch:\nhave %+v\nwant %+v", blocks, want)
+	}
+	for _, pc := range []uint64{5, 9} {
+		if blocks.find(pc) < 0 {
+			t.Errorf("block at %d not found", pc)
+		}
+	}
+	for _, pc := range []uint64{1, 12, 13, 14} {
+		if blocks.find(pc) >= 0 {
+			t.Errorf("unexpected block at %d", pc)
+		}
+	}
+}
+
+// stepTracer is an EVMLogger that does nothing, but makes the interpreter
+// execute the code instruction by instruction.
+type stepTracer struct{}
+
+func (stepTracer) CaptureTxStart(uint64) {}
+func (stepTracer) CaptureTxEnd(uint64)   {}
+func (stepTracer) CaptureStart(*EVM, common.Address, common.Address, bool, []byte, uint64, *big.Int) {
+}
+func (stepTracer) CaptureEnd([]byte, uint64, error)                                               {}
+func (stepTracer) CaptureEnter(OpCode, common.Address, common.Address, []byte, uint64, *big.Int)  {}
+func (stepTracer) CaptureExit([]byte, uint64, error)                                              {}
+func (stepTracer) CaptureState(uint64, OpCode, uint64, uint64, *ScopeContext, []byte, int, error) {}
+func (stepTracer) CaptureFault(uint64, OpCode, uint64, uint64, *ScopeContext, int, error)         {}
+
+// Tests that executing code block by block results in the same gas usage and
+// errors as executing it instruction by instruction, with any amount of gas.
+func TestBlockExecution(t *testing.T) {
+	tests := []string{
+		// Loop counting down from 10: PUSH1 10 JUMPDEST PUSH1 1 SWAP1 SUB DUP1 PUSH1 2 JUMPI STOP
+		"600a5b600190038060025700",
+		// Stack underflow in the middle of a block: PUSH1 1 ADD
+		"600101",
+		// Gas left in the middle of a block: PUSH1 1 GAS POP POP GAS
+		"60015a50505a",
+		// Jump into push data: PUSH1 4 JUMP PUSH1 0x5b
+		"600456605b",
+		// Jump out of the code: PUSH1 0xff JUMP
+		"60ff56",
+		// Storage and memory accesses: PUSH1 1 PUSH1 0 SSTORE PUSH1 0 SLOAD PUSH1 0 MSTORE
+		"6001600055600054600052",
+		// Return data: PUSH1 32 PUSH1 0 RETURN
+		"60206000f3",
+		// Undefined opcode after some instructions: PUSH1 1 POP 0xef
+		"600150ef",
+	}
+	address := common.BytesToAddress([]byte("contract"))
+	for i, tt := range tests {
+		code := common.FromHex(tt)
+		for gas := uint64(0); gas < 50000; gas += 1 + gas/8 {
+			var results [2]string
+			for j, cfg := range []Config{{}, {Tracer: stepTracer{}}} {
+				statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+				statedb.CreateAccount(address)
+				statedb.SetCode(address, code)
+				statedb.Finalise(true)
+
+				evm := NewEVM(BlockContext{Transfer: func(StateDB, common.Address, common.Address, *uint256.Int) {}}, TxContext{}, statedb, params.TestChainConfig, cfg)
+				ret, left, err := evm.Call(AccountRef(common.Address{}), address, nil, gas, new(uint256.Int))
+				results[j] = fmt.Sprintf("ret %x, gas left %d, err %v", ret, left, err)
+			}
+			if results[0] != results[1] {
+				t.Fatalf("test %d, gas %d: block execution mismatch:\nhave %s\nwant %s", i, gas, results[0], results[1])
+			}
+		}
+	}
+}
@@ -18,6 +18,7 @@ package vm
 
 import (
 	"math/bits"
+	"reflect"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/crypto"
@@ -59,6 +60,14 @@ func TestJumpDestAnalysis(t *testing.T) {
 	}
 }
 
+func TestJumpDests(t *testing.T) {
+	// JUMPDEST PUSH1 JUMPDEST JUMPDEST PUSH2 JUMPDEST JUMPDEST JUMPDEST
+	code := []byte{byte(JUMPDEST), byte(PUSH1), byte(JUMPDEST), byte(JUMPDEST), byte(PUSH2), byte(JUMPDEST), byte(JUMPDEST), byte(JUMPDEST)}
+	if have, want := JumpDests(code), []uint64{0, 3, 7}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("jump destinations mismatch: have %v, want %v", have, want)
+	}
+}
+
 const analysisCodeSize = 1200 * 1024
 
 func BenchmarkJumpdestAnalysis_1200k(bench *testing.B) {
@@ -0,0 +1,263 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+)
+
+// CheckpointFunc is invoked by the interpreter right before a state-access
+// instruction (SLOAD, BALANCE, EXTCODE* and the CALL family) is executed, with
+// the checkpoint the execution can later be resumed from. If it returns true,
+// the execution is suspended: every frame returns ErrExecutionSuspended without
+// reverting any state, and the outermost call returns the same error.
+//
+// Checkpoints are only offered while all frames of the call stack are message
+// calls into legacy code: instructions executed within contract creation or
+// EOF code are not checkpointed.
+type CheckpointFunc func(cp *Checkpoint) bool
+
+// Checkpoint is a self-contained copy of a call stack suspended before a state
+// access instruction.
+type Checkpoint struct {
+	Depth    int                // Call depth the outermost frame was entered from
+	Snapshot int                // State revision at the time of the checkpoint
+	Frames   []*FrameCheckpoint // Call frames, outermost first
+}
+
+// FrameCheckpoint is the execution context of a single call frame. The innermost
+// frame is positioned at the state-access instruction to be executed, all other
+// frames are positioned at the CALL-family instruction waiting for the frame
+// above to return.
+type FrameCheckpoint struct {
+	Op         OpCode        // Instruction at the program counter
+	Pc         uint64        // Program counter
+	Gas        uint64        // Gas available to the frame, without the gas forwarded to the pending call
+	Stack      []uint256.Int // Stack contents, bottom first
+	Memory     []byte        // Memory contents
+	ReturnData []byte        // Return data of the last finished call
+	ReadOnly   bool          // Whether the frame executes in a static context
+	Snapshot   int           // State revision to revert to if the frame fails
+
+	CallerAddress common.Address
+	Address       common.Address
+	Value         *uint256.Int
+	Code          []byte
+	CodeHash      common.Hash
+	CodeAddr      *common.Address
+	Input         []byte
+
+	RetOffset uint64 // Memory offset of the pending call's return data
+	RetSize   uint64 // Memory size reserved for the pending call's return data
+
+	memoryCost uint64 // Memory expansion cost already paid
+}
+
+// frame is a live call frame on the interpreter, tracked only if checkpointing
+// is enabled.
+type frame struct {
+	pc       *uint64
+	scope    *ScopeContext
+	snapshot int
+	readOnly bool
+
+	retOffset uint64
+	retSize   uint64
+}
+
+// isCheckpointOp returns whether a checkpoint is offered before the given op.
+func isCheckpointOp(op OpCode) bool {
+	switch op {
+	case SLOAD, BALANCE, EXTCODESIZE, EXTCODECOPY, EXTCODEHASH:
+		return true
+	}
+	return isCallOp(op)
+}
+
+// isCallOp returns whether the given op opens a message call frame.
+func isCallOp(op OpCode) bool {
+	switch op {
+	case CALL, CALLCODE, DELEGATECALL, STATICCALL:
+		return true
+	}
+	return false
+}
+
+// pendingCall records where the return data of the call about to be made by
+// the current frame is to be stored.
+func (in *EVMInterpreter) pendingCall(retOffset, retSize uint64) {
+	f := in.frames[len(in.frames)-1]
+	f.retOffset, f.retSize = retOffset, retSize
+}
+
+// checkpoint assembles the checkpoint of the live call stack and hands it to
+// the configured hook, returning whether the execution should be suspended.
+func (in *EVMInterpreter) checkpoint() bool {
+	frames := make([]*FrameCheckpoint, len(in.frames))
+	for i, f := range in.frames {
+		if f.snapshot < 0 {
+			return false
+		}
+		contract := f.scope.Contract
+		if contract.Container != nil {
+			return false // EOF frames are not checkpointed
+		}
+		op := contract.GetOp(*f.pc)
+		if i < len(in.frames)-1 && !isCallOp(op) {
+			return false
+		}
+		cp := &FrameCheckpoint{
+			Op:            op,
+			Pc:            *f.pc,
+			Gas:           contract.Gas,
+			Stack:         append([]uint256.Int(nil), f.scope.Stack.data...),
+			Memory:        common.CopyBytes(f.scope.Memory.store),
+			ReadOnly:      f.readOnly,
+			Snapshot:      f.snapshot,
+			CallerAddress: contract.CallerAddress,
+			Address:       contract.Address(),
+			Code:          contract.Code,
+			CodeHash:      contract.CodeHash,
+			Input:         common.CopyBytes(contract.Input),
+			RetOffset:     f.retOffset,
+			RetSize:       f.retSize,
+			memoryCost:    f.scope.Memory.lastGasCost,
+		}
+		if contract.value != nil {
+			cp.Value = new(uint256.Int).Set(contract.value)
+		}
+		if contract.CodeAddr != nil {
+			addr := *contract.CodeAddr
+			cp.CodeAddr = &addr
+		}
+		frames[i] = cp
+	}
+	// Only the innermost frame's return data is current, the others have been
+	// reset by the frames above. Remember it for resumption of the innermost.
+	frames[len(frames)-1].ReturnData = common.CopyBytes(in.returnData)
+
+	return in.evm.Config.Checkpointer(&Checkpoint{
+		Depth:    in.evm.depth - len(in.frames),
+		Snapshot: in.evm.StateDB.Snapshot(),
+		Frames:   frames,
+	})
+}
+
+// restore loads the outermost of the given frames into the scope. If further
+// frames follow, the pending call is resumed first and its result is applied
+// as the CALL-family instruction would. The returned flag reports whether the
+// instruction at the program counter is the one the checkpoint was taken at.
+func (in *EVMInterpreter) restore(pc *uint64, scope *ScopeContext, frames []*FrameCheckpoint) (bool, error) {
+	f := frames[0]
+
+	*pc = f.Pc
+	scope.Stack.data = append(scope.Stack.data[:0], f.Stack...)
+	scope.Memory.store = common.CopyBytes(f.Memory)
+	scope.Memory.lastGasCost = f.memoryCost
+	in.returnData = f.ReturnData
+
+	if len(frames) == 1 {
+		return true, nil
+	}
+	ret, returnGas, err := in.evm.resume(f.Op, frames[1:])
+	if err == ErrExecutionSuspended {
+		return false, err
+	}
+	var success uint256.Int
+	if err == nil {
+		success.SetOne()
+	}
+	scope.Stack.push(&success)
+	if err == nil || err == ErrExecutionReverted {
+		scope.Memory.Set(f.RetOffset, f.RetSize, ret)
+	}
+	scope.Contract.Gas += returnGas
+	in.returnData = ret
+
+	*pc++
+	return false, nil
+}
+
+// Resume continues the execution captured by the checkpoint and returns the
+// result of the outermost frame, as the original EVM call would have. The state
+// is not touched beforehand: the caller must make sure it corresponds to the
+// checkpoint, e.g. by reverting to cp.Snapshot.
+//
+// As the suspension exited all frames of the tracer, the re-entered frames are
+// reported to it again, with the gas available to them at the checkpoint. The outermost
+// frame is reported as a CALL if it is not the top call frame.
+func (evm *EVM) Resume(cp *Checkpoint) (ret []byte, leftOverGas uint64, err error) {
+	if len(cp.Frames) == 0 {
+		return nil, 0, errors.New("empty checkpoint")
+	}
+	depth := evm.depth
+	evm.depth = cp.Depth
+	defer func() { evm.depth = depth }()
+
+	return evm.resume(CALL, cp.Frames)
+}
+
+// resume re-enters the outermost of the given frames, opened by the given call
+// type, and finishes it the same way the message call methods do.
+func (evm *EVM) resume(typ OpCode, frames []*FrameCheckpoint) (ret []byte, leftOverGas uint64, err error) {
+	f := frames[0]
+
+	if evm.Config.Tracer != nil {
+		// The gas available to the frame includes the gas forwarded to the
+		// frames above it
+		var gas uint64
+		for _, frame := range frames {
+			gas += frame.Gas
+		}
+		from, to := f.CallerAddress, f.Address
+		if (typ == CALLCODE || typ == DELEGATECALL) && f.CodeAddr != nil {
+			from, to = f.Address, *f.CodeAddr
+		}
+		var value *big.Int
+		if typ != STATICCALL {
+			value = f.Value.ToBig()
+		}
+		if evm.depth == 0 {
+			evm.Config.Tracer.CaptureStart(evm, from, to, false, f.Input, gas, value)
+			defer func(startGas uint64) {
+				evm.Config.Tracer.CaptureEnd(ret, startGas-leftOverGas, err)
+			}(gas)
+		} else {
+			evm.Config.Tracer.CaptureEnter(typ, from, to, f.Input, gas, value)
+			defer func(startGas uint64) {
+				evm.Config.Tracer.CaptureExit(ret, startGas-leftOverGas, err)
+			}(gas)
+		}
+	}
+
+	contract := NewContract(AccountRef(f.CallerAddress), AccountRef(f.Address), f.Value, f.Gas)
+	contract.SetCallCode(f.CodeAddr, f.CodeHash, f.Code)
+
+	ret, err = evm.interpreter.run(contract, f.Input, f.ReadOnly, f.Snapshot, frames)
+	gas := contract.Gas
+	if err != nil && err != ErrExecutionSuspended {
+		evm.StateDB.RevertToSnapshot(f.Snapshot)
+		if err != ErrExecutionReverted {
+			gas = 0
+		}
+	}
+	return ret, gas, err
+}
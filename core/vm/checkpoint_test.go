@@ -0,0 +1,225 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+var (
+	checkpointCaller = common.HexToAddress("0xaa")
+	checkpointCallee = common.HexToAddress("0xbb")
+)
+
+// newCheckpointState creates a state where 0xaa calls 0xbb, which increments
+// its slot 0 and returns the previous value. 0xaa stores the returned value in
+// its slot 1 and returns it as well.
+func newCheckpointState(counter byte) *state.StateDB {
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(checkpointCaller, []byte{
+		byte(PUSH1), 32, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0,
+		byte(PUSH1), 0xbb, byte(GAS), byte(CALL), byte(POP),
+		byte(PUSH1), 0, byte(MLOAD), byte(PUSH1), 1, byte(SSTORE),
+		byte(PUSH1), 32, byte(PUSH1), 0, byte(RETURN),
+	})
+	statedb.SetCode(checkpointCallee, []byte{
+		byte(PUSH1), 0, byte(SLOAD), byte(DUP1), byte(PUSH1), 1, byte(ADD), byte(PUSH1), 0, byte(SSTORE),
+		byte(PUSH1), 0, byte(MSTORE), byte(PUSH1), 32, byte(PUSH1), 0, byte(RETURN),
+	})
+	statedb.SetState(checkpointCallee, common.Hash{}, common.BytesToHash([]byte{counter}))
+	statedb.Finalise(true)
+	statedb.AddAddressToAccessList(checkpointCaller)
+	return statedb
+}
+
+func newCheckpointEVM(statedb *state.StateDB, checkpointer CheckpointFunc) *EVM {
+	vmctx := BlockContext{
+		CanTransfer: func(StateDB, common.Address, *uint256.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *uint256.Int) {},
+		BlockNumber: common.Big0,
+	}
+	return NewEVM(vmctx, TxContext{}, statedb, params.AllEthashProtocolChanges, Config{Checkpointer: checkpointer})
+}
+
+// Tests that an execution suspended in a nested frame resumes to the exact same
+// result and gas usage as the uninterrupted execution.
+func TestCheckpointSuspendResume(t *testing.T) {
+	// Run the reference execution without any checkpoints
+	refState := newCheckpointState(7)
+	wantRet, wantGas, err := newCheckpointEVM(refState, nil).Call(AccountRef(common.Address{}), checkpointCaller, nil, 100000, new(uint256.Int))
+	if err != nil {
+		t.Fatalf("reference execution failed: %v", err)
+	}
+	// Suspend at the first SLOAD within the callee
+	var (
+		statedb = newCheckpointState(7)
+		cp      *Checkpoint
+	)
+	evm := newCheckpointEVM(statedb, func(c *Checkpoint) bool {
+		if cp == nil && c.Frames[len(c.Frames)-1].Op == SLOAD {
+			cp = c
+			return true
+		}
+		return false
+	})
+	if _, _, err := evm.Call(AccountRef(common.Address{}), checkpointCaller, nil, 100000, new(uint256.Int)); err != ErrExecutionSuspended {
+		t.Fatalf("execution not suspended: %v", err)
+	}
+	if cp == nil || len(cp.Frames) != 2 || cp.Depth != 0 {
+		t.Fatalf("unexpected checkpoint: %+v", cp)
+	}
+	if cp.Frames[0].Op != CALL || cp.Frames[0].RetSize != 32 {
+		t.Fatalf("unexpected caller frame: %+v", cp.Frames[0])
+	}
+	ret, gas, err := evm.Resume(cp)
+	if err != nil {
+		t.Fatalf("resumed execution failed: %v", err)
+	}
+	if !bytes.Equal(ret, wantRet) {
+		t.Errorf("return data mismatch: have %x, want %x", ret, wantRet)
+	}
+	if gas != wantGas {
+		t.Errorf("leftover gas mismatch: have %d, want %d", gas, wantGas)
+	}
+	for _, slot := range []common.Hash{{}, common.BytesToHash([]byte{1})} {
+		for _, addr := range []common.Address{checkpointCaller, checkpointCallee} {
+			if have, want := statedb.GetState(addr, slot), refState.GetState(addr, slot); have != want {
+				t.Errorf("storage mismatch %x/%x: have %x, want %x", addr, slot, have, want)
+			}
+		}
+	}
+}
+
+// Tests that a finished execution can be restarted from a checkpoint after the
+// state it was taken at has been rolled back and altered.
+func TestCheckpointRestart(t *testing.T) {
+	var (
+		statedb = newCheckpointState(7)
+		cps     []*Checkpoint
+	)
+	evm := newCheckpointEVM(statedb, func(c *Checkpoint) bool {
+		cps = append(cps, c)
+		return false
+	})
+	ret, _, err := evm.Call(AccountRef(common.Address{}), checkpointCaller, nil, 100000, new(uint256.Int))
+	if err != nil {
+		t.Fatalf("execution failed: %v", err)
+	}
+	if have := new(uint256.Int).SetBytes(ret).Uint64(); have != 7 {
+		t.Fatalf("unexpected result: have %d, want 7", have)
+	}
+	// Roll back to the callee's SLOAD and pretend another transaction changed it
+	var cp *Checkpoint
+	for _, c := range cps {
+		if c.Frames[len(c.Frames)-1].Op == SLOAD {
+			cp = c
+		}
+	}
+	if cp == nil {
+		t.Fatal("no checkpoint at SLOAD")
+	}
+	statedb.RevertToSnapshot(cp.Snapshot)
+	statedb.SetState(checkpointCallee, common.Hash{}, common.BytesToHash([]byte{42}))
+
+	ret, _, err = evm.Resume(cp)
+	if err != nil {
+		t.Fatalf("resumed execution failed: %v", err)
+	}
+	if have := new(uint256.Int).SetBytes(ret).Uint64(); have != 42 {
+		t.Errorf("unexpected resumed result: have %d, want 42", have)
+	}
+	if have := statedb.GetState(checkpointCallee, common.Hash{}); have != common.BytesToHash([]byte{43}) {
+		t.Errorf("callee counter mismatch: have %x, want 43", have)
+	}
+	if have := statedb.GetState(checkpointCaller, common.BytesToHash([]byte{1})); have != common.BytesToHash([]byte{42}) {
+		t.Errorf("caller storage mismatch: have %x, want 42", have)
+	}
+}
+
+// frameTracer records the balance of the call frames entered and exited.
+type frameTracer struct {
+	depth    int
+	unopened int // Frames exited without being entered
+	starts   int
+}
+
+func (t *frameTracer) CaptureTxStart(gasLimit uint64) {}
+func (t *frameTracer) CaptureTxEnd(restGas uint64)    {}
+func (t *frameTracer) CaptureStart(env *EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.depth++
+	t.starts++
+}
+func (t *frameTracer) CaptureEnd(output []byte, gasUsed uint64, err error) { t.exit() }
+func (t *frameTracer) CaptureEnter(typ OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	t.depth++
+}
+func (t *frameTracer) CaptureExit(output []byte, gasUsed uint64, err error) { t.exit() }
+func (t *frameTracer) CaptureState(pc uint64, op OpCode, gas, cost uint64, scope *ScopeContext, rData []byte, depth int, err error) {
+}
+func (t *frameTracer) CaptureFault(pc uint64, op OpCode, gas, cost uint64, scope *ScopeContext, depth int, err error) {
+}
+
+func (t *frameTracer) exit() {
+	if t.depth == 0 {
+		t.unopened++
+		return
+	}
+	t.depth--
+}
+
+// Tests that the tracer is notified about the frames re-entered by a resumed
+// execution, keeping the enter and exit hooks balanced.
+func TestCheckpointResumeTracer(t *testing.T) {
+	var (
+		statedb = newCheckpointState(7)
+		tracer  = new(frameTracer)
+		cp      *Checkpoint
+	)
+	evm := newCheckpointEVM(statedb, func(c *Checkpoint) bool {
+		if cp == nil && c.Frames[len(c.Frames)-1].Op == SLOAD {
+			cp = c
+			return true
+		}
+		return false
+	})
+	evm.Config.Tracer = tracer
+
+	if _, _, err := evm.Call(AccountRef(common.Address{}), checkpointCaller, nil, 100000, new(uint256.Int)); err != ErrExecutionSuspended {
+		t.Fatalf("execution not suspended: %v", err)
+	}
+	if tracer.depth != 0 || tracer.unopened != 0 {
+		t.Fatalf("unbalanced suspension: depth %d, unopened %d", tracer.depth, tracer.unopened)
+	}
+	if _, _, err := evm.Resume(cp); err != nil {
+		t.Fatalf("resumed execution failed: %v", err)
+	}
+	if tracer.depth != 0 || tracer.unopened != 0 {
+		t.Errorf("unbalanced resumption: depth %d, unopened %d", tracer.depth, tracer.unopened)
+	}
+	if tracer.starts != 2 {
+		t.Errorf("top call frame starts mismatch: have %d, want 2", tracer.starts)
+	}
+}
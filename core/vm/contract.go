@@ -50,11 +50,17 @@ type Contract struct {
 
 	jumpdests map[common.Hash]bitvec // Aggregated result of JUMPDEST analysis.
 	analysis  bitvec                 // Locally cached result of JUMPDEST analysis
+	blocks    codeBlocks             // Basic blocks of the code, if executed block by block
 
-	Code     []byte
-	CodeHash common.Hash
-	CodeAddr *common.Address
-	Input    []byte
+	Code      []byte
+	CodeHash  common.Hash
+	CodeAddr  *common.Address
+	Input     []byte
+	Container *Container // Parsed EOF container, nil for legacy code
+
+	// IsDeployment is set for initcode, which isn't part of the state and
+	// thus not charged for its code chunks in verkle mode.
+	IsDeployment bool
 
 	Gas   uint64
 	value *uint256.Int
@@ -91,6 +97,10 @@ func (c *Contract) validJumpdest(dest *uint256.Int) bool {
 	if OpCode(c.Code[udest]) != JUMPDEST {
 		return false
 	}
+	// Every JUMPDEST outside of push data starts a basic block
+	if c.blocks != nil {
+		return c.blocks.find(udest) >= 0
+	}
 	return c.isCode(udest)
 }
 
@@ -148,6 +158,23 @@ func (c *Contract) GetOp(n uint64) OpCode {
 	return STOP
 }
 
+// CodeAt returns the given code section of an EOF contract, or the entire code
+// of a legacy contract.
+func (c *Contract) CodeAt(section uint64) []byte {
+	if c.Container == nil {
+		return c.Code
+	}
+	return c.Container.codeSections[section]
+}
+
+// getSectionOp returns the n'th element of the given code section.
+func (c *Contract) getSectionOp(section uint64, n uint64) OpCode {
+	if code := c.CodeAt(section); n < uint64(len(code)) {
+		return OpCode(code[n])
+	}
+	return STOP
+}
+
 // Caller returns the caller of the contract.
 //
 // Caller will recursively call caller when the contract is a delegate
@@ -21,11 +21,13 @@ import (
 	"sort"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/holiman/uint256"
 )
 
 var activators = map[int]func(*JumpTable){
+	7702: enable7702,
 	5656: enable5656,
 	6780: enable6780,
 	3855: enable3855,
@@ -319,3 +321,263 @@ func enable6780(jt *JumpTable) {
 		maxStack:    maxStack(1, 0),
 	}
 }
+
+// enable7702 applies the EIP-7702 changes to support delegation designators.
+func enable7702(jt *JumpTable) {
+	jt[CALL].dynamicGas = gasCallEIP7702
+	jt[CALLCODE].dynamicGas = gasCallCodeEIP7702
+	jt[STATICCALL].dynamicGas = gasStaticCallEIP7702
+	jt[DELEGATECALL].dynamicGas = gasDelegateCallEIP7702
+}
+
+// enable4762 applies the EIP-4762 "Statelessness gas cost changes", which
+// charge for the verkle tree leaves that a stateless client needs in the
+// witness instead of the EIP-2929 access list costs.
+func enable4762(jt *JumpTable) {
+	jt[SSTORE].dynamicGas = gasSStore4762
+	jt[SLOAD].dynamicGas = gasSLoad4762
+
+	jt[BALANCE].constantGas = 0
+	jt[BALANCE].dynamicGas = gasBalance4762
+
+	jt[EXTCODESIZE].constantGas = 0
+	jt[EXTCODESIZE].dynamicGas = gasExtCodeSize4762
+
+	jt[EXTCODEHASH].constantGas = 0
+	jt[EXTCODEHASH].dynamicGas = gasExtCodeHash4762
+
+	jt[EXTCODECOPY].constantGas = 0
+	jt[EXTCODECOPY].dynamicGas = gasExtCodeCopyEIP4762
+
+	jt[CODECOPY].dynamicGas = gasCodeCopyEip4762
+	jt[BLOCKHASH].dynamicGas = gasBlockHash4762
+
+	jt[SELFDESTRUCT].dynamicGas = gasSelfdestructEIP4762
+
+	jt[CREATE].constantGas = params.CreateNGasEip4762
+	jt[CREATE2].constantGas = params.CreateNGasEip4762
+
+	jt[CALL].constantGas = 0
+	jt[CALL].dynamicGas = gasCallEIP4762
+
+	jt[CALLCODE].constantGas = 0
+	jt[CALLCODE].dynamicGas = gasCallCodeEIP4762
+
+	jt[STATICCALL].constantGas = 0
+	jt[STATICCALL].dynamicGas = gasStaticCallEIP4762
+
+	jt[DELEGATECALL].constantGas = 0
+	jt[DELEGATECALL].dynamicGas = gasDelegateCallEIP4762
+}
+
+// enable3540 applies the EIP-3540 changes to legacy code: the code of EOF
+// contracts is not introspectable, and reads as the two byte magic instead.
+func enable3540(jt *JumpTable) {
+	jt[EXTCODESIZE].execute = opExtCodeSizeEIP3540
+	jt[EXTCODECOPY].execute = opExtCodeCopyEIP3540
+	jt[EXTCODEHASH].execute = opExtCodeHashEIP3540
+}
+
+// opExtCodeSizeEIP3540 implements EXTCODESIZE, reporting EOF code as the magic.
+func opExtCodeSizeEIP3540(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	slot := scope.Stack.peek()
+	code := interpreter.evm.StateDB.GetCode(slot.Bytes20())
+	if HasEOFMagic(code) {
+		code = eofMagic
+	}
+	slot.SetUint64(uint64(len(code)))
+	return nil, nil
+}
+
+// opExtCodeCopyEIP3540 implements EXTCODECOPY, copying EOF code as the magic.
+func opExtCodeCopyEIP3540(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		stack      = scope.Stack
+		a          = stack.pop()
+		memOffset  = stack.pop()
+		codeOffset = stack.pop()
+		length     = stack.pop()
+	)
+	uint64CodeOffset, overflow := codeOffset.Uint64WithOverflow()
+	if overflow {
+		uint64CodeOffset = math.MaxUint64
+	}
+	code := interpreter.evm.StateDB.GetCode(a.Bytes20())
+	if HasEOFMagic(code) {
+		code = eofMagic
+	}
+	scope.Memory.Set(memOffset.Uint64(), length.Uint64(), getData(code, uint64CodeOffset, length.Uint64()))
+	return nil, nil
+}
+
+// opExtCodeHashEIP3540 implements EXTCODEHASH, hashing EOF code as the magic.
+func opExtCodeHashEIP3540(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	slot := scope.Stack.peek()
+	address := common.Address(slot.Bytes20())
+	switch {
+	case interpreter.evm.StateDB.Empty(address):
+		slot.Clear()
+	case HasEOFMagic(interpreter.evm.StateDB.GetCode(address)):
+		slot.SetBytes(eofCodeHash.Bytes())
+	default:
+		slot.SetBytes(interpreter.evm.StateDB.GetCodeHash(address).Bytes())
+	}
+	return nil, nil
+}
+
+// enableEOF turns a legacy jump table into the one EOF code is validated and
+// executed against: the instructions of EIP-4200 (static relative jumps),
+// EIP-4750 (functions), EIP-6206 (JUMPF), EIP-663 (DUPN, SWAPN, EXCHANGE),
+// EIP-7480 (data section access), EIP-7069 (EXT*CALL) and EIP-7620 (EOF
+// contract creation) are defined, whereas the instructions inspecting code,
+// gas or the program counter, as well as the legacy calls and creations, are
+// undefined.
+func enableEOF(jt *JumpTable) {
+	undefined := &operation{
+		execute:   opUndefined,
+		maxStack:  maxStack(0, 0),
+		undefined: true,
+	}
+	for _, op := range []OpCode{
+		CALL, CALLCODE, DELEGATECALL, STATICCALL, SELFDESTRUCT,
+		JUMP, JUMPI, PC, CREATE, CREATE2, GAS,
+		CODESIZE, CODECOPY, EXTCODESIZE, EXTCODECOPY, EXTCODEHASH,
+	} {
+		jt[op] = undefined
+	}
+	jt[RETURNDATACOPY] = &operation{
+		execute:     opReturnDataCopyEOF,
+		constantGas: GasFastestStep,
+		dynamicGas:  gasReturnDataCopy,
+		minStack:    minStack(3, 0),
+		maxStack:    maxStack(3, 0),
+		memorySize:  memoryReturnDataCopy,
+	}
+	jt[RJUMP] = &operation{
+		execute:     opRjump,
+		constantGas: GasQuickStep,
+		minStack:    minStack(0, 0),
+		maxStack:    maxStack(0, 0),
+	}
+	jt[RJUMPI] = &operation{
+		execute:     opRjumpi,
+		constantGas: GasFastishStep,
+		minStack:    minStack(1, 0),
+		maxStack:    maxStack(1, 0),
+	}
+	jt[RJUMPV] = &operation{
+		execute:     opRjumpv,
+		constantGas: GasFastishStep,
+		minStack:    minStack(1, 0),
+		maxStack:    maxStack(1, 0),
+	}
+	// The stack effects of the following instructions depend on their
+	// immediates, they are checked by the code validation and the instruction.
+	jt[CALLF] = &operation{
+		execute:     opCallf,
+		constantGas: GasFastStep,
+		minStack:    minStack(0, 0),
+		maxStack:    maxStack(0, 0),
+	}
+	jt[RETF] = &operation{
+		execute:     opRetf,
+		constantGas: GasFastestStep,
+		minStack:    minStack(0, 0),
+		maxStack:    maxStack(0, 0),
+	}
+	jt[JUMPF] = &operation{
+		execute:     opJumpf,
+		constantGas: GasFastStep,
+		minStack:    minStack(0, 0),
+		maxStack:    maxStack(0, 0),
+	}
+	jt[DUPN] = &operation{
+		execute:     opDupN,
+		constantGas: GasFastestStep,
+		minStack:    minStack(0, 0),
+		maxStack:    maxStack(0, 1),
+	}
+	jt[SWAPN] = &operation{
+		execute:     opSwapN,
+		constantGas: GasFastestStep,
+		minStack:    minStack(0, 0),
+		maxStack:    maxStack(0, 0),
+	}
+	jt[EXCHANGE] = &operation{
+		execute:     opExchange,
+		constantGas: GasFastestStep,
+		minStack:    minStack(0, 0),
+		maxStack:    maxStack(0, 0),
+	}
+	jt[DATALOAD] = &operation{
+		execute:     opDataLoad,
+		constantGas: GasFastishStep,
+		minStack:    minStack(1, 1),
+		maxStack:    maxStack(1, 1),
+	}
+	jt[DATALOADN] = &operation{
+		execute:     opDataLoadN,
+		constantGas: GasFastestStep,
+		minStack:    minStack(0, 1),
+		maxStack:    maxStack(0, 1),
+	}
+	jt[DATASIZE] = &operation{
+		execute:     opDataSize,
+		constantGas: GasQuickStep,
+		minStack:    minStack(0, 1),
+		maxStack:    maxStack(0, 1),
+	}
+	jt[DATACOPY] = &operation{
+		execute:     opDataCopy,
+		constantGas: GasFastestStep,
+		dynamicGas:  memoryCopierGas(2),
+		minStack:    minStack(3, 0),
+		maxStack:    maxStack(3, 0),
+		memorySize:  memoryDataCopy,
+	}
+	jt[RETURNDATALOAD] = &operation{
+		execute:     opReturnDataLoad,
+		constantGas: GasFastestStep,
+		minStack:    minStack(1, 1),
+		maxStack:    maxStack(1, 1),
+	}
+	jt[EXTCALL] = &operation{
+		execute:     opExtCall,
+		constantGas: params.WarmStorageReadCostEIP2929,
+		dynamicGas:  gasExtCall,
+		minStack:    minStack(4, 1),
+		maxStack:    maxStack(4, 1),
+		memorySize:  memoryExtCall,
+	}
+	jt[EXTDELEGATECALL] = &operation{
+		execute:     opExtDelegateCall,
+		constantGas: params.WarmStorageReadCostEIP2929,
+		dynamicGas:  gasExtDelegateCall,
+		minStack:    minStack(3, 1),
+		maxStack:    maxStack(3, 1),
+		memorySize:  memoryExtCall,
+	}
+	jt[EXTSTATICCALL] = &operation{
+		execute:     opExtStaticCall,
+		constantGas: params.WarmStorageReadCostEIP2929,
+		dynamicGas:  gasExtStaticCall,
+		minStack:    minStack(3, 1),
+		maxStack:    maxStack(3, 1),
+		memorySize:  memoryExtCall,
+	}
+	jt[EOFCREATE] = &operation{
+		execute:     opEOFCreate,
+		constantGas: params.CreateGas,
+		dynamicGas:  pureMemoryGascost,
+		minStack:    minStack(4, 1),
+		maxStack:    maxStack(4, 1),
+		memorySize:  memoryEOFCreate,
+	}
+	jt[RETURNCONTRACT] = &operation{
+		execute:    opReturnContract,
+		dynamicGas: pureMemoryGascost,
+		minStack:   minStack(2, 0),
+		maxStack:   maxStack(2, 0),
+		memorySize: memoryReturn,
+	}
+}
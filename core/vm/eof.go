@@ -0,0 +1,415 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	offsetVersion   = 2
+	offsetTypesKind = 3
+	offsetCodeKind  = 6
+
+	kindTypes     = 1
+	kindCode      = 2
+	kindContainer = 3
+	kindData      = 0xff
+
+	eof1Version = 1
+
+	maxInputItems        = 127
+	maxOutputItems       = 127
+	maxStackIncrease     = 1023
+	maxCodeSections      = 1024
+	maxContainerSections = 256
+
+	nonReturningFunction = 0x80
+)
+
+var (
+	errInvalidMagic                = errors.New("invalid magic")
+	errUndefinedInstruction        = errors.New("undefined instruction")
+	errTruncatedImmediate          = errors.New("truncated immediate")
+	errInvalidSectionArgument      = errors.New("invalid section argument")
+	errInvalidContainerArgument    = errors.New("invalid container argument")
+	errInvalidCallArgument         = errors.New("callf into non-returning section")
+	errInvalidDataloadNArgument    = errors.New("invalid dataloadN argument")
+	errInvalidJumpDest             = errors.New("invalid jump destination")
+	errInvalidBackwardJump         = errors.New("invalid backward jump")
+	errInvalidOutputs              = errors.New("invalid number of outputs")
+	errInvalidMaxStackHeight       = errors.New("invalid max stack height")
+	errInvalidCodeTermination      = errors.New("invalid code termination")
+	errEOFCreateWithTruncated      = errors.New("eofcreate with truncated container")
+	errOrphanedSubcontainer        = errors.New("subcontainer not referenced at all")
+	errIncompatibleContainerKind   = errors.New("incompatible container kind")
+	errStopInInitCode              = errors.New("initcode contains a RETURN or STOP opcode")
+	errTruncatedTopLevelContainer  = errors.New("truncated top level container")
+	errUnreachableCode             = errors.New("unreachable code")
+	errInvalidNonReturning         = errors.New("invalid non-returning flag, bad RETF")
+	errInvalidVersion              = errors.New("invalid version")
+	errMissingTypeHeader           = errors.New("missing type header")
+	errInvalidTypeSize             = errors.New("invalid type section size")
+	errMissingCodeHeader           = errors.New("missing code header")
+	errInvalidCodeSize             = errors.New("invalid code size")
+	errInvalidContainerSectionSize = errors.New("invalid container section size")
+	errMissingDataHeader           = errors.New("missing data header")
+	errMissingTerminator           = errors.New("missing header terminator")
+	errTooManyInputs               = errors.New("invalid type content, too many inputs")
+	errTooManyOutputs              = errors.New("invalid type content, too many outputs")
+	errInvalidSection0Type         = errors.New("invalid section 0 type, input and output should be zero and non-returning (0x80)")
+	errTooLargeMaxStackIncrease    = errors.New("invalid type content, max stack increase exceeds limit")
+	errInvalidContainerSize        = errors.New("invalid container size")
+	errStackUnderflow              = errors.New("stack underflow")
+	errStackOverflow               = errors.New("stack overflow")
+	errUnreachableCodeSections     = errors.New("unreachable code sections")
+	errReturnStackExceeded         = errors.New("return stack limit reached")
+)
+
+var eofMagic = []byte{0xef, 0x00}
+
+// eofCodeHash is the code hash reported by legacy code introspecting an EOF
+// contract, which only sees the magic bytes.
+var eofCodeHash = crypto.Keccak256Hash(eofMagic)
+
+// HasEOFMagic returns true if code starts with magic defined by EIP-3540
+func HasEOFMagic(code []byte) bool {
+	return len(eofMagic) <= len(code) && bytes.Equal(eofMagic, code[0:len(eofMagic)])
+}
+
+// Container is an EOF container object.
+type Container struct {
+	types             []*functionMetadata
+	codeSections      [][]byte
+	subContainerCodes [][]byte
+	data              []byte
+	dataSize          int // might be more than len(data)
+}
+
+// functionMetadata is an EOF function signature.
+type functionMetadata struct {
+	inputs           uint8
+	outputs          uint8
+	maxStackIncrease uint16
+}
+
+// returning reports whether the function returns control to its caller.
+func (meta *functionMetadata) returning() bool {
+	return meta.outputs != nonReturningFunction
+}
+
+// CodeSections returns the code sections of the container.
+func (c *Container) CodeSections() [][]byte {
+	return c.codeSections
+}
+
+// SectionType returns the number of stack inputs and outputs, the maximum stack
+// increase of the given code section and whether it returns to its caller. The
+// outputs are meaningless for non-returning sections.
+func (c *Container) SectionType(section int) (inputs, outputs, maxStackIncrease int, returning bool) {
+	meta := c.types[section]
+	return int(meta.inputs), int(meta.outputs), int(meta.maxStackIncrease), meta.returning()
+}
+
+// SubContainers returns the encoded subcontainers of the container.
+func (c *Container) SubContainers() [][]byte {
+	return c.subContainerCodes
+}
+
+// Data returns the data section of the container.
+func (c *Container) Data() []byte {
+	return c.data
+}
+
+// MarshalBinary encodes an EOF container into binary format.
+func (c *Container) MarshalBinary() []byte {
+	// Build header.
+	b := make([]byte, 2)
+	copy(b, eofMagic)
+	b = append(b, eof1Version)
+	b = append(b, kindTypes)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(c.types)*4))
+	b = append(b, kindCode)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(c.codeSections)))
+	for _, code := range c.codeSections {
+		b = binary.BigEndian.AppendUint16(b, uint16(len(code)))
+	}
+	if len(c.subContainerCodes) != 0 {
+		b = append(b, kindContainer)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(c.subContainerCodes)))
+		for _, section := range c.subContainerCodes {
+			b = binary.BigEndian.AppendUint32(b, uint32(len(section)))
+		}
+	}
+	b = append(b, kindData)
+	b = binary.BigEndian.AppendUint16(b, uint16(c.dataSize))
+	b = append(b, 0) // terminator
+
+	// Write section contents.
+	for _, ty := range c.types {
+		b = append(b, []byte{ty.inputs, ty.outputs, byte(ty.maxStackIncrease >> 8), byte(ty.maxStackIncrease & 0x00ff)}...)
+	}
+	for _, code := range c.codeSections {
+		b = append(b, code...)
+	}
+	for _, section := range c.subContainerCodes {
+		b = append(b, section...)
+	}
+	b = append(b, c.data...)
+
+	return b
+}
+
+// UnmarshalBinary decodes an EOF container. The data section may be truncated
+// with respect to the size declared in the header, which is only allowed for
+// containers still to be deployed; it is up to validation to reject it in
+// other contexts.
+func (c *Container) UnmarshalBinary(b []byte) error {
+	size, err := c.unmarshal(b, false)
+	if err != nil {
+		return err
+	}
+	if size != len(b) {
+		return fmt.Errorf("%w: have %d, want %d", errInvalidContainerSize, len(b), size)
+	}
+	return nil
+}
+
+// unmarshal decodes an EOF container from the start of b and returns the
+// number of bytes it occupies. If complete is set, the data section must be
+// of the declared size and b may carry trailing bytes beyond the container,
+// as the calldata of a creation transaction does (EIP-7698).
+func (c *Container) unmarshal(b []byte, complete bool) (int, error) {
+	if !HasEOFMagic(b) {
+		return 0, fmt.Errorf("%w: want %x", errInvalidMagic, eofMagic)
+	}
+	if len(b) < 15 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if b[offsetVersion] != eof1Version {
+		return 0, fmt.Errorf("%w: have %d, want %d", errInvalidVersion, b[offsetVersion], eof1Version)
+	}
+	var (
+		kind, typesSize, dataSize int
+		codeSizes                 []int
+		containerSizes            []int
+		err                       error
+	)
+	// Parse type section header.
+	kind, typesSize, err = parseSection(b, offsetTypesKind)
+	if err != nil {
+		return 0, err
+	}
+	if kind != kindTypes {
+		return 0, fmt.Errorf("%w: found section kind %x instead", errMissingTypeHeader, kind)
+	}
+	if typesSize < 4 || typesSize%4 != 0 {
+		return 0, fmt.Errorf("%w: type section size must be divisible by 4, have %d", errInvalidTypeSize, typesSize)
+	}
+	if typesSize/4 > maxCodeSections {
+		return 0, fmt.Errorf("%w: type section must not exceed 4*%d, have %d", errInvalidTypeSize, maxCodeSections, typesSize)
+	}
+	// Parse code section header.
+	kind, codeSizes, err = parseSectionList(b, offsetCodeKind, 2)
+	if err != nil {
+		return 0, err
+	}
+	if kind != kindCode {
+		return 0, fmt.Errorf("%w: found section kind %x instead", errMissingCodeHeader, kind)
+	}
+	if len(codeSizes) != typesSize/4 {
+		return 0, fmt.Errorf("%w: mismatch of code sections found and type signatures, types %d, code %d", errInvalidCodeSize, typesSize/4, len(codeSizes))
+	}
+	// Parse an optional container section header.
+	offset := offsetCodeKind + 2 + 2*len(codeSizes) + 1
+	if offset < len(b) && b[offset] == kindContainer {
+		kind, containerSizes, err = parseSectionList(b, offset, 4)
+		if err != nil {
+			return 0, err
+		}
+		if len(containerSizes) > maxContainerSections {
+			return 0, fmt.Errorf("%w: number of container sections must not exceed %d, have %d", errInvalidContainerSectionSize, maxContainerSections, len(containerSizes))
+		}
+		offset += 2 + 4*len(containerSizes) + 1
+	}
+	// Parse data section header.
+	kind, dataSize, err = parseSection(b, offset)
+	if err != nil {
+		return 0, err
+	}
+	if kind != kindData {
+		return 0, fmt.Errorf("%w: found section %x instead", errMissingDataHeader, kind)
+	}
+	c.dataSize = dataSize
+
+	// Check for terminator.
+	offsetTerminator := offset + 3
+	if len(b) <= offsetTerminator {
+		return 0, fmt.Errorf("%w: invalid offset terminator", io.ErrUnexpectedEOF)
+	}
+	if b[offsetTerminator] != 0 {
+		return 0, fmt.Errorf("%w: have %x", errMissingTerminator, b[offsetTerminator])
+	}
+	// Verify the body is large enough to hold the declared sections.
+	bodySize := typesSize
+	for _, size := range codeSizes {
+		bodySize += size
+	}
+	for _, size := range containerSizes {
+		bodySize += size
+	}
+	idx := offsetTerminator + 1
+	if len(b) < idx+bodySize {
+		return 0, fmt.Errorf("%w: have %d, want at least %d", errInvalidContainerSize, len(b), idx+bodySize)
+	}
+	// Parse types section.
+	var types = make([]*functionMetadata, 0, typesSize/4)
+	for i := 0; i < typesSize/4; i++ {
+		sig := &functionMetadata{
+			inputs:           b[idx+i*4],
+			outputs:          b[idx+i*4+1],
+			maxStackIncrease: binary.BigEndian.Uint16(b[idx+i*4+2:]),
+		}
+		if sig.inputs > maxInputItems {
+			return 0, fmt.Errorf("%w for section %d: have %d", errTooManyInputs, i, sig.inputs)
+		}
+		if sig.outputs > maxOutputItems && sig.outputs != nonReturningFunction {
+			return 0, fmt.Errorf("%w for section %d: have %d", errTooManyOutputs, i, sig.outputs)
+		}
+		if sig.maxStackIncrease > maxStackIncrease {
+			return 0, fmt.Errorf("%w for section %d: have %d", errTooLargeMaxStackIncrease, i, sig.maxStackIncrease)
+		}
+		types = append(types, sig)
+	}
+	if types[0].inputs != 0 || types[0].outputs != nonReturningFunction {
+		return 0, fmt.Errorf("%w: have %d, %d", errInvalidSection0Type, types[0].inputs, types[0].outputs)
+	}
+	c.types = types
+	idx += typesSize
+
+	// Parse code sections.
+	c.codeSections = make([][]byte, len(codeSizes))
+	for i, size := range codeSizes {
+		c.codeSections[i] = b[idx : idx+size]
+		idx += size
+	}
+	// Parse the subcontainers, they are decoded and validated on demand.
+	c.subContainerCodes = nil
+	if len(containerSizes) > 0 {
+		c.subContainerCodes = make([][]byte, len(containerSizes))
+		for i, size := range containerSizes {
+			c.subContainerCodes[i] = b[idx : idx+size]
+			idx += size
+		}
+	}
+	// Parse the data section, which may be truncated unless the container
+	// must be complete.
+	end := idx + dataSize
+	if len(b) < end {
+		if complete {
+			return 0, fmt.Errorf("%w: have %d, want %d", errInvalidContainerSize, len(b), end)
+		}
+		end = len(b)
+	}
+	c.data = b[idx:end]
+
+	return end, nil
+}
+
+// subContainer decodes the subcontainer at the given index.
+func (c *Container) subContainer(idx int) (*Container, error) {
+	sub := new(Container)
+	if err := sub.UnmarshalBinary(c.subContainerCodes[idx]); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// withAuxData returns the encoding of the container with the given auxiliary
+// data appended to its data section, as deployed by RETURNCONTRACT. The data
+// section must be complete afterwards.
+func (c *Container) withAuxData(aux []byte) ([]byte, error) {
+	size := len(c.data) + len(aux)
+	if size < c.dataSize || size > 0xffff {
+		return nil, fmt.Errorf("%w: data section size %d, declared %d", errInvalidContainerSize, size, c.dataSize)
+	}
+	deployed := *c
+	deployed.data = append(append(make([]byte, 0, size), c.data...), aux...)
+	deployed.dataSize = size
+	return deployed.MarshalBinary(), nil
+}
+
+// parseSection decodes a (kind, size) pair from an EOF header.
+func parseSection(b []byte, idx int) (kind, size int, err error) {
+	if idx+3 >= len(b) {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	kind = int(b[idx])
+	size = int(binary.BigEndian.Uint16(b[idx+1 : idx+3]))
+	return kind, size, nil
+}
+
+// parseSectionList decodes a (kind, len, []sizes) tuple from an EOF header,
+// where each size is encoded in the given number of bytes.
+func parseSectionList(b []byte, idx int, width int) (kind int, list []int, err error) {
+	if idx >= len(b) {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	kind = int(b[idx])
+	list, err = decodeSizeList(b, idx+1, width)
+	if err != nil {
+		return 0, nil, err
+	}
+	return kind, list, nil
+}
+
+// decodeSizeList decodes a list of section sizes, prefixed by their 2-byte
+// count, from an EOF header.
+func decodeSizeList(b []byte, idx int, width int) ([]int, error) {
+	if len(b) < idx+2 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	count := int(binary.BigEndian.Uint16(b[idx : idx+2]))
+	if count == 0 {
+		return nil, fmt.Errorf("%w: section list must not be empty", errInvalidCodeSize)
+	}
+	if count > maxCodeSections {
+		return nil, fmt.Errorf("%w: list too long, have %d", errInvalidCodeSize, count)
+	}
+	if len(b) <= idx+2+count*width {
+		return nil, io.ErrUnexpectedEOF
+	}
+	list := make([]int, count)
+	for i := 0; i < count; i++ {
+		pos := idx + 2 + width*i
+		if width == 2 {
+			list[i] = int(binary.BigEndian.Uint16(b[pos:]))
+		} else {
+			list[i] = int(binary.BigEndian.Uint32(b[pos:]))
+		}
+		if list[i] == 0 {
+			return nil, fmt.Errorf("%w: section %d size must not be 0", errInvalidCodeSize, i)
+		}
+	}
+	return list, nil
+}
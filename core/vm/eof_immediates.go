@@ -0,0 +1,57 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+// immediates define the number of immediate bytes following an opcode in EOF
+// code.
+var immediates [256]uint8
+
+// terminals are the opcodes ending the execution of a code section.
+var terminals [256]bool
+
+func init() {
+	for i := 1; i <= 32; i++ {
+		immediates[int(PUSH0)+i] = uint8(i)
+	}
+	immediates[DATALOADN] = 2
+	immediates[RJUMP] = 2
+	immediates[RJUMPI] = 2
+	immediates[RJUMPV] = 3
+	immediates[CALLF] = 2
+	immediates[JUMPF] = 2
+	immediates[DUPN] = 1
+	immediates[SWAPN] = 1
+	immediates[EXCHANGE] = 1
+	immediates[EOFCREATE] = 1
+	immediates[RETURNCONTRACT] = 1
+
+	terminals[RETF] = true
+	terminals[JUMPF] = true
+	terminals[STOP] = true
+	terminals[RETURN] = true
+	terminals[RETURNCONTRACT] = true
+	terminals[REVERT] = true
+	terminals[INVALID] = true
+}
+
+// Immediates returns the number of immediate bytes (arguments taken from the
+// code rather than the stack) following the given opcode in EOF code. For
+// RJUMPV, which has a variable sized immediate determined by the count byte
+// following the opcode, the minimum size of 3 is returned.
+func Immediates(op OpCode) int {
+	return int(immediates[op])
+}
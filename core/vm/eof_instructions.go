@@ -0,0 +1,426 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// ReturnContext is the position a CALLF returns to once the called code
+// section executes RETF.
+type ReturnContext struct {
+	Section uint64
+	Pc      uint64
+}
+
+// opRjump implements the RJUMP opcode (EIP-4200).
+func opRjump(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	if interpreter.evm.abort.Load() {
+		return nil, errStopToken
+	}
+	code := scope.Contract.CodeAt(scope.CodeSection)
+	offset := parseInt16(code[*pc+1:])
+	// The pc is incremented by the interpreter after the instruction.
+	*pc = uint64(int64(*pc+3)+int64(offset)) - 1
+	return nil, nil
+}
+
+// opRjumpi implements the RJUMPI opcode (EIP-4200).
+func opRjumpi(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	if interpreter.evm.abort.Load() {
+		return nil, errStopToken
+	}
+	cond := scope.Stack.pop()
+	if cond.IsZero() {
+		*pc += 2
+		return nil, nil
+	}
+	return opRjump(pc, interpreter, scope)
+}
+
+// opRjumpv implements the RJUMPV opcode (EIP-4200), falling through if the
+// case index is out of range of the jump table.
+func opRjumpv(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	if interpreter.evm.abort.Load() {
+		return nil, errStopToken
+	}
+	var (
+		code  = scope.Contract.CodeAt(scope.CodeSection)
+		count = uint64(code[*pc+1]) + 1
+		index = scope.Stack.pop()
+		next  = *pc + 2 + 2*count
+	)
+	if !index.LtUint64(count) {
+		*pc = next - 1
+		return nil, nil
+	}
+	offset := parseInt16(code[*pc+2+2*index.Uint64():])
+	*pc = uint64(int64(next)+int64(offset)) - 1
+	return nil, nil
+}
+
+// opCallf implements the CALLF opcode (EIP-4750), entering the target code
+// section with the return position recorded on the return stack.
+func opCallf(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		code    = scope.Contract.CodeAt(scope.CodeSection)
+		section = uint64(binary.BigEndian.Uint16(code[*pc+1:]))
+		meta    = scope.Contract.Container.types[section]
+	)
+	if scope.Stack.len()+int(meta.maxStackIncrease) > int(params.StackLimit) {
+		return nil, &ErrStackOverflow{stackLen: scope.Stack.len(), limit: int(params.StackLimit) - int(meta.maxStackIncrease)}
+	}
+	if len(scope.ReturnStack) >= int(params.StackLimit) {
+		return nil, errReturnStackExceeded
+	}
+	scope.ReturnStack = append(scope.ReturnStack, &ReturnContext{Section: scope.CodeSection, Pc: *pc + 3})
+	scope.CodeSection = section
+	*pc = ^uint64(0) // wraps to the first instruction once incremented
+	return nil, nil
+}
+
+// opRetf implements the RETF opcode (EIP-4750).
+func opRetf(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	ret := scope.ReturnStack[len(scope.ReturnStack)-1]
+	scope.ReturnStack = scope.ReturnStack[:len(scope.ReturnStack)-1]
+	scope.CodeSection = ret.Section
+	*pc = ret.Pc - 1
+	return nil, nil
+}
+
+// opJumpf implements the JUMPF opcode (EIP-6206), a tail call into the target
+// code section which leaves the return stack untouched.
+func opJumpf(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		code    = scope.Contract.CodeAt(scope.CodeSection)
+		section = uint64(binary.BigEndian.Uint16(code[*pc+1:]))
+		meta    = scope.Contract.Container.types[section]
+	)
+	if scope.Stack.len()+int(meta.maxStackIncrease) > int(params.StackLimit) {
+		return nil, &ErrStackOverflow{stackLen: scope.Stack.len(), limit: int(params.StackLimit) - int(meta.maxStackIncrease)}
+	}
+	scope.CodeSection = section
+	*pc = ^uint64(0) // wraps to the first instruction once incremented
+	return nil, nil
+}
+
+// opDupN implements the DUPN opcode (EIP-663).
+func opDupN(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	code := scope.Contract.CodeAt(scope.CodeSection)
+	n := int(code[*pc+1]) + 1
+	if scope.Stack.len() < n {
+		return nil, &ErrStackUnderflow{stackLen: scope.Stack.len(), required: n}
+	}
+	scope.Stack.dup(n)
+	*pc += 1
+	return nil, nil
+}
+
+// opSwapN implements the SWAPN opcode (EIP-663).
+func opSwapN(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	code := scope.Contract.CodeAt(scope.CodeSection)
+	n := int(code[*pc+1]) + 1
+	if scope.Stack.len() <= n {
+		return nil, &ErrStackUnderflow{stackLen: scope.Stack.len(), required: n + 1}
+	}
+	scope.Stack.swap(n + 1)
+	*pc += 1
+	return nil, nil
+}
+
+// opExchange implements the EXCHANGE opcode (EIP-663), swapping the stack
+// items at depths n+1 and n+m+1 as encoded by the immediate nibbles.
+func opExchange(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		code = scope.Contract.CodeAt(scope.CodeSection)
+		n    = int(code[*pc+1]>>4) + 1
+		m    = int(code[*pc+1]&0x0f) + 1
+		data = scope.Stack.data
+		top  = len(data) - 1
+	)
+	if len(data) <= n+m {
+		return nil, &ErrStackUnderflow{stackLen: len(data), required: n + m + 1}
+	}
+	data[top-n], data[top-n-m] = data[top-n-m], data[top-n]
+	*pc += 1
+	return nil, nil
+}
+
+// opDataLoad implements the DATALOAD opcode (EIP-7480).
+func opDataLoad(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		offset = scope.Stack.peek()
+		data   = scope.Contract.Container.data
+	)
+	start, overflow := offset.Uint64WithOverflow()
+	if overflow {
+		start = uint64(len(data))
+	}
+	offset.SetBytes32(getData(data, start, 32))
+	return nil, nil
+}
+
+// opDataLoadN implements the DATALOADN opcode (EIP-7480), whose offset is
+// validated to lie within the data section.
+func opDataLoadN(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		code   = scope.Contract.CodeAt(scope.CodeSection)
+		offset = uint64(binary.BigEndian.Uint16(code[*pc+1:]))
+	)
+	scope.Stack.push(new(uint256.Int).SetBytes32(getData(scope.Contract.Container.data, offset, 32)))
+	*pc += 2
+	return nil, nil
+}
+
+// opDataSize implements the DATASIZE opcode (EIP-7480).
+func opDataSize(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	scope.Stack.push(new(uint256.Int).SetUint64(uint64(len(scope.Contract.Container.data))))
+	return nil, nil
+}
+
+// opDataCopy implements the DATACOPY opcode (EIP-7480).
+func opDataCopy(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		memOffset = scope.Stack.pop()
+		offset    = scope.Stack.pop()
+		size      = scope.Stack.pop()
+	)
+	start, overflow := offset.Uint64WithOverflow()
+	if overflow {
+		start = uint64(len(scope.Contract.Container.data))
+	}
+	scope.Memory.Set(memOffset.Uint64(), size.Uint64(), getData(scope.Contract.Container.data, start, size.Uint64()))
+	return nil, nil
+}
+
+// opReturnDataLoad implements the RETURNDATALOAD opcode (EIP-7069), reading
+// zeroes past the end of the return data.
+func opReturnDataLoad(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	offset := scope.Stack.peek()
+	start, overflow := offset.Uint64WithOverflow()
+	if overflow {
+		start = uint64(len(interpreter.returnData))
+	}
+	offset.SetBytes32(getData(interpreter.returnData, start, 32))
+	return nil, nil
+}
+
+// opReturnDataCopyEOF implements RETURNDATACOPY for EOF code, which pads out
+// of bounds reads with zeroes instead of failing (EIP-7069).
+func opReturnDataCopyEOF(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		memOffset  = scope.Stack.pop()
+		dataOffset = scope.Stack.pop()
+		length     = scope.Stack.pop()
+	)
+	start, overflow := dataOffset.Uint64WithOverflow()
+	if overflow {
+		start = uint64(len(interpreter.returnData))
+	}
+	scope.Memory.Set(memOffset.Uint64(), length.Uint64(), getData(interpreter.returnData, start, length.Uint64()))
+	return nil, nil
+}
+
+// EXT*CALL status codes pushed onto the stack (EIP-7069).
+const (
+	extCallSuccess = 0
+	extCallRevert  = 1 // reverted, or failed before the callee was entered
+	extCallFailure = 2
+)
+
+// extCallStatus converts the outcome of a message call into an EXT*CALL status
+// code. Calls that never entered the callee count as reverted.
+func extCallStatus(err error) uint64 {
+	switch err {
+	case nil:
+		return extCallSuccess
+	case ErrExecutionReverted, ErrDepth, ErrInsufficientBalance:
+		return extCallRevert
+	}
+	return extCallFailure
+}
+
+// popExtCallTarget pops the target address of an EXT*CALL, which must not have
+// any of its upper 12 bytes set.
+func popExtCallTarget(stack *Stack) (common.Address, error) {
+	target := stack.pop()
+	if target.BitLen() > 160 {
+		return common.Address{}, ErrAddressOutOfRange
+	}
+	return common.Address(target.Bytes20()), nil
+}
+
+// opExtCall implements the EXTCALL opcode (EIP-7069).
+func opExtCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	stack := scope.Stack
+	toAddr, err := popExtCallTarget(stack)
+	if err != nil {
+		return nil, err
+	}
+	inOffset, inSize, value := stack.pop(), stack.pop(), stack.pop()
+	if interpreter.readOnly && !value.IsZero() {
+		return nil, ErrWriteProtection
+	}
+	var (
+		gas    = interpreter.evm.callGasTemp
+		ret    []byte
+		status = uint64(extCallRevert)
+	)
+	if gas != 0 {
+		args := scope.Memory.GetPtr(int64(inOffset.Uint64()), int64(inSize.Uint64()))
+
+		var returnGas uint64
+		ret, returnGas, err = interpreter.evm.Call(scope.Contract, toAddr, args, gas, &value)
+		if err == ErrExecutionSuspended {
+			return nil, err
+		}
+		status = extCallStatus(err)
+		scope.Contract.Gas += returnGas
+	}
+	stack.push(new(uint256.Int).SetUint64(status))
+
+	interpreter.returnData = ret
+	return ret, nil
+}
+
+// opExtDelegateCall implements the EXTDELEGATECALL opcode (EIP-7069). Only EOF
+// code may be delegated to, other targets revert without being entered.
+func opExtDelegateCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	stack := scope.Stack
+	toAddr, err := popExtCallTarget(stack)
+	if err != nil {
+		return nil, err
+	}
+	inOffset, inSize := stack.pop(), stack.pop()
+	var (
+		gas    = interpreter.evm.callGasTemp
+		ret    []byte
+		status = uint64(extCallRevert)
+	)
+	if gas != 0 && HasEOFMagic(interpreter.evm.resolveCode(toAddr)) {
+		args := scope.Memory.GetPtr(int64(inOffset.Uint64()), int64(inSize.Uint64()))
+
+		var returnGas uint64
+		ret, returnGas, err = interpreter.evm.DelegateCall(scope.Contract, toAddr, args, gas)
+		if err == ErrExecutionSuspended {
+			return nil, err
+		}
+		status = extCallStatus(err)
+		scope.Contract.Gas += returnGas
+	} else {
+		scope.Contract.Gas += gas
+	}
+	stack.push(new(uint256.Int).SetUint64(status))
+
+	interpreter.returnData = ret
+	return ret, nil
+}
+
+// opExtStaticCall implements the EXTSTATICCALL opcode (EIP-7069).
+func opExtStaticCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	stack := scope.Stack
+	toAddr, err := popExtCallTarget(stack)
+	if err != nil {
+		return nil, err
+	}
+	inOffset, inSize := stack.pop(), stack.pop()
+	var (
+		gas    = interpreter.evm.callGasTemp
+		ret    []byte
+		status = uint64(extCallRevert)
+	)
+	if gas != 0 {
+		args := scope.Memory.GetPtr(int64(inOffset.Uint64()), int64(inSize.Uint64()))
+
+		var returnGas uint64
+		ret, returnGas, err = interpreter.evm.StaticCall(scope.Contract, toAddr, args, gas)
+		if err == ErrExecutionSuspended {
+			return nil, err
+		}
+		status = extCallStatus(err)
+		scope.Contract.Gas += returnGas
+	}
+	stack.push(new(uint256.Int).SetUint64(status))
+
+	interpreter.returnData = ret
+	return ret, nil
+}
+
+// opEOFCreate implements the EOFCREATE opcode (EIP-7620), deploying one of the
+// subcontainers as initcode with the given input.
+func opEOFCreate(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	if interpreter.readOnly {
+		return nil, ErrWriteProtection
+	}
+	var (
+		code         = scope.Contract.CodeAt(scope.CodeSection)
+		idx          = int(code[*pc+1])
+		value        = scope.Stack.pop()
+		salt         = scope.Stack.pop()
+		offset, size = scope.Stack.pop(), scope.Stack.pop()
+		input        = scope.Memory.GetCopy(int64(offset.Uint64()), int64(size.Uint64()))
+		gas          = scope.Contract.Gas
+	)
+	*pc += 1
+
+	gas -= gas / 64
+	scope.Contract.UseGas(gas)
+
+	// reuse size int for stackvalue
+	stackvalue := size
+	res, addr, returnGas, suberr := interpreter.evm.EOFCreate(scope.Contract, scope.Contract.Container.subContainerCodes[idx], input, gas, &value, &salt)
+	if suberr != nil {
+		stackvalue.Clear()
+	} else {
+		stackvalue.SetBytes(addr.Bytes())
+	}
+	scope.Stack.push(&stackvalue)
+	scope.Contract.Gas += returnGas
+
+	if suberr == ErrExecutionReverted {
+		interpreter.returnData = res // set REVERT data to return data buffer
+		return res, nil
+	}
+	interpreter.returnData = nil // clear dirty return data buffer
+	return nil, nil
+}
+
+// opReturnContract implements the RETURNCONTRACT opcode (EIP-7620), ending the
+// initcode execution with one of the subcontainers, extended by the auxiliary
+// data, as the code to deploy.
+func opReturnContract(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var (
+		code   = scope.Contract.CodeAt(scope.CodeSection)
+		idx    = int(code[*pc+1])
+		offset = scope.Stack.pop()
+		size   = scope.Stack.pop()
+		aux    = scope.Memory.GetPtr(int64(offset.Uint64()), int64(size.Uint64()))
+	)
+	deploy, err := scope.Contract.Container.subContainer(idx)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := deploy.withAuxData(aux)
+	if err != nil {
+		return nil, err
+	}
+	return ret, errStopToken
+}
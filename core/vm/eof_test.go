@@ -0,0 +1,285 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+func TestEOFMarshaling(t *testing.T) {
+	for i, test := range []Container{
+		{
+			types:        []*functionMetadata{{inputs: 0, outputs: nonReturningFunction, maxStackIncrease: 0}},
+			codeSections: [][]byte{common.Hex2Bytes("604200")},
+			data:         []byte{0x01, 0x02, 0x03},
+			dataSize:     3,
+		},
+		{
+			types: []*functionMetadata{
+				{inputs: 0, outputs: nonReturningFunction, maxStackIncrease: 1},
+				{inputs: 2, outputs: 3, maxStackIncrease: 4},
+				{inputs: 1, outputs: 1, maxStackIncrease: 1},
+			},
+			codeSections: [][]byte{
+				common.Hex2Bytes("604200"),
+				common.Hex2Bytes("6042604200"),
+				common.Hex2Bytes("00"),
+			},
+			subContainerCodes: [][]byte{common.Hex2Bytes("ef00010100040200010001ff00000000800000fe")},
+			data:              []byte{},
+			dataSize:          0,
+		},
+	} {
+		var (
+			b   = test.MarshalBinary()
+			got Container
+		)
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatalf("test %d: failed to unmarshal binary: %v", i, err)
+		}
+		if !bytes.Equal(got.MarshalBinary(), b) {
+			t.Fatalf("test %d: encoding mismatch: have %x, want %x", i, got.MarshalBinary(), b)
+		}
+		if len(got.codeSections) != len(test.codeSections) || len(got.subContainerCodes) != len(test.subContainerCodes) {
+			t.Fatalf("test %d: section count mismatch", i)
+		}
+	}
+}
+
+func TestEOFUnmarshalErrors(t *testing.T) {
+	for i, test := range []struct {
+		code string
+		want error
+	}{
+		{"ef01010100040200010001ff00000000800000fe", errInvalidMagic},
+		{"ef00020100040200010001ff00000000800000fe", errInvalidVersion},
+		{"ef00010200040200010001ff00000000800000fe", errMissingTypeHeader},
+		{"ef00010100030200010001ff00000000800000fe", errInvalidTypeSize},
+		{"ef00010100040300010001ff00000000800000fe", errMissingCodeHeader},
+		{"ef000101000402000100010500000000800000fe", errMissingDataHeader},
+		{"ef00010100040200010001ff00000100800000fe", errMissingTerminator},
+		{"ef00010100040200010001ff00000000000000fe", errInvalidSection0Type},
+		{"ef00010100040200010001ff00000000800000fe00", errInvalidContainerSize},
+	} {
+		var c Container
+		if err := c.UnmarshalBinary(common.FromHex(test.code)); !errors.Is(err, test.want) {
+			t.Errorf("test %d: have error %v, want %v", i, err, test.want)
+		}
+	}
+}
+
+// newEOFEnv creates an Osaka EVM on top of an empty state.
+func newEOFEnv() (*EVM, *state.StateDB) {
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	config := *params.MergedTestChainConfig
+	config.PragueTime = new(uint64)
+	config.OsakaTime = new(uint64)
+	vmctx := BlockContext{
+		CanTransfer: func(StateDB, common.Address, *uint256.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *uint256.Int) {},
+		BlockNumber: common.Big0,
+		Random:      new(common.Hash),
+	}
+	return NewEVM(vmctx, TxContext{}, statedb, &config, Config{}), statedb
+}
+
+// Tests that an EOF contract is deployed by a creation transaction and that its
+// code sections, data section and the EXT*CALL family are executed correctly.
+func TestEOFExecution(t *testing.T) {
+	var (
+		word    = common.HexToHash("0x4242").Bytes()
+		runtime = &Container{
+			types: []*functionMetadata{
+				{inputs: 0, outputs: nonReturningFunction, maxStackIncrease: 2},
+				{inputs: 0, outputs: 1, maxStackIncrease: 2},
+			},
+			codeSections: [][]byte{
+				// CALLF 1, store the result and return it
+				{byte(CALLF), 0, 1, byte(PUSH0), byte(MSTORE), byte(PUSH1), 32, byte(PUSH0), byte(RETURN)},
+				// DATALOADN 0, jump over the NOT and return the word
+				{byte(DATALOADN), 0, 0, byte(PUSH1), 1, byte(RJUMPI), 0, 1, byte(NOT), byte(RETF)},
+			},
+			data:     word,
+			dataSize: len(word),
+		}
+		initcode = &Container{
+			types:             []*functionMetadata{{inputs: 0, outputs: nonReturningFunction, maxStackIncrease: 2}},
+			codeSections:      [][]byte{{byte(PUSH0), byte(PUSH0), byte(RETURNCONTRACT), 0}},
+			subContainerCodes: [][]byte{runtime.MarshalBinary()},
+			data:              []byte{},
+		}
+		sender = common.HexToAddress("0xaa")
+	)
+	evm, statedb := newEOFEnv()
+	_, addr, _, err := evm.Create(AccountRef(sender), initcode.MarshalBinary(), 1000000, new(uint256.Int))
+	if err != nil {
+		t.Fatalf("failed to deploy container: %v", err)
+	}
+	if code := statedb.GetCode(addr); !bytes.Equal(code, runtime.MarshalBinary()) {
+		t.Fatalf("deployed code mismatch: have %x, want %x", code, runtime.MarshalBinary())
+	}
+	ret, _, err := evm.Call(AccountRef(sender), addr, nil, 100000, new(uint256.Int))
+	if err != nil {
+		t.Fatalf("failed to call contract: %v", err)
+	}
+	if !bytes.Equal(ret, word) {
+		t.Fatalf("return mismatch: have %x, want %x", ret, word)
+	}
+	// Call it through EXTCALL and return the RETURNDATALOADed result
+	caller := &Container{
+		types: []*functionMetadata{{inputs: 0, outputs: nonReturningFunction, maxStackIncrease: 4}},
+		codeSections: [][]byte{append(append(
+			[]byte{byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(PUSH20)}, addr.Bytes()...),
+			byte(EXTCALL), byte(POP), byte(PUSH0), byte(RETURNDATALOAD), byte(PUSH0), byte(MSTORE),
+			byte(PUSH1), 32, byte(PUSH0), byte(RETURN),
+		)},
+		data: []byte{},
+	}
+	callerAddr := common.HexToAddress("0xbb")
+	statedb.SetCode(callerAddr, caller.MarshalBinary())
+	if err := caller.ValidateCode(&eofInstructionSet, false); err != nil {
+		t.Fatalf("invalid caller container: %v", err)
+	}
+	ret, _, err = evm.Call(AccountRef(sender), callerAddr, nil, 100000, new(uint256.Int))
+	if err != nil {
+		t.Fatalf("failed to call contract: %v", err)
+	}
+	if !bytes.Equal(ret, word) {
+		t.Fatalf("return mismatch: have %x, want %x", ret, word)
+	}
+	// Deploy the initcode again through EOFCREATE
+	factory := &Container{
+		types: []*functionMetadata{{inputs: 0, outputs: nonReturningFunction, maxStackIncrease: 4}},
+		codeSections: [][]byte{{
+			byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(EOFCREATE), 0,
+			byte(PUSH0), byte(MSTORE), byte(PUSH1), 32, byte(PUSH0), byte(RETURN),
+		}},
+		subContainerCodes: [][]byte{initcode.MarshalBinary()},
+		data:              []byte{},
+	}
+	factoryAddr := common.HexToAddress("0xdd")
+	statedb.SetCode(factoryAddr, factory.MarshalBinary())
+	ret, _, err = evm.Call(AccountRef(sender), factoryAddr, nil, 1000000, new(uint256.Int))
+	if err != nil {
+		t.Fatalf("failed to call factory: %v", err)
+	}
+	want := common.BytesToAddress(crypto.Keccak256([]byte{0xff}, common.LeftPadBytes(factoryAddr.Bytes(), 32), make([]byte, 32))[12:])
+	if created := common.BytesToAddress(ret); created != want {
+		t.Fatalf("created address mismatch: have %x, want %x", created, want)
+	}
+	if code := statedb.GetCode(want); !bytes.Equal(code, runtime.MarshalBinary()) {
+		t.Fatalf("deployed code mismatch: have %x, want %x", code, runtime.MarshalBinary())
+	}
+	// Legacy code may not introspect EOF code
+	legacyAddr := common.HexToAddress("0xcc")
+	statedb.SetCode(legacyAddr, append(append([]byte{byte(PUSH20)}, addr.Bytes()...),
+		byte(EXTCODESIZE), byte(PUSH0), byte(MSTORE), byte(PUSH1), 32, byte(PUSH0), byte(RETURN)))
+	ret, _, err = evm.Call(AccountRef(sender), legacyAddr, nil, 100000, new(uint256.Int))
+	if err != nil {
+		t.Fatalf("failed to call contract: %v", err)
+	}
+	if size := new(uint256.Int).SetBytes(ret); size.Uint64() != 2 {
+		t.Fatalf("EXTCODESIZE mismatch: have %d, want 2", size)
+	}
+}
+
+// Tests that the container of deployed EOF code is parsed once and reused by
+// subsequent call frames.
+func TestEOFContainerCache(t *testing.T) {
+	var (
+		code = (&Container{
+			types:        []*functionMetadata{{inputs: 0, outputs: nonReturningFunction, maxStackIncrease: 0}},
+			codeSections: [][]byte{{byte(STOP)}},
+			data:         []byte{},
+		}).MarshalBinary()
+		sender = common.HexToAddress("0xaa")
+		addr   = common.HexToAddress("0xbb")
+	)
+	evm, statedb := newEOFEnv()
+	statedb.SetCode(addr, code)
+
+	if _, _, err := evm.Call(AccountRef(sender), addr, nil, 100000, new(uint256.Int)); err != nil {
+		t.Fatalf("failed to call contract: %v", err)
+	}
+	cached := evm.interpreter.containers[crypto.Keccak256Hash(code)]
+	if cached == nil {
+		t.Fatal("container not cached")
+	}
+	contract := NewContract(AccountRef(sender), AccountRef(addr), new(uint256.Int), 100000)
+	contract.SetCallCode(&addr, crypto.Keccak256Hash(code), code)
+	if _, err := evm.interpreter.Run(contract, nil, false); err != nil {
+		t.Fatalf("failed to run contract: %v", err)
+	}
+	if contract.Container != cached {
+		t.Fatal("container parsed again")
+	}
+}
+
+// Tests that creation transactions with invalid EOF initcode, and legacy
+// creations with EOF initcode, are rejected.
+func TestEOFInvalidCreation(t *testing.T) {
+	sender := common.HexToAddress("0xaa")
+
+	// Initcode may not RETURN
+	initcode := &Container{
+		types:        []*functionMetadata{{inputs: 0, outputs: nonReturningFunction, maxStackIncrease: 2}},
+		codeSections: [][]byte{{byte(PUSH0), byte(PUSH0), byte(RETURN)}},
+		data:         []byte{},
+	}
+	evm, statedb := newEOFEnv()
+	_, _, gas, err := evm.Create(AccountRef(sender), initcode.MarshalBinary(), 100000, new(uint256.Int))
+	if !errors.Is(err, ErrInvalidEOFInitcode) {
+		t.Fatalf("have error %v, want %v", err, ErrInvalidEOFInitcode)
+	}
+	if gas != 100000 {
+		t.Fatalf("initcode execution gas consumed: have %d left", gas)
+	}
+	if nonce := statedb.GetNonce(sender); nonce != 1 {
+		t.Fatalf("sender nonce mismatch: have %d, want 1", nonce)
+	}
+	// Legacy CREATE with EOF initcode fails
+	factory := common.HexToAddress("0xbb")
+	valid := &Container{
+		types:        []*functionMetadata{{inputs: 0, outputs: nonReturningFunction, maxStackIncrease: 0}},
+		codeSections: [][]byte{{byte(INVALID)}},
+		data:         []byte{},
+	}
+	var (
+		code = valid.MarshalBinary()
+		word = common.RightPadBytes(code, 32)
+	)
+	statedb.SetCode(factory, append(append([]byte{byte(PUSH32)}, word...),
+		byte(PUSH0), byte(MSTORE), byte(PUSH1), byte(len(code)), byte(PUSH0), byte(PUSH0), byte(CREATE),
+		byte(PUSH0), byte(MSTORE), byte(PUSH1), 32, byte(PUSH0), byte(RETURN)))
+	ret, _, err := evm.Call(AccountRef(sender), factory, nil, 100000, new(uint256.Int))
+	if err != nil {
+		t.Fatalf("failed to call factory: %v", err)
+	}
+	if !bytes.Equal(ret, make([]byte, 32)) {
+		t.Fatalf("legacy creation with eof initcode succeeded: %x", ret)
+	}
+}
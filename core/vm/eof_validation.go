@@ -0,0 +1,357 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Subcontainer reference kinds, a subcontainer must be referenced by exactly
+// one of them.
+const (
+	refEOFCreate      = 1 << iota // deployed as initcode by EOFCREATE
+	refReturnContract             // returned as runtime code by RETURNCONTRACT
+)
+
+// ValidateCode validates the code sections of the container, as well as all of
+// its subcontainers, against the EOF v1 rules. Initcode containers (executed by
+// a creation transaction or EOFCREATE) may not contain STOP and RETURN, whereas
+// runtime containers may not contain RETURNCONTRACT.
+func (c *Container) ValidateCode(jt *JumpTable, isInitCode bool) error {
+	if len(c.data) < c.dataSize {
+		return fmt.Errorf("%w: have %d, want %d", errTruncatedTopLevelContainer, len(c.data), c.dataSize)
+	}
+	return c.validate(jt, isInitCode)
+}
+
+// validate checks the container's code sections and recursively validates the
+// subcontainers according to the kind they are referenced as.
+func (c *Container) validate(jt *JumpTable, isInitCode bool) error {
+	var (
+		visited = make([]bool, len(c.codeSections))
+		queue   = []int{0}
+		subRefs = make([]int, len(c.subContainerCodes))
+	)
+	visited[0] = true
+	for len(queue) > 0 {
+		section := queue[0]
+		queue = queue[1:]
+
+		calls, err := validateCode(c.codeSections[section], section, c, jt, isInitCode, subRefs)
+		if err != nil {
+			return err
+		}
+		for _, target := range calls {
+			if !visited[target] {
+				visited[target] = true
+				queue = append(queue, target)
+			}
+		}
+	}
+	for section, ok := range visited {
+		if !ok {
+			return fmt.Errorf("%w: section %d", errUnreachableCodeSections, section)
+		}
+	}
+	for idx, refs := range subRefs {
+		sub, err := c.subContainer(idx)
+		if err != nil {
+			return fmt.Errorf("subcontainer %d: %w", idx, err)
+		}
+		switch refs {
+		case refEOFCreate:
+			if len(sub.data) < sub.dataSize {
+				return fmt.Errorf("%w: subcontainer %d", errEOFCreateWithTruncated, idx)
+			}
+			err = sub.validate(jt, true)
+		case refReturnContract:
+			err = sub.validate(jt, false)
+		case 0:
+			return fmt.Errorf("%w: subcontainer %d", errOrphanedSubcontainer, idx)
+		default:
+			return fmt.Errorf("%w: subcontainer %d referenced by both EOFCREATE and RETURNCONTRACT", errIncompatibleContainerKind, idx)
+		}
+		if err != nil {
+			return fmt.Errorf("subcontainer %d: %w", idx, err)
+		}
+	}
+	return nil
+}
+
+// validateCode validates the instructions of a single code section and returns
+// the code sections it transfers control to. The subcontainers it references
+// are recorded into subRefs.
+func validateCode(code []byte, section int, container *Container, jt *JumpTable, isInitCode bool, subRefs []int) ([]int, error) {
+	var (
+		i         = 0
+		op        OpCode
+		analysis  *bitvec
+		calls     []int
+		returning bool
+		meta      = container.types[section]
+	)
+	for i < len(code) {
+		op = OpCode(code[i])
+		if jt[op].undefined {
+			return nil, fmt.Errorf("%w: op %s, pos %d", errUndefinedInstruction, op, i)
+		}
+		size := int(immediates[op])
+		if size != 0 && len(code) <= i+size {
+			return nil, fmt.Errorf("%w: op %s, pos %d", errTruncatedImmediate, op, i)
+		}
+		switch op {
+		case RJUMP, RJUMPI:
+			if err := checkDest(code, &analysis, i+1, i+3); err != nil {
+				return nil, err
+			}
+		case RJUMPV:
+			count := int(code[i+1]) + 1
+			size = 1 + 2*count
+			if len(code) <= i+size {
+				return nil, fmt.Errorf("%w: jump table truncated, op %s, pos %d", errTruncatedImmediate, op, i)
+			}
+			for j := 0; j < count; j++ {
+				if err := checkDest(code, &analysis, i+2+2*j, i+1+size); err != nil {
+					return nil, err
+				}
+			}
+		case CALLF:
+			arg := int(binary.BigEndian.Uint16(code[i+1:]))
+			if arg >= len(container.types) {
+				return nil, fmt.Errorf("%w: arg %d, last %d, pos %d", errInvalidSectionArgument, arg, len(container.types), i)
+			}
+			if !container.types[arg].returning() {
+				return nil, fmt.Errorf("%w: section %d, pos %d", errInvalidCallArgument, arg, i)
+			}
+			calls = append(calls, arg)
+		case JUMPF:
+			arg := int(binary.BigEndian.Uint16(code[i+1:]))
+			if arg >= len(container.types) {
+				return nil, fmt.Errorf("%w: arg %d, last %d, pos %d", errInvalidSectionArgument, arg, len(container.types), i)
+			}
+			if target := container.types[arg]; target.returning() {
+				if !meta.returning() {
+					return nil, fmt.Errorf("%w: non-returning section %d jumps to returning section %d, pos %d", errInvalidNonReturning, section, arg, i)
+				}
+				if target.outputs > meta.outputs {
+					return nil, fmt.Errorf("%w: section %d has %d outputs, target %d has %d, pos %d", errInvalidOutputs, section, meta.outputs, arg, target.outputs, i)
+				}
+				returning = true
+			}
+			calls = append(calls, arg)
+		case RETF:
+			if !meta.returning() {
+				return nil, fmt.Errorf("%w: section %d, pos %d", errInvalidNonReturning, section, i)
+			}
+			returning = true
+		case DATALOADN:
+			arg := int(binary.BigEndian.Uint16(code[i+1:]))
+			if arg+32 > container.dataSize {
+				return nil, fmt.Errorf("%w: arg %d, data size %d, pos %d", errInvalidDataloadNArgument, arg, container.dataSize, i)
+			}
+		case EOFCREATE, RETURNCONTRACT:
+			arg := int(code[i+1])
+			if arg >= len(container.subContainerCodes) {
+				return nil, fmt.Errorf("%w: arg %d, last %d, pos %d", errInvalidContainerArgument, arg, len(container.subContainerCodes), i)
+			}
+			if op == RETURNCONTRACT {
+				if !isInitCode {
+					return nil, fmt.Errorf("%w: RETURNCONTRACT in runtime code, pos %d", errIncompatibleContainerKind, i)
+				}
+				subRefs[arg] |= refReturnContract
+			} else {
+				subRefs[arg] |= refEOFCreate
+			}
+		case STOP, RETURN:
+			if isInitCode {
+				return nil, fmt.Errorf("%w: op %s, pos %d", errStopInInitCode, op, i)
+			}
+		}
+		i += size + 1
+	}
+	// Declared returning sections must actually return.
+	if meta.returning() && !returning {
+		return nil, fmt.Errorf("%w: section %d never returns", errInvalidNonReturning, section)
+	}
+	// Code sections may not "fall through" and require proper termination.
+	if !terminals[op] && op != RJUMP {
+		return nil, fmt.Errorf("%w: end with %s, pos %d", errInvalidCodeTermination, op, i)
+	}
+	if err := validateControlFlow(code, section, container.types, jt); err != nil {
+		return nil, err
+	}
+	return calls, nil
+}
+
+// checkDest parses a relative offset at code[imm:imm+2] and checks that the
+// destination it points to, relative to from, is a valid instruction.
+func checkDest(code []byte, analysis **bitvec, imm, from int) error {
+	if len(code) < imm+2 {
+		return io.ErrUnexpectedEOF
+	}
+	if *analysis == nil {
+		bits := eofCodeBitmap(code)
+		*analysis = &bits
+	}
+	offset := parseInt16(code[imm:])
+	dest := from + offset
+	if dest < 0 || dest >= len(code) {
+		return fmt.Errorf("%w: out-of-bounds offset: offset %d, dest %d, pos %d", errInvalidJumpDest, offset, dest, imm)
+	}
+	if !(*analysis).codeSegment(uint64(dest)) {
+		return fmt.Errorf("%w: offset into immediate: offset %d, dest %d, pos %d", errInvalidJumpDest, offset, dest, imm)
+	}
+	return nil
+}
+
+// stackBounds is the range of operand stack heights an instruction may be
+// reached with.
+type stackBounds struct {
+	min, max int
+	visited  bool
+}
+
+// validateControlFlow performs the stack validation of EIP-5450 on a code
+// section in a single forward pass: every instruction must be reachable, must
+// not underflow or overflow the stack, and backward jumps must preserve the
+// stack height. The maximum stack height must match the declared one.
+func validateControlFlow(code []byte, section int, metadata []*functionMetadata, jt *JumpTable) error {
+	var (
+		meta    = metadata[section]
+		heights = make([]stackBounds, len(code))
+		highest = int(meta.inputs)
+	)
+	// visit propagates the stack bounds from the instruction at pos to the one
+	// at dest.
+	visit := func(pos, dest int, next stackBounds) error {
+		if dest >= len(code) {
+			return fmt.Errorf("%w: falls off the end of the code, pos %d", errInvalidCodeTermination, pos)
+		}
+		target := &heights[dest]
+		if dest <= pos {
+			if !target.visited || target.min != next.min || target.max != next.max {
+				return fmt.Errorf("%w: stack height mismatch at %d, pos %d", errInvalidBackwardJump, dest, pos)
+			}
+			return nil
+		}
+		if !target.visited {
+			*target = next
+		} else {
+			target.min = min(target.min, next.min)
+			target.max = max(target.max, next.max)
+		}
+		return nil
+	}
+	heights[0] = stackBounds{min: int(meta.inputs), max: int(meta.inputs), visited: true}
+
+	for pos := 0; pos < len(code); {
+		var (
+			op   = OpCode(code[pos])
+			cur  = heights[pos]
+			size = int(immediates[op])
+			in   = jt[op].minStack
+			out  = in + int(params.StackLimit) - jt[op].maxStack
+		)
+		if !cur.visited {
+			return fmt.Errorf("%w: pos %d", errUnreachableCode, pos)
+		}
+		switch op {
+		case CALLF, JUMPF:
+			target := metadata[binary.BigEndian.Uint16(code[pos+1:])]
+			if cur.max+int(target.maxStackIncrease) > int(params.StackLimit) {
+				return fmt.Errorf("%w: op %s, pos %d", errStackOverflow, op, pos)
+			}
+			in, out = int(target.inputs), int(target.outputs)
+			if op == JUMPF {
+				if target.returning() {
+					want := int(meta.outputs) + int(target.inputs) - int(target.outputs)
+					if cur.min != want || cur.max != want {
+						return fmt.Errorf("%w: have %d-%d, want %d, pos %d", errInvalidOutputs, cur.min, cur.max, want, pos)
+					}
+				}
+				out = 0
+			}
+		case RETF:
+			want := int(meta.outputs)
+			if cur.min != want || cur.max != want {
+				return fmt.Errorf("%w: have %d-%d, want %d, pos %d", errInvalidOutputs, cur.min, cur.max, want, pos)
+			}
+		case DUPN:
+			n := int(code[pos+1]) + 1
+			in, out = n, n+1
+		case SWAPN:
+			n := int(code[pos+1]) + 1
+			in, out = n+1, n+1
+		case EXCHANGE:
+			n, m := int(code[pos+1]>>4)+1, int(code[pos+1]&0x0f)+1
+			in, out = n+m+1, n+m+1
+		case RJUMPV:
+			size = 1 + 2*(int(code[pos+1])+1)
+		}
+		if cur.min < in {
+			return fmt.Errorf("%w: have %d, want %d, op %s, pos %d", errStackUnderflow, cur.min, in, op, pos)
+		}
+		next := stackBounds{min: cur.min - in + out, max: cur.max - in + out, visited: true}
+		if next.max > maxStackIncrease {
+			return fmt.Errorf("%w: have %d, op %s, pos %d", errStackOverflow, next.max, op, pos)
+		}
+		highest = max(highest, next.max)
+
+		following := pos + 1 + size
+		switch {
+		case op == RJUMP:
+			if err := visit(pos, following+parseInt16(code[pos+1:]), next); err != nil {
+				return err
+			}
+		case op == RJUMPI:
+			if err := visit(pos, following, next); err != nil {
+				return err
+			}
+			if err := visit(pos, following+parseInt16(code[pos+1:]), next); err != nil {
+				return err
+			}
+		case op == RJUMPV:
+			if err := visit(pos, following, next); err != nil {
+				return err
+			}
+			for i := 0; i < int(code[pos+1])+1; i++ {
+				if err := visit(pos, following+parseInt16(code[pos+2+2*i:]), next); err != nil {
+					return err
+				}
+			}
+		case terminals[op]:
+		default:
+			if err := visit(pos, following, next); err != nil {
+				return err
+			}
+		}
+		pos = following
+	}
+	if want := int(meta.inputs) + int(meta.maxStackIncrease); highest != want {
+		return fmt.Errorf("%w in code section %d: have %d, want %d", errInvalidMaxStackHeight, section, highest, want)
+	}
+	return nil
+}
+
+// parseInt16 returns the int16 located at b[0:2].
+func parseInt16(b []byte) int {
+	return int(int16(b[1]) | int16(b[0])<<8)
+}
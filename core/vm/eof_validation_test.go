@@ -0,0 +1,194 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEOFValidateCode(t *testing.T) {
+	for i, test := range []struct {
+		code     []byte
+		maxStack uint16
+		extra    []*functionMetadata // additional sections, all with code RETF
+		data     int
+		want     error
+	}{
+		{
+			code:     []byte{byte(CALLER), byte(POP), byte(STOP)},
+			maxStack: 1,
+		},
+		{
+			code:  []byte{byte(CALLF), 0, 1, byte(STOP)},
+			extra: []*functionMetadata{{inputs: 0, outputs: 0}},
+		},
+		{
+			code:     []byte{byte(ADDRESS), byte(CALLF), 0, 1, byte(POP), byte(STOP)},
+			maxStack: 1,
+			extra:    []*functionMetadata{{inputs: 1, outputs: 1, maxStackIncrease: 0}},
+		},
+		{
+			code:     []byte{byte(CALLER), byte(PUSH0), byte(RJUMPI), 0, 1, byte(NOT), byte(STOP)},
+			maxStack: 2,
+		},
+		{
+			code:     []byte{byte(PUSH0), byte(RJUMPI), 0, 0, byte(STOP)},
+			maxStack: 1,
+		},
+		{
+			code:     []byte{byte(PUSH0), byte(RJUMPV), 1, 0, 0, 0, 1, byte(JUMPDEST), byte(STOP)},
+			maxStack: 1,
+		},
+		{
+			code: []byte{byte(RJUMP), 0xff, 0xfd},
+		},
+		{
+			code:     []byte{byte(DATALOADN), 0, 0, byte(POP), byte(STOP)},
+			maxStack: 1,
+			data:     32,
+		},
+		{
+			code: []byte{byte(DUPN), 0, byte(STOP)},
+			want: errStackUnderflow,
+		},
+		{
+			code:     []byte{byte(CALLER), byte(CALLER), byte(EXCHANGE), 0, byte(STOP)},
+			maxStack: 2,
+			want:     errStackUnderflow,
+		},
+		{
+			code:     []byte{byte(CALLER), byte(CALLER), byte(CALLER), byte(EXCHANGE), 0, byte(STOP)},
+			maxStack: 3,
+		},
+		{
+			code:     []byte{byte(JUMPDEST), byte(PC), byte(STOP)},
+			maxStack: 1,
+			want:     errUndefinedInstruction,
+		},
+		{
+			code:     []byte{byte(CALLER), byte(POP)},
+			maxStack: 1,
+			want:     errInvalidCodeTermination,
+		},
+		{
+			code: []byte{byte(PUSH2), 0},
+			want: errTruncatedImmediate,
+		},
+		{
+			code: []byte{byte(RJUMP), 0, 1, byte(STOP)},
+			want: errInvalidJumpDest,
+		},
+		{
+			code: []byte{byte(RJUMP), 0, 1, byte(PUSH1), 0, byte(STOP)},
+			want: errInvalidJumpDest,
+		},
+		{
+			code:     []byte{byte(CALLER), byte(RJUMP), 0xff, 0xfc},
+			maxStack: 1,
+			want:     errInvalidBackwardJump,
+		},
+		{
+			code: []byte{byte(STOP), byte(STOP)},
+			want: errUnreachableCode,
+		},
+		{
+			code: []byte{byte(POP), byte(STOP)},
+			want: errStackUnderflow,
+		},
+		{
+			code:     []byte{byte(CALLER), byte(POP), byte(STOP)},
+			maxStack: 2,
+			want:     errInvalidMaxStackHeight,
+		},
+		{
+			code:     []byte{byte(DATALOADN), 0, 1, byte(POP), byte(STOP)},
+			maxStack: 1,
+			data:     32,
+			want:     errInvalidDataloadNArgument,
+		},
+		{
+			code:  []byte{byte(CALLF), 0, 2, byte(STOP)},
+			extra: []*functionMetadata{{inputs: 0, outputs: 0}},
+			want:  errInvalidSectionArgument,
+		},
+		{
+			code: []byte{byte(RETF)},
+			want: errInvalidNonReturning,
+		},
+		{
+			code:  []byte{byte(STOP)},
+			extra: []*functionMetadata{{inputs: 0, outputs: 0}},
+			want:  errUnreachableCodeSections,
+		},
+	} {
+		container := &Container{
+			types:        append([]*functionMetadata{{inputs: 0, outputs: nonReturningFunction, maxStackIncrease: test.maxStack}}, test.extra...),
+			codeSections: [][]byte{test.code},
+			data:         make([]byte, test.data),
+			dataSize:     test.data,
+		}
+		for range test.extra {
+			container.codeSections = append(container.codeSections, []byte{byte(RETF)})
+		}
+		err := container.ValidateCode(&eofInstructionSet, false)
+		if !errors.Is(err, test.want) {
+			t.Errorf("test %d (%x): have error %v, want %v", i, test.code, err, test.want)
+		}
+	}
+}
+
+func TestEOFValidateSubcontainers(t *testing.T) {
+	var (
+		runtime = &Container{
+			types:        []*functionMetadata{{inputs: 0, outputs: nonReturningFunction}},
+			codeSections: [][]byte{{byte(STOP)}},
+			data:         []byte{},
+		}
+		initcode = &Container{
+			types:             []*functionMetadata{{inputs: 0, outputs: nonReturningFunction, maxStackIncrease: 2}},
+			codeSections:      [][]byte{{byte(PUSH0), byte(PUSH0), byte(RETURNCONTRACT), 0}},
+			subContainerCodes: [][]byte{runtime.MarshalBinary()},
+			data:              []byte{},
+		}
+	)
+	if err := initcode.ValidateCode(&eofInstructionSet, true); err != nil {
+		t.Fatalf("valid initcode rejected: %v", err)
+	}
+	if err := initcode.ValidateCode(&eofInstructionSet, false); !errors.Is(err, errIncompatibleContainerKind) {
+		t.Fatalf("have error %v, want %v", err, errIncompatibleContainerKind)
+	}
+	if err := runtime.ValidateCode(&eofInstructionSet, true); !errors.Is(err, errStopInInitCode) {
+		t.Fatalf("have error %v, want %v", err, errStopInInitCode)
+	}
+	// The initcode may be deployed by an EOFCREATE of a runtime container
+	factory := &Container{
+		types:             []*functionMetadata{{inputs: 0, outputs: nonReturningFunction, maxStackIncrease: 4}},
+		codeSections:      [][]byte{{byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(EOFCREATE), 0, byte(POP), byte(STOP)}},
+		subContainerCodes: [][]byte{initcode.MarshalBinary()},
+		data:              []byte{},
+	}
+	if err := factory.ValidateCode(&eofInstructionSet, false); err != nil {
+		t.Fatalf("valid factory rejected: %v", err)
+	}
+	// Subcontainers must be referenced
+	factory.codeSections[0] = []byte{byte(STOP)}
+	factory.types[0].maxStackIncrease = 0
+	if err := factory.ValidateCode(&eofInstructionSet, false); !errors.Is(err, errOrphanedSubcontainer) {
+		t.Fatalf("have error %v, want %v", err, errOrphanedSubcontainer)
+	}
+}
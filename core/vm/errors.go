@@ -37,6 +37,10 @@ var (
 	ErrGasUintOverflow          = errors.New("gas uint64 overflow")
 	ErrInvalidCode              = errors.New("invalid code: must not begin with 0xef")
 	ErrNonceUintOverflow        = errors.New("nonce uint64 overflow")
+	ErrExecutionSuspended       = errors.New("execution suspended")
+	ErrInvalidEOFInitcode       = errors.New("invalid eof initcode")
+	ErrLegacyEOFCreate          = errors.New("legacy contract creation with eof initcode")
+	ErrAddressOutOfRange        = errors.New("address has non-zero upper bytes")
 
 	// errStopToken is an internal token indicating interpreter loop termination,
 	// never returned to outside callers.
@@ -17,12 +17,14 @@
 package vm
 
 import (
+	"fmt"
 	"math/big"
 	"sync/atomic"
 
 	"github.com/holiman/uint256"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/params"
@@ -41,6 +43,10 @@ type (
 func (evm *EVM) precompile(addr common.Address) (PrecompiledContract, bool) {
 	var precompiles map[common.Address]PrecompiledContract
 	switch {
+	case evm.chainRules.IsPrague && evm.chainRules.IsOptimismFjord:
+		precompiles = PrecompiledContractsPragueFjord
+	case evm.chainRules.IsPrague:
+		precompiles = PrecompiledContractsPrague
 	case evm.chainRules.IsOptimismFjord:
 		precompiles = PrecompiledContractsFjord
 	case evm.chainRules.IsCancun:
@@ -97,6 +103,8 @@ type TxContext struct {
 	GasPrice   *big.Int       // Provides information for GASPRICE (and is used to zero the basefee if NoBaseFee is set)
 	BlobHashes []common.Hash  // Provides information for BLOBHASH
 	BlobFeeCap *big.Int       // Is used to zero the blobbasefee if NoBaseFee is set
+
+	AccessEvents *state.AccessEvents // Capture all state accesses for this tx, nil unless in verkle mode
 }
 
 // EVM is the Ethereum Virtual Machine base object and provides
@@ -157,6 +165,9 @@ func NewEVM(blockCtx BlockContext, txCtx TxContext, statedb StateDB, chainConfig
 		chainConfig: chainConfig,
 		chainRules:  chainConfig.Rules(blockCtx.BlockNumber, blockCtx.Random != nil, blockCtx.Time),
 	}
+	if evm.chainRules.IsVerkle && evm.TxContext.AccessEvents == nil {
+		evm.TxContext.AccessEvents = state.NewAccessEvents(statedb.PointCache())
+	}
 	evm.interpreter = NewEVMInterpreter(evm)
 	return evm
 }
@@ -164,6 +175,9 @@ func NewEVM(blockCtx BlockContext, txCtx TxContext, statedb StateDB, chainConfig
 // Reset resets the EVM with a new transaction context.Reset
 // This is not threadsafe and should only be done very cautiously.
 func (evm *EVM) Reset(txCtx TxContext, statedb StateDB) {
+	if evm.chainRules.IsVerkle && txCtx.AccessEvents == nil {
+		txCtx.AccessEvents = state.NewAccessEvents(statedb.PointCache())
+	}
 	evm.TxContext = txCtx
 	evm.StateDB = statedb
 }
@@ -202,6 +216,15 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	debug := evm.Config.Tracer != nil
 
 	if !evm.StateDB.Exist(addr) {
+		if !isPrecompile && evm.chainRules.IsVerkle {
+			// Add the proof of absence to the witness
+			wgas := evm.AccessEvents.AddAccount(addr, false)
+			if gas < wgas {
+				evm.StateDB.RevertToSnapshot(snapshot)
+				return nil, 0, ErrOutOfGas
+			}
+			gas -= wgas
+		}
 		if !isPrecompile && evm.chainRules.IsEIP158 && value.IsZero() {
 			// Calling a non existing account, don't do anything, but ping the tracer
 			if debug {
@@ -240,7 +263,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	} else {
 		// Initialise a new contract and set the code that is to be used by the EVM.
 		// The contract is a scoped environment for this execution context only.
-		code := evm.StateDB.GetCode(addr)
+		code := evm.resolveCode(addr)
 		if len(code) == 0 {
 			ret, err = nil, nil // gas is unchanged
 		} else {
@@ -248,15 +271,15 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 			// If the account has no code, we can abort here
 			// The depth-check is already done, and precompiles handled above
 			contract := NewContract(caller, AccountRef(addrCopy), value, gas)
-			contract.SetCallCode(&addrCopy, evm.StateDB.GetCodeHash(addrCopy), code)
-			ret, err = evm.interpreter.Run(contract, input, false)
+			contract.SetCallCode(&addrCopy, evm.resolveCodeHash(addrCopy), code)
+			ret, err = evm.interpreter.run(contract, input, false, snapshot, nil)
 			gas = contract.Gas
 		}
 	}
 	// When an error was returned by the EVM or when setting the creation code
 	// above we revert to the snapshot and consume any gas remaining. Additionally
 	// when we're in homestead this also counts for code storage gas errors.
-	if err != nil {
+	if err != nil && err != ErrExecutionSuspended {
 		evm.StateDB.RevertToSnapshot(snapshot)
 		if err != ErrExecutionReverted {
 			gas = 0
@@ -305,11 +328,11 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 		// Initialise a new contract and set the code that is to be used by the EVM.
 		// The contract is a scoped environment for this execution context only.
 		contract := NewContract(caller, AccountRef(caller.Address()), value, gas)
-		contract.SetCallCode(&addrCopy, evm.StateDB.GetCodeHash(addrCopy), evm.StateDB.GetCode(addrCopy))
-		ret, err = evm.interpreter.Run(contract, input, false)
+		contract.SetCallCode(&addrCopy, evm.resolveCodeHash(addrCopy), evm.resolveCode(addrCopy))
+		ret, err = evm.interpreter.run(contract, input, false, snapshot, nil)
 		gas = contract.Gas
 	}
-	if err != nil {
+	if err != nil && err != ErrExecutionSuspended {
 		evm.StateDB.RevertToSnapshot(snapshot)
 		if err != ErrExecutionReverted {
 			gas = 0
@@ -349,11 +372,11 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 		addrCopy := addr
 		// Initialise a new contract and make initialise the delegate values
 		contract := NewContract(caller, AccountRef(caller.Address()), nil, gas).AsDelegate()
-		contract.SetCallCode(&addrCopy, evm.StateDB.GetCodeHash(addrCopy), evm.StateDB.GetCode(addrCopy))
-		ret, err = evm.interpreter.Run(contract, input, false)
+		contract.SetCallCode(&addrCopy, evm.resolveCodeHash(addrCopy), evm.resolveCode(addrCopy))
+		ret, err = evm.interpreter.run(contract, input, false, snapshot, nil)
 		gas = contract.Gas
 	}
-	if err != nil {
+	if err != nil && err != ErrExecutionSuspended {
 		evm.StateDB.RevertToSnapshot(snapshot)
 		if err != ErrExecutionReverted {
 			gas = 0
@@ -402,14 +425,14 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 		// Initialise a new contract and set the code that is to be used by the EVM.
 		// The contract is a scoped environment for this execution context only.
 		contract := NewContract(caller, AccountRef(addrCopy), new(uint256.Int), gas)
-		contract.SetCallCode(&addrCopy, evm.StateDB.GetCodeHash(addrCopy), evm.StateDB.GetCode(addrCopy))
+		contract.SetCallCode(&addrCopy, evm.resolveCodeHash(addrCopy), evm.resolveCode(addrCopy))
 		// When an error was returned by the EVM or when setting the creation code
 		// above we revert to the snapshot and consume any gas remaining. Additionally
 		// when we're in Homestead this also counts for code storage gas errors.
-		ret, err = evm.interpreter.Run(contract, input, true)
+		ret, err = evm.interpreter.run(contract, input, true, snapshot, nil)
 		gas = contract.Gas
 	}
-	if err != nil {
+	if err != nil && err != ErrExecutionSuspended {
 		evm.StateDB.RevertToSnapshot(snapshot)
 		if err != ErrExecutionReverted {
 			gas = 0
@@ -418,6 +441,48 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 	return ret, gas, err
 }
 
+// blockHash returns the hash of the given ancestor block. From Prague onwards
+// the hash is served from the EIP-2935 history storage contract, falling back
+// to the header chain for blocks recorded before the contract was deployed.
+func (evm *EVM) blockHash(num uint64) common.Hash {
+	if evm.chainRules.IsPrague {
+		slot := common.BigToHash(new(big.Int).SetUint64(num % params.HistoryServeWindow))
+		if hash := evm.StateDB.GetState(params.HistoryStorageAddress, slot); hash != (common.Hash{}) {
+			return hash
+		}
+	}
+	return evm.Context.GetHash(num)
+}
+
+// resolveCode returns the code associated with the provided account. After
+// Prague, it can also resolve code pointed to by a delegation designator.
+func (evm *EVM) resolveCode(addr common.Address) []byte {
+	code := evm.StateDB.GetCode(addr)
+	if !evm.chainRules.IsPrague {
+		return code
+	}
+	if target, ok := types.ParseDelegation(code); ok {
+		// Note we only follow one level of delegation.
+		return evm.StateDB.GetCode(target)
+	}
+	return code
+}
+
+// resolveCodeHash returns the code hash associated with the provided address.
+// After Prague, it can also resolve code hash of the account pointed to by a
+// delegation designator. Although this is not accessible in the EVM it is used
+// internally to associate jumpdest analysis to code.
+func (evm *EVM) resolveCodeHash(addr common.Address) common.Hash {
+	if evm.chainRules.IsPrague {
+		code := evm.StateDB.GetCode(addr)
+		if target, ok := types.ParseDelegation(code); ok {
+			// Note we only follow one level of delegation.
+			return evm.StateDB.GetCodeHash(target)
+		}
+	}
+	return evm.StateDB.GetCodeHash(addr)
+}
+
 type codeAndHash struct {
 	code []byte
 	hash common.Hash
@@ -430,8 +495,13 @@ func (c *codeAndHash) Hash() common.Hash {
 	return c.hash
 }
 
-// create creates a new contract using code as deployment code.
-func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64, value *uint256.Int, address common.Address, typ OpCode) ([]byte, common.Address, uint64, error) {
+// create creates a new contract using code as deployment code, which is
+// executed with the given input.
+//
+// From Osaka, EOF initcode may only be deployed by creation transactions and
+// EOFCREATE. The initcode of creation transactions is validated here, and the
+// bytes following the initcode container are passed as input.
+func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, input []byte, gas uint64, value *uint256.Int, address common.Address, typ OpCode) ([]byte, common.Address, uint64, error) {
 	// Depth check execution. Fail if we're trying to execute above the
 	// limit.
 	if evm.depth > int(params.CallCreateDepth) {
@@ -450,11 +520,42 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 	if evm.chainRules.IsBerlin {
 		evm.StateDB.AddAddressToAccessList(address)
 	}
+	var container *Container
+	if evm.chainRules.IsOsaka && HasEOFMagic(codeAndHash.code) {
+		container = new(Container)
+		switch {
+		case typ == EOFCREATE:
+			// Subcontainers are validated along with the creating container
+			if err := container.UnmarshalBinary(codeAndHash.code); err != nil {
+				return nil, common.Address{}, 0, err
+			}
+		case typ == CREATE && evm.depth == 0:
+			size, err := container.unmarshal(codeAndHash.code, true)
+			if err == nil {
+				err = container.ValidateCode(evm.interpreter.eofTable, true)
+			}
+			if err != nil {
+				return nil, common.Address{}, gas, fmt.Errorf("%w: %v", ErrInvalidEOFInitcode, err)
+			}
+			input = codeAndHash.code[size:]
+			codeAndHash.code = codeAndHash.code[:size]
+		default:
+			return nil, common.Address{}, 0, ErrLegacyEOFCreate
+		}
+	}
 	// Ensure there's no existing contract already at the designated address
 	contractHash := evm.StateDB.GetCodeHash(address)
 	if evm.StateDB.GetNonce(address) != 0 || (contractHash != (common.Hash{}) && contractHash != types.EmptyCodeHash) {
 		return nil, common.Address{}, 0, ErrContractAddressCollision
 	}
+	// Charge the witness costs of the new account's fields in verkle mode
+	if evm.chainRules.IsVerkle {
+		wgas := evm.AccessEvents.ContractCreateInitGas(address, !value.IsZero())
+		if gas < wgas {
+			return nil, common.Address{}, 0, ErrOutOfGas
+		}
+		gas -= wgas
+	}
 	// Create a new account on the state
 	snapshot := evm.StateDB.Snapshot()
 	evm.StateDB.CreateAccount(address)
@@ -467,6 +568,8 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 	// The contract is a scoped environment for this execution context only.
 	contract := NewContract(caller, AccountRef(address), value, gas)
 	contract.SetCodeOptionalHash(&address, codeAndHash)
+	contract.Container = container
+	contract.IsDeployment = true
 
 	if evm.Config.Tracer != nil {
 		if evm.depth == 0 {
@@ -476,15 +579,16 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 		}
 	}
 
-	ret, err := evm.interpreter.Run(contract, nil, false)
+	ret, err := evm.interpreter.Run(contract, input, false)
 
 	// Check whether the max code size has been exceeded, assign err if the case.
 	if err == nil && evm.chainRules.IsEIP158 && len(ret) > params.MaxCodeSize {
 		err = ErrMaxCodeSizeExceeded
 	}
 
-	// Reject code starting with 0xEF if EIP-3541 is enabled.
-	if err == nil && len(ret) >= 1 && ret[0] == 0xEF && evm.chainRules.IsLondon {
+	// Reject code starting with 0xEF if EIP-3541 is enabled. EOF initcode can
+	// only return validated containers.
+	if err == nil && len(ret) >= 1 && ret[0] == 0xEF && evm.chainRules.IsLondon && container == nil {
 		err = ErrInvalidCode
 	}
 
@@ -494,6 +598,11 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 	// by the error checking condition below.
 	if err == nil {
 		createDataGas := uint64(len(ret)) * params.CreateDataGas
+		if evm.chainRules.IsVerkle {
+			// The code is charged by the chunks written to the tree instead
+			createDataGas = evm.AccessEvents.ContractCreateCompletedGas(address)
+			createDataGas += evm.AccessEvents.CodeChunksRangeGas(address, 0, uint64(len(ret)), uint64(len(ret)), true)
+		}
 		if contract.UseGas(createDataGas) {
 			evm.StateDB.SetCode(address, ret)
 		} else {
@@ -524,7 +633,7 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 // Create creates a new contract using code as deployment code.
 func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, value *uint256.Int) (ret []byte, contractAddr common.Address, leftOverGas uint64, err error) {
 	contractAddr = crypto.CreateAddress(caller.Address(), evm.StateDB.GetNonce(caller.Address()))
-	return evm.create(caller, &codeAndHash{code: code}, gas, value, contractAddr, CREATE)
+	return evm.create(caller, &codeAndHash{code: code}, nil, gas, value, contractAddr, CREATE)
 }
 
 // Create2 creates a new contract using code as deployment code.
@@ -534,7 +643,18 @@ func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, value *uint2
 func (evm *EVM) Create2(caller ContractRef, code []byte, gas uint64, endowment *uint256.Int, salt *uint256.Int) (ret []byte, contractAddr common.Address, leftOverGas uint64, err error) {
 	codeAndHash := &codeAndHash{code: code}
 	contractAddr = crypto.CreateAddress2(caller.Address(), salt.Bytes32(), codeAndHash.Hash().Bytes())
-	return evm.create(caller, codeAndHash, gas, endowment, contractAddr, CREATE2)
+	return evm.create(caller, codeAndHash, nil, gas, endowment, contractAddr, CREATE2)
+}
+
+// EOFCreate creates a new contract from an EOF initcode container (EIP-7620),
+// which is executed with the given input.
+//
+// The contract is deployed at keccak256(0xff ++ msg.sender ++ salt)[12:], the
+// sender being left-padded to 32 bytes.
+func (evm *EVM) EOFCreate(caller ContractRef, initcode []byte, input []byte, gas uint64, endowment *uint256.Int, salt *uint256.Int) (ret []byte, contractAddr common.Address, leftOverGas uint64, err error) {
+	saltBytes := salt.Bytes32()
+	contractAddr = common.BytesToAddress(crypto.Keccak256([]byte{0xff}, common.LeftPadBytes(caller.Address().Bytes(), 32), saltBytes[:])[12:])
+	return evm.create(caller, &codeAndHash{code: initcode}, input, gas, endowment, contractAddr, EOFCREATE)
 }
 
 // ChainConfig returns the environment's chain configuration
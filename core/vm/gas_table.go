@@ -455,6 +455,56 @@ func gasStaticCall(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memo
 	return gas, nil
 }
 
+// makeGasExtCall creates the gas function of the EIP-7069 EXT*CALL family. The
+// forwarded gas is all but max(1/64th, MIN_RETAINED_GAS) of the available gas,
+// and if it falls below MIN_CALLEE_GAS the call fails without being entered,
+// which is signalled to the instruction by a zero callGasTemp.
+func makeGasExtCall(transfersValue bool) gasFunc {
+	return func(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+		gas, err := memoryGasCost(mem, memorySize)
+		if err != nil {
+			return 0, err
+		}
+		var overflow bool
+		// Invalid targets abort the execution, no need to charge for them
+		if target := stack.Back(0); target.BitLen() <= 160 {
+			addr := common.Address(target.Bytes20())
+			if !evm.StateDB.AddressInAccessList(addr) {
+				evm.StateDB.AddAddressToAccessList(addr)
+				if gas, overflow = math.SafeAdd(gas, params.ColdAccountAccessCostEIP2929-params.WarmStorageReadCostEIP2929); overflow {
+					return 0, ErrGasUintOverflow
+				}
+			}
+			if transfersValue && !stack.Back(3).IsZero() {
+				extra := params.CallValueTransferGas
+				if evm.StateDB.Empty(addr) {
+					extra += params.CallNewAccountGas
+				}
+				if gas, overflow = math.SafeAdd(gas, extra); overflow {
+					return 0, ErrGasUintOverflow
+				}
+			}
+		}
+		if contract.Gas < gas {
+			return 0, ErrOutOfGas
+		}
+		available := contract.Gas - gas
+		retained := max(available/64, params.ExtCallMinRetainedGas)
+		if available < retained+params.ExtCallMinCalleeGas {
+			evm.callGasTemp = 0
+		} else {
+			evm.callGasTemp = available - retained
+		}
+		return gas + evm.callGasTemp, nil
+	}
+}
+
+var (
+	gasExtCall         = makeGasExtCall(true)
+	gasExtDelegateCall = makeGasExtCall(false)
+	gasExtStaticCall   = makeGasExtCall(false)
+)
+
 func gasSelfdestruct(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
 	var gas uint64
 	// EIP150 homestead gas reprice fork:
@@ -17,6 +17,10 @@
 package vm
 
 import (
+	"fmt"
+	"slices"
+	"sync"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -34,6 +38,8 @@ type Config struct {
 	EnablePreimageRecording     bool                // Enables recording of SHA3/keccak preimages
 	ExtraEips                   []int               // Additional EIPS that are to be enabled
 	OptimismPrecompileOverrides PrecompileOverrides // Precompile overrides for Optimism
+	Checkpointer                CheckpointFunc      // Invoked with a checkpoint before every state-access instruction
+	FuseInstructions            bool                // Executes common instruction sequences as superinstructions
 }
 
 // ScopeContext contains the things that are per-call, such as stack and memory,
@@ -42,18 +48,26 @@ type ScopeContext struct {
 	Memory   *Memory
 	Stack    *Stack
 	Contract *Contract
+
+	CodeSection uint64           // EOF code section being executed
+	ReturnStack []*ReturnContext // EOF return positions of the CALLF instructions
 }
 
 // EVMInterpreter represents an EVM interpreter
 type EVMInterpreter struct {
-	evm   *EVM
-	table *JumpTable
+	evm      *EVM
+	table    *JumpTable
+	eofTable *JumpTable // Instructions of EOF code, nil before Osaka
+
+	containers map[common.Hash]*Container // Parsed EOF containers of the executed code by code hash
 
 	hasher    crypto.KeccakState // Keccak256 hasher instance shared across opcodes
 	hasherBuf common.Hash        // Keccak256 hasher result array shared across opcodes
 
 	readOnly   bool   // Whether to throw on stateful modifications
 	returnData []byte // Last CALL's return data for subsequent reuse
+
+	frames []*frame // Live call frames, only maintained if checkpointing is enabled
 }
 
 // NewEVMInterpreter returns a new instance of the Interpreter.
@@ -61,6 +75,12 @@ func NewEVMInterpreter(evm *EVM) *EVMInterpreter {
 	// If jump table was not initialised we set the default one.
 	var table *JumpTable
 	switch {
+	case evm.chainRules.IsVerkle:
+		table = &verkleInstructionSet
+	case evm.chainRules.IsOsaka:
+		table = &osakaInstructionSet
+	case evm.chainRules.IsPrague:
+		table = &pragueInstructionSet
 	case evm.chainRules.IsCancun:
 		table = &cancunInstructionSet
 	case evm.chainRules.IsShanghai:
@@ -86,21 +106,77 @@ func NewEVMInterpreter(evm *EVM) *EVMInterpreter {
 	default:
 		table = &frontierInstructionSet
 	}
-	var extraEips []int
 	if len(evm.Config.ExtraEips) > 0 {
-		// Deep-copy jumptable to prevent modification of opcodes in other tables
-		table = copyJumpTable(table)
+		// Disable the EIPs failing to activate, so caller can check if
+		// they're activated or not
+		table, evm.Config.ExtraEips = extendJumpTable(table, evm.Config.ExtraEips)
+	}
+
+	in := &EVMInterpreter{evm: evm, table: table}
+	if evm.chainRules.IsOsaka {
+		in.eofTable = &eofInstructionSet
+	}
+	return in
+}
+
+// extendedJumpTable is an instruction set with extra EIPs enabled on top of a
+// fork's one.
+type extendedJumpTable struct {
+	table *JumpTable
+	eips  []int // EIPs successfully enabled
+}
+
+var (
+	// extendedJumpTables interns the instruction sets with extra EIPs, keyed by
+	// the fork's set and the requested EIPs. The analyses of the code are cached
+	// per instruction set, so identical configurations have to share it.
+	extendedJumpTables     = make(map[string]*extendedJumpTable)
+	extendedJumpTablesLock sync.Mutex
+)
+
+// extendJumpTable returns the instruction set with the given EIPs enabled on
+// top of the base one, along with the EIPs that could be enabled.
+func extendJumpTable(base *JumpTable, eips []int) (*JumpTable, []int) {
+	key := fmt.Sprintf("%p%v", base, eips)
+
+	extendedJumpTablesLock.Lock()
+	defer extendedJumpTablesLock.Unlock()
+
+	if ext, ok := extendedJumpTables[key]; ok {
+		return ext.table, slices.Clone(ext.eips)
 	}
-	for _, eip := range evm.Config.ExtraEips {
-		if err := EnableEIP(eip, table); err != nil {
-			// Disable it, so caller can check if it's activated or not
+	// Deep-copy jumptable to prevent modification of opcodes in other tables
+	ext := &extendedJumpTable{table: copyJumpTable(base)}
+	for _, eip := range eips {
+		if err := EnableEIP(eip, ext.table); err != nil {
 			log.Error("EIP activation failed", "eip", eip, "error", err)
 		} else {
-			extraEips = append(extraEips, eip)
+			ext.eips = append(ext.eips, eip)
+		}
+	}
+	extendedJumpTables[key] = ext
+	return ext.table, slices.Clone(ext.eips)
+}
+
+// container returns the parsed EOF container of the contract's code, or nil if
+// it cannot be parsed. Deployed code doesn't change, so the containers are only
+// parsed once and cached by code hash.
+func (in *EVMInterpreter) container(contract *Contract) *Container {
+	if container, ok := in.containers[contract.CodeHash]; ok {
+		return container
+	}
+	container := new(Container)
+	if container.UnmarshalBinary(contract.Code) != nil {
+		container = nil
+	}
+	// Initcode might be executed without a code hash, don't cache it
+	if contract.CodeHash != (common.Hash{}) {
+		if in.containers == nil {
+			in.containers = make(map[common.Hash]*Container)
 		}
+		in.containers[contract.CodeHash] = container
 	}
-	evm.Config.ExtraEips = extraEips
-	return &EVMInterpreter{evm: evm, table: table}
+	return container
 }
 
 // Run loops and evaluates the contract's code with the given input data and returns
@@ -110,6 +186,14 @@ func NewEVMInterpreter(evm *EVM) *EVMInterpreter {
 // considered a revert-and-consume-all-gas operation except for
 // ErrExecutionReverted which means revert-and-keep-gas-left.
 func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (ret []byte, err error) {
+	return in.run(contract, input, readOnly, -1, nil)
+}
+
+// run is the implementation of Run. The snapshot is the state revision the
+// calling EVM method reverts to if the frame fails, or -1 if the frame cannot
+// be checkpointed (i.e. contract creation). If resume is set, the execution is
+// not started afresh but continued from the given checkpointed frames.
+func (in *EVMInterpreter) run(contract *Contract, input []byte, readOnly bool, snapshot int, resume []*FrameCheckpoint) (ret []byte, err error) {
 	// Increment the call depth which is restricted to 1024
 	in.evm.depth++
 	defer func() { in.evm.depth-- }()
@@ -129,6 +213,18 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 	if len(contract.Code) == 0 {
 		return nil, nil
 	}
+	// From Osaka, code starting with the EOF magic is executed as an EOF
+	// container, which was validated before deployment.
+	table := in.table
+	if in.eofTable != nil {
+		if contract.Container == nil && HasEOFMagic(contract.Code) {
+			contract.Container = in.container(contract)
+		}
+		if contract.Container != nil {
+			table = in.eofTable
+		}
+	}
+	isEOF := contract.Container != nil
 
 	var (
 		op          OpCode        // current opcode
@@ -150,6 +246,9 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 		logged  bool   // deferred EVMLogger should ignore already logged steps
 		res     []byte // result of the opcode execution function
 		debug   = in.evm.Config.Tracer != nil
+
+		checkpointing = in.evm.Config.Checkpointer != nil
+		resumed       bool // whether the first instruction is the resumed one
 	)
 	// Don't move this deferred function, it's placed before the capturestate-deferred method,
 	// so that it gets executed _after_: the capturestate needs the stacks before
@@ -170,6 +269,43 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 			}
 		}()
 	}
+	if checkpointing {
+		in.frames = append(in.frames, &frame{pc: &pc, scope: callContext, snapshot: snapshot, readOnly: in.readOnly})
+		defer func() { in.frames = in.frames[:len(in.frames)-1] }()
+	}
+	if resume != nil {
+		if resumed, err = in.restore(&pc, callContext, resume); err != nil {
+			return nil, err
+		}
+	}
+	// In verkle mode the code chunks of the executed instructions are part of
+	// the witness (EIP-4762), except for initcode which isn't in the state.
+	var (
+		chargeChunks = in.evm.chainRules.IsVerkle && !isEOF && !contract.IsDeployment
+		codeAddr     = contract.Address()
+	)
+	if contract.CodeAddr != nil {
+		codeAddr = *contract.CodeAddr
+	}
+	// Unless every instruction has to be observed or charged on its own, legacy
+	// code is executed in basic blocks, whose static gas and stack requirements
+	// are checked on entry. Blocks failing the checks are executed instruction
+	// by instruction, so that errors occur at the exact same instruction.
+	var (
+		blocks  codeBlocks
+		block   = -1   // index of the current block, -1 if outside of any
+		last    uint64 // position of the last instruction of the current block
+		enter   = true // whether the next instruction starts a block
+		prepaid bool   // whether the current block was checked and charged
+		fused   []byte // superinstruction starting at each position, if any
+	)
+	if !debug && !checkpointing && !chargeChunks && !isEOF && resume == nil {
+		blocks = in.codeBlocks(contract)
+		contract.blocks = blocks
+		if in.evm.Config.FuseInstructions {
+			fused = in.codeFusion(contract)
+		}
+	}
 	// The Interpreter main run loop (contextual). This loop runs until either an
 	// explicit STOP, RETURN or SELFDESTRUCT is executed, an error occurred during
 	// the execution of one of the operations or until the done flag is set by the
@@ -181,17 +317,73 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 		}
 		// Get the operation from the jump table and validate the stack to ensure there are
 		// enough stack items available to perform the operation.
-		op = contract.GetOp(pc)
-		operation := in.table[op]
+		if isEOF {
+			op = contract.getSectionOp(callContext.CodeSection, pc)
+		} else {
+			op = contract.GetOp(pc)
+		}
+		operation := table[op]
 		cost = operation.constantGas // For tracing
-		// Validate stack
-		if sLen := stack.len(); sLen < operation.minStack {
-			return nil, &ErrStackUnderflow{stackLen: sLen, required: operation.minStack}
-		} else if sLen > operation.maxStack {
-			return nil, &ErrStackOverflow{stackLen: sLen, limit: operation.maxStack}
+		// Offer a checkpoint before state accesses, except for the instruction
+		// the execution was just resumed at
+		if checkpointing {
+			if !resumed && isCheckpointOp(op) && in.checkpoint() {
+				return nil, ErrExecutionSuspended
+			}
+			resumed = false
 		}
-		if !contract.UseGas(cost) {
-			return nil, ErrOutOfGas
+		if blocks != nil {
+			if enter {
+				if block+1 < len(blocks) && blocks[block+1].start == pc {
+					block++
+				} else {
+					block = blocks.find(pc)
+				}
+				prepaid, last = false, pc
+				if block >= 0 {
+					b := &blocks[block]
+					if sLen := stack.len(); sLen >= b.minStack && sLen <= b.maxStack && contract.Gas >= b.gas {
+						contract.Gas -= b.gas
+						prepaid = true
+					}
+					last = b.last
+				}
+			}
+			enter = pc == last
+
+			// Within a checked block, pairs of instructions can be executed
+			// at once, the block ends if the second one was its last.
+			if prepaid && fused != nil && fused[pc] != fuseNone {
+				second := pc + 1
+				if op >= PUSH1 && op <= PUSH32 {
+					second += uint64(op - PUSH0)
+				}
+				enter = second == last
+				res, err = superinstructions[fused[pc]](&pc, in, callContext)
+				if err != nil {
+					break
+				}
+				pc++
+				continue
+			}
+		}
+		if !prepaid {
+			// Validate stack
+			if sLen := stack.len(); sLen < operation.minStack {
+				return nil, &ErrStackUnderflow{stackLen: sLen, required: operation.minStack}
+			} else if sLen > operation.maxStack {
+				return nil, &ErrStackOverflow{stackLen: sLen, limit: operation.maxStack}
+			}
+			if chargeChunks {
+				size := uint64(1)
+				if op >= PUSH1 && op <= PUSH32 {
+					size += uint64(op - PUSH0) // immediates of the push
+				}
+				cost += in.evm.AccessEvents.CodeChunksRangeGas(codeAddr, pc, size, uint64(len(contract.Code)), false)
+			}
+			if !contract.UseGas(cost) {
+				return nil, ErrOutOfGas
+			}
 		}
 		if operation.dynamicGas != nil {
 			// All ops with a dynamic memory usage also has a dynamic gas cost.
@@ -17,6 +17,7 @@
 package vm
 
 import (
+	"slices"
 	"testing"
 	"time"
 
@@ -75,3 +76,21 @@ func TestLoopInterrupt(t *testing.T) {
 		}
 	}
 }
+
+// Tests that EVMs enabling the same extra EIPs share their instruction set, and
+// with it the code analyses cached per instruction set.
+func TestExtraEipsSharedTable(t *testing.T) {
+	newEVM := func(eips ...int) *EVM {
+		return NewEVM(BlockContext{}, TxContext{}, nil, params.AllEthashProtocolChanges, Config{ExtraEips: eips})
+	}
+	a, b := newEVM(3855, 9999), newEVM(3855, 9999)
+	if a.interpreter.table != b.interpreter.table {
+		t.Error("identical configurations use distinct instruction sets")
+	}
+	if want := []int{3855}; !slices.Equal(a.Config.ExtraEips, want) || !slices.Equal(b.Config.ExtraEips, want) {
+		t.Errorf("enabled EIPs mismatch: have %v and %v, want %v", a.Config.ExtraEips, b.Config.ExtraEips, want)
+	}
+	if c := newEVM(1344); c.interpreter.table == a.interpreter.table {
+		t.Error("distinct configurations share their instruction set")
+	}
+}
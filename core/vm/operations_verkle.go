@@ -0,0 +1,212 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// The gas functions of EIP-4762 replace the EIP-2929 access list charges by
+// the witness costs of the touched verkle tree leaves. An access to a leaf
+// that is already part of the witness is charged the warm storage read cost.
+
+func gasSStore4762(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+	gas := evm.AccessEvents.SlotGas(contract.Address(), stack.peek().Bytes32(), true)
+	if gas == 0 {
+		gas = params.WarmStorageReadCostEIP2929
+	}
+	return gas, nil
+}
+
+func gasSLoad4762(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+	gas := evm.AccessEvents.SlotGas(contract.Address(), stack.peek().Bytes32(), false)
+	if gas == 0 {
+		gas = params.WarmStorageReadCostEIP2929
+	}
+	return gas, nil
+}
+
+func gasBalance4762(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+	gas := evm.AccessEvents.BalanceGas(stack.peek().Bytes20(), false)
+	if gas == 0 {
+		gas = params.WarmStorageReadCostEIP2929
+	}
+	return gas, nil
+}
+
+func gasExtCodeSize4762(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+	address := common.Address(stack.peek().Bytes20())
+	if _, isPrecompile := evm.precompile(address); isPrecompile {
+		return 0, nil
+	}
+	gas := evm.AccessEvents.VersionGas(address, false)
+	gas += evm.AccessEvents.CodeSizeGas(address, false)
+	if gas == 0 {
+		gas = params.WarmStorageReadCostEIP2929
+	}
+	return gas, nil
+}
+
+func gasExtCodeHash4762(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+	address := common.Address(stack.peek().Bytes20())
+	if _, isPrecompile := evm.precompile(address); isPrecompile {
+		return 0, nil
+	}
+	gas := evm.AccessEvents.CodeHashGas(address, false)
+	if gas == 0 {
+		gas = params.WarmStorageReadCostEIP2929
+	}
+	return gas, nil
+}
+
+// gasBlockHash4762 charges the access to the EIP-2935 history storage slot
+// that BLOCKHASH reads the hash from.
+func gasBlockHash4762(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+	num, overflow := stack.peek().Uint64WithOverflow()
+	if overflow {
+		return 0, nil
+	}
+	upper := evm.Context.BlockNumber.Uint64()
+	if num >= upper || upper-num > 256 {
+		return 0, nil
+	}
+	slot := common.BigToHash(new(big.Int).SetUint64(num % params.HistoryServeWindow))
+	return evm.AccessEvents.SlotGas(params.HistoryStorageAddress, slot, false), nil
+}
+
+// makeCallVariantGasEIP4762 charges the witness costs of the callee before
+// the old calculator, so that they are accounted for in the 63/64ths rule.
+func makeCallVariantGasEIP4762(oldCalculator gasFunc, withValue bool) gasFunc {
+	return func(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+		var (
+			target     = common.Address(stack.Back(1).Bytes20())
+			witnessGas uint64
+		)
+		_, isPrecompile := evm.precompile(target)
+		switch {
+		case withValue && !stack.Back(2).IsZero():
+			witnessGas = evm.AccessEvents.ValueTransferGas(contract.Address(), target)
+		case isPrecompile || target == params.HistoryStorageAddress:
+			witnessGas = params.WarmStorageReadCostEIP2929
+		default:
+			witnessGas = evm.AccessEvents.MessageCallGas(target)
+		}
+		if witnessGas == 0 {
+			witnessGas = params.WarmStorageReadCostEIP2929
+		}
+		if !contract.UseGas(witnessGas) {
+			return 0, ErrOutOfGas
+		}
+		gas, err := oldCalculator(evm, contract, stack, mem, memorySize)
+		// Add the witness charge back, it is charged as part of the dynamic
+		// gas so that tracers report it correctly.
+		contract.Gas += witnessGas
+		if err != nil {
+			return 0, err
+		}
+		var overflow bool
+		if gas, overflow = math.SafeAdd(gas, witnessGas); overflow {
+			return 0, ErrGasUintOverflow
+		}
+		return gas, nil
+	}
+}
+
+var (
+	gasCallEIP4762         = makeCallVariantGasEIP4762(gasCall, true)
+	gasCallCodeEIP4762     = makeCallVariantGasEIP4762(gasCallCode, true)
+	gasStaticCallEIP4762   = makeCallVariantGasEIP4762(gasStaticCall, false)
+	gasDelegateCallEIP4762 = makeCallVariantGasEIP4762(gasDelegateCall, false)
+)
+
+func gasSelfdestructEIP4762(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+	beneficiaryAddr := common.Address(stack.peek().Bytes20())
+	contractAddr := contract.Address()
+
+	gas := evm.AccessEvents.BalanceGas(contractAddr, false)
+	balanceIsZero := evm.StateDB.GetBalance(contractAddr).IsZero()
+	if _, isPrecompile := evm.precompile(beneficiaryAddr); !(isPrecompile && balanceIsZero) {
+		gas += evm.AccessEvents.BalanceGas(beneficiaryAddr, false)
+	}
+	if !balanceIsZero {
+		gas += evm.AccessEvents.BalanceGas(contractAddr, true)
+		gas += evm.AccessEvents.BalanceGas(beneficiaryAddr, true)
+	}
+	return gas, nil
+}
+
+func gasCodeCopyEip4762(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+	gas, err := gasCodeCopy(evm, contract, stack, mem, memorySize)
+	if err != nil {
+		return 0, err
+	}
+	if contract.IsDeployment {
+		return gas, nil
+	}
+	start, size := copiedCodeRange(contract.Code, stack.Back(1), stack.Back(2).Uint64())
+	var overflow bool
+	if gas, overflow = math.SafeAdd(gas, evm.AccessEvents.CodeChunksRangeGas(contract.Address(), start, size, uint64(len(contract.Code)), false)); overflow {
+		return 0, ErrGasUintOverflow
+	}
+	return gas, nil
+}
+
+func gasExtCodeCopyEIP4762(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+	// memory expansion first (dynamic part of pre-2929 implementation)
+	gas, err := gasExtCodeCopy(evm, contract, stack, mem, memorySize)
+	if err != nil {
+		return 0, err
+	}
+	addr := common.Address(stack.peek().Bytes20())
+	if _, isPrecompile := evm.precompile(addr); isPrecompile {
+		return gas, nil
+	}
+	witnessGas := evm.AccessEvents.VersionGas(addr, false)
+	witnessGas += evm.AccessEvents.CodeSizeGas(addr, false)
+	if witnessGas == 0 {
+		witnessGas = params.WarmStorageReadCostEIP2929
+	}
+	// The code of EOF contracts is opaque, none of its chunks are read
+	if code := evm.StateDB.GetCode(addr); !HasEOFMagic(code) {
+		start, size := copiedCodeRange(code, stack.Back(2), stack.Back(3).Uint64())
+		witnessGas += evm.AccessEvents.CodeChunksRangeGas(addr, start, size, uint64(len(code)), false)
+	}
+	var overflow bool
+	if gas, overflow = math.SafeAdd(gas, witnessGas); overflow {
+		return 0, ErrGasUintOverflow
+	}
+	return gas, nil
+}
+
+// copiedCodeRange returns the start and size of the part of the code that a
+// copy of the given length from the given offset reads, without the padding.
+func copiedCodeRange(code []byte, offset *uint256.Int, length uint64) (uint64, uint64) {
+	start, overflow := offset.Uint64WithOverflow()
+	if overflow || start > uint64(len(code)) {
+		start = uint64(len(code))
+	}
+	end := start + length
+	if end < start || end > uint64(len(code)) {
+		end = uint64(len(code))
+	}
+	return start, end - start
+}
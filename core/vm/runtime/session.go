@@ -0,0 +1,511 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package runtime
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip1559"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Session is an execution environment keeping its state across transactions
+// and blocks. Transactions are applied to a pending block built from the
+// config, with receipts and logs, until NextBlock seals it and starts the next
+// one. A session is not safe for concurrent use.
+type Session struct {
+	cfg   *Config
+	state *state.StateDB
+
+	header *types.Header // Header of the pending block, without results
+	first  uint64        // Number of the first block of the session
+	hashes []common.Hash // Hashes of the blocks sealed by the session
+	gp     *core.GasPool // Gas left in the pending block
+	used   uint64        // Gas used by the pending block
+	txs    []*types.Transaction
+	recps  []*types.Receipt
+
+	snapshots []sessionSnapshot
+}
+
+// sessionSnapshot is everything needed to revert a session.
+type sessionSnapshot struct {
+	state  *state.StateDB
+	header *types.Header
+	hashes []common.Hash
+	gas    uint64
+	used   uint64
+	txs    []*types.Transaction
+	recps  []*types.Receipt
+}
+
+// NewSession creates a session starting from the state and the block given by
+// the config, or from an empty state if none is set.
+func NewSession(cfg *Config) *Session {
+	setDefaults(cfg)
+
+	if cfg.State == nil {
+		cfg.State, _ = state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	}
+	header := &types.Header{
+		Coinbase:   cfg.Coinbase,
+		Number:     new(big.Int).Set(cfg.BlockNumber),
+		GasLimit:   cfg.GasLimit,
+		Time:       cfg.Time,
+		Difficulty: cfg.Difficulty,
+		BaseFee:    cfg.BaseFee,
+	}
+	if cfg.Random != nil {
+		header.MixDigest = *cfg.Random
+	}
+	return &Session{
+		cfg:    cfg,
+		state:  cfg.State,
+		header: header,
+		first:  header.Number.Uint64(),
+		gp:     new(core.GasPool).AddGas(header.GasLimit),
+	}
+}
+
+// State returns the current state of the session. The returned state is
+// replaced by NextBlock and RevertToSnapshot.
+func (s *Session) State() *state.StateDB {
+	return s.state
+}
+
+// Header returns the header of the pending block. The results of the block,
+// such as the state root, are only filled in by NextBlock.
+func (s *Session) Header() *types.Header {
+	return types.CopyHeader(s.header)
+}
+
+// Receipts returns the receipts of the transactions in the pending block.
+func (s *Session) Receipts() []*types.Receipt {
+	return s.recps
+}
+
+// BlockContext returns the block context of the pending block.
+func (s *Session) BlockContext() vm.BlockContext {
+	return s.blockContext(s.state)
+}
+
+func (s *Session) blockContext(statedb *state.StateDB) vm.BlockContext {
+	return vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		GetHash:     s.getHash,
+		Coinbase:    s.header.Coinbase,
+		BlockNumber: new(big.Int).Set(s.header.Number),
+		Time:        s.header.Time,
+		Difficulty:  s.header.Difficulty,
+		GasLimit:    s.header.GasLimit,
+		BaseFee:     s.header.BaseFee,
+		BlobBaseFee: s.cfg.BlobBaseFee,
+		Random:      s.cfg.Random,
+		L1CostFunc:  types.NewL1CostFunc(s.cfg.ChainConfig, statedb),
+	}
+}
+
+// getHash returns the hashes of the blocks sealed by the session, deferring
+// to the configured GetHashFn for the ones preceding it.
+func (s *Session) getHash(n uint64) common.Hash {
+	if n >= s.first && n-s.first < uint64(len(s.hashes)) {
+		return s.hashes[n-s.first]
+	}
+	return s.cfg.GetHashFn(n)
+}
+
+// signer returns the signer of the pending block.
+func (s *Session) signer() types.Signer {
+	return types.MakeSigner(s.cfg.ChainConfig, s.header.Number, s.header.Time)
+}
+
+// ApplyTransaction applies a signed transaction to the pending block. If the
+// transaction is invalid, an error is returned and the session is unchanged.
+func (s *Session) ApplyTransaction(tx *types.Transaction) (*types.Receipt, *core.ExecutionResult, error) {
+	msg, err := core.TransactionToMessage(tx, s.signer(), s.header.BaseFee)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.apply(tx, msg)
+}
+
+// ApplyUnsignedTransaction applies a transaction sent from the given account
+// to the pending block, without checking the signature of the transaction.
+func (s *Session) ApplyUnsignedTransaction(tx *types.Transaction, from common.Address) (*types.Receipt, *core.ExecutionResult, error) {
+	// The message is filled in even if the sender can't be recovered
+	msg, _ := core.TransactionToMessage(tx, s.signer(), s.header.BaseFee)
+	msg.From = from
+	return s.apply(tx, msg)
+}
+
+// apply executes a transaction on the session state, reverting the state and
+// the gas pool if it's invalid.
+func (s *Session) apply(tx *types.Transaction, msg *core.Message) (*types.Receipt, *core.ExecutionResult, error) {
+	var (
+		snap = s.state.Snapshot()
+		gas  = s.gp.Gas()
+	)
+	s.state.SetTxContext(tx.Hash(), len(s.txs))
+	receipt, result, err := s.execute(s.state, s.gp, tx, msg)
+	if err != nil {
+		s.state.RevertToSnapshot(snap)
+		s.gp.SetGas(gas)
+		return nil, nil, err
+	}
+	s.include(tx, receipt)
+	return receipt, result, nil
+}
+
+// execute runs a message on the given state and finalises it. The returned
+// receipt lacks the cumulative gas used, which depends on the block.
+func (s *Session) execute(statedb *state.StateDB, gp *core.GasPool, tx *types.Transaction, msg *core.Message) (*types.Receipt, *core.ExecutionResult, error) {
+	var (
+		config = s.cfg.ChainConfig
+		number = s.header.Number
+		evm    = vm.NewEVM(s.blockContext(statedb), core.NewEVMTxContext(msg), statedb, config, s.cfg.EVMConfig)
+	)
+	result, err := core.ApplyMessage(evm, msg, gp)
+	if err != nil {
+		return nil, nil, err
+	}
+	if result.Suspended() {
+		return nil, nil, result.Err
+	}
+	var root []byte
+	if config.IsByzantium(number) {
+		statedb.Finalise(true)
+	} else {
+		root = statedb.IntermediateRoot(config.IsEIP158(number)).Bytes()
+	}
+	receipt := &types.Receipt{
+		Type:              tx.Type(),
+		PostState:         root,
+		Status:            types.ReceiptStatusSuccessful,
+		TxHash:            tx.Hash(),
+		GasUsed:           result.UsedGas,
+		BlockNumber:       new(big.Int).Set(number),
+		TransactionIndex:  uint(statedb.TxIndex()),
+		EffectiveGasPrice: new(big.Int).Set(msg.GasPrice),
+	}
+	if result.Failed() {
+		receipt.Status = types.ReceiptStatusFailed
+	}
+	if tx.Type() == types.BlobTxType {
+		receipt.BlobGasUsed = uint64(len(tx.BlobHashes()) * params.BlobTxBlobGasPerBlob)
+		receipt.BlobGasPrice = evm.Context.BlobBaseFee
+	}
+	if msg.To == nil {
+		receipt.ContractAddress = crypto.CreateAddress(msg.From, tx.Nonce())
+	}
+	receipt.Logs = statedb.GetLogs(tx.Hash(), number.Uint64(), common.Hash{})
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	return receipt, result, nil
+}
+
+// include adds an executed transaction to the pending block.
+func (s *Session) include(tx *types.Transaction, receipt *types.Receipt) {
+	s.used += receipt.GasUsed
+	receipt.CumulativeGasUsed = s.used
+
+	s.txs = append(s.txs, tx)
+	s.recps = append(s.recps, receipt)
+}
+
+// NextBlock seals the pending block, commits its state and starts the next
+// block with the given timestamp. The receipts of the sealed block are
+// updated with its hash.
+func (s *Session) NextBlock(time uint64) (*types.Block, error) {
+	if time <= s.header.Time {
+		return nil, fmt.Errorf("invalid timestamp: have %d, parent %d", time, s.header.Time)
+	}
+	config := s.cfg.ChainConfig
+	root, err := s.state.Commit(s.header.Number.Uint64(), config.IsEIP158(s.header.Number))
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := state.New(root, s.state.Database(), nil)
+	if err != nil {
+		return nil, err
+	}
+	header := types.CopyHeader(s.header)
+	header.Root = root
+	header.GasUsed = s.used
+
+	block := types.NewBlock(header, s.txs, nil, s.recps, trie.NewStackTrie(nil))
+	for _, receipt := range s.recps {
+		receipt.BlockHash = block.Hash()
+		for _, log := range receipt.Logs {
+			log.BlockHash = block.Hash()
+		}
+	}
+	next := &types.Header{
+		ParentHash: block.Hash(),
+		Coinbase:   header.Coinbase,
+		Number:     new(big.Int).Add(header.Number, common.Big1),
+		GasLimit:   header.GasLimit,
+		Time:       time,
+		Difficulty: header.Difficulty,
+		MixDigest:  header.MixDigest,
+		BaseFee:    s.cfg.BaseFee,
+	}
+	if config.IsLondon(next.Number) {
+		next.BaseFee = eip1559.CalcBaseFee(config, block.Header(), time)
+	}
+	s.state, s.header = statedb, next
+	s.hashes = append(s.hashes, block.Hash())
+	s.gp = new(core.GasPool).AddGas(next.GasLimit)
+	s.used, s.txs, s.recps = 0, nil, nil
+
+	return block, nil
+}
+
+// Snapshot returns an identifier for the current state of the session, which
+// can be restored by RevertToSnapshot, across blocks too.
+func (s *Session) Snapshot() int {
+	s.snapshots = append(s.snapshots, sessionSnapshot{
+		state:  s.state.Fork(),
+		header: s.header,
+		hashes: s.hashes,
+		gas:    s.gp.Gas(),
+		used:   s.used,
+		txs:    s.txs,
+		recps:  s.recps,
+	})
+	return len(s.snapshots) - 1
+}
+
+// RevertToSnapshot restores the session to a snapshot. The snapshot and all
+// the ones taken after it are invalidated.
+func (s *Session) RevertToSnapshot(id int) {
+	if id < 0 || id >= len(s.snapshots) {
+		panic(fmt.Errorf("session snapshot id %v cannot be reverted", id))
+	}
+	snap := s.snapshots[id]
+	s.snapshots = s.snapshots[:id]
+
+	s.state, s.header, s.hashes = snap.state, snap.header, snap.hashes
+	s.gp = new(core.GasPool).AddGas(snap.gas)
+	s.used, s.txs, s.recps = snap.used, snap.txs, snap.recps
+}
+
+// ApplyBatch applies signed transactions to the pending block in order,
+// stopping at the first invalid one. If more than one worker is requested,
+// the transactions are executed speculatively in parallel on forks of the
+// state and merged in order, unless they read something modified by an
+// earlier transaction of the batch, in which case they're executed again.
+// The results are the same as the ones of sequential execution.
+func (s *Session) ApplyBatch(txs []*types.Transaction, workers int) ([]*types.Receipt, error) {
+	// Tracers expect sequential execution, and receipts before Byzantium
+	// contain intermediate roots.
+	if workers <= 1 || s.cfg.EVMConfig.Tracer != nil || !s.cfg.ChainConfig.IsByzantium(s.header.Number) {
+		receipts := make([]*types.Receipt, 0, len(txs))
+		for _, tx := range txs {
+			receipt, _, err := s.ApplyTransaction(tx)
+			if err != nil {
+				return receipts, err
+			}
+			receipts = append(receipts, receipt)
+		}
+		return receipts, nil
+	}
+	return newBatch(s, txs).run(workers)
+}
+
+// speculation is a transaction executed on a speculative fork of the state.
+type speculation struct {
+	tx  *types.Transaction
+	msg *core.Message
+
+	state   *state.StateDB
+	reads   *state.ReadSet
+	writes  *state.TxWrites
+	receipt *types.Receipt
+	err     error
+}
+
+// batch is the parallel execution of transactions in a session.
+type batch struct {
+	session *Session
+	origin  *state.StateDB // Untouched fork of the state before the batch
+	specs   []*speculation
+
+	// Accounts and slots modified by the transactions merged so far. Accounts
+	// are only included if their balance, nonce, code or existence changed.
+	accounts map[common.Address]struct{}
+	slots    map[common.Address]map[common.Hash]struct{}
+}
+
+func newBatch(s *Session, txs []*types.Transaction) *batch {
+	b := &batch{
+		session:  s,
+		origin:   s.state.Fork(),
+		specs:    make([]*speculation, len(txs)),
+		accounts: make(map[common.Address]struct{}),
+		slots:    make(map[common.Address]map[common.Hash]struct{}),
+	}
+	signer := s.signer()
+	for i, tx := range txs {
+		spec := &speculation{tx: tx}
+		if spec.msg, spec.err = core.TransactionToMessage(tx, signer, s.header.BaseFee); spec.err == nil {
+			spec.state = s.state.Speculate()
+		}
+		b.specs[i] = spec
+	}
+	return b
+}
+
+// run executes the transactions on the workers and merges them in order.
+func (b *batch) run(workers int) ([]*types.Receipt, error) {
+	var (
+		s     = b.session
+		queue = make(chan int)
+		wg    sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				b.speculate(b.specs[i], len(s.txs)+i)
+			}
+		}()
+	}
+	for i, spec := range b.specs {
+		if spec.err == nil {
+			queue <- i
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	receipts := make([]*types.Receipt, 0, len(b.specs))
+	for _, spec := range b.specs {
+		if spec.err != nil || b.conflicts(spec) || s.gp.Gas() < spec.tx.Gas() {
+			// Execute the transaction again on the merged state
+			prev := s.state.Fork()
+			s.state.StartWriteLog()
+			receipt, _, err := s.ApplyTransaction(spec.tx)
+			writes := s.state.StopWriteLog()
+			if err != nil {
+				return receipts, err
+			}
+			for _, w := range writes {
+				b.record(prev, s.state, w)
+			}
+			receipts = append(receipts, receipt)
+			continue
+		}
+		s.state.SetTxContext(spec.tx.Hash(), len(s.txs))
+		if err := s.state.Merge(spec.state, b.origin); err != nil {
+			return receipts, err
+		}
+		// Re-index the logs relative to the merged ones
+		for _, log := range spec.receipt.Logs {
+			s.state.AddLog(log)
+		}
+		s.state.Finalise(true)
+		s.gp.SubGas(spec.receipt.GasUsed)
+		s.include(spec.tx, spec.receipt)
+
+		if spec.writes != nil {
+			b.record(b.origin, spec.state, spec.writes)
+		}
+		receipts = append(receipts, spec.receipt)
+	}
+	return receipts, nil
+}
+
+// speculate executes a transaction on its fork of the state, gathering the
+// accounts and slots it reads and writes.
+func (b *batch) speculate(spec *speculation, index int) {
+	spec.reads = state.NewReadSet()
+	spec.state.SetTxContext(spec.tx.Hash(), index)
+	spec.state.SetReadSet(spec.reads)
+	spec.state.StartWriteLog()
+
+	gp := new(core.GasPool).AddGas(b.session.gp.Gas())
+	spec.receipt, _, spec.err = b.session.execute(spec.state, gp, spec.tx, spec.msg)
+
+	spec.state.SetReadSet(nil)
+	if writes := spec.state.StopWriteLog(); len(writes) > 0 {
+		spec.writes = writes[0]
+	}
+}
+
+// conflicts reports whether a speculative execution read anything modified by
+// the transactions merged so far, or recreated an account they modified.
+func (b *batch) conflicts(spec *speculation) bool {
+	for addr := range spec.reads.Accounts {
+		if _, ok := b.accounts[addr]; ok {
+			return true
+		}
+	}
+	for addr, keys := range spec.reads.Slots {
+		for key := range keys {
+			if _, ok := b.slots[addr][key]; ok {
+				return true
+			}
+		}
+	}
+	if spec.writes != nil {
+		for _, addr := range spec.writes.Accounts {
+			if !spec.state.Recreated(addr) {
+				continue
+			}
+			if _, ok := b.accounts[addr]; ok {
+				return true
+			}
+			if _, ok := b.slots[addr]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// record adds the modifications of a transaction to the ones of the batch,
+// comparing the written accounts before and after it.
+func (b *batch) record(prev, post *state.StateDB, writes *state.TxWrites) {
+	for _, addr := range writes.Accounts {
+		if post.Recreated(addr) ||
+			prev.Exist(addr) != post.Exist(addr) ||
+			prev.GetBalance(addr).Cmp(post.GetBalance(addr)) != 0 ||
+			prev.GetNonce(addr) != post.GetNonce(addr) ||
+			prev.GetCodeHash(addr) != post.GetCodeHash(addr) {
+			b.accounts[addr] = struct{}{}
+		}
+	}
+	for addr, keys := range writes.Slots {
+		if b.slots[addr] == nil {
+			b.slots[addr] = make(map[common.Hash]struct{})
+		}
+		for _, key := range keys {
+			b.slots[addr][key] = struct{}{}
+		}
+	}
+}
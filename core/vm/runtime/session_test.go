@@ -0,0 +1,214 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package runtime
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+var (
+	// counterAddr increments slot 0 and logs the new value
+	counterAddr = common.HexToAddress("0xc0")
+	counterCode = common.FromHex("6000546001018060005560005260206000a000")
+
+	// blockhashAddr stores the hash of the previous block in slot 0
+	blockhashAddr = common.HexToAddress("0xb0")
+	blockhashCode = common.FromHex("600143034060005500")
+)
+
+// newTestSession creates a session with the test contracts and the given
+// number of funded accounts. The coinbase is funded too, unless it's zero.
+func newTestSession(t *testing.T, accounts int, coinbase common.Address) (*Session, []*ecdsa.PrivateKey) {
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(counterAddr, counterCode)
+	statedb.SetCode(blockhashAddr, blockhashCode)
+	if coinbase != (common.Address{}) {
+		statedb.AddBalance(coinbase, uint256.NewInt(1))
+	}
+
+	keys := make([]*ecdsa.PrivateKey, accounts)
+	for i := range keys {
+		keys[i], _ = crypto.ToECDSA(crypto.Keccak256(big.NewInt(int64(i + 1)).Bytes()))
+		statedb.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), uint256.NewInt(params.Ether))
+	}
+	root, err := statedb.Commit(0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statedb, _ = state.New(root, statedb.Database(), nil)
+
+	return NewSession(&Config{
+		ChainConfig: params.TestChainConfig,
+		BlockNumber: big.NewInt(1),
+		Time:        10,
+		GasLimit:    30_000_000,
+		Coinbase:    coinbase,
+		State:       statedb,
+	}), keys
+}
+
+func newTestTx(key *ecdsa.PrivateKey, nonce uint64, to common.Address, value int64) *types.Transaction {
+	return types.MustSignNewTx(key, types.LatestSigner(params.TestChainConfig), &types.DynamicFeeTx{
+		ChainID:   params.TestChainConfig.ChainID,
+		Nonce:     nonce,
+		To:        &to,
+		Value:     big.NewInt(value),
+		Gas:       100_000,
+		GasFeeCap: big.NewInt(params.GWei * 10),
+		GasTipCap: big.NewInt(params.GWei),
+	})
+}
+
+func TestSession(t *testing.T) {
+	session, keys := newTestSession(t, 1, common.Address{})
+
+	for i := uint64(0); i < 2; i++ {
+		receipt, result, err := session.ApplyTransaction(newTestTx(keys[0], i, counterAddr, 0))
+		if err != nil {
+			t.Fatalf("tx %d: %v", i, err)
+		}
+		if result.Failed() || receipt.Status != types.ReceiptStatusSuccessful {
+			t.Fatalf("tx %d failed: %v", i, result.Err)
+		}
+		if receipt.TransactionIndex != uint(i) || len(receipt.Logs) != 1 || receipt.Logs[0].Index != uint(i) {
+			t.Fatalf("tx %d: receipt indices mismatch: %+v", i, receipt)
+		}
+	}
+	// Invalid transactions are rejected without side effects
+	if _, _, err := session.ApplyTransaction(newTestTx(keys[0], 5, counterAddr, 0)); err == nil {
+		t.Fatal("transaction with a nonce gap accepted")
+	}
+	receipts := session.Receipts()
+	if len(receipts) != 2 || receipts[1].CumulativeGasUsed != receipts[0].GasUsed+receipts[1].GasUsed {
+		t.Fatalf("receipts mismatch: %+v", receipts)
+	}
+	if have := session.State().GetState(counterAddr, common.Hash{}); have != common.BigToHash(big.NewInt(2)) {
+		t.Fatalf("counter mismatch: have %v, want 2", have)
+	}
+	// Seal the block and check that the next one sees it
+	block, err := session.NextBlock(22)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(block.Transactions()) != 2 || block.GasUsed() != receipts[1].CumulativeGasUsed {
+		t.Fatalf("block contents mismatch: %d txs, %d gas", len(block.Transactions()), block.GasUsed())
+	}
+	if receipts[0].BlockHash != block.Hash() || receipts[0].Logs[0].BlockHash != block.Hash() {
+		t.Fatal("receipts not updated with the block hash")
+	}
+	if header := session.Header(); header.Number.Uint64() != 2 || header.ParentHash != block.Hash() || header.Time != 22 {
+		t.Fatalf("next header mismatch: %+v", header)
+	}
+	if _, _, err := session.ApplyTransaction(newTestTx(keys[0], 2, blockhashAddr, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if have := session.State().GetState(blockhashAddr, common.Hash{}); have != block.Hash() {
+		t.Fatalf("blockhash mismatch: have %v, want %v", have, block.Hash())
+	}
+	if _, err := session.NextBlock(22); err == nil {
+		t.Fatal("block with the parent's timestamp accepted")
+	}
+}
+
+func TestSessionSnapshot(t *testing.T) {
+	session, keys := newTestSession(t, 1, common.Address{})
+
+	snap := session.Snapshot()
+	if _, _, err := session.ApplyTransaction(newTestTx(keys[0], 0, counterAddr, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.NextBlock(22); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := session.ApplyTransaction(newTestTx(keys[0], 1, counterAddr, 0)); err != nil {
+		t.Fatal(err)
+	}
+	session.RevertToSnapshot(snap)
+
+	if number := session.Header().Number.Uint64(); number != 1 {
+		t.Fatalf("block number mismatch: have %d, want 1", number)
+	}
+	if len(session.Receipts()) != 0 {
+		t.Fatalf("receipts not reverted: %d left", len(session.Receipts()))
+	}
+	if have := session.State().GetState(counterAddr, common.Hash{}); have != (common.Hash{}) {
+		t.Fatalf("counter not reverted: %v", have)
+	}
+	// The reverted transactions can be applied again
+	if _, _, err := session.ApplyTransaction(newTestTx(keys[0], 0, counterAddr, 0)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionApplyBatch(t *testing.T) {
+	for _, coinbase := range []common.Address{{}, common.HexToAddress("0xc01b")} {
+		var (
+			results [][]byte
+			hashes  []common.Hash
+		)
+		for _, workers := range []int{1, 4} {
+			session, keys := newTestSession(t, 16, coinbase)
+
+			// Mix conflicting counter calls, independent transfers and
+			// transactions depending on earlier ones of the same sender
+			var txs []*types.Transaction
+			for i, key := range keys {
+				switch i % 4 {
+				case 0:
+					txs = append(txs, newTestTx(key, 0, counterAddr, 0))
+				case 1:
+					txs = append(txs, newTestTx(key, 0, common.BigToAddress(big.NewInt(int64(0x1000+i))), 1))
+				case 2:
+					txs = append(txs, newTestTx(key, 0, blockhashAddr, 0), newTestTx(key, 1, counterAddr, 0))
+				case 3:
+					txs = append(txs, newTestTx(key, 0, crypto.PubkeyToAddress(keys[i-1].PublicKey), 1000))
+				}
+			}
+			receipts, err := session.ApplyBatch(txs, workers)
+			if err != nil {
+				t.Fatalf("workers %d: %v", workers, err)
+			}
+			if len(receipts) != len(txs) {
+				t.Fatalf("workers %d: receipt count mismatch: have %d, want %d", workers, len(receipts), len(txs))
+			}
+			block, err := session.NextBlock(22)
+			if err != nil {
+				t.Fatal(err)
+			}
+			blob, _ := json.Marshal(receipts)
+			results = append(results, blob)
+			hashes = append(hashes, block.Hash())
+		}
+		if hashes[0] != hashes[1] {
+			t.Errorf("coinbase %x: block mismatch: sequential %x, parallel %x", coinbase, hashes[0], hashes[1])
+		}
+		if string(results[0]) != string(results[1]) {
+			t.Errorf("coinbase %x: receipts mismatch:\nsequential %s\nparallel   %s", coinbase, results[0], results[1])
+		}
+	}
+}
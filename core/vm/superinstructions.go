@@ -0,0 +1,194 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/holiman/uint256"
+)
+
+// Superinstructions execute a pair of instructions at once. They are only
+// used within basic blocks whose static gas and stack requirements were
+// checked on entry, so a superinstruction only has to charge the dynamic gas
+// of its last instruction. The execution function is called with the position
+// of the first instruction, and leaves the program counter as the function of
+// the last instruction would.
+const (
+	fuseNone        byte = iota
+	fusePushJump         // PUSHn JUMP
+	fusePushJumpi        // PUSHn JUMPI
+	fusePushAdd          // PUSHn ADD
+	fuseDupSwap          // DUPn SWAPm
+	fuseCallerSload      // CALLER SLOAD
+	fuseCount
+)
+
+// superinstructions are the execution functions of the superinstructions.
+var superinstructions = [fuseCount]executionFunc{
+	fusePushJump:    opPushJump,
+	fusePushJumpi:   opPushJumpi,
+	fusePushAdd:     opPushAdd,
+	fuseDupSwap:     opDupSwap,
+	fuseCallerSload: opCallerSload,
+}
+
+// fusionCache holds the superinstruction analyses of recently executed
+// contracts.
+var fusionCache = lru.NewCache[blockCacheKey, []byte](blockCacheSize)
+
+// fusion returns the superinstruction executing the given instructions, or
+// fuseNone if they can't be fused in the given instruction set.
+func fusion(first, second OpCode, table *JumpTable) byte {
+	// The second instruction must belong to the block of the first one, and
+	// apart from SLOAD its gas must be static.
+	if endsBlock(first, table[first]) || table[second].undefined {
+		return fuseNone
+	}
+	if table[second].dynamicGas != nil && second != SLOAD {
+		return fuseNone
+	}
+	switch {
+	case first >= PUSH1 && first <= PUSH32 && second == JUMP:
+		return fusePushJump
+	case first >= PUSH1 && first <= PUSH32 && second == JUMPI:
+		return fusePushJumpi
+	case first >= PUSH1 && first <= PUSH32 && second == ADD:
+		return fusePushAdd
+	case first >= DUP1 && first <= DUP16 && second >= SWAP1 && second <= SWAP16:
+		return fuseDupSwap
+	case first == CALLER && second == SLOAD:
+		return fuseCallerSload
+	}
+	return fuseNone
+}
+
+// analyzeFusion returns the superinstruction starting at every position of the
+// legacy code, or nil if the code contains none.
+func analyzeFusion(code []byte, table *JumpTable) []byte {
+	var fused []byte
+	for pc := uint64(0); pc < uint64(len(code)); {
+		op := OpCode(code[pc])
+		next := pc + 1
+		if op >= PUSH1 && op <= PUSH32 {
+			next += uint64(op - PUSH0)
+		}
+		if next < uint64(len(code)) {
+			if kind := fusion(op, OpCode(code[next]), table); kind != fuseNone {
+				if fused == nil {
+					fused = make([]byte, len(code))
+				}
+				fused[pc] = kind
+			}
+		}
+		pc = next
+	}
+	return fused
+}
+
+// codeFusion returns the superinstruction analysis of the contract code, which
+// is cached by code hash like the basic block analysis.
+func (in *EVMInterpreter) codeFusion(contract *Contract) []byte {
+	if contract.CodeHash == (common.Hash{}) {
+		return analyzeFusion(contract.Code, in.table)
+	}
+	key := blockCacheKey{table: in.table, hash: contract.CodeHash}
+	if fused, ok := fusionCache.Get(key); ok {
+		return fused
+	}
+	fused := analyzeFusion(contract.Code, in.table)
+	fusionCache.Add(key, fused)
+	return fused
+}
+
+// pushedValue sets v to the immediate of the push instruction at the given
+// position, and returns the position of the following instruction. The
+// analysis only fuses pushes whose immediate is entirely within the code.
+func pushedValue(code []byte, pc uint64, v *uint256.Int) uint64 {
+	end := pc + 1 + uint64(OpCode(code[pc])-PUSH0)
+	v.SetBytes(code[pc+1 : end])
+	return end
+}
+
+func opPushJump(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	if interpreter.evm.abort.Load() {
+		return nil, errStopToken
+	}
+	var pos uint256.Int
+	pushedValue(scope.Contract.Code, *pc, &pos)
+	if !scope.Contract.validJumpdest(&pos) {
+		return nil, ErrInvalidJump
+	}
+	*pc = pos.Uint64() - 1 // pc will be increased by the interpreter loop
+	return nil, nil
+}
+
+func opPushJumpi(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	if interpreter.evm.abort.Load() {
+		return nil, errStopToken
+	}
+	var pos uint256.Int
+	next := pushedValue(scope.Contract.Code, *pc, &pos)
+	cond := scope.Stack.pop()
+	if !cond.IsZero() {
+		if !scope.Contract.validJumpdest(&pos) {
+			return nil, ErrInvalidJump
+		}
+		*pc = pos.Uint64() - 1 // pc will be increased by the interpreter loop
+	} else {
+		*pc = next
+	}
+	return nil, nil
+}
+
+func opPushAdd(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	var x uint256.Int
+	next := pushedValue(scope.Contract.Code, *pc, &x)
+	y := scope.Stack.peek()
+	y.Add(&x, y)
+	*pc = next
+	return nil, nil
+}
+
+func opDupSwap(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	code := scope.Contract.Code
+	scope.Stack.dup(int(code[*pc]-byte(DUP1)) + 1)
+	*pc++
+	scope.Stack.swap(int(code[*pc]-byte(SWAP1)) + 2)
+	return nil, nil
+}
+
+func opCallerSload(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	scope.Stack.push(new(uint256.Int).SetBytes(scope.Contract.Caller().Bytes()))
+	*pc++
+	if err := interpreter.useDynamicGas(SLOAD, scope); err != nil {
+		return nil, err
+	}
+	return opSload(pc, interpreter, scope)
+}
+
+// useDynamicGas charges the dynamic gas of the last instruction of a
+// superinstruction. It must not be used for instructions expanding memory.
+func (in *EVMInterpreter) useDynamicGas(op OpCode, scope *ScopeContext) error {
+	if dynamicGas := in.table[op].dynamicGas; dynamicGas != nil {
+		cost, err := dynamicGas(in.evm, scope.Contract, scope.Stack, scope.Memory, 0)
+		if err != nil || !scope.Contract.UseGas(cost) {
+			return ErrOutOfGas
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+func TestAnalyzeFusion(t *testing.T) {
+	tests := []struct {
+		code string
+		want map[int]byte
+	}{
+		// PUSH1 4 JUMP JUMPDEST
+		{"6004565b", map[int]byte{0: fusePushJump}},
+		// PUSH2 1 JUMPI ADD PUSH1 1 ADD
+		{"61000157" + "01" + "600101", map[int]byte{0: fusePushJumpi, 5: fusePushAdd}},
+		// DUP2 SWAP1 CALLER SLOAD
+		{"8190" + "3354", map[int]byte{0: fuseDupSwap, 2: fuseCallerSload}},
+		// PUSH1 1 JUMPDEST ADD: the ADD starts another block
+		{"60015b01", nil},
+		// PUSH2 with missing immediate byte, then ADD
+		{"610001", nil},
+		// Nothing to fuse: PUSH1 1 POP
+		{"600150", nil},
+	}
+	for i, tt := range tests {
+		fused := analyzeFusion(common.FromHex(tt.code), &shanghaiInstructionSet)
+		if tt.want == nil {
+			if fused != nil {
+				t.Errorf("test %d: unexpected fusions %v", i, fused)
+			}
+			continue
+		}
+		for pc, kind := range fused {
+			if kind != tt.want[pc] {
+				t.Errorf("test %d: fusion mismatch at %d: have %d, want %d", i, pc, kind, tt.want[pc])
+			}
+		}
+	}
+}
+
+// countTracer counts the instructions reported to the tracer.
+type countTracer struct {
+	stepTracer
+	steps int
+}
+
+func (t *countTracer) CaptureState(uint64, OpCode, uint64, uint64, *ScopeContext, []byte, int, error) {
+	t.steps++
+}
+
+// Tests that executing code with superinstructions results in the same gas
+// usage, results and errors, and that tracers still see every instruction.
+func TestFusedExecution(t *testing.T) {
+	tests := []string{
+		// Loop counting down from 10: PUSH1 10 JUMPDEST PUSH1 1 SWAP1 SUB DUP1 PUSH1 2 JUMPI STOP
+		"600a5b600190038060025700",
+		// Sum the caller's slot to itself: CALLER SLOAD DUP1 SWAP1 ADD PUSH1 5 ADD CALLER SSTORE
+		"3354" + "8090" + "01" + "600501" + "3355",
+		// Jump into push data: PUSH1 4 JUMP PUSH1 0x5b
+		"600456605b",
+		// Conditional jump out of the code: PUSH1 1 PUSH1 0xff JUMPI
+		"600160ff57",
+		// Stack underflow in a superinstruction: DUP1 SWAP1
+		"8090",
+		// Return the sum of the pushes: PUSH1 1 PUSH32 1 ADD PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+		"6001" + "7f" + "0000000000000000000000000000000000000000000000000000000000000001" + "01" + "600052" + "60206000f3",
+	}
+	address := common.BytesToAddress([]byte("contract"))
+	run := func(code []byte, gas uint64, cfg Config) string {
+		statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		statedb.CreateAccount(address)
+		statedb.SetCode(address, code)
+		statedb.SetState(address, common.Hash{}, common.HexToHash("0x10"))
+		statedb.Finalise(true)
+
+		evm := NewEVM(BlockContext{Transfer: func(StateDB, common.Address, common.Address, *uint256.Int) {}}, TxContext{}, statedb, params.TestChainConfig, cfg)
+		ret, left, err := evm.Call(AccountRef(common.Address{}), address, nil, gas, new(uint256.Int))
+		return fmt.Sprintf("ret %x, gas left %d, err %v, slot %x", ret, left, err, statedb.GetState(address, common.Hash{}))
+	}
+	for i, tt := range tests {
+		code := common.FromHex(tt)
+		for gas := uint64(0); gas < 50000; gas += 1 + gas/8 {
+			have := run(code, gas, Config{FuseInstructions: true})
+			want := run(code, gas, Config{})
+			if have != want {
+				t.Fatalf("test %d, gas %d: fused execution mismatch:\nhave %s\nwant %s", i, gas, have, want)
+			}
+		}
+		// Tracers see the same instructions with and without fusion
+		var plain, fused countTracer
+		run(code, 50000, Config{Tracer: &plain})
+		run(code, 50000, Config{Tracer: &fused, FuseInstructions: true})
+		if plain.steps != fused.steps {
+			t.Errorf("test %d: traced steps mismatch: have %d, want %d", i, fused.steps, plain.steps)
+		}
+	}
+}
@@ -20,19 +20,25 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"runtime"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/storagelayout"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/trie"
+	"github.com/holiman/uint256"
 )
 
 // DebugAPI is the collection of Ethereum full node APIs for debugging the
@@ -46,12 +52,47 @@ func NewDebugAPI(eth *Ethereum) *DebugAPI {
 	return &DebugAPI{eth: eth}
 }
 
-// DumpBlock retrieves the entire state of the database at a given block.
-func (api *DebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error) {
+// DumpBlockConfig is the set of options of a state dump over RPC.
+type DumpBlockConfig struct {
+	NoCode        bool             `json:"noCode"`
+	NoStorage     bool             `json:"noStorage"`
+	Incompletes   bool             `json:"incompletes"`
+	Start         hexutil.Bytes    `json:"start"` // Account hash or continuation token of a partial dump
+	Max           uint64           `json:"max"`
+	Threads       int              `json:"threads"`
+	Addresses     []common.Address `json:"addresses"`
+	OnlyContracts bool             `json:"onlyContracts"`
+	MinBalance    *hexutil.U256    `json:"minBalance"`
+	MaxStorage    uint64           `json:"maxStorage"`
+}
+
+// DumpBlock retrieves the entire state of the database at a given block. The
+// optional config can filter the accounts, limit their storage, and continue
+// a previous partial dump.
+func (api *DebugAPI) DumpBlock(blockNr rpc.BlockNumber, config *DumpBlockConfig) (state.Dump, error) {
 	opts := &state.DumpConfig{
 		OnlyWithAddresses: true,
 		Max:               AccountRangeMaxResults, // Sanity limit over RPC
 	}
+	if config != nil {
+		opts.SkipCode = config.NoCode
+		opts.SkipStorage = config.NoStorage
+		opts.OnlyWithAddresses = !config.Incompletes
+		opts.Start = config.Start
+		opts.Addresses = config.Addresses
+		opts.OnlyContracts = config.OnlyContracts
+		opts.MaxStorage = config.MaxStorage
+		opts.Threads = min(config.Threads, runtime.NumCPU())
+		if config.Max > 0 && config.Max < AccountRangeMaxResults {
+			opts.Max = config.Max
+		}
+		if config.MinBalance != nil {
+			opts.MinBalance = (*uint256.Int)(config.MinBalance)
+		}
+		if err := opts.Validate(); err != nil {
+			return state.Dump{}, err
+		}
+	}
 	if blockNr == rpc.PendingBlockNumber {
 		// If we're dumping the pending state, we need to request
 		// both the pending block as well as the pending state from
@@ -268,6 +309,39 @@ func storageRangeAt(statedb *state.StateDB, root common.Hash, address common.Add
 	return result, nil
 }
 
+// StorageDecodeMaxResults is the maximum number of array elements, mapping entries
+// and string words decoded per collection by DecodeStorageAt.
+const StorageDecodeMaxResults = 1024
+
+// DecodeStorageAt decodes the storage of a contract at the given block according
+// to its storage layout, as emitted by solc. Mapping entries are recovered from
+// the preimages of the storage slots, which are only available if the node runs
+// with the preimage cache and preimage recording enabled.
+func (api *DebugAPI) DecodeStorageAt(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, address common.Address, layout *storagelayout.Layout, limit *uint64) (*storagelayout.Result, error) {
+	if layout == nil {
+		return nil, errors.New("missing storage layout")
+	}
+	if number, ok := blockNrOrHash.Number(); ok && number == rpc.PendingBlockNumber {
+		return nil, errors.New("pending state is not supported")
+	}
+	maxResults := uint64(StorageDecodeMaxResults)
+	if limit != nil && *limit > 0 && *limit < maxResults {
+		maxResults = *limit
+	}
+	statedb, header, err := api.eth.APIBackend.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if statedb == nil || header == nil {
+		return nil, fmt.Errorf("block %v not found", blockNrOrHash)
+	}
+	storage, err := storagelayout.NewTrieStorage(api.eth.ChainDb(), statedb.Database().TrieDB(), header.Root, address, statedb.GetStorageRoot(address))
+	if err != nil {
+		return nil, err
+	}
+	return storagelayout.Decode(ctx, layout, storage, maxResults)
+}
+
 // GetModifiedAccountsByNumber returns all accounts that have changed between the
 // two blocks specified. A change is defined as a difference in nonce, balance,
 // code hash, or storage hash.
@@ -446,3 +520,144 @@ func (api *DebugAPI) GetTrieFlushInterval() (string, error) {
 	}
 	return api.eth.blockchain.GetTrieFlushInterval().String(), nil
 }
+
+// TxResourceUsage is the per-transaction result of a debug_blockResourceUsage
+// API call.
+type TxResourceUsage struct {
+	TxHash        common.Hash    `json:"txHash"`
+	TxIndex       hexutil.Uint   `json:"txIndex"`
+	GasUsed       hexutil.Uint64 `json:"gasUsed"`
+	ExecutionTime time.Duration  `json:"executionTime"` // Wall-clock time of applying and finalising the transaction
+	Executions    hexutil.Uint64 `json:"executions"`    // Times the transaction was executed on import, including discarded speculations (0 if unknown)
+
+	state.ResourceUsage
+}
+
+// BlockResourceUsage re-executes the given block on top of its parent state and
+// returns the state access accounting and wall-clock execution time of every
+// transaction. Contrary to the gas used, these reflect the actual cost of the
+// transactions on this node.
+func (api *DebugAPI) BlockResourceUsage(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]*TxResourceUsage, error) {
+	block, err := api.eth.APIBackend.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %v not found", blockNrOrHash)
+	}
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not executable")
+	}
+	parent := api.eth.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent %#x not found", block.ParentHash())
+	}
+	statedb, release, err := api.eth.stateAtBlock(ctx, parent, 0, nil, true, false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var (
+		config  = api.eth.blockchain.Config()
+		signer  = types.MakeSigner(config, block.Number(), block.Time())
+		context = core.NewEVMBlockContext(block.Header(), api.eth.blockchain, nil, config, statedb)
+		vmenv   = vm.NewEVM(context, vm.TxContext{}, statedb, config, vm.Config{})
+		gp      = new(core.GasPool).AddGas(block.GasLimit())
+		results = make([]*TxResourceUsage, 0, len(block.Transactions()))
+
+		executions = hexutil.Uint64(api.eth.blockchain.BlockExecutions(block.Hash()))
+	)
+	misc.EnsureCreate2Deployer(config, block.Time(), statedb)
+	if beaconRoot := block.BeaconRoot(); beaconRoot != nil {
+		core.ProcessBeaconBlockRoot(*beaconRoot, vmenv, statedb)
+	}
+	if config.IsPrague(block.Number(), block.Time()) {
+		core.ProcessParentBlockHash(block.ParentHash(), vmenv, statedb)
+	}
+	defer statedb.SetResourceUsage(nil)
+
+	for i, tx := range block.Transactions() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		msg, err := core.TransactionToMessage(tx, signer, block.BaseFee())
+		if err != nil {
+			return nil, fmt.Errorf("transaction %#x invalid: %v", tx.Hash(), err)
+		}
+		usage := &TxResourceUsage{
+			TxHash:     tx.Hash(),
+			TxIndex:    hexutil.Uint(i),
+			Executions: executions,
+		}
+		statedb.SetTxContext(tx.Hash(), i)
+		statedb.SetResourceUsage(&usage.ResourceUsage)
+		vmenv.Reset(core.NewEVMTxContext(msg), statedb)
+
+		start := time.Now()
+		result, err := core.ApplyMessage(vmenv, msg, gp)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %#x failed: %v", tx.Hash(), err)
+		}
+		statedb.Finalise(config.IsEIP158(block.Number()))
+		usage.ExecutionTime = time.Since(start)
+		usage.GasUsed = hexutil.Uint64(result.UsedGas)
+
+		results = append(results, usage)
+	}
+	return results, nil
+}
+
+// ExecutionWitness re-executes the given block on top of its parent state and
+// returns the RLP encoded witness of all the trie nodes, contract codes and
+// ancestor headers accessed. The witness is sufficient to execute and verify
+// the block without access to the state.
+func (api *DebugAPI) ExecutionWitness(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	block, err := api.eth.APIBackend.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %v not found", blockNrOrHash)
+	}
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not executable")
+	}
+	witness, err := api.eth.blockchain.ExecutionWitness(block)
+	if err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(witness)
+}
+
+// GetStateDiff re-executes the given block on top of its parent state and
+// returns the accounts, storage slots and codes changed by it, along with their
+// original values. The state of the parent block must be available.
+func (api *DebugAPI) GetStateDiff(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*ethapi.RPCStateDiff, error) {
+	block, err := api.eth.APIBackend.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %v not found", blockNrOrHash)
+	}
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not executable")
+	}
+	diff, err := api.eth.blockchain.StateDiff(block)
+	if err != nil {
+		return nil, err
+	}
+	return ethapi.NewRPCStateDiff(block.Header(), diff), nil
+}
+
+// ContentionReport returns the accounts and storage slots written by the most
+// transactions within the same block, over the window of recently processed
+// blocks. The number of returned accounts and slots defaults to 20.
+func (api *DebugAPI) ContentionReport(limit *int) (*core.ContentionReport, error) {
+	n := 20
+	if limit != nil {
+		n = *limit
+	}
+	return api.eth.blockchain.ContentionReport(n)
+}
@@ -19,16 +19,23 @@ package eth
 import (
 	"bytes"
 	"fmt"
+	"math/big"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/triedb"
 	"github.com/holiman/uint256"
 	"golang.org/x/exp/slices"
@@ -220,3 +227,69 @@ func TestStorageRangeAt(t *testing.T) {
 		}
 	}
 }
+
+// Tests that debug_blockResourceUsage reports how many times the transactions
+// of a block were executed on import.
+func TestBlockResourceUsage(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		genesis = &core.Genesis{
+			Config: params.AllEthashProtocolChanges,
+			Alloc:  types.GenesisAlloc{addr: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(genesis.Config)
+	)
+	_, blocks, _ := core.GenerateChainWithGenesis(genesis, ethash.NewFaker(), 2, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(addr), common.Address{0x01}, big.NewInt(1), params.TxGas, b.BaseFee(), nil), signer, key)
+		b.AddTx(tx)
+	})
+	n, err := node.New(&node.Config{})
+	if err != nil {
+		t.Fatalf("can't create new node: %v", err)
+	}
+	defer n.Close()
+
+	ethservice, err := New(n, &ethconfig.Config{Genesis: genesis, PipelinedImport: true})
+	if err != nil {
+		t.Fatalf("can't create new ethereum service: %v", err)
+	}
+	if err := n.Start(); err != nil {
+		t.Fatalf("can't start test node: %v", err)
+	}
+	if _, err := ethservice.BlockChain().InsertChain(blocks); err != nil {
+		t.Fatalf("can't import test blocks: %v", err)
+	}
+	client := n.Attach()
+	defer client.Close()
+
+	check := func(number uint64, executions uint64) {
+		t.Helper()
+
+		var usages []*TxResourceUsage
+		if err := client.Call(&usages, "debug_blockResourceUsage", hexutil.Uint64(number)); err != nil {
+			t.Fatalf("block %d: resource usage failed: %v", number, err)
+		}
+		if len(usages) != 1 {
+			t.Fatalf("block %d: usage count mismatch: have %d, want 1", number, len(usages))
+		}
+		if usages[0].TxHash != blocks[number-1].Transactions()[0].Hash() {
+			t.Errorf("block %d: transaction mismatch: have %x", number, usages[0].TxHash)
+		}
+		if uint64(usages[0].Executions) != executions {
+			t.Errorf("block %d: execution count mismatch: have %d, want %d", number, usages[0].Executions, executions)
+		}
+	}
+	check(1, 1)
+	check(2, 1)
+
+	// Rewind and import the second block again, executing it a second time
+	if err := ethservice.BlockChain().SetHead(1); err != nil {
+		t.Fatalf("can't rewind chain: %v", err)
+	}
+	if _, err := ethservice.BlockChain().InsertChain(blocks[1:]); err != nil {
+		t.Fatalf("can't reimport test block: %v", err)
+	}
+	check(1, 1)
+	check(2, 2)
+}
@@ -199,17 +199,22 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	var (
 		vmConfig = vm.Config{
 			EnablePreimageRecording: config.EnablePreimageRecording,
+			FuseInstructions:        config.FuseInstructions,
 		}
 		cacheConfig = &core.CacheConfig{
 			TrieCleanLimit:      config.TrieCleanCache,
 			TrieCleanNoPrefetch: config.NoPrefetch,
+			PipelinedImport:     config.PipelinedImport,
 			TrieDirtyLimit:      config.TrieDirtyCache,
 			TrieDirtyDisabled:   config.NoPruning,
 			TrieTimeLimit:       config.TrieTimeout,
 			SnapshotLimit:       config.SnapshotCache,
 			Preimages:           config.Preimages,
 			StateHistory:        config.StateHistory,
+			StateHistoryIndex:   config.StateHistoryIndex,
 			StateScheme:         scheme,
+			ContentionWindow:    config.ContentionWindow,
+			HistoryPrune:        config.HistoryPrune,
 		}
 	)
 	// Override the chain config with provided settings.
@@ -217,6 +222,9 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	if config.OverrideCancun != nil {
 		overrides.OverrideCancun = config.OverrideCancun
 	}
+	if config.OverridePrague != nil {
+		overrides.OverridePrague = config.OverridePrague
+	}
 	if config.OverrideVerkle != nil {
 		overrides.OverrideVerkle = config.OverrideVerkle
 	}
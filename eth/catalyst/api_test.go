@@ -48,6 +48,7 @@ import (
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/forks"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/trie"
 	"github.com/mattn/go-colorable"
@@ -76,6 +77,9 @@ func generateMergeChain(n int, merged bool) (*core.Genesis, []*types.Block) {
 		Alloc: types.GenesisAlloc{
 			testAddr:                         {Balance: testBalance},
 			params.BeaconRootsStorageAddress: {Balance: common.Big0, Code: common.Hex2Bytes("3373fffffffffffffffffffffffffffffffffffffffe14604457602036146024575f5ffd5b620180005f350680545f35146037575f5ffd5b6201800001545f5260205ff35b6201800042064281555f359062018000015500")},
+			// Request system contracts dequeuing nothing, for the Prague tests
+			params.WithdrawalQueueAddress:    {Nonce: 1, Code: []byte{0x00}},
+			params.ConsolidationQueueAddress: {Nonce: 1, Code: []byte{0x00}},
 		},
 		ExtraData:  []byte("test genesis"),
 		Timestamp:  9000,
@@ -320,7 +324,7 @@ func TestEth2NewBlock(t *testing.T) {
 		if err != nil {
 			t.Fatalf("Failed to create the executable data %v", err)
 		}
-		block, err := engine.ExecutableDataToBlock(*execData, nil, nil)
+		block, err := engine.ExecutableDataToBlock(*execData, nil, nil, nil)
 		if err != nil {
 			t.Fatalf("Failed to convert executable data to block %v", err)
 		}
@@ -362,7 +366,7 @@ func TestEth2NewBlock(t *testing.T) {
 		if err != nil {
 			t.Fatalf("Failed to create the executable data %v", err)
 		}
-		block, err := engine.ExecutableDataToBlock(*execData, nil, nil)
+		block, err := engine.ExecutableDataToBlock(*execData, nil, nil, nil)
 		if err != nil {
 			t.Fatalf("Failed to convert executable data to block %v", err)
 		}
@@ -1001,7 +1005,7 @@ func TestSimultaneousNewBlock(t *testing.T) {
 				t.Fatal(testErr)
 			}
 		}
-		block, err := engine.ExecutableDataToBlock(*execData, nil, nil)
+		block, err := engine.ExecutableDataToBlock(*execData, nil, nil, nil)
 		if err != nil {
 			t.Fatalf("Failed to convert executable data to block %v", err)
 		}
@@ -1571,7 +1575,7 @@ func TestBlockToPayloadWithBlobs(t *testing.T) {
 	}
 
 	block := types.NewBlock(&header, txs, nil, nil, trie.NewStackTrie(nil))
-	envelope := engine.BlockToExecutableData(block, nil, sidecars)
+	envelope := engine.BlockToExecutableData(block, nil, sidecars, nil)
 	var want int
 	for _, tx := range txs {
 		want += len(tx.BlobHashes())
@@ -1585,7 +1589,7 @@ func TestBlockToPayloadWithBlobs(t *testing.T) {
 	if got := len(envelope.BlobsBundle.Blobs); got != want {
 		t.Fatalf("invalid number of blobs: got %v, want %v", got, want)
 	}
-	_, err := engine.ExecutableDataToBlock(*envelope.ExecutionPayload, make([]common.Hash, 1), nil)
+	_, err := engine.ExecutableDataToBlock(*envelope.ExecutionPayload, make([]common.Hash, 1), nil, nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -1677,6 +1681,73 @@ func TestParentBeaconBlockRoot(t *testing.T) {
 	}
 }
 
+// Tests that the chain can be driven across the Osaka fork with the same engine
+// API methods as Prague.
+func TestOsakaTransition(t *testing.T) {
+	genesis, blocks := generateMergeChain(10, true)
+
+	// Activate prague at the next block and osaka at the one after
+	var (
+		prague = blocks[len(blocks)-1].Time() + 5
+		osaka  = prague + 5
+	)
+	genesis.Config.ShanghaiTime = &prague
+	genesis.Config.CancunTime = &prague
+	genesis.Config.PragueTime = &prague
+	genesis.Config.OsakaTime = &osaka
+
+	n, ethservice := startEthService(t, genesis, blocks)
+	ethservice.Merger().ReachTTD()
+	defer n.Close()
+
+	var (
+		api    = NewConsensusAPI(ethservice)
+		parent = ethservice.BlockChain().CurrentHeader()
+	)
+	for _, want := range []forks.Fork{forks.Prague, forks.Osaka} {
+		blockParams := engine.PayloadAttributes{
+			Timestamp:   parent.Time + 5,
+			Withdrawals: make([]*types.Withdrawal, 0),
+			BeaconRoot:  &common.Hash{42},
+		}
+		if fork := genesis.Config.LatestFork(blockParams.Timestamp); fork != want {
+			t.Fatalf("fork mismatch: have %v, want %v", fork, want)
+		}
+		fcState := engine.ForkchoiceStateV1{HeadBlockHash: parent.Hash()}
+		resp, err := api.ForkchoiceUpdatedV3(fcState, &blockParams)
+		if err != nil {
+			t.Fatalf("%v: error preparing payload: %v", want, err)
+		}
+		if resp.PayloadStatus.Status != engine.VALID {
+			t.Fatalf("%v: unexpected status (got: %s, want: %s)", want, resp.PayloadStatus.Status, engine.VALID)
+		}
+		require.NoError(t, waitForApiPayloadToBuild(api, *resp.PayloadID))
+		envelope, err := api.GetPayloadV4(*resp.PayloadID)
+		if err != nil {
+			t.Fatalf("%v: error getting payload: %v", want, err)
+		}
+		requests := make([]hexutil.Bytes, len(envelope.Requests))
+		for i, req := range envelope.Requests {
+			requests[i] = req
+		}
+		status, err := api.NewPayloadV4(*envelope.ExecutionPayload, []common.Hash{}, &common.Hash{42}, requests)
+		if err != nil {
+			t.Fatalf("%v: error validating payload: %v", want, err)
+		}
+		if status.Status != engine.VALID {
+			t.Fatalf("%v: invalid payload: %v", want, status.ValidationError)
+		}
+		fcState.HeadBlockHash = envelope.ExecutionPayload.BlockHash
+		if _, err := api.ForkchoiceUpdatedV3(fcState, nil); err != nil {
+			t.Fatalf("%v: error updating forkchoice: %v", want, err)
+		}
+		parent = ethservice.BlockChain().CurrentHeader()
+		if parent.Hash() != envelope.ExecutionPayload.BlockHash {
+			t.Fatalf("%v: head not updated", want)
+		}
+	}
+}
+
 func waitForPayloadToBuild(payload *miner.Payload) {
 	payload.WaitFull()
 }
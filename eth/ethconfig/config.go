@@ -91,10 +91,21 @@ type Config struct {
 	NoPruning  bool // Whether to disable pruning and flush everything to disk
 	NoPrefetch bool // Whether to disable prefetching and only load state on demand
 
+	// Whether to execute blocks on the uncommitted state of their parent while
+	// it's validated and written during chain import.
+	PipelinedImport bool
+
 	// Deprecated, use 'TransactionHistory' instead.
 	TxLookupLimit      uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
 	TransactionHistory uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
 	StateHistory       uint64 `toml:",omitempty"` // The maximum number of blocks from head whose state histories are reserved.
+	StateHistoryIndex  bool   `toml:",omitempty"` // Whether the state histories are indexed to serve historical state.
+	ContentionWindow   uint64 `toml:",omitempty"` // The number of recent blocks whose state write contention is tracked.
+
+	// HistoryPrune is the boundary below which the block bodies and receipts
+	// are pruned from the ancient store. It can be a block number, 'merge' for
+	// the first proof-of-stake block, or empty to retain the entire history.
+	HistoryPrune string `toml:",omitempty"`
 
 	// State scheme represents the scheme used to store ethereum states and trie
 	// nodes on top. It can be 'hash', 'path', or none which means use the scheme
@@ -142,6 +153,9 @@ type Config struct {
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
+	// Executes common instruction sequences as superinstructions in the VM
+	FuseInstructions bool
+
 	// Miscellaneous options
 	DocRoot string `toml:"-"`
 
@@ -158,6 +172,9 @@ type Config struct {
 	// OverrideCancun (TODO: remove after the fork)
 	OverrideCancun *uint64 `toml:",omitempty"`
 
+	// OverridePrague (TODO: remove after the fork)
+	OverridePrague *uint64 `toml:",omitempty"`
+
 	// OverrideVerkle (TODO: remove after the fork)
 	OverrideVerkle *uint64 `toml:",omitempty"`
 
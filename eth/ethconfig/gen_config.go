@@ -24,9 +24,13 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		SnapDiscoveryURLs                       []string
 		NoPruning                               bool
 		NoPrefetch                              bool
+		PipelinedImport                         bool
 		TxLookupLimit                           uint64                 `toml:",omitempty"`
 		TransactionHistory                      uint64                 `toml:",omitempty"`
 		StateHistory                            uint64                 `toml:",omitempty"`
+		StateHistoryIndex                       bool                   `toml:",omitempty"`
+		ContentionWindow                        uint64                 `toml:",omitempty"`
+		HistoryPrune                            string                 `toml:",omitempty"`
 		StateScheme                             string                 `toml:",omitempty"`
 		RequiredBlocks                          map[uint64]common.Hash `toml:"-"`
 		LightServ                               int                    `toml:",omitempty"`
@@ -50,11 +54,13 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		BlobPool                                blobpool.Config
 		GPO                                     gasprice.Config
 		EnablePreimageRecording                 bool
+		FuseInstructions                        bool
 		DocRoot                                 string `toml:"-"`
 		RPCGasCap                               uint64
 		RPCEVMTimeout                           time.Duration
 		RPCTxFeeCap                             float64
 		OverrideCancun                          *uint64 `toml:",omitempty"`
+		OverridePrague                          *uint64 `toml:",omitempty"`
 		OverrideVerkle                          *uint64 `toml:",omitempty"`
 		OverrideOptimismCanyon                  *uint64 `toml:",omitempty"`
 		OverrideOptimismEcotone                 *uint64 `toml:",omitempty"`
@@ -76,9 +82,13 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.SnapDiscoveryURLs = c.SnapDiscoveryURLs
 	enc.NoPruning = c.NoPruning
 	enc.NoPrefetch = c.NoPrefetch
+	enc.PipelinedImport = c.PipelinedImport
 	enc.TxLookupLimit = c.TxLookupLimit
 	enc.TransactionHistory = c.TransactionHistory
 	enc.StateHistory = c.StateHistory
+	enc.StateHistoryIndex = c.StateHistoryIndex
+	enc.ContentionWindow = c.ContentionWindow
+	enc.HistoryPrune = c.HistoryPrune
 	enc.StateScheme = c.StateScheme
 	enc.RequiredBlocks = c.RequiredBlocks
 	enc.LightServ = c.LightServ
@@ -102,11 +112,13 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.BlobPool = c.BlobPool
 	enc.GPO = c.GPO
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
+	enc.FuseInstructions = c.FuseInstructions
 	enc.DocRoot = c.DocRoot
 	enc.RPCGasCap = c.RPCGasCap
 	enc.RPCEVMTimeout = c.RPCEVMTimeout
 	enc.RPCTxFeeCap = c.RPCTxFeeCap
 	enc.OverrideCancun = c.OverrideCancun
+	enc.OverridePrague = c.OverridePrague
 	enc.OverrideVerkle = c.OverrideVerkle
 	enc.OverrideOptimismCanyon = c.OverrideOptimismCanyon
 	enc.OverrideOptimismEcotone = c.OverrideOptimismEcotone
@@ -132,9 +144,13 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		SnapDiscoveryURLs                       []string
 		NoPruning                               *bool
 		NoPrefetch                              *bool
+		PipelinedImport                         *bool
 		TxLookupLimit                           *uint64                `toml:",omitempty"`
 		TransactionHistory                      *uint64                `toml:",omitempty"`
 		StateHistory                            *uint64                `toml:",omitempty"`
+		StateHistoryIndex                       *bool                  `toml:",omitempty"`
+		ContentionWindow                        *uint64                `toml:",omitempty"`
+		HistoryPrune                            *string                `toml:",omitempty"`
 		StateScheme                             *string                `toml:",omitempty"`
 		RequiredBlocks                          map[uint64]common.Hash `toml:"-"`
 		LightServ                               *int                   `toml:",omitempty"`
@@ -158,11 +174,13 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		BlobPool                                *blobpool.Config
 		GPO                                     *gasprice.Config
 		EnablePreimageRecording                 *bool
+		FuseInstructions                        *bool
 		DocRoot                                 *string `toml:"-"`
 		RPCGasCap                               *uint64
 		RPCEVMTimeout                           *time.Duration
 		RPCTxFeeCap                             *float64
 		OverrideCancun                          *uint64 `toml:",omitempty"`
+		OverridePrague                          *uint64 `toml:",omitempty"`
 		OverrideVerkle                          *uint64 `toml:",omitempty"`
 		OverrideOptimismCanyon                  *uint64 `toml:",omitempty"`
 		OverrideOptimismEcotone                 *uint64 `toml:",omitempty"`
@@ -201,6 +219,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.NoPrefetch != nil {
 		c.NoPrefetch = *dec.NoPrefetch
 	}
+	if dec.PipelinedImport != nil {
+		c.PipelinedImport = *dec.PipelinedImport
+	}
 	if dec.TxLookupLimit != nil {
 		c.TxLookupLimit = *dec.TxLookupLimit
 	}
@@ -210,6 +231,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.StateHistory != nil {
 		c.StateHistory = *dec.StateHistory
 	}
+	if dec.StateHistoryIndex != nil {
+		c.StateHistoryIndex = *dec.StateHistoryIndex
+	}
+	if dec.ContentionWindow != nil {
+		c.ContentionWindow = *dec.ContentionWindow
+	}
+	if dec.HistoryPrune != nil {
+		c.HistoryPrune = *dec.HistoryPrune
+	}
 	if dec.StateScheme != nil {
 		c.StateScheme = *dec.StateScheme
 	}
@@ -279,6 +309,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.EnablePreimageRecording != nil {
 		c.EnablePreimageRecording = *dec.EnablePreimageRecording
 	}
+	if dec.FuseInstructions != nil {
+		c.FuseInstructions = *dec.FuseInstructions
+	}
 	if dec.DocRoot != nil {
 		c.DocRoot = *dec.DocRoot
 	}
@@ -294,6 +327,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.OverrideCancun != nil {
 		c.OverrideCancun = dec.OverrideCancun
 	}
+	if dec.OverridePrague != nil {
+		c.OverridePrague = dec.OverridePrague
+	}
 	if dec.OverrideVerkle != nil {
 		c.OverrideVerkle = dec.OverrideVerkle
 	}
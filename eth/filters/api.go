@@ -28,8 +28,10 @@ import (
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
@@ -253,6 +255,56 @@ func (api *FilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
 	return rpcSub, nil
 }
 
+// StateDiffs sends a notification with the state changes of each block, once
+// the changes are committed. The blocks may not become canonical, the same as
+// with logs, the notification carries the hash of the block.
+func (api *FilterAPI) StateDiffs(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		// Subscribe to the chain directly, the state changes are only gathered
+		// while anyone is subscribed.
+		var (
+			diffs   = make(chan core.StateDiffEvent)
+			pending = make(chan core.StateDiffEvent, stateDiffChanSize)
+		)
+		diffsSub := api.sys.backend.SubscribeStateDiffEvent(diffs)
+		defer diffsSub.Unsubscribe()
+
+		// Deliver the notifications on a separate routine, the chain must not be
+		// held up by a slow connection.
+		go func() {
+			for ev := range pending {
+				notifier.Notify(rpcSub.ID, ethapi.NewRPCStateDiff(ev.Header, ev.Diff))
+			}
+		}()
+		defer close(pending)
+
+		for {
+			select {
+			case ev := <-diffs:
+				select {
+				case pending <- ev:
+				default:
+					log.Warn("Dropping lagging state diff subscriber", "id", rpcSub.ID, "pending", len(pending))
+					return
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // Logs creates a subscription that fires for all new log that match the given filter criteria.
 func (api *FilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
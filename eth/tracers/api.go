@@ -269,6 +269,8 @@ func (api *API) traceChain(start, end *types.Block, config *TraceConfig, closed
 					signer   = types.MakeSigner(api.backend.ChainConfig(), task.block.Number(), task.block.Time())
 					blockCtx = core.NewEVMBlockContext(task.block.Header(), api.chainContext(ctx), nil, api.backend.ChainConfig(), task.statedb)
 				)
+				processSystemCalls(task.block, blockCtx, task.statedb, api.backend.ChainConfig())
+
 				// Trace all the transactions contained within
 				for i, tx := range task.block.Transactions() {
 					msg, _ := core.TransactionToMessage(tx, signer, task.block.BaseFee())
@@ -387,7 +389,7 @@ func (api *API) traceChain(start, end *types.Block, config *TraceConfig, closed
 			// Send the block over to the concurrent tracers (if not in the fast-forward phase)
 			txs := next.Transactions()
 			select {
-			case taskCh <- &blockTraceTask{statedb: statedb.Copy(), block: next, release: release, results: make([]*txTraceResult, len(txs))}:
+			case taskCh <- &blockTraceTask{statedb: statedb.Fork(), block: next, release: release, results: make([]*txTraceResult, len(txs))}:
 			case <-closed:
 				tracker.releaseState(number, release)
 				return
@@ -556,6 +558,7 @@ func (api *API) IntermediateRoots(ctx context.Context, hash common.Hash, config
 		vmctx              = core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil, chainConfig, statedb)
 		deleteEmptyObjects = chainConfig.IsEIP158(block.Number())
 	)
+	processSystemCalls(block, vmctx, statedb, chainConfig)
 	for i, tx := range block.Transactions() {
 		if err := ctx.Err(); err != nil {
 			return nil, err
@@ -594,6 +597,15 @@ func (api *API) StandardTraceBadBlockToFile(ctx context.Context, hash common.Has
 	return api.standardTraceBlockToFile(ctx, block, config)
 }
 
+// processSystemCalls applies the system calls made at the start of the block,
+// before its transactions are executed on the parent state.
+func processSystemCalls(block *types.Block, vmctx vm.BlockContext, statedb *state.StateDB, config *params.ChainConfig) {
+	if config.IsPrague(block.Number(), block.Time()) {
+		vmenv := vm.NewEVM(vmctx, vm.TxContext{}, statedb, config, vm.Config{})
+		core.ProcessParentBlockHash(block.ParentHash(), vmenv, statedb)
+	}
+}
+
 // traceBlock configures a new tracer according to the provided configuration, and
 // executes all the transactions contained within. The return value will be one item
 // per transaction, dependent on the requested tracer.
@@ -616,6 +628,9 @@ func (api *API) traceBlock(ctx context.Context, block *types.Block, config *Trac
 	}
 	defer release()
 
+	blockCtx := core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil, api.backend.ChainConfig(), statedb)
+	processSystemCalls(block, blockCtx, statedb, api.backend.ChainConfig())
+
 	// JS tracers have high overhead. In this case run a parallel
 	// process that generates states in one thread and traces txes
 	// in separate worker threads.
@@ -629,7 +644,6 @@ func (api *API) traceBlock(ctx context.Context, block *types.Block, config *Trac
 		txs       = block.Transactions()
 		blockHash = block.Hash()
 		is158     = api.backend.ChainConfig().IsEIP158(block.Number())
-		blockCtx  = core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil, api.backend.ChainConfig(), statedb)
 		signer    = types.MakeSigner(api.backend.ChainConfig(), block.Number(), block.Time())
 		results   = make([]*txTraceResult, len(txs))
 	)
@@ -701,7 +715,7 @@ func (api *API) traceBlockParallel(ctx context.Context, block *types.Block, stat
 txloop:
 	for i, tx := range txs {
 		// Send the trace task over for execution
-		task := &txTraceTask{statedb: statedb.Copy(), index: i}
+		task := &txTraceTask{statedb: statedb.Fork(), index: i}
 		select {
 		case <-ctx.Done():
 			failed = ctx.Err()
@@ -787,6 +801,7 @@ func (api *API) standardTraceBlockToFile(ctx context.Context, block *types.Block
 		// Note: This copies the config, to not screw up the main config
 		chainConfig, canon = overrideConfig(chainConfig, config.Overrides)
 	}
+	processSystemCalls(block, vmctx, statedb, chainConfig)
 	for i, tx := range block.Transactions() {
 		// Prepare the transaction for un-traced execution
 		var (
@@ -903,6 +918,22 @@ func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *
 	return api.traceTx(ctx, msg, txctx, vmctx, statedb, config)
 }
 
+// callStateAtBlock returns the state for tracing a call on top of the given
+// block. The state is served from the snapshot if the backend supports it and
+// the state is available there, otherwise it's retrieved or regenerated as usual.
+func (api *API) callStateAtBlock(ctx context.Context, block *types.Block, reexec uint64) (*state.StateDB, StateReleaseFunc, error) {
+	if sb, ok := api.backend.(ethapi.SnapshotStateBackend); ok {
+		statedb, _, err := sb.SnapshotStateAndHeaderByNumberOrHash(ctx, rpc.BlockNumberOrHashWithHash(block.Hash(), false))
+		if err == nil {
+			return statedb, func() {}, nil
+		}
+		if !errors.Is(err, state.ErrSnapshotUnavailable) {
+			return nil, nil, err
+		}
+	}
+	return api.backend.StateAtBlock(ctx, block, reexec, nil, true, false)
+}
+
 // TraceCall lets you trace a given eth_call. It collects the structured logs
 // created during the execution of EVM if the given transaction was added on
 // top of the provided block and returns them as a JSON object.
@@ -950,7 +981,7 @@ func (api *API) TraceCall(ctx context.Context, args ethapi.TransactionArgs, bloc
 	if config != nil && config.TxIndex != nil {
 		_, _, statedb, release, err = api.backend.StateAtTransaction(ctx, block, int(*config.TxIndex), reexec)
 	} else {
-		statedb, release, err = api.backend.StateAtBlock(ctx, block, reexec, nil, true, false)
+		statedb, release, err = api.callStateAtBlock(ctx, block, reexec)
 	}
 	if err != nil {
 		return nil, err
@@ -975,7 +1006,14 @@ func (api *API) TraceCall(ctx context.Context, args ethapi.TransactionArgs, bloc
 	if config != nil {
 		traceConfig = &config.TraceConfig
 	}
-	return api.traceTx(ctx, msg, new(Context), vmctx, statedb, traceConfig)
+	result, err := api.traceTx(ctx, msg, new(Context), vmctx, statedb, traceConfig)
+
+	// The state may be served from the snapshot, which can become stale during
+	// the execution. Don't return the trace of an execution on a partial state.
+	if err := statedb.Error(); err != nil {
+		return nil, err
+	}
+	return result, err
 }
 
 // traceTx configures a new tracer according to the provided configuration, and
@@ -34,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
@@ -147,6 +148,10 @@ func newTestBackend(t *testing.T, n int, gspec *core.Genesis, generator func(i i
 		historical:     historicalClient,
 		mockHistorical: mock,
 	}
+	// Ethash doesn't support the forks after the merge
+	if gspec.Config.ShanghaiTime != nil {
+		backend.engine = beacon.NewFaker()
+	}
 	// Generate blocks for testing
 	_, blocks, _ := core.GenerateChainWithGenesis(gspec, backend.engine, n, generator)
 
@@ -242,6 +247,10 @@ func (b *testBackend) StateAtTransaction(ctx context.Context, block *types.Block
 	if err != nil {
 		return nil, vm.BlockContext{}, nil, nil, errStateNotFound
 	}
+	if b.chainConfig.IsPrague(block.Number(), block.Time()) {
+		context := core.NewEVMBlockContext(block.Header(), b.chain, nil, b.chainConfig, statedb)
+		core.ProcessParentBlockHash(block.ParentHash(), vm.NewEVM(context, vm.TxContext{}, statedb, b.chainConfig, vm.Config{}), statedb)
+	}
 	if txIndex == 0 && len(block.Transactions()) == 0 {
 		return nil, vm.BlockContext{}, statedb, release, nil
 	}
@@ -485,6 +494,73 @@ func TestTraceCall(t *testing.T) {
 	}
 }
 
+// snapshotBackend serves the call states through the snapshot state backend
+// interface, opening them on the given database.
+type snapshotBackend struct {
+	*testBackend
+	db state.Database
+}
+
+func (b *snapshotBackend) SnapshotStateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	hash, _ := blockNrOrHash.Hash()
+	header := b.chain.GetHeaderByHash(hash)
+	statedb, err := state.New(header.Root, b.db, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return statedb, header, nil
+}
+
+var errStaleState = errors.New("stale state")
+
+// staleDatabase opens tries failing all the account reads, mimicking a state
+// whose snapshot layer became stale during the execution.
+type staleDatabase struct {
+	state.Database
+}
+
+func (db staleDatabase) OpenTrie(root common.Hash) (state.Trie, error) {
+	tr, err := db.Database.OpenTrie(root)
+	if err != nil {
+		return nil, err
+	}
+	return staleTrie{tr}, nil
+}
+
+type staleTrie struct {
+	state.Trie
+}
+
+func (t staleTrie) GetAccount(common.Address) (*types.StateAccount, error) {
+	return nil, errStaleState
+}
+
+// Tests that the failed state reads during a traced call are reported instead of
+// the trace of the execution on the partial state.
+func TestTraceCallStateError(t *testing.T) {
+	t.Parallel()
+
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: types.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		},
+	}
+	backend := newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {})
+	defer backend.teardown()
+
+	api := NewAPI(&snapshotBackend{testBackend: backend, db: staleDatabase{state.NewDatabase(backend.chaindb)}})
+	_, err := api.TraceCall(context.Background(), ethapi.TransactionArgs{
+		From:  &accounts[0].addr,
+		To:    &accounts[1].addr,
+		Value: (*hexutil.Big)(big.NewInt(1000)),
+	}, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), nil)
+	if !errors.Is(err, errStaleState) {
+		t.Fatalf("have error %v, want %v", err, errStaleState)
+	}
+}
+
 func TestTraceTransaction(t *testing.T) {
 	t.Parallel()
 
@@ -1177,3 +1253,75 @@ func TestTraceChain(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the parent block hash is recorded in the EIP-2935 history storage
+// before the transactions of a Prague block are traced, overwriting any hash
+// left in the slot from an earlier history window.
+func TestTracePragueBlockHash(t *testing.T) {
+	t.Parallel()
+
+	var (
+		accounts = newAccounts(1)
+		config   = *params.MergedTestChainConfig
+		reader   = common.HexToAddress("0xbbbb")
+		stale    = common.HexToHash("0xdead")
+	)
+	config.PragueTime = new(uint64)
+
+	genesis := &core.Genesis{
+		Config: &config,
+		Alloc: types.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+			// Returns BLOCKHASH(NUMBER-1)
+			reader: {Code: []byte{
+				byte(vm.PUSH1), 1, byte(vm.NUMBER), byte(vm.SUB), byte(vm.BLOCKHASH),
+				byte(vm.PUSH1), 0, byte(vm.MSTORE), byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN),
+			}},
+			// The slot of block 1 holds the hash of a block of an earlier window
+			params.HistoryStorageAddress: {
+				Nonce:   1,
+				Code:    params.HistoryStorageCode,
+				Storage: map[common.Hash]common.Hash{common.BigToHash(big.NewInt(1)): stale},
+			},
+			params.WithdrawalQueueAddress:    {Nonce: 1, Code: []byte{byte(vm.STOP)}},
+			params.ConsolidationQueueAddress: {Nonce: 1, Code: []byte{byte(vm.STOP)}},
+		},
+	}
+	signer := types.LatestSigner(&config)
+	backend := newTestBackend(t, 2, genesis, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTx(&types.LegacyTx{
+			Nonce:    uint64(i),
+			To:       &reader,
+			Gas:      100000,
+			GasPrice: b.BaseFee(),
+		}), signer, accounts[0].key)
+		b.AddTx(tx)
+	})
+	defer backend.teardown()
+	api := NewAPI(backend)
+
+	var (
+		block = backend.chain.GetBlockByNumber(2)
+		want  = block.ParentHash()
+	)
+	check := func(name string, res interface{}) {
+		var result logger.ExecutionResult
+		if err := json.Unmarshal(res.(json.RawMessage), &result); err != nil {
+			t.Fatalf("%s: failed to decode trace: %v", name, err)
+		}
+		if have := common.HexToHash(result.ReturnValue); have != want {
+			t.Errorf("%s: block hash mismatch: have %x, want %x", name, have, want)
+		}
+	}
+	results, err := api.TraceBlockByNumber(context.Background(), 2, nil)
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	check("block", results[0].Result)
+
+	res, err := api.TraceTransaction(context.Background(), block.Transactions()[0].Hash(), nil)
+	if err != nil {
+		t.Fatalf("failed to trace transaction: %v", err)
+	}
+	check("transaction", res)
+}
@@ -125,6 +125,86 @@ func (ec *Client) GetProof(ctx context.Context, account common.Address, keys []s
 	return &result, err
 }
 
+// MultiProofResult is the result of a GetMultiProof operation.
+type MultiProofResult struct {
+	Accounts []MultiProofAccount
+	Proof    []string
+}
+
+// MultiProofAccount provides the values of an account proven by a multiproof.
+type MultiProofAccount struct {
+	Address     common.Address
+	Balance     *big.Int
+	CodeHash    common.Hash
+	Nonce       uint64
+	StorageHash common.Hash
+	Storage     []MultiProofStorage
+}
+
+// MultiProofStorage provides the value of a storage slot proven by a multiproof.
+type MultiProofStorage struct {
+	Key   common.Hash
+	Value *big.Int
+}
+
+// GetMultiProof returns the values of the specified accounts and storage slots
+// including a single Merkle-multiproof for all of them. The block number can be
+// nil, in which case the values are taken from the latest known block.
+func (ec *Client) GetMultiProof(ctx context.Context, accounts types.AccessList, blockNumber *big.Int) (*MultiProofResult, error) {
+	type storageResult struct {
+		Key   common.Hash  `json:"key"`
+		Value *hexutil.Big `json:"value"`
+	}
+
+	type accountResult struct {
+		Address     common.Address  `json:"address"`
+		Balance     *hexutil.Big    `json:"balance"`
+		CodeHash    common.Hash     `json:"codeHash"`
+		Nonce       hexutil.Uint64  `json:"nonce"`
+		StorageHash common.Hash     `json:"storageHash"`
+		Storage     []storageResult `json:"storage"`
+	}
+
+	type multiProofResult struct {
+		Accounts []accountResult `json:"accounts"`
+		Proof    []string        `json:"proof"`
+	}
+
+	// Avoid accounts and storage keys being 'null'.
+	list := make(types.AccessList, 0, len(accounts))
+	for _, acc := range accounts {
+		if acc.StorageKeys == nil {
+			acc.StorageKeys = []common.Hash{}
+		}
+		list = append(list, acc)
+	}
+
+	var res multiProofResult
+	if err := ec.c.CallContext(ctx, &res, "eth_getMultiProof", list, toBlockNumArg(blockNumber)); err != nil {
+		return nil, err
+	}
+	// Turn hexutils back to normal datatypes
+	result := &MultiProofResult{
+		Accounts: make([]MultiProofAccount, 0, len(res.Accounts)),
+		Proof:    res.Proof,
+	}
+	for _, acc := range res.Accounts {
+		storage := make([]MultiProofStorage, 0, len(acc.Storage))
+		for _, st := range acc.Storage {
+			storage = append(storage, MultiProofStorage{Key: st.Key, Value: st.Value.ToInt()})
+		}
+		result.Accounts = append(result.Accounts, MultiProofAccount{
+			Address:     acc.Address,
+			Balance:     acc.Balance.ToInt(),
+			CodeHash:    acc.CodeHash,
+			Nonce:       uint64(acc.Nonce),
+			StorageHash: acc.StorageHash,
+			Storage:     storage,
+		})
+	}
+	return result, nil
+}
+
 // CallContract executes a message call transaction, which is directly executed in the VM
 // of the node, but never mined into the blockchain.
 //
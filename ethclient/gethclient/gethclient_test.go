@@ -33,9 +33,12 @@ import (
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/eth/filters"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
 )
 
 var (
@@ -123,6 +126,9 @@ func TestGethClient(t *testing.T) {
 		}, {
 			"TestGetProofCanonicalizeKeys",
 			func(t *testing.T) { testGetProofCanonicalizeKeys(t, client) },
+		}, {
+			"TestGetMultiProof",
+			func(t *testing.T) { testGetMultiProof(t, client) },
 		}, {
 			"TestGCStats",
 			func(t *testing.T) { testGCStats(t, client) },
@@ -284,6 +290,98 @@ func testGetProofCanonicalizeKeys(t *testing.T, client *rpc.Client) {
 	}
 }
 
+func testGetMultiProof(t *testing.T, client *rpc.Client) {
+	ec := New(client)
+	ethcl := ethclient.NewClient(client)
+	missing := common.HexToAddress("0x0001")
+	accounts := types.AccessList{
+		{Address: testAddr, StorageKeys: []common.Hash{testSlot, common.HexToHash("0x01")}},
+		{Address: testContract},
+		{Address: testEmpty, StorageKeys: []common.Hash{testSlot}},
+		{Address: missing},
+	}
+	result, err := ec.GetMultiProof(context.Background(), accounts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Accounts) != len(accounts) {
+		t.Fatalf("invalid account count, want %d got %d", len(accounts), len(result.Accounts))
+	}
+	head, err := ethcl.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof := memorydb.New()
+	for _, node := range result.Proof {
+		blob := common.FromHex(node)
+		proof.Put(crypto.Keccak256(blob), blob)
+	}
+	if len(result.Proof) != proof.Len() {
+		t.Fatalf("proof contains duplicate nodes")
+	}
+	// Verify the account values against the proof
+	keys := make([][]byte, len(accounts))
+	for i, acc := range accounts {
+		keys[i] = crypto.Keccak256(acc.Address.Bytes())
+	}
+	values, err := trie.VerifyMultiProof(head.Root, keys, proof)
+	if err != nil {
+		t.Fatalf("failed to verify account proof: %v", err)
+	}
+	for i, acc := range result.Accounts {
+		if acc.Address != accounts[i].Address {
+			t.Fatalf("unexpected address, have: %v want: %v", acc.Address, accounts[i].Address)
+		}
+		if balance, _ := ethcl.BalanceAt(context.Background(), acc.Address, nil); acc.Balance.Cmp(balance) != 0 {
+			t.Fatalf("invalid balance, want: %v got: %v", balance, acc.Balance)
+		}
+		if values[i] == nil {
+			if acc.Address != missing {
+				t.Fatalf("account %v missing from proof", acc.Address)
+			}
+			continue
+		}
+		var proven types.StateAccount
+		if err := rlp.DecodeBytes(values[i], &proven); err != nil {
+			t.Fatal(err)
+		}
+		if proven.Root != acc.StorageHash || proven.Nonce != acc.Nonce || proven.Balance.ToBig().Cmp(acc.Balance) != 0 {
+			t.Fatalf("account %v mismatch with proof", acc.Address)
+		}
+		// Verify the storage values against the proof
+		if len(acc.Storage) != len(accounts[i].StorageKeys) {
+			t.Fatalf("invalid storage count, want %d got %d", len(accounts[i].StorageKeys), len(acc.Storage))
+		}
+		if len(acc.Storage) == 0 || acc.StorageHash == types.EmptyRootHash {
+			continue
+		}
+		slots := make([][]byte, len(acc.Storage))
+		for j, st := range acc.Storage {
+			slots[j] = crypto.Keccak256(st.Key.Bytes())
+		}
+		svalues, err := trie.VerifyMultiProof(acc.StorageHash, slots, proof)
+		if err != nil {
+			t.Fatalf("failed to verify storage proof: %v", err)
+		}
+		for j, st := range acc.Storage {
+			var want []byte
+			if svalues[j] != nil {
+				_, content, _, err := rlp.Split(svalues[j])
+				if err != nil {
+					t.Fatal(err)
+				}
+				want = content
+			}
+			if have := st.Value.Bytes(); !bytes.Equal(have, want) {
+				t.Fatalf("slot %v value mismatch with proof: have %x want %x", st.Key, have, want)
+			}
+		}
+	}
+	if have := common.BigToHash(result.Accounts[0].Storage[0].Value); have != testValue {
+		t.Fatalf("invalid storage value, want: %v got: %v", testValue, have)
+	}
+}
+
 func testGetProofNonExistent(t *testing.T, client *rpc.Client) {
 	addr := common.HexToAddress("0x0001")
 	ec := New(client)
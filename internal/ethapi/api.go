@@ -48,6 +48,7 @@ import (
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/trie/trienode"
 	"github.com/holiman/uint256"
 	"github.com/tyler-smith/go-bip39"
 )
@@ -797,6 +798,100 @@ func (s *BlockChainAPI) GetProof(ctx context.Context, address common.Address, st
 	}, statedb.Error()
 }
 
+// MultiProofResult is the result of GetMultiProof. The proof is a single list
+// of trie nodes covering the account trie and all storage tries, each node
+// included only once.
+type MultiProofResult struct {
+	Accounts []MultiProofAccount `json:"accounts"`
+	Proof    []string            `json:"proof"`
+}
+
+// MultiProofAccount is an account proven in a MultiProofResult.
+type MultiProofAccount struct {
+	Address     common.Address      `json:"address"`
+	Balance     *hexutil.Big        `json:"balance"`
+	CodeHash    common.Hash         `json:"codeHash"`
+	Nonce       hexutil.Uint64      `json:"nonce"`
+	StorageHash common.Hash         `json:"storageHash"`
+	Storage     []MultiProofStorage `json:"storage"`
+}
+
+// MultiProofStorage is a storage slot proven in a MultiProofResult.
+type MultiProofStorage struct {
+	Key   common.Hash  `json:"key"`
+	Value *hexutil.Big `json:"value"`
+}
+
+// GetMultiProof returns a single Merkle-multiproof for the given accounts and
+// storage slots. Unlike GetProof, the nodes shared by the proofs of different
+// accounts and slots are returned only once.
+func (s *BlockChainAPI) GetMultiProof(ctx context.Context, accounts types.AccessList, blockNrOrHash rpc.BlockNumberOrHash) (*MultiProofResult, error) {
+	header, err := headerByNumberOrHash(ctx, s.b, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if s.b.ChainConfig().IsOptimismPreBedrock(header.Number) {
+		return nil, errors.New("multiproofs are not supported for pre-bedrock blocks")
+	}
+	statedb, header, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	var (
+		proof    = trienode.NewProofSet()
+		results  = make([]MultiProofAccount, 0, len(accounts))
+		hashKeys = make([][]byte, 0, len(accounts))
+	)
+	for _, account := range accounts {
+		address := account.Address
+		hashKeys = append(hashKeys, crypto.Keccak256(address.Bytes()))
+
+		storageRoot := statedb.GetStorageRoot(address)
+		storage := make([]MultiProofStorage, 0, len(account.StorageKeys))
+		for _, key := range account.StorageKeys {
+			value := (*hexutil.Big)(statedb.GetState(address, key).Big())
+			storage = append(storage, MultiProofStorage{Key: key, Value: value})
+		}
+		// Create the proof of the storage slots, if there are any.
+		if len(account.StorageKeys) > 0 && storageRoot != types.EmptyRootHash && storageRoot != (common.Hash{}) {
+			id := trie.StorageTrieID(header.Root, crypto.Keccak256Hash(address.Bytes()), storageRoot)
+			st, err := trie.NewStateTrie(id, statedb.Database().TrieDB())
+			if err != nil {
+				return nil, err
+			}
+			keys := make([][]byte, 0, len(account.StorageKeys))
+			for _, key := range account.StorageKeys {
+				keys = append(keys, crypto.Keccak256(key.Bytes()))
+			}
+			if err := st.ProveMulti(keys, proof); err != nil {
+				return nil, err
+			}
+		}
+		results = append(results, MultiProofAccount{
+			Address:     address,
+			Balance:     (*hexutil.Big)(statedb.GetBalance(address).ToBig()),
+			CodeHash:    statedb.GetCodeHash(address),
+			Nonce:       hexutil.Uint64(statedb.GetNonce(address)),
+			StorageHash: storageRoot,
+			Storage:     storage,
+		})
+	}
+	// Create the proof of the accounts.
+	tr, err := trie.NewStateTrie(trie.StateTrieID(header.Root), statedb.Database().TrieDB())
+	if err != nil {
+		return nil, err
+	}
+	if err := tr.ProveMulti(hashKeys, proof); err != nil {
+		return nil, err
+	}
+	nodes := proof.List()
+	encoded := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		encoded = append(encoded, hexutil.Encode(node))
+	}
+	return &MultiProofResult{Accounts: results, Proof: encoded}, statedb.Error()
+}
+
 // decodeHash parses a hex-encoded 32-byte hash. The input may optionally
 // be prefixed by 0x and can have a byte length up to 32.
 func decodeHash(s string) (h common.Hash, inputLength int, err error) {
@@ -1004,6 +1099,9 @@ func headerByNumberOrHash(ctx context.Context, b Backend, blockNrOrHash rpc.Bloc
 // GetBlockReceipts returns the block receipts for the given block hash or number or tag.
 func (s *BlockChainAPI) GetBlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
 	block, err := s.b.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if errors.Is(err, core.ErrHistoryPruned) {
+		return nil, err
+	}
 	if block == nil || err != nil {
 		// When the block doesn't exist, the RPC method should return JSON null
 		// as per specification.
@@ -1215,7 +1313,7 @@ func doCall(ctx context.Context, b Backend, args TransactionArgs, state *state.S
 func DoCall(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides, timeout time.Duration, globalGasCap uint64) (*core.ExecutionResult, error) {
 	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
-	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	state, header, err := callStateAndHeader(ctx, b, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
 	}
@@ -1270,7 +1368,7 @@ func (s *BlockChainAPI) Call(ctx context.Context, args TransactionArgs, blockNrO
 // non-zero) and `gasCap` (if non-zero).
 func DoEstimateGas(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, gasCap uint64) (hexutil.Uint64, error) {
 	// Retrieve the base state and mutate it with any overrides
-	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	state, header, err := callStateAndHeader(ctx, b, blockNrOrHash)
 	if state == nil || err != nil {
 		return 0, err
 	}
@@ -1427,30 +1525,49 @@ func (s *BlockChainAPI) rpcMarshalBlock(ctx context.Context, b *types.Block, inc
 	return fields, nil
 }
 
+// RPCStateDiff represents the state changes of a block that will serialize to
+// the RPC representation of a state diff.
+type RPCStateDiff struct {
+	BlockHash   common.Hash    `json:"blockHash"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	state.StateDiff
+}
+
+// NewRPCStateDiff returns the RPC representation of the state changes made by
+// the block with the given header.
+func NewRPCStateDiff(header *types.Header, diff *state.StateDiff) *RPCStateDiff {
+	return &RPCStateDiff{
+		BlockHash:   header.Hash(),
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		StateDiff:   *diff,
+	}
+}
+
 // RPCTransaction represents a transaction that will serialize to the RPC representation of a transaction
 type RPCTransaction struct {
-	BlockHash           *common.Hash      `json:"blockHash"`
-	BlockNumber         *hexutil.Big      `json:"blockNumber"`
-	From                common.Address    `json:"from"`
-	Gas                 hexutil.Uint64    `json:"gas"`
-	GasPrice            *hexutil.Big      `json:"gasPrice"`
-	GasFeeCap           *hexutil.Big      `json:"maxFeePerGas,omitempty"`
-	GasTipCap           *hexutil.Big      `json:"maxPriorityFeePerGas,omitempty"`
-	MaxFeePerBlobGas    *hexutil.Big      `json:"maxFeePerBlobGas,omitempty"`
-	Hash                common.Hash       `json:"hash"`
-	Input               hexutil.Bytes     `json:"input"`
-	Nonce               hexutil.Uint64    `json:"nonce"`
-	To                  *common.Address   `json:"to"`
-	TransactionIndex    *hexutil.Uint64   `json:"transactionIndex"`
-	Value               *hexutil.Big      `json:"value"`
-	Type                hexutil.Uint64    `json:"type"`
-	Accesses            *types.AccessList `json:"accessList,omitempty"`
-	ChainID             *hexutil.Big      `json:"chainId,omitempty"`
-	BlobVersionedHashes []common.Hash     `json:"blobVersionedHashes,omitempty"`
-	V                   *hexutil.Big      `json:"v"`
-	R                   *hexutil.Big      `json:"r"`
-	S                   *hexutil.Big      `json:"s"`
-	YParity             *hexutil.Uint64   `json:"yParity,omitempty"`
+	BlockHash           *common.Hash                 `json:"blockHash"`
+	BlockNumber         *hexutil.Big                 `json:"blockNumber"`
+	From                common.Address               `json:"from"`
+	Gas                 hexutil.Uint64               `json:"gas"`
+	GasPrice            *hexutil.Big                 `json:"gasPrice"`
+	GasFeeCap           *hexutil.Big                 `json:"maxFeePerGas,omitempty"`
+	GasTipCap           *hexutil.Big                 `json:"maxPriorityFeePerGas,omitempty"`
+	MaxFeePerBlobGas    *hexutil.Big                 `json:"maxFeePerBlobGas,omitempty"`
+	Hash                common.Hash                  `json:"hash"`
+	Input               hexutil.Bytes                `json:"input"`
+	Nonce               hexutil.Uint64               `json:"nonce"`
+	To                  *common.Address              `json:"to"`
+	TransactionIndex    *hexutil.Uint64              `json:"transactionIndex"`
+	Value               *hexutil.Big                 `json:"value"`
+	Type                hexutil.Uint64               `json:"type"`
+	Accesses            *types.AccessList            `json:"accessList,omitempty"`
+	ChainID             *hexutil.Big                 `json:"chainId,omitempty"`
+	BlobVersionedHashes []common.Hash                `json:"blobVersionedHashes,omitempty"`
+	AuthorizationList   []types.SetCodeAuthorization `json:"authorizationList,omitempty"`
+	V                   *hexutil.Big                 `json:"v"`
+	R                   *hexutil.Big                 `json:"r"`
+	S                   *hexutil.Big                 `json:"s"`
+	YParity             *hexutil.Uint64              `json:"yParity,omitempty"`
 
 	// deposit-tx only
 	SourceHash *common.Hash `json:"sourceHash,omitempty"`
@@ -1552,6 +1669,22 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 		}
 		result.MaxFeePerBlobGas = (*hexutil.Big)(tx.BlobGasFeeCap())
 		result.BlobVersionedHashes = tx.BlobHashes()
+
+	case types.SetCodeTxType:
+		al := tx.AccessList()
+		yparity := hexutil.Uint64(v.Sign())
+		result.Accesses = &al
+		result.ChainID = (*hexutil.Big)(tx.ChainId())
+		result.YParity = &yparity
+		result.GasFeeCap = (*hexutil.Big)(tx.GasFeeCap())
+		result.GasTipCap = (*hexutil.Big)(tx.GasTipCap())
+		// if the transaction has been mined, compute the effective gas price
+		if baseFee != nil && blockHash != (common.Hash{}) {
+			result.GasPrice = (*hexutil.Big)(effectiveGasPrice(tx, baseFee))
+		} else {
+			result.GasPrice = (*hexutil.Big)(tx.GasFeeCap())
+		}
+		result.AuthorizationList = tx.SetCodeAuthorizations()
 	}
 	return result
 }
@@ -1833,7 +1966,7 @@ func (s *TransactionAPI) GetTransactionByHash(ctx context.Context, hash common.H
 		if err == nil {
 			return nil, nil
 		}
-		return nil, NewTxIndexingError()
+		return nil, txLookupError(err)
 	}
 	header, err := s.b.HeaderByHash(ctx, blockHash)
 	if err != nil {
@@ -1854,7 +1987,7 @@ func (s *TransactionAPI) GetRawTransactionByHash(ctx context.Context, hash commo
 		if err == nil {
 			return nil, nil
 		}
-		return nil, NewTxIndexingError()
+		return nil, txLookupError(err)
 	}
 	return tx.MarshalBinary()
 }
@@ -1863,7 +1996,7 @@ func (s *TransactionAPI) GetRawTransactionByHash(ctx context.Context, hash commo
 func (s *TransactionAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
 	found, tx, blockHash, blockNumber, index, err := s.b.GetTransaction(ctx, hash)
 	if err != nil {
-		return nil, NewTxIndexingError() // transaction is not fully indexed
+		return nil, txLookupError(err) // transaction is not fully indexed or pruned
 	}
 	if !found {
 		return nil, nil // transaction is not existent or reachable
@@ -2291,7 +2424,7 @@ func (s *DebugAPI) GetRawTransaction(ctx context.Context, hash common.Hash) (hex
 		if err == nil {
 			return nil, nil
 		}
-		return nil, NewTxIndexingError()
+		return nil, txLookupError(err)
 	}
 	return tx.MarshalBinary()
 }
@@ -19,6 +19,7 @@ package ethapi
 
 import (
 	"context"
+	"errors"
 	"math/big"
 	"time"
 
@@ -97,10 +98,33 @@ type Backend interface {
 	SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription
 	SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription
 	SubscribePendingLogsEvent(ch chan<- []*types.Log) event.Subscription
+	SubscribeStateDiffEvent(ch chan<- core.StateDiffEvent) event.Subscription
 	BloomStatus() (uint64, uint64)
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
 }
 
+// SnapshotStateBackend is implemented by the backends able to serve read-only
+// states purely from the state snapshot, which avoids resolving the tries.
+type SnapshotStateBackend interface {
+	// SnapshotStateAndHeaderByNumberOrHash returns a read-only state served from
+	// the snapshot, or an error wrapping state.ErrSnapshotUnavailable if the
+	// state is not available in the snapshot.
+	SnapshotStateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error)
+}
+
+// callStateAndHeader returns the state for read-only execution, such as calls
+// and gas estimation. The state is served from the snapshot if the backend
+// supports it and the state is available there, otherwise from the tries.
+func callStateAndHeader(ctx context.Context, b Backend, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	if sb, ok := b.(SnapshotStateBackend); ok {
+		statedb, header, err := sb.SnapshotStateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+		if !errors.Is(err, state.ErrSnapshotUnavailable) {
+			return statedb, header, err
+		}
+	}
+	return b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+}
+
 func GetAPIs(apiBackend Backend) []rpc.API {
 	nonceLock := new(AddrLocker)
 	return []rpc.API{
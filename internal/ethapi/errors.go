@@ -17,10 +17,12 @@
 package ethapi
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/vm"
 )
 
@@ -76,3 +78,41 @@ func (e *TxIndexingError) ErrorCode() int {
 
 // ErrorData returns the hex encoded revert reason.
 func (e *TxIndexingError) ErrorData() interface{} { return "transaction indexing is in progress" }
+
+// PrunedHistoryError is an API error that indicates the requested block bodies,
+// receipts or transactions are below the history cutoff of the node and have
+// been pruned.
+type PrunedHistoryError struct {
+	Cutoff uint64 // Number of the first block whose history is retained
+}
+
+// NewPrunedHistoryError creates a PrunedHistoryError instance.
+func NewPrunedHistoryError(cutoff uint64) *PrunedHistoryError {
+	return &PrunedHistoryError{Cutoff: cutoff}
+}
+
+// Error implement error interface, returning the error message.
+func (e *PrunedHistoryError) Error() string {
+	return fmt.Sprintf("pruned history unavailable, retained from block %d", e.Cutoff)
+}
+
+// ErrorCode returns the JSON error code for pruned history, as per EIP-4444.
+func (e *PrunedHistoryError) ErrorCode() int {
+	return 4444
+}
+
+// ErrorData returns the number of the first block whose history is retained.
+func (e *PrunedHistoryError) ErrorData() interface{} { return hexutil.Uint64(e.Cutoff) }
+
+// Unwrap returns the underlying chain error.
+func (e *PrunedHistoryError) Unwrap() error { return core.ErrHistoryPruned }
+
+// txLookupError converts the error of a failed transaction lookup to its API
+// error. Unless the history is pruned, the transaction indexing is still in
+// progress.
+func txLookupError(err error) error {
+	if errors.Is(err, core.ErrHistoryPruned) {
+		return err
+	}
+	return NewTxIndexingError()
+}
@@ -87,14 +87,16 @@ func (args *BuildPayloadArgs) Id() engine.PayloadID {
 // the revenue. Therefore, the empty-block here is always available and full-block
 // will be set/updated afterwards.
 type Payload struct {
-	id       engine.PayloadID
-	empty    *types.Block
-	full     *types.Block
-	sidecars []*types.BlobTxSidecar
-	fullFees *big.Int
-	stop     chan struct{}
-	lock     sync.Mutex
-	cond     *sync.Cond
+	id            engine.PayloadID
+	empty         *types.Block
+	emptyRequests [][]byte
+	full          *types.Block
+	sidecars      []*types.BlobTxSidecar
+	requests      [][]byte
+	fullFees      *big.Int
+	stop          chan struct{}
+	lock          sync.Mutex
+	cond          *sync.Cond
 
 	err       error
 	stopOnce  sync.Once
@@ -102,11 +104,12 @@ type Payload struct {
 }
 
 // newPayload initializes the payload object.
-func newPayload(empty *types.Block, id engine.PayloadID) *Payload {
+func newPayload(empty *types.Block, emptyRequests [][]byte, id engine.PayloadID) *Payload {
 	payload := &Payload{
-		id:    id,
-		empty: empty,
-		stop:  make(chan struct{}),
+		id:            id,
+		empty:         empty,
+		emptyRequests: emptyRequests,
+		stop:          make(chan struct{}),
 
 		interrupt: new(atomic.Int32),
 	}
@@ -147,6 +150,7 @@ func (payload *Payload) update(r *newPayloadResult, elapsed time.Duration) {
 		payload.full = r.block
 		payload.fullFees = r.fees
 		payload.sidecars = r.sidecars
+		payload.requests = r.requests
 
 		feesInEther := new(big.Float).Quo(new(big.Float).SetInt(r.fees), big.NewFloat(params.Ether))
 		log.Info("Updated payload",
@@ -175,7 +179,7 @@ func (payload *Payload) ResolveEmpty() *engine.ExecutionPayloadEnvelope {
 	payload.lock.Lock()
 	defer payload.lock.Unlock()
 
-	return engine.BlockToExecutableData(payload.empty, big.NewInt(0), nil)
+	return engine.BlockToExecutableData(payload.empty, big.NewInt(0), nil, payload.emptyRequests)
 }
 
 // ResolveFull is basically identical to Resolve, but it expects full block only.
@@ -214,9 +218,9 @@ func (payload *Payload) resolve(onlyFull bool) *engine.ExecutionPayloadEnvelope
 	payload.stopBuilding()
 
 	if payload.full != nil {
-		return engine.BlockToExecutableData(payload.full, payload.fullFees, payload.sidecars)
+		return engine.BlockToExecutableData(payload.full, payload.fullFees, payload.sidecars, payload.requests)
 	} else if !onlyFull && payload.empty != nil {
-		return engine.BlockToExecutableData(payload.empty, big.NewInt(0), nil)
+		return engine.BlockToExecutableData(payload.empty, big.NewInt(0), nil, payload.emptyRequests)
 	} else if err := payload.err; err != nil {
 		log.Error("Error building any payload", "id", payload.id, "err", err)
 	}
@@ -276,10 +280,11 @@ func (w *worker) buildPayload(args *BuildPayloadArgs) (*Payload, error) {
 		if empty.err != nil {
 			return nil, empty.err
 		}
-		payload := newPayload(empty.block, args.Id())
+		payload := newPayload(empty.block, empty.requests, args.Id())
 		// make sure to make it appear as full, otherwise it will wait indefinitely for payload building to complete.
 		payload.full = empty.block
 		payload.fullFees = empty.fees
+		payload.requests = empty.requests
 		payload.cond.Broadcast() // unblocks Resolve
 		return payload, nil
 	}
@@ -304,7 +309,7 @@ func (w *worker) buildPayload(args *BuildPayloadArgs) (*Payload, error) {
 		return nil, err
 	}
 
-	payload := newPayload(nil, args.Id())
+	payload := newPayload(nil, nil, args.Id())
 	// set shared interrupt
 	fullParams.interrupt = payload.interrupt
 
@@ -104,7 +104,7 @@ type environment struct {
 func (env *environment) copy() *environment {
 	cpy := &environment{
 		signer:   env.signer,
-		state:    env.state.Copy(),
+		state:    env.state.Fork(),
 		tcount:   env.tcount,
 		coinbase: env.coinbase,
 		header:   types.CopyHeader(env.header),
@@ -161,6 +161,7 @@ type newPayloadResult struct {
 	block    *types.Block
 	fees     *big.Int               // total block fees
 	sidecars []*types.BlobTxSidecar // collected blobs of blob transactions
+	requests [][]byte               // execution-layer requests (EIP-7685), nil before Prague
 }
 
 // getWorkReq represents a request for getting a new sealing work with provided parameters.
@@ -361,7 +362,7 @@ func (w *worker) pending() (*types.Block, *state.StateDB) {
 	if w.snapshotState == nil {
 		return nil, nil
 	}
-	return w.snapshotBlock, w.snapshotState.Copy()
+	return w.snapshotBlock, w.snapshotState.Fork()
 }
 
 // pendingBlock returns pending block. The returned block can be nil in case the
@@ -792,7 +793,7 @@ func (w *worker) updateSnapshot(env *environment) {
 		trie.NewStackTrie(nil),
 	)
 	w.snapshotReceipts = copyReceipts(env.receipts)
-	w.snapshotState = env.state.Copy()
+	w.snapshotState = env.state.Fork()
 }
 
 func (w *worker) commitTransaction(env *environment, tx *types.Transaction) ([]*types.Log, error) {
@@ -1100,6 +1101,11 @@ func (w *worker) prepareWork(genParams *generateParams) (*environment, error) {
 		vmenv := vm.NewEVM(context, vm.TxContext{}, env.state, w.chainConfig, vm.Config{})
 		core.ProcessBeaconBlockRoot(*header.ParentBeaconRoot, vmenv, env.state)
 	}
+	if w.chainConfig.IsPrague(header.Number, header.Time) {
+		context := core.NewEVMBlockContext(header, w.chain, nil, w.chainConfig, env.state)
+		vmenv := vm.NewEVM(context, vm.TxContext{}, env.state, w.chainConfig, vm.Config{})
+		core.ProcessParentBlockHash(header.ParentHash, vmenv, env.state)
+	}
 	return env, nil
 }
 
@@ -1210,7 +1216,24 @@ func (w *worker) generateWork(genParams *generateParams) *newPayloadResult {
 	if intr := genParams.interrupt; intr != nil && genParams.isUpdate && intr.Load() != commitInterruptNone {
 		return &newPayloadResult{err: errInterruptedUpdate}
 	}
-
+	// Collect the execution-layer requests emitted by the block and commit
+	// to them in the header.
+	var requests [][]byte
+	if w.chainConfig.IsPrague(work.header.Number, work.header.Time) {
+		var allLogs []*types.Log
+		for _, r := range work.receipts {
+			allLogs = append(allLogs, r.Logs...)
+		}
+		context := core.NewEVMBlockContext(work.header, w.chain, nil, w.chainConfig, work.state)
+		vmenv := vm.NewEVM(context, vm.TxContext{}, work.state, w.chainConfig, vm.Config{})
+		reqs, err := core.ProcessRequests(w.chainConfig, allLogs, vmenv, work.state)
+		if err != nil {
+			return &newPayloadResult{err: err}
+		}
+		requests = reqs
+		reqHash := types.CalcRequestsHash(requests)
+		work.header.RequestsHash = &reqHash
+	}
 	block, err := w.engine.FinalizeAndAssemble(w.chain, work.header, work.state, work.txs, nil, work.receipts, genParams.withdrawals)
 	if err != nil {
 		return &newPayloadResult{err: err}
@@ -1219,6 +1242,7 @@ func (w *worker) generateWork(genParams *generateParams) *newPayloadResult {
 		block:    block,
 		fees:     totalFees(block, work.receipts),
 		sidecars: work.sidecars,
+		requests: requests,
 	}
 }
 
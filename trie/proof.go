@@ -24,6 +24,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
+	"golang.org/x/exp/slices"
 )
 
 // Prove constructs a merkle proof for key. The result contains all encoded nodes
@@ -111,6 +112,173 @@ func (t *StateTrie) Prove(key []byte, proofDb ethdb.KeyValueWriter) error {
 	return t.trie.Prove(key, proofDb)
 }
 
+// ProveMulti constructs a merkle multiproof for the given keys. The result
+// contains all encoded nodes on the paths to the values at the keys, like the
+// individual proofs of Prove combined, but each node is included only once
+// and resolved only once. The nodes are written in depth-first order, parents
+// before their children.
+//
+// The keys not contained in the trie are proven by the nodes of their longest
+// existing prefixes, the same as for Prove.
+func (t *Trie) ProveMulti(keys [][]byte, proofDb ethdb.KeyValueWriter) error {
+	// Short circuit if the trie is already committed and not usable.
+	if t.committed {
+		return ErrCommitted
+	}
+	// Sort the keys in nibbles, so that the keys sharing a path are adjacent.
+	hexkeys := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		hexkeys = append(hexkeys, keybytesToHex(key))
+	}
+	slices.SortFunc(hexkeys, bytes.Compare)
+	hexkeys = slices.CompactFunc(hexkeys, bytes.Equal)
+
+	p := &multiProver{
+		trie:    t,
+		hasher:  newHasher(false),
+		proofDb: proofDb,
+		seen:    make(map[common.Hash]struct{}),
+	}
+	defer returnHasherToPool(p.hasher)
+
+	return p.prove(t.root, nil, hexkeys, true)
+}
+
+// ProveMulti constructs a merkle multiproof for the given keys. The result
+// contains all encoded nodes on the paths to the values at the keys, each
+// included only once.
+func (t *StateTrie) ProveMulti(keys [][]byte, proofDb ethdb.KeyValueWriter) error {
+	return t.trie.ProveMulti(keys, proofDb)
+}
+
+// multiProver collects the nodes of a merkle multiproof.
+type multiProver struct {
+	trie    *Trie
+	hasher  *hasher
+	proofDb ethdb.KeyValueWriter
+	seen    map[common.Hash]struct{} // Nodes already written into the proof
+}
+
+// prove writes the nodes on the paths of the given keys in the subtrie of the
+// node at prefix. The keys are sorted and relative to the node's position.
+func (p *multiProver) prove(n node, prefix []byte, keys [][]byte, root bool) error {
+	if n == nil || len(keys) == 0 {
+		return nil
+	}
+	switch tn := n.(type) {
+	case valueNode:
+		return nil
+	case hashNode:
+		// Retrieve the specified node from the underlying node reader. The
+		// loaded node is not linked to the trie, the same as in Prove.
+		blob, err := p.trie.reader.node(prefix, common.BytesToHash(tn))
+		if err != nil {
+			log.Error("Unhandled trie error in Trie.ProveMulti", "err", err)
+			return err
+		}
+		return p.prove(mustDecodeNodeUnsafe(tn, blob), prefix, keys, root)
+	}
+	// If the node's database encoding is a hash (or is the root node), it
+	// becomes a proof element.
+	collapsed, hashed := p.hasher.proofHash(n)
+	if hash, ok := hashed.(hashNode); ok || root {
+		enc := nodeToBytes(collapsed)
+		if !ok {
+			hash = p.hasher.hashData(enc)
+		}
+		if _, dup := p.seen[common.BytesToHash(hash)]; !dup {
+			p.seen[common.BytesToHash(hash)] = struct{}{}
+			p.proofDb.Put(hash, enc)
+		}
+	}
+	switch tn := n.(type) {
+	case *shortNode:
+		// Descend with the keys going through the node, the others are proven
+		// absent by the node itself.
+		var rest [][]byte
+		for _, key := range keys {
+			if len(key) >= len(tn.Key) && bytes.Equal(tn.Key, key[:len(tn.Key)]) {
+				rest = append(rest, key[len(tn.Key):])
+			}
+		}
+		return p.prove(tn.Val, concat(prefix, tn.Key...), rest, false)
+
+	case *fullNode:
+		// Descend into the children with the keys grouped by their first nibble.
+		for start := 0; start < len(keys); {
+			nibble := keys[start][0]
+			end := start + 1
+			for end < len(keys) && keys[end][0] == nibble {
+				end++
+			}
+			rest := make([][]byte, 0, end-start)
+			for _, key := range keys[start:end] {
+				rest = append(rest, key[1:])
+			}
+			if err := p.prove(tn.Children[nibble], concat(prefix, nibble), rest, false); err != nil {
+				return err
+			}
+			start = end
+		}
+		return nil
+
+	default:
+		panic(fmt.Sprintf("%T: invalid node: %v", n, n))
+	}
+}
+
+// VerifyMultiProof checks a merkle multiproof of the given keys. The proof must
+// contain the values of all the keys in the trie with the given root hash, or
+// prove their absence. The values are returned in the order of the keys, nil
+// for the absent ones. VerifyMultiProof returns an error if the proof contains
+// invalid trie nodes or misses any node required.
+func VerifyMultiProof(rootHash common.Hash, keys [][]byte, proofDb ethdb.KeyValueReader) ([][]byte, error) {
+	// Decode each proof node only once, as they are shared by the keys
+	nodes := make(map[common.Hash]node)
+	resolve := func(hash common.Hash) (node, error) {
+		if n, ok := nodes[hash]; ok {
+			return n, nil
+		}
+		buf, _ := proofDb.Get(hash[:])
+		if buf == nil {
+			return nil, fmt.Errorf("proof node (hash %064x) missing", hash)
+		}
+		n, err := decodeNode(hash[:], buf)
+		if err != nil {
+			return nil, fmt.Errorf("bad proof node: %v", err)
+		}
+		nodes[hash] = n
+		return n, nil
+	}
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		var (
+			hexkey   = keybytesToHex(key)
+			wantHash = rootHash
+		)
+	walk:
+		for {
+			n, err := resolve(wantHash)
+			if err != nil {
+				return nil, fmt.Errorf("key %x: %v", key, err)
+			}
+			keyrest, cld := get(n, hexkey, true)
+			switch cld := cld.(type) {
+			case nil:
+				// The trie doesn't contain the key.
+				break walk
+			case hashNode:
+				hexkey = keyrest
+				copy(wantHash[:], cld)
+			case valueNode:
+				values[i] = cld
+				break walk
+			}
+		}
+	}
+	return values, nil
+}
+
 // VerifyProof checks merkle proofs. The given proof must contain the value for
 // key in a trie with the given root hash. VerifyProof returns an error if the
 // proof contains invalid trie nodes or the wrong value.
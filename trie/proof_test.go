@@ -26,8 +26,10 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/trie/trienode"
 	"golang.org/x/exp/slices"
 )
 
@@ -165,6 +167,109 @@ func TestMissingKeyProof(t *testing.T) {
 	}
 }
 
+// Tests that multiproofs of many keys, both present and missing ones, verify
+// and contain each node only once, both for resolved and unresolved tries.
+func TestMultiProof(t *testing.T) {
+	_, vals := randomTrie(500)
+	db := newTestDatabase(rawdb.NewMemoryDatabase(), rawdb.HashScheme)
+	trie := NewEmpty(db)
+	for _, kv := range vals {
+		trie.MustUpdate(kv.k, kv.v)
+	}
+	root, nodes, _ := trie.Commit(false)
+	db.Update(root, types.EmptyRootHash, trienode.NewWithNodeSet(nodes))
+
+	var keys [][]byte
+	for _, kv := range vals {
+		keys = append(keys, kv.k)
+		if len(keys) == 200 {
+			break
+		}
+	}
+	for i := 0; i < 50; i++ {
+		keys = append(keys, randBytes(32))
+	}
+	keys = append(keys, keys[0]) // duplicate keys are proven once
+
+	for i := 0; i < 2; i++ {
+		tr, _ := New(TrieID(root), db)
+		if i == 1 {
+			// Resolve the entire trie into memory first
+			it := tr.MustNodeIterator(nil)
+			for it.Next(true) {
+			}
+			for _, key := range keys {
+				tr.MustGet(key)
+			}
+		}
+		proof := memorydb.New()
+		if err := tr.ProveMulti(keys, proof); err != nil {
+			t.Fatalf("test %d: failed to prove: %v", i, err)
+		}
+		values, err := VerifyMultiProof(root, keys, proof)
+		if err != nil {
+			t.Fatalf("test %d: failed to verify multiproof: %v", i, err)
+		}
+		for j, key := range keys {
+			var want []byte
+			if kv, ok := vals[string(key)]; ok {
+				want = kv.v
+			}
+			if !bytes.Equal(values[j], want) {
+				t.Fatalf("test %d: verified value mismatch for key %x: have %x, want %x", i, key, values[j], want)
+			}
+		}
+		// The multiproof must contain exactly the union of the single proofs
+		union := memorydb.New()
+		for _, key := range keys {
+			tr.Prove(key, union)
+		}
+		if proof.Len() != union.Len() {
+			t.Fatalf("test %d: proof size mismatch: have %d, want %d", i, proof.Len(), union.Len())
+		}
+		list := trienode.NewProofSet()
+		tr.ProveMulti(keys, list)
+		if len(list.List()) != union.Len() {
+			t.Fatalf("test %d: proof contains duplicate nodes: have %d, want %d", i, len(list.List()), union.Len())
+		}
+	}
+}
+
+// Tests that multiproofs with a modified or missing node are rejected.
+func TestBadMultiProof(t *testing.T) {
+	trie, vals := randomTrie(800)
+	root := trie.Hash()
+
+	var keys [][]byte
+	for _, kv := range vals {
+		keys = append(keys, kv.k)
+		if len(keys) == 100 {
+			break
+		}
+	}
+	for i := 0; i < 100; i++ {
+		proof := memorydb.New()
+		trie.ProveMulti(keys, proof)
+
+		it := proof.NewIterator(nil, nil)
+		for i, d := 0, mrand.Intn(proof.Len()); i <= d; i++ {
+			it.Next()
+		}
+		key := it.Key()
+		val, _ := proof.Get(key)
+		proof.Delete(key)
+		it.Release()
+
+		if i%2 == 0 {
+			mutateByte(val)
+			proof.Put(crypto.Keccak256(val), val)
+		}
+		if _, err := VerifyMultiProof(root, keys, proof); err == nil {
+			t.Fatalf("test %d: expected multiproof to fail", i)
+		}
+	}
+}
+
 // TestRangeProof tests normal range proof with both edge proofs
 // as the existent proof. The test cases are generated randomly.
 func TestRangeProof(t *testing.T) {
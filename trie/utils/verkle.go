@@ -204,7 +204,9 @@ func CodeSizeKey(address []byte) []byte {
 	return GetTreeKey(address, zero, CodeSizeLeafKey)
 }
 
-func codeChunkIndex(chunk *uint256.Int) (*uint256.Int, byte) {
+// CodeChunkIndex returns the tree index and the sub index of the leaf holding
+// the given code chunk within the account's verkle tree layout.
+func CodeChunkIndex(chunk *uint256.Int) (*uint256.Int, byte) {
 	var (
 		chunkOffset = new(uint256.Int).Add(codeOffset, chunk)
 		treeIndex   = new(uint256.Int).Div(chunkOffset, verkleNodeWidth)
@@ -220,11 +222,13 @@ func codeChunkIndex(chunk *uint256.Int) (*uint256.Int, byte) {
 // CodeChunkKey returns the verkle tree key of the code chunk for the
 // specified account.
 func CodeChunkKey(address []byte, chunk *uint256.Int) []byte {
-	treeIndex, subIndex := codeChunkIndex(chunk)
+	treeIndex, subIndex := CodeChunkIndex(chunk)
 	return GetTreeKey(address, treeIndex, subIndex)
 }
 
-func storageIndex(bytes []byte) (*uint256.Int, byte) {
+// StorageIndex returns the tree index and the sub index of the leaf holding
+// the given storage slot within the account's verkle tree layout.
+func StorageIndex(bytes []byte) (*uint256.Int, byte) {
 	// If the storage slot is in the header, we need to add the header offset.
 	var key uint256.Int
 	key.SetBytes(bytes)
@@ -250,7 +254,7 @@ func storageIndex(bytes []byte) (*uint256.Int, byte) {
 // StorageSlotKey returns the verkle tree key of the storage slot for the
 // specified account.
 func StorageSlotKey(address []byte, storageKey []byte) []byte {
-	treeIndex, subIndex := storageIndex(storageKey)
+	treeIndex, subIndex := StorageIndex(storageKey)
 	return GetTreeKey(address, treeIndex, subIndex)
 }
 
@@ -293,7 +297,7 @@ func CodeSizeKeyWithEvaluatedAddress(evaluated *verkle.Point) []byte {
 // chunk for the specified account. The difference between CodeChunkKey is the
 // address evaluation is already computed to minimize the computational overhead.
 func CodeChunkKeyWithEvaluatedAddress(addressPoint *verkle.Point, chunk *uint256.Int) []byte {
-	treeIndex, subIndex := codeChunkIndex(chunk)
+	treeIndex, subIndex := CodeChunkIndex(chunk)
 	return GetTreeKeyWithEvaluatedAddress(addressPoint, treeIndex, subIndex)
 }
 
@@ -301,7 +305,7 @@ func CodeChunkKeyWithEvaluatedAddress(addressPoint *verkle.Point, chunk *uint256
 // slot for the specified account. The difference between StorageSlotKey is the
 // address evaluation is already computed to minimize the computational overhead.
 func StorageSlotKeyWithEvaluatedAddress(evaluated *verkle.Point, storageKey []byte) []byte {
-	treeIndex, subIndex := storageIndex(storageKey)
+	treeIndex, subIndex := StorageIndex(storageKey)
 	return GetTreeKeyWithEvaluatedAddress(evaluated, treeIndex, subIndex)
 }
 
@@ -256,6 +256,34 @@ func (t *VerkleTrie) Prove(key []byte, proofDb ethdb.KeyValueWriter) error {
 	panic("not implemented")
 }
 
+// Proof builds a multiproof of the values of the given keys in the tree, and
+// the state diff to their values in the post-state tree, if it is provided.
+func (t *VerkleTrie) Proof(posttrie *VerkleTrie, keys [][]byte) (*verkle.VerkleProof, verkle.StateDiff, error) {
+	var postroot verkle.VerkleNode
+	if posttrie != nil {
+		// Resolve the leaves in the post-state tree first, the resolver of
+		// the proof only serves the nodes of the pre-state tree.
+		for _, key := range keys {
+			if _, err := posttrie.root.Get(key, posttrie.nodeResolver); err != nil {
+				return nil, nil, fmt.Errorf("failed to resolve post-state key %x: %w", key, err)
+			}
+		}
+		postroot = posttrie.root
+	}
+	proof, _, _, _, err := verkle.MakeVerkleMultiProof(t.root, postroot, keys, t.nodeResolver)
+	if err != nil {
+		return nil, nil, err
+	}
+	return verkle.SerializeProof(proof)
+}
+
+// Witness returns a set containing all trie nodes that have been accessed.
+//
+// TODO(gballet, rjl493456442) implement it.
+func (t *VerkleTrie) Witness() map[string]struct{} {
+	panic("not implemented")
+}
+
 // Copy returns a deep-copied verkle tree.
 func (t *VerkleTrie) Copy() *VerkleTrie {
 	return &VerkleTrie{
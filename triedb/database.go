@@ -108,7 +108,7 @@ func NewDatabase(diskdb ethdb.Database, config *Config) *Database {
 		log.Crit("Both 'hash' and 'path' mode are configured")
 	}
 	if config.PathDB != nil {
-		db.backend = pathdb.New(diskdb, config.PathDB)
+		db.backend = pathdb.New(diskdb, config.PathDB, config.IsVerkle)
 	} else {
 		var resolver hashdb.ChildResolver
 		if config.IsVerkle {
@@ -275,6 +275,17 @@ func (db *Database) Recover(target common.Hash) error {
 	return pdb.Recover(target, loader)
 }
 
+// HistoricReader returns a reader of the historical state with the given root,
+// which is served by the indexed state histories. It's only supported by
+// path-based database and will return an error for others.
+func (db *Database) HistoricReader(root common.Hash) (*pathdb.HistoricReader, error) {
+	pdb, ok := db.backend.(*pathdb.Database)
+	if !ok || db.config.IsVerkle {
+		return nil, errors.New("not supported")
+	}
+	return pdb.HistoricReader(root, trie.NewMerkleLoader(db))
+}
+
 // Recoverable returns the indicator if the specified state is enabled to be
 // recovered. It's only supported by path-based database and will return an
 // error for others.
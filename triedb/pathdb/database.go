@@ -26,11 +26,13 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/trie/trienode"
 	"github.com/ethereum/go-ethereum/trie/triestate"
+	"github.com/gballet/go-verkle"
 )
 
 const (
@@ -86,10 +88,11 @@ type layer interface {
 
 // Config contains the settings for database.
 type Config struct {
-	StateHistory   uint64 // Number of recent blocks to maintain state history for
-	CleanCacheSize int    // Maximum memory allowance (in bytes) for caching clean nodes
-	DirtyCacheSize int    // Maximum memory allowance (in bytes) for caching dirty nodes
-	ReadOnly       bool   // Flag whether the database is opened in read only mode.
+	StateHistory      uint64 // Number of recent blocks to maintain state history for
+	StateHistoryIndex bool   // Flag whether the state histories are indexed for serving historical state
+	CleanCacheSize    int    // Maximum memory allowance (in bytes) for caching clean nodes
+	DirtyCacheSize    int    // Maximum memory allowance (in bytes) for caching dirty nodes
+	ReadOnly          bool   // Flag whether the database is opened in read only mode.
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -130,18 +133,22 @@ type Database struct {
 	// the shutdown to reject all following unexpected mutations.
 	readOnly   bool                     // Flag if database is opened in read only mode
 	waitSync   bool                     // Flag if database is deactivated due to initial state sync
+	isVerkle   bool                     // Flag if database is used for verkle tree
 	bufferSize int                      // Memory allowance (in bytes) for caching dirty nodes
 	config     *Config                  // Configuration for database
 	diskdb     ethdb.Database           // Persistent storage for matured trie nodes
 	tree       *layerTree               // The group for all known layers
 	freezer    *rawdb.ResettableFreezer // Freezer for storing trie histories, nil possible in tests
 	lock       sync.RWMutex             // Lock to prevent mutations from happening at the same time
+
+	indexQuit chan struct{} // Quit channel to stop the background history indexing
+	indexDone chan struct{} // Channel closed once the background history indexing exits
 }
 
 // New attempts to load an already existing layer from a persistent key-value
 // store (with a number of memory layers from a journal). If the journal is not
 // matched with the base persistent layer, all the recorded diff layers are discarded.
-func New(diskdb ethdb.Database, config *Config) *Database {
+func New(diskdb ethdb.Database, config *Config, isVerkle bool) *Database {
 	if config == nil {
 		config = Defaults
 	}
@@ -149,6 +156,7 @@ func New(diskdb ethdb.Database, config *Config) *Database {
 
 	db := &Database{
 		readOnly:   config.ReadOnly,
+		isVerkle:   isVerkle,
 		bufferSize: config.DirtyCacheSize,
 		config:     config,
 		diskdb:     diskdb,
@@ -183,6 +191,9 @@ func New(diskdb ethdb.Database, config *Config) *Database {
 				if err != nil {
 					log.Crit("Failed to reset state histories", "err", err)
 				}
+				if err := rawdb.DeleteStateHistoryIndex(db.diskdb); err != nil {
+					log.Crit("Failed to wipe state history index", "err", err)
+				}
 				log.Info("Truncated extraneous state history")
 			}
 		} else {
@@ -196,6 +207,14 @@ func New(diskdb ethdb.Database, config *Config) *Database {
 				log.Warn("Truncated extra state histories", "number", pruned)
 			}
 		}
+		// Index the state histories if it's requested. Otherwise drop the index
+		// tail, so that the stale index is wiped if it's enabled again.
+		if config.StateHistoryIndex && !isVerkle {
+			db.setupHistoryIndex()
+		} else if rawdb.ReadStateHistoryIndexTail(diskdb) != nil {
+			rawdb.DeleteStateHistoryIndexTail(diskdb)
+			log.Info("Disabled state history index")
+		}
 	}
 	// Disable database in case node is still in the initial state sync stage.
 	if rawdb.ReadSnapSyncStatusFlag(diskdb) == rawdb.StateSyncRunning && !db.readOnly {
@@ -207,6 +226,30 @@ func New(diskdb ethdb.Database, config *Config) *Database {
 	return db
 }
 
+// nodeHash returns the hash of the given trie node blob. The nodes of verkle
+// trees are not referenced by hash, a zero hash is returned for them.
+func (db *Database) nodeHash(blob []byte) common.Hash {
+	if db.isVerkle {
+		return common.Hash{}
+	}
+	return crypto.Keccak256Hash(blob)
+}
+
+// persistedRoot returns the root hash of the state persisted in the disk, or
+// the empty root hash if there is none. The root hash of a verkle tree is the
+// commitment of its root node.
+func (db *Database) persistedRoot() (common.Hash, error) {
+	blob, hash := rawdb.ReadAccountTrieNode(db.diskdb, nil)
+	if !db.isVerkle || len(blob) == 0 {
+		return types.TrieRootHash(hash), nil
+	}
+	root, err := verkle.ParseNode(blob, 0)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return root.Commit().Bytes(), nil
+}
+
 // Reader retrieves a layer belonging to the given state root.
 func (db *Database) Reader(root common.Hash) (layer, error) {
 	l := db.tree.get(root)
@@ -297,7 +340,10 @@ func (db *Database) Enable(root common.Hash) error {
 	}
 	// Ensure the provided state root matches the stored one.
 	root = types.TrieRootHash(root)
-	_, stored := rawdb.ReadAccountTrieNode(db.diskdb, nil)
+	stored, err := db.persistedRoot()
+	if err != nil {
+		return err
+	}
 	if stored != root {
 		return fmt.Errorf("state root mismatch: stored %x, synced %x", stored, root)
 	}
@@ -317,6 +363,12 @@ func (db *Database) Enable(root common.Hash) error {
 		if err := db.freezer.Reset(); err != nil {
 			return err
 		}
+		if rawdb.ReadStateHistoryIndexTail(db.diskdb) != nil {
+			if err := rawdb.DeleteStateHistoryIndex(db.diskdb); err != nil {
+				return err
+			}
+			rawdb.WriteStateHistoryIndexTail(db.diskdb, 0)
+		}
 	}
 	// Re-construct a new disk layer backed by persistent state
 	// with **empty clean cache and node buffer**.
@@ -340,7 +392,7 @@ func (db *Database) Recover(root common.Hash, loader triestate.TrieLoader) error
 	if err := db.modifyAllowed(); err != nil {
 		return err
 	}
-	if db.freezer == nil {
+	if db.freezer == nil || db.isVerkle {
 		return errors.New("state rollback is non-supported")
 	}
 	// Short circuit if the target state is not recoverable.
@@ -378,6 +430,10 @@ func (db *Database) Recover(root common.Hash, loader triestate.TrieLoader) error
 
 // Recoverable returns the indicator if the specified state is recoverable.
 func (db *Database) Recoverable(root common.Hash) bool {
+	// The state histories can't be applied on verkle trees yet.
+	if db.isVerkle {
+		return false
+	}
 	// Ensure the requested state is a known state.
 	root = types.TrieRootHash(root)
 	id := rawdb.ReadStateID(db.diskdb, root)
@@ -408,6 +464,10 @@ func (db *Database) Recoverable(root common.Hash) bool {
 
 // Close closes the trie database and the held freezer.
 func (db *Database) Close() error {
+	// Terminate the background history indexing first, it acquires the
+	// lock as well.
+	db.stopHistoryIndex()
+
 	db.lock.Lock()
 	defer db.lock.Unlock()
 
@@ -46,6 +46,19 @@ var (
 	// a destination without associated state history available.
 	errStateUnrecoverable = errors.New("state is unrecoverable")
 
+	// errStateHistoryNotIndexed is returned if historical state is requested
+	// without the associated state histories indexed.
+	errStateHistoryNotIndexed = errors.New("state history is not indexed")
+
+	// errStateHistoryPruned is returned if historical state is requested without
+	// the associated state histories available anymore.
+	errStateHistoryPruned = errors.New("state history is pruned")
+
+	// errIncompleteHistory is returned if a historical storage slot is requested
+	// whose changes are not recorded in the state histories due to the large
+	// deletion of the storage.
+	errIncompleteHistory = errors.New("incomplete state history")
+
 	// errUnexpectedNode is returned if the requested node with specified path is
 	// not hash matched with expectation.
 	errUnexpectedNode = errors.New("unexpected node")
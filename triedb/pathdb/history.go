@@ -512,8 +512,9 @@ func readHistory(freezer *rawdb.ResettableFreezer, id uint64) (*history, error)
 	return &dec, nil
 }
 
-// writeHistory persists the state history with the provided state set.
-func writeHistory(freezer *rawdb.ResettableFreezer, dl *diffLayer) error {
+// writeHistory persists the state history with the provided state set. The
+// history is indexed as well if the state histories are indexed.
+func writeHistory(db ethdb.KeyValueStore, freezer *rawdb.ResettableFreezer, dl *diffLayer) error {
 	// Short circuit if state set is not available.
 	if dl.states == nil {
 		return errors.New("state change set is not available")
@@ -529,6 +530,14 @@ func writeHistory(freezer *rawdb.ResettableFreezer, dl *diffLayer) error {
 	// Write history data into five freezer table respectively.
 	rawdb.WriteStateHistory(freezer, dl.stateID(), history.meta.encode(), accountIndex, storageIndex, accountData, storageData)
 
+	if rawdb.ReadStateHistoryIndexTail(db) != nil {
+		batch := db.NewBatch()
+		indexHistory(batch, dl.stateID(), history)
+		if err := batch.Write(); err != nil {
+			return err
+		}
+	}
+
 	historyDataBytesMeter.Mark(int64(dataSize))
 	historyIndexBytesMeter.Mark(int64(indexSize))
 	historyBuildTimeMeter.UpdateSince(start)
@@ -566,7 +575,7 @@ func checkHistories(freezer *rawdb.ResettableFreezer, start, count uint64, check
 
 // truncateFromHead removes the extra state histories from the head with the given
 // parameters. It returns the number of items removed from the head.
-func truncateFromHead(db ethdb.Batcher, freezer *rawdb.ResettableFreezer, nhead uint64) (int, error) {
+func truncateFromHead(db ethdb.KeyValueStore, freezer *rawdb.ResettableFreezer, nhead uint64) (int, error) {
 	ohead, err := freezer.Ancients()
 	if err != nil {
 		return 0, err
@@ -583,6 +592,12 @@ func truncateFromHead(db ethdb.Batcher, freezer *rawdb.ResettableFreezer, nhead
 	if ohead == nhead {
 		return 0, nil
 	}
+	// Drop the index entries of the truncated histories if they're indexed
+	if itail := rawdb.ReadStateHistoryIndexTail(db); itail != nil {
+		if err := unindexHistories(db, freezer, max(nhead, *itail)+1, ohead, min(nhead, *itail)); err != nil {
+			return 0, err
+		}
+	}
 	// Load the meta objects in range [nhead+1, ohead]
 	blobs, err := rawdb.ReadStateHistoryMetaList(freezer, nhead+1, ohead-nhead)
 	if err != nil {
@@ -608,7 +623,7 @@ func truncateFromHead(db ethdb.Batcher, freezer *rawdb.ResettableFreezer, nhead
 
 // truncateFromTail removes the extra state histories from the tail with the given
 // parameters. It returns the number of items removed from the tail.
-func truncateFromTail(db ethdb.Batcher, freezer *rawdb.ResettableFreezer, ntail uint64) (int, error) {
+func truncateFromTail(db ethdb.KeyValueStore, freezer *rawdb.ResettableFreezer, ntail uint64) (int, error) {
 	ohead, err := freezer.Ancients()
 	if err != nil {
 		return 0, err
@@ -625,6 +640,12 @@ func truncateFromTail(db ethdb.Batcher, freezer *rawdb.ResettableFreezer, ntail
 	if otail == ntail {
 		return 0, nil
 	}
+	// Drop the index entries of the truncated histories if they're indexed
+	if itail := rawdb.ReadStateHistoryIndexTail(db); itail != nil {
+		if err := unindexHistories(db, freezer, max(otail, *itail)+1, ntail, max(ntail, *itail)); err != nil {
+			return 0, err
+		}
+	}
 	// Load the meta objects in range [otail+1, ntail]
 	blobs, err := rawdb.ReadStateHistoryMetaList(freezer, otail+1, ntail-otail)
 	if err != nil {
@@ -0,0 +1,210 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>
+
+package pathdb
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// The state histories can be indexed in order to serve historical state. For
+// every account and storage slot modified in a state history, an index entry
+// is stored in the key-value store, keyed by the item and the history id:
+//
+//	account:    prefix + address + id
+//	storage:    prefix + address + slot hash + id
+//	incomplete: prefix + address + id
+//
+// The value of an item in state n is the original value recorded in the first
+// state history after n which modified the item, located by a single iterator
+// seek. If there is none, the item was not modified since then and its value
+// is resolved from the disk layer.
+//
+// The index tail denotes the newest state history which is not indexed, all
+// the histories after it are. Newly written histories are indexed along with
+// their creation, while the existing ones are indexed in the background from
+// the newest to the oldest, moving the tail down.
+
+// indexHistory writes the index entries of the given state history.
+func indexHistory(db ethdb.KeyValueWriter, id uint64, h *history) {
+	for _, addr := range h.accountList {
+		rawdb.WriteStateAccountHistoryIndex(db, addr, id)
+	}
+	for addr, slots := range h.storageList {
+		for _, slot := range slots {
+			rawdb.WriteStateStorageHistoryIndex(db, addr, slot, id)
+		}
+	}
+	for _, addr := range h.meta.incomplete {
+		rawdb.WriteStateIncompleteHistoryIndex(db, addr, id)
+	}
+}
+
+// unindexHistory deletes the index entries of the given state history.
+func unindexHistory(db ethdb.KeyValueWriter, id uint64, h *history) {
+	for _, addr := range h.accountList {
+		rawdb.DeleteStateAccountHistoryIndex(db, addr, id)
+	}
+	for addr, slots := range h.storageList {
+		for _, slot := range slots {
+			rawdb.DeleteStateStorageHistoryIndex(db, addr, slot, id)
+		}
+	}
+	for _, addr := range h.meta.incomplete {
+		rawdb.DeleteStateIncompleteHistoryIndex(db, addr, id)
+	}
+}
+
+// unindexHistories deletes the index entries of the state histories in range
+// [start, end] which are about to be truncated. The index tail is updated to
+// the given one first, so that an interrupted removal leaves no histories
+// regarded as indexed with their entries partially removed.
+func unindexHistories(db ethdb.KeyValueStore, freezer *rawdb.ResettableFreezer, start, end uint64, tail uint64) error {
+	batch := db.NewBatch()
+	rawdb.WriteStateHistoryIndexTail(batch, tail)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	batch.Reset()
+
+	for id := start; id <= end; id++ {
+		h, err := readHistory(freezer, id)
+		if err != nil {
+			return err
+		}
+		unindexHistory(batch, id, h)
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	return batch.Write()
+}
+
+// setupHistoryIndex prepares the index of the state histories and starts
+// indexing the existing ones in the background. If the histories are not
+// indexed yet, the leftovers of a previous index are wiped first.
+func (db *Database) setupHistoryIndex() {
+	if rawdb.ReadStateHistoryIndexTail(db.diskdb) == nil {
+		head, err := db.freezer.Ancients()
+		if err != nil {
+			log.Crit("Failed to retrieve head of state history", "err", err)
+		}
+		if err := rawdb.DeleteStateHistoryIndex(db.diskdb); err != nil {
+			log.Crit("Failed to wipe state history index", "err", err)
+		}
+		rawdb.WriteStateHistoryIndexTail(db.diskdb, head)
+		log.Info("Enabled state history index", "head", head)
+	}
+	db.indexQuit = make(chan struct{})
+	db.indexDone = make(chan struct{})
+	go db.indexHistories()
+}
+
+// stopHistoryIndex terminates the background indexing of state histories, if
+// it's running.
+func (db *Database) stopHistoryIndex() {
+	if db.indexQuit == nil {
+		return
+	}
+	select {
+	case <-db.indexQuit:
+	default:
+		close(db.indexQuit)
+	}
+	<-db.indexDone
+}
+
+// indexHistories indexes the state histories below the index tail, from the
+// newest to the oldest available one. It's meant to be run in a background
+// thread until all histories are indexed or db.indexQuit is closed.
+func (db *Database) indexHistories() {
+	defer close(db.indexDone)
+
+	var (
+		start   = time.Now()
+		logged  = time.Now()
+		indexed uint64
+	)
+	for {
+		select {
+		case <-db.indexQuit:
+			return
+		default:
+		}
+		n, tail, finished, err := db.indexHistoryBatch()
+		if err != nil {
+			log.Error("Failed to index state histories", "err", err)
+			return
+		}
+		indexed += n
+		if finished {
+			if indexed > 0 {
+				log.Info("Indexed state histories", "count", indexed, "elapsed", common.PrettyDuration(time.Since(start)))
+			}
+			return
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Indexing state histories", "count", indexed, "tail", tail, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+}
+
+// indexHistoryBatch indexes a batch of state histories below the index tail
+// and moves the tail down accordingly. It returns the number of indexed
+// histories, the new index tail and whether all histories are indexed.
+func (db *Database) indexHistoryBatch() (uint64, uint64, bool, error) {
+	// Hold the lock to prevent the histories from being written or truncated
+	// concurrently.
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if db.readOnly {
+		return 0, 0, true, nil
+	}
+	tail := rawdb.ReadStateHistoryIndexTail(db.diskdb)
+	if tail == nil {
+		return 0, 0, true, nil
+	}
+	otail, err := db.freezer.Tail()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	var (
+		id    = *tail
+		batch = db.diskdb.NewBatch()
+	)
+	for ; id > otail && batch.ValueSize() < ethdb.IdealBatchSize; id-- {
+		h, err := readHistory(db.freezer, id)
+		if err != nil {
+			return 0, 0, false, err
+		}
+		indexHistory(batch, id, h)
+	}
+	rawdb.WriteStateHistoryIndexTail(batch, id)
+	if err := batch.Write(); err != nil {
+		return 0, 0, false, err
+	}
+	return *tail - id, id, id <= otail, nil
+}
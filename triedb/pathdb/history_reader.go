@@ -0,0 +1,272 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>
+
+package pathdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie/triestate"
+)
+
+// HistoricReader serves the accounts and storage slots of a historical state,
+// which is no longer maintained by the layer tree, from the indexed state
+// histories.
+type HistoricReader struct {
+	db     *Database
+	root   common.Hash          // The root of the requested state
+	id     uint64               // The id of the requested state
+	loader triestate.TrieLoader // Loader of the disk layer tries
+}
+
+// HistoricReader constructs a reader of the historical state with the given
+// root. The state must be below the disk layer and all the state histories
+// after it must be indexed. The provided loader is used to read the disk layer
+// state for the items which were not modified since the requested state.
+func (db *Database) HistoricReader(root common.Hash, loader triestate.TrieLoader) (*HistoricReader, error) {
+	if db.freezer == nil || db.isVerkle {
+		return nil, errors.New("historical state is non-supported")
+	}
+	root = types.TrieRootHash(root)
+	id := rawdb.ReadStateID(db.diskdb, root)
+	if id == nil {
+		return nil, fmt.Errorf("state %#x is not available", root)
+	}
+	if *id > db.tree.bottom().stateID() {
+		return nil, fmt.Errorf("state %#x is not historical", root)
+	}
+	r := &HistoricReader{
+		db:     db,
+		root:   root,
+		id:     *id,
+		loader: loader,
+	}
+	if err := r.check(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// check ensures that the state histories after the requested state are still
+// indexed and available.
+func (r *HistoricReader) check() error {
+	tail := rawdb.ReadStateHistoryIndexTail(r.db.diskdb)
+	if tail == nil {
+		return errStateHistoryNotIndexed
+	}
+	if r.id < *tail {
+		return fmt.Errorf("%w: state %d, index tail %d", errStateHistoryNotIndexed, r.id, *tail)
+	}
+	otail, err := r.db.freezer.Tail()
+	if err != nil {
+		return err
+	}
+	if r.id < otail {
+		return fmt.Errorf("%w: state %d, history tail %d", errStateHistoryPruned, r.id, otail)
+	}
+	return nil
+}
+
+// Account returns the account with the given address in the historical state,
+// or nil if it was not present.
+func (r *HistoricReader) Account(address common.Address) (*types.StateAccount, error) {
+	for {
+		var (
+			blob    []byte
+			err     error
+			dl      = r.db.tree.bottom()
+			id, ok  = rawdb.ReadStateAccountHistoryIndex(r.db.diskdb, address, r.id)
+			current = !ok || id > dl.stateID()
+		)
+		if current {
+			blob, err = r.diskAccount(dl.rootHash(), address)
+		} else {
+			blob, err = readAccountHistory(r.db.freezer, id, address)
+		}
+		// The disk layer might be replaced in the meantime, retry with the new
+		// one if so.
+		if err != nil && current && dl.isStale() {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Ensure the histories were not pruned during the read.
+		if err := r.check(); err != nil {
+			return nil, err
+		}
+		if len(blob) == 0 {
+			return nil, nil
+		}
+		if current {
+			var account types.StateAccount
+			if err := rlp.DecodeBytes(blob, &account); err != nil {
+				return nil, err
+			}
+			return &account, nil
+		}
+		return types.FullAccount(blob)
+	}
+}
+
+// Storage returns the value of the storage slot with the given hash of the
+// account in the historical state, or nil if it was not present. The value
+// is trimmed of its leading zeroes.
+func (r *HistoricReader) Storage(address common.Address, slot common.Hash) ([]byte, error) {
+	for {
+		var (
+			blob    []byte
+			err     error
+			dl      = r.db.tree.bottom()
+			id, ok  = rawdb.ReadStateStorageHistoryIndex(r.db.diskdb, address, slot, r.id)
+			current = !ok || id > dl.stateID()
+		)
+		if current {
+			id = dl.stateID()
+		}
+		// The slot is not resolvable if the storage changes of the account
+		// were not recorded in the meantime due to a large deletion.
+		if inc, ok := rawdb.ReadStateIncompleteHistoryIndex(r.db.diskdb, address, r.id); ok && inc <= id {
+			return nil, fmt.Errorf("%w: account %#x, history %d", errIncompleteHistory, address, inc)
+		}
+		if current {
+			blob, err = r.diskStorage(dl.rootHash(), address, slot)
+		} else {
+			blob, err = readStorageHistory(r.db.freezer, id, address, slot)
+		}
+		// The disk layer might be replaced in the meantime, retry with the new
+		// one if so.
+		if err != nil && current && dl.isStale() {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Ensure the histories were not pruned during the read.
+		if err := r.check(); err != nil {
+			return nil, err
+		}
+		if len(blob) == 0 {
+			return nil, nil
+		}
+		_, content, _, err := rlp.Split(blob)
+		if err != nil {
+			return nil, err
+		}
+		return content, nil
+	}
+}
+
+// diskAccount reads the full-format account from the disk layer state with
+// the given root.
+func (r *HistoricReader) diskAccount(root common.Hash, address common.Address) ([]byte, error) {
+	tr, err := r.loader.OpenTrie(root)
+	if err != nil {
+		return nil, err
+	}
+	return tr.Get(crypto.Keccak256(address.Bytes()))
+}
+
+// diskStorage reads the rlp-encoded storage slot from the disk layer state
+// with the given root.
+func (r *HistoricReader) diskStorage(root common.Hash, address common.Address, slot common.Hash) ([]byte, error) {
+	blob, err := r.diskAccount(root, address)
+	if err != nil || len(blob) == 0 {
+		return nil, err
+	}
+	var account types.StateAccount
+	if err := rlp.DecodeBytes(blob, &account); err != nil {
+		return nil, err
+	}
+	tr, err := r.loader.OpenStorageTrie(root, crypto.Keccak256Hash(address.Bytes()), account.Root)
+	if err != nil {
+		return nil, err
+	}
+	return tr.Get(slot.Bytes())
+}
+
+// findAccount locates the account in the encoded account indexes of a state
+// history with binary search.
+func findAccount(indexes []byte, address common.Address) (accountIndex, bool) {
+	n := len(indexes) / accountIndexSize
+	pos := sort.Search(n, func(i int) bool {
+		return bytes.Compare(indexes[i*accountIndexSize:i*accountIndexSize+common.AddressLength], address.Bytes()) >= 0
+	})
+	if pos == n {
+		return accountIndex{}, false
+	}
+	var index accountIndex
+	index.decode(indexes[pos*accountIndexSize : (pos+1)*accountIndexSize])
+	return index, index.address == address
+}
+
+// readAccountHistory reads the original value of the account recorded in the
+// state history with the given id. It's in the slim format, and empty if the
+// account was not present.
+func readAccountHistory(freezer *rawdb.ResettableFreezer, id uint64, address common.Address) ([]byte, error) {
+	index, ok := findAccount(rawdb.ReadStateAccountIndex(freezer, id), address)
+	if !ok {
+		return nil, fmt.Errorf("account %#x not found in state history %d", address, id)
+	}
+	data := rawdb.ReadStateAccountHistory(freezer, id)
+	if uint32(len(data)) < index.offset+uint32(index.length) {
+		return nil, fmt.Errorf("account data of state history %d is corrupted", id)
+	}
+	return data[index.offset : index.offset+uint32(index.length)], nil
+}
+
+// readStorageHistory reads the original value of the storage slot recorded in
+// the state history with the given id. It's rlp-encoded, and empty if the slot
+// was not present.
+func readStorageHistory(freezer *rawdb.ResettableFreezer, id uint64, address common.Address, slot common.Hash) ([]byte, error) {
+	index, ok := findAccount(rawdb.ReadStateAccountIndex(freezer, id), address)
+	if !ok {
+		return nil, fmt.Errorf("account %#x not found in state history %d", address, id)
+	}
+	var (
+		indexes = rawdb.ReadStateStorageIndex(freezer, id)
+		start   = int(index.storageOffset)
+		end     = int(index.storageOffset + index.storageSlots)
+	)
+	if len(indexes) < end*slotIndexSize {
+		return nil, fmt.Errorf("storage index of state history %d is corrupted", id)
+	}
+	pos := start + sort.Search(end-start, func(i int) bool {
+		offset := (start + i) * slotIndexSize
+		return bytes.Compare(indexes[offset:offset+common.HashLength], slot.Bytes()) >= 0
+	})
+	if pos == end {
+		return nil, fmt.Errorf("slot %#x of account %#x not found in state history %d", slot, address, id)
+	}
+	var sindex slotIndex
+	sindex.decode(indexes[pos*slotIndexSize : (pos+1)*slotIndexSize])
+	if sindex.hash != slot {
+		return nil, fmt.Errorf("slot %#x of account %#x not found in state history %d", slot, address, id)
+	}
+	data := rawdb.ReadStateStorageHistory(freezer, id)
+	if uint32(len(data)) < sindex.offset+uint32(sindex.length) {
+		return nil, fmt.Errorf("storage data of state history %d is corrupted", id)
+	}
+	return data[sindex.offset : sindex.offset+uint32(sindex.length)], nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>
+
+package pathdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie/triestate"
+)
+
+// fullLoader wraps the hash loader, returning the accounts in the full format
+// as the merkle tries do.
+type fullLoader struct {
+	*hashLoader
+}
+
+func (l fullLoader) OpenTrie(root common.Hash) (triestate.Trie, error) {
+	tr, err := l.hashLoader.OpenTrie(root)
+	if err != nil {
+		return nil, err
+	}
+	return fullTrie{tr}, nil
+}
+
+type fullTrie struct {
+	triestate.Trie
+}
+
+func (t fullTrie) Get(key []byte) ([]byte, error) {
+	blob, err := t.Trie.Get(key)
+	if err != nil || len(blob) == 0 {
+		return blob, err
+	}
+	return types.FullAccountRLP(blob)
+}
+
+// verifyHistoricState checks the state with the given root served by the
+// historic reader against the state snapshot, for a sample of the accounts.
+func (t *tester) verifyHistoricState(root common.Hash) error {
+	loader := fullLoader{newHashLoader(t.accounts, t.storages)}
+	reader, err := t.db.HistoricReader(root, loader)
+	if err != nil {
+		return err
+	}
+	var checked int
+	for addrHash, addr := range t.preimages {
+		if checked++; checked > 64 {
+			break
+		}
+		blob := t.snapAccounts[root][addrHash]
+		account, err := reader.Account(addr)
+		if err != nil {
+			return err
+		}
+		if len(blob) == 0 {
+			if account != nil {
+				return fmt.Errorf("unexpected account %#x", addr)
+			}
+			continue
+		}
+		want, _ := types.FullAccount(blob)
+		if account == nil || account.Root != want.Root || account.Nonce != want.Nonce || account.Balance.Cmp(want.Balance) != 0 {
+			return fmt.Errorf("account %#x mismatch: have %v, want %v", addr, account, want)
+		}
+		for slot, enc := range t.snapStorages[root][addrHash] {
+			value, err := reader.Storage(addr, slot)
+			if err != nil {
+				return err
+			}
+			_, content, _, _ := rlp.Split(enc)
+			if !bytes.Equal(value, content) {
+				return fmt.Errorf("slot %#x of account %#x mismatch: have %x, want %x", slot, addr, value, content)
+			}
+		}
+	}
+	return nil
+}
+
+func TestHistoricReader(t *testing.T) {
+	tester := newTester(t, 0)
+	defer tester.release()
+
+	// Histories are not served without the index
+	if _, err := tester.db.HistoricReader(tester.roots[0], nil); !errors.Is(err, errStateHistoryNotIndexed) {
+		t.Fatalf("Unexpected error without index: %v", err)
+	}
+	// Flatten all layers into disk and reopen the database with the index
+	// enabled, indexing the existing histories in the background.
+	if err := tester.db.Commit(tester.lastHash(), false); err != nil {
+		t.Fatalf("Failed to commit layers: %v", err)
+	}
+	tester.db.Close()
+	tester.db = New(tester.db.diskdb, &Config{StateHistoryIndex: true}, false)
+	<-tester.db.indexDone
+
+	if tail := rawdb.ReadStateHistoryIndexTail(tester.db.diskdb); tail == nil || *tail != 0 {
+		t.Fatalf("Unexpected index tail: %v", tail)
+	}
+	// Extend the chain, the new histories are indexed along with their creation
+	for i := 0; i < 16; i++ {
+		parent := tester.lastHash()
+		root, nodes, states := tester.generate(parent)
+		if err := tester.db.Update(root, parent, uint64(len(tester.roots)), nodes, states); err != nil {
+			t.Fatalf("Failed to update state changes: %v", err)
+		}
+		tester.roots = append(tester.roots, root)
+	}
+	if err := tester.db.Commit(tester.lastHash(), false); err != nil {
+		t.Fatalf("Failed to commit layers: %v", err)
+	}
+	for _, i := range []int{0, 99, 255, len(tester.roots) - 2} {
+		if err := tester.verifyHistoricState(tester.roots[i]); err != nil {
+			t.Fatalf("State %d mismatch: %v", i+1, err)
+		}
+	}
+	// Truncate the oldest histories, the states before are not served anymore
+	if _, err := truncateFromTail(tester.db.diskdb, tester.db.freezer, 100); err != nil {
+		t.Fatalf("Failed to truncate histories: %v", err)
+	}
+	if _, err := tester.db.HistoricReader(tester.roots[50], nil); err == nil {
+		t.Fatal("Pruned state is served")
+	}
+	if err := tester.verifyHistoricState(tester.roots[100]); err != nil {
+		t.Fatalf("State 101 mismatch: %v", err)
+	}
+	for addrHash, addr := range tester.preimages {
+		if id, ok := rawdb.ReadStateAccountHistoryIndex(tester.db.diskdb, addr, 0); ok && id <= 100 {
+			t.Fatalf("Index of truncated history %d left for account %#x", id, addrHash)
+		}
+	}
+}